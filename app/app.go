@@ -5,21 +5,41 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"github.com/katoozi/golang-mongodb-rest-api/app/encryption"
+	"github.com/katoozi/golang-mongodb-rest-api/app/gdpr"
 	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/jobs"
+	"github.com/katoozi/golang-mongodb-rest-api/app/migrations"
+	"github.com/katoozi/golang-mongodb-rest-api/app/openapi"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
 	"github.com/katoozi/golang-mongodb-rest-api/config"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/x/bsonx"
 	"golang.org/x/net/context"
 )
 
-// App has the mongo database and router instances
+// App has the mongo database, router and job worker pool instances
 type App struct {
 	Router *mux.Router
-	DB     *mongo.Database
+	DB     *mongo.Database   // use Database method for reading it, it is replaced when credentials are rotated.
+	People store.PeopleStore // people of handlers, grpc and graphql. nil uses the people collection of DB.
+	Cache  *store.Cache      // read cache in front of People, nil if person_cache_size is 0.
+	Jobs   *jobs.Pool
+	Spec   *openapi.Spec // OpenAPI document of routes, route helpers add their operations to it.
+
+	config         *config.Config
+	cipher         *encryption.Cipher            // cipher of field encryption, nil if it is disabled
+	versionRouters []*mux.Router                 // subrouters of handler.Versions
+	specs          map[string]*openapi.Spec      // version -> openapi document
+	validators     map[string]*openapi.Validator // version -> validator of its document
+	ownFormats     map[string]bool               // "method path" of routes that write their own response format
+	dbLock         sync.RWMutex
 }
 
 // ConfigAndRunApp will create and initialize App structure. App factory function.
@@ -31,22 +51,45 @@ func ConfigAndRunApp(config *config.Config) {
 
 // Initialize initialize the app with
 func (app *App) Initialize(config *config.Config) {
-	app.DB = db.InitialConnection("golang", config.MongoURI())
+	app.config = config
+	app.DB = db.InitialConnection(config.MongoDatabase, config.MongoURI())
+	if config.MigrateOnStart {
+		app.migrate()
+	}
 	app.createIndexes()
+	app.setup()
+	app.Jobs = jobs.NewPool(jobs.NewMongo(app.Database), config.JobWorkers)
+	app.registerJobs(app.Jobs)
+}
 
-	app.Router = mux.NewRouter()
-	app.UseMiddleware(handler.JSONContentTypeMiddleware)
-	app.setRouters()
+// New will create an app that keeps people in people, it is used by tests. it does not connect to mongo db
+// or start job workers, so routes that need them like imports and jobs respond with internal errors.
+func New(config *config.Config, people store.PeopleStore) *App {
+	app := &App{config: config, People: people}
+	app.setup()
+	return app
 }
 
-// SetupRouters will register routes in router
-func (app *App) setRouters() {
-	app.Post("/person", app.handleRequest(handler.CreatePerson))
-	app.Patch("/person/{id}", app.handleRequest(handler.UpdatePerson))
-	app.Put("/person/{id}", app.handleRequest(handler.UpdatePerson))
-	app.Get("/person/{id}", app.handleRequest(handler.GetPerson))
-	app.Get("/person", app.handleRequest(handler.GetPersons))
-	app.Get("/person", app.handleRequest(handler.GetPersons), "page", "{page}")
+// setup will create the router with its middlewares and routes and the openapi documents of versions.
+func (app *App) setup() {
+	cipher, err := Cipher(app.config)
+	if err != nil {
+		log.Fatalf("Error while loading field encryption keys: %v", err)
+	}
+	app.cipher = cipher
+	if app.config != nil && app.config.PersonCacheSize > 0 {
+		ttl := time.Duration(app.config.PersonCacheTTLSeconds) * time.Second
+		app.Cache = store.NewCache(app.peopleStore, app.config.PersonCacheSize, ttl)
+	}
+	app.setVersionDates()
+	app.Router = mux.NewRouter()
+	app.Spec = newSpec()
+	app.UseMiddleware(handler.DefaultCompression().Middleware)
+	app.UseMiddleware(handler.Negotiation{OwnFormat: app.ownFormat}.Middleware)
+	app.UseMiddleware(app.tenantMiddleware)
+	app.UseMiddleware(app.validationMiddleware)
+	app.setRouters()
+	app.setSpecs()
 }
 
 // UseMiddleware will add global middleware in router
@@ -54,40 +97,76 @@ func (app *App) UseMiddleware(middleware mux.MiddlewareFunc) {
 	app.Router.Use(middleware)
 }
 
-// createIndexes will create unique and index fields.
+// ownFormat will return true if the route of req writes its own format, its operation has a raw success response.
+func (app *App) ownFormat(req *http.Request) bool {
+	route := mux.CurrentRoute(req)
+	if route == nil {
+		return false
+	}
+	template, _ := route.GetPathTemplate()
+	path, _, _ := routeVersion(req, template)
+	return app.ownFormats[req.Method+" "+path]
+}
+
+// migrate will run the pending migrations. other servers wait until the migrations are done.
+func (app *App) migrate() {
+	done, err := migrations.New(app.DB).Up(context.Background())
+	for _, migration := range done {
+		log.Printf("Migration %d (%s) is applied.\n", migration.Version, migration.Description)
+	}
+	if err != nil {
+		log.Fatalf("Error while running migrations: %v", err)
+	}
+}
+
+// createIndexes will create the missing indexes of IndexSpecs and drop the retired ones. drifted and
+// unknown indexes are only reported, use indexes apply -drop command to change them. the server is
+// stopped if a unique index can not be created, other errors are logged. in database mode of tenancy
+// the databases of tenants get their indexes when they are used first.
 func (app *App) createIndexes() {
-	// username and email will be unique.
-	keys := bsonx.Doc{
-		{Key: "username", Value: bsonx.Int32(1)},
-		{Key: "email", Value: bsonx.Int32(1)},
+	ctx := context.Background()
+	plan, err := db.PlanIndexes(ctx, app.DB, IndexSpecs(app.config))
+	if err != nil {
+		log.Printf("Error while planning indexes: %v\n", err)
+		return
+	}
+	for _, change := range plan.Changes() {
+		if change.Action != db.ActionCreate && !change.Retired() {
+			log.Printf("Index %s.%s needs %s: %v\n", change.Collection, change.Name, change.Action, change.Reasons)
+		}
+	}
+	err = plan.Apply(ctx, app.DB, false)
+	if errs, ok := err.(db.IndexErrors); ok && len(errs.Unique()) > 0 {
+		log.Fatalf("Error while creating unique indexes, their fields are not unique: %v", errs.Unique())
+	}
+	if err != nil {
+		log.Printf("Error while creating indexes: %v\n", err)
 	}
-	people := app.DB.Collection("people")
-	db.SetIndexes(people, keys)
 }
 
-// Get will register Get method for an endpoint
-func (app *App) Get(path string, endpoint http.HandlerFunc, queries ...string) {
-	app.Router.HandleFunc(path, endpoint).Methods("GET").Queries(queries...)
+// Get will register Get method for an endpoint in all api versions and add its operation to openapi spec
+func (app *App) Get(path string, endpoint http.HandlerFunc, operation openapi.Operation, queries ...string) {
+	app.handle("GET", path, endpoint, operation, queries)
 }
 
-// Post will register Post method for an endpoint
-func (app *App) Post(path string, endpoint http.HandlerFunc, queries ...string) {
-	app.Router.HandleFunc(path, endpoint).Methods("POST").Queries(queries...)
+// Post will register Post method for an endpoint in all api versions and add its operation to openapi spec
+func (app *App) Post(path string, endpoint http.HandlerFunc, operation openapi.Operation, queries ...string) {
+	app.handle("POST", path, endpoint, operation, queries)
 }
 
-// Put will register Put method for an endpoint
-func (app *App) Put(path string, endpoint http.HandlerFunc, queries ...string) {
-	app.Router.HandleFunc(path, endpoint).Methods("PUT").Queries(queries...)
+// Put will register Put method for an endpoint in all api versions and add its operation to openapi spec
+func (app *App) Put(path string, endpoint http.HandlerFunc, operation openapi.Operation, queries ...string) {
+	app.handle("PUT", path, endpoint, operation, queries)
 }
 
-// Patch will register Patch method for an endpoint
-func (app *App) Patch(path string, endpoint http.HandlerFunc, queries ...string) {
-	app.Router.HandleFunc(path, endpoint).Methods("PATCH").Queries(queries...)
+// Patch will register Patch method for an endpoint in all api versions and add its operation to openapi spec
+func (app *App) Patch(path string, endpoint http.HandlerFunc, operation openapi.Operation, queries ...string) {
+	app.handle("PATCH", path, endpoint, operation, queries)
 }
 
-// Delete will register Delete method for an endpoint
-func (app *App) Delete(path string, endpoint http.HandlerFunc, queries ...string) {
-	app.Router.HandleFunc(path, endpoint).Methods("DELETE").Queries(queries...)
+// Delete will register Delete method for an endpoint in all api versions and add its operation to openapi spec
+func (app *App) Delete(path string, endpoint http.HandlerFunc, operation openapi.Operation, queries ...string) {
+	app.handle("DELETE", path, endpoint, operation, queries)
 }
 
 // Run will start the http server on host that you pass in. host:<ip:port>
@@ -99,19 +178,113 @@ func (app *App) Run(host string) {
 		log.Fatal(http.ListenAndServe(host, app.Router))
 	}()
 	log.Printf("Server is listning on http://%s\n", host)
+	grpcServer, healthServer := app.serveGRPC()
+	app.Jobs.Start()
+	ctx, cancel := context.WithCancel(context.Background())
+	go app.config.WatchSecretFiles(ctx, secretFilesWatchInterval, app.rotateCredentials)
+	if app.Cache != nil {
+		go func() {
+			// the cache of all tenants is invalidated by one change stream.
+			if err := app.Cache.Listen(tenant.NewContext(ctx, tenant.Tenant{Mode: app.tenancy()})); err != nil {
+				log.Printf("Person cache is not invalidated by the writes of other servers, people expire after ttl: %v\n", err)
+			}
+		}()
+	}
 	sig := <-sigs
 	log.Println("Signal: ", sig)
+	cancel()
+
+	if grpcServer != nil {
+		log.Println("Stoping gRPC Server...")
+		healthServer.Shutdown()
+		grpcServer.GracefulStop()
+	}
+
+	log.Println("Stoping Job Workers...")
+	app.Jobs.Stop()
 
 	log.Println("Stoping MongoDB Connection...")
-	app.DB.Client().Disconnect(context.Background())
+	app.Database().Client().Disconnect(context.Background())
 }
 
 // RequestHandlerFunction is a custome type that help us to pass db arg to all endpoints
 type RequestHandlerFunction func(db *mongo.Database, w http.ResponseWriter, r *http.Request)
 
-// handleRequest is a middleware we create for pass in db connection to endpoints.
-func (app *App) handleRequest(handler RequestHandlerFunction) http.HandlerFunc {
+// handleRequest is a middleware we create for pass in db connection to endpoints. it is the database
+// of request tenant in database mode of tenancy.
+func (app *App) handleRequest(fn RequestHandlerFunction) http.HandlerFunc {
+	return app.handleBaseRequest(func(database *mongo.Database, w http.ResponseWriter, r *http.Request) {
+		fn(tenant.Database(r.Context(), database), w, r)
+	})
+}
+
+// handleBaseRequest is the middleware of endpoints that use the base database in every mode of tenancy,
+// like jobs that all tenants keep in it. they must scope their queries by the tenant of request.
+func (app *App) handleBaseRequest(fn RequestHandlerFunction) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		handler(app.DB, w, r)
+		database := app.Database()
+		if database == nil {
+			handler.ErrorResponse(w, r, handler.CodeInternal, "mongo db is not configured.", nil)
+			return
+		}
+		if app.cipher != nil {
+			r = r.WithContext(encryption.NewContext(r.Context(), app.cipher))
+		}
+		if app.config.ErasureLogKey != "" {
+			r = r.WithContext(gdpr.NewContext(r.Context(), []byte(app.config.ErasureLogKey)))
+		}
+		fn(database, w, r)
+	}
+}
+
+// PeopleHandlerFunction is an endpoint that reads and writes people with the people store of app.
+type PeopleHandlerFunction func(people store.PeopleStore, w http.ResponseWriter, r *http.Request)
+
+// handlePeople is a middleware that passes the people store to endpoints.
+func (app *App) handlePeople(fn PeopleHandlerFunction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fn(app.people(), w, r)
+	}
+}
+
+// PeopleRequestHandlerFunction is an endpoint that needs the people store and the database of request.
+type PeopleRequestHandlerFunction func(people store.PeopleStore, db *mongo.Database, w http.ResponseWriter, r *http.Request)
+
+// handlePeopleRequest is a middleware that passes the people store and the database of request to endpoints.
+func (app *App) handlePeopleRequest(fn PeopleRequestHandlerFunction) http.HandlerFunc {
+	return app.handleRequest(func(db *mongo.Database, w http.ResponseWriter, r *http.Request) {
+		fn(app.people(), db, w, r)
+	})
+}
+
+// JobsRequestHandlerFunction is an endpoint that queues jobs.
+type JobsRequestHandlerFunction func(pool *jobs.Pool, db *mongo.Database, w http.ResponseWriter, r *http.Request)
+
+// handleJobsRequest is a middleware that passes the job pool and the database of request to endpoints.
+func (app *App) handleJobsRequest(fn JobsRequestHandlerFunction) http.HandlerFunc {
+	return app.handleRequest(func(db *mongo.Database, w http.ResponseWriter, r *http.Request) {
+		fn(app.Jobs, db, w, r)
+	})
+}
+
+// people will return the store of handlers, grpc and graphql, it validates their writes and reads the
+// cache if it is enabled.
+func (app *App) people() store.PeopleStore {
+	if app.Cache != nil {
+		return store.NewValidated(app.Cache)
+	}
+	return store.NewValidated(app.peopleStore())
+}
+
+// peopleStore will return the People store of app or the people collection of current database, their
+// fields are encrypted if field encryption is enabled.
+func (app *App) peopleStore() store.PeopleStore {
+	var people store.PeopleStore = app.People
+	if people == nil {
+		people = store.NewPeople(app.Database())
+	}
+	if app.cipher != nil {
+		return store.NewEncrypted(people, app.cipher)
 	}
+	return people
 }
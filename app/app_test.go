@@ -0,0 +1,154 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/openapi"
+	"github.com/katoozi/golang-mongodb-rest-api/config"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+func TestRoutesAreDocumented(t *testing.T) {
+	app := &App{Router: mux.NewRouter(), Spec: newSpec()}
+	app.setRouters()
+	document := app.Spec.Document()
+	ids := make(map[string]string)
+	err := app.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil || route.GetHandler() == nil {
+			return nil // version subrouters
+		}
+		path, _, _ := routeVersion(httptest.NewRequest("GET", "/", nil), template)
+		methods, err := route.GetMethods()
+		if err != nil {
+			t.Errorf("%s route %s has no methods", failed, path)
+			return nil
+		}
+		for _, method := range methods {
+			operation, ok := document.Paths[openapi.Path(path)][strings.ToLower(method)]
+			if !ok {
+				t.Errorf("%s %s %s is not documented", failed, method, path)
+				continue
+			}
+			if operation.Summary == "" || len(operation.Responses) == 0 {
+				t.Errorf("%s %s %s has no summary or responses", failed, method, path)
+			}
+			if other, ok := ids[operation.OperationID]; ok && other != method+" "+path {
+				t.Errorf("%s operation id %s is used by %s and %s %s", failed, operation.OperationID, other, method, path)
+			}
+			ids[operation.OperationID] = method + " " + path
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := json.Marshal(document); err != nil {
+		t.Fatalf("%s document could not be encoded: %v", failed, err)
+	}
+	t.Logf("%s Testing all routes are documented is successful", succeed)
+}
+
+func TestValidationMiddleware(t *testing.T) {
+	app := &App{Router: mux.NewRouter(), Spec: newSpec()}
+	app.UseMiddleware(handler.NegotiationMiddleware)
+	app.UseMiddleware(app.validationMiddleware)
+	app.setRouters()
+	app.setSpecs()
+
+	tests := []struct {
+		method, target, body string
+		fields               []string
+	}{
+		{"GET", "/person/123", "", []string{"path.id"}},
+		{"GET", "/person?page=first", "", []string{"query.page"}},
+		{"GET", "/v2/person/123", "", []string{"path.id"}},
+		{"DELETE", "/jobs/xyz", "", []string{"path.id"}},
+		{"POST", "/person", `{"username": "john", "email": "john"}`, []string{"body.email"}},
+		{"POST", "/person", `{"first_name": "john"}`, []string{"body.email", "body.username"}},
+		{"POST", "/person", "", []string{"body"}},
+		{"PATCH", "/person/5e8f3b2a9d1c4a0b3c2d1e0f", `{"email": "john"}`, []string{"body.email"}},
+		{"PUT", "/person/5e8f3b2a9d1c4a0b3c2d1e0f", `{"username": "", "data": []}`, []string{"body.data", "body.username"}},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/problem+json")
+		rr := httptest.NewRecorder()
+		app.Router.ServeHTTP(rr, req)
+		var problem handler.Problem
+		json.NewDecoder(rr.Body).Decode(&problem)
+		var fields []string
+		for _, err := range problem.Errors {
+			fields = append(fields, err.Field)
+		}
+		if rr.Code != http.StatusBadRequest || problem.Code != handler.CodeInvalidRequest.Code || !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("%s %s %s validation is wrong: %d %+v", failed, test.method, test.target, rr.Code, problem)
+		}
+	}
+	t.Logf("%s Testing request validation is successful", succeed)
+}
+
+func TestVersions(t *testing.T) {
+	app := &App{Router: mux.NewRouter(), Spec: newSpec(), config: &config.Config{V1Deprecation: "2026-11-01", V1Sunset: "2027-05-01"}}
+	app.setVersionDates()
+	defer func() { handler.V1.Deprecation, handler.V1.Sunset = time.Time{}, time.Time{} }()
+	app.UseMiddleware(handler.NegotiationMiddleware)
+	app.setRouters()
+	app.setSpecs()
+
+	tests := []struct {
+		target, accept string
+		status         int
+		contentType    string
+		keys           []string
+		deprecated     bool
+	}{
+		{"/problems/invalid_body", "", http.StatusOK, "application/json; charset=UTF-8", []string{"content", "message", "status"}, false},
+		{"/problems/invalid_body", "application/vnd.person.v1+json", http.StatusOK, "application/vnd.person.v1+json; charset=UTF-8", []string{"content", "message", "status"}, true},
+		{"/v1/problems/invalid_body", "", http.StatusOK, "application/json; charset=UTF-8", []string{"content", "message", "status"}, true},
+		{"/v2/problems/invalid_body", "", http.StatusOK, "application/json; charset=UTF-8", []string{"content"}, false},
+		{"/problems/invalid_body", "application/vnd.person.v2+json", http.StatusOK, "application/vnd.person.v2+json; charset=UTF-8", []string{"content"}, false},
+		{"/v1/problems/invalid_body", "application/vnd.person.v2+json", http.StatusOK, "application/json; charset=UTF-8", []string{"content", "message", "status"}, true},
+		{"/problems/invalid_body", "application/vnd.person.v9+json", http.StatusNotAcceptable, "application/json; charset=UTF-8", []string{"content", "message", "status"}, false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.target, nil)
+		req.Header.Set("Accept", test.accept)
+		rr := httptest.NewRecorder()
+		app.Router.ServeHTTP(rr, req)
+		var body map[string]interface{}
+		json.NewDecoder(rr.Body).Decode(&body)
+		var keys []string
+		for key := range body {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		deprecated := rr.Header().Get("Deprecation") != "" && rr.Header().Get("Sunset") != ""
+		if rr.Code != test.status || rr.Header().Get("Content-Type") != test.contentType || !reflect.DeepEqual(keys, test.keys) || deprecated != test.deprecated {
+			t.Errorf("%s GET %s with %q is wrong: %d %s %v %v", failed, test.target, test.accept, rr.Code, rr.Header().Get("Content-Type"), keys, rr.Header())
+		}
+	}
+
+	for name, spec := range app.specs {
+		var document openapi.Document
+		json.Unmarshal(spec.JSON(), &document)
+		envelope := document.Paths["/person/{id}"]["get"].Responses["200"].Content["application/json"].Schema
+		_, hasStatus := envelope.Properties["status"]
+		version, _ := handler.Versions.Lookup(name)
+		if hasStatus != (version == handler.V1) || document.Paths["/person/{id}"]["get"].Deprecated != version.Deprecated() {
+			t.Errorf("%s document of %s is wrong: %+v", failed, name, envelope)
+		}
+	}
+	t.Logf("%s Testing api versions is successful", succeed)
+}
@@ -0,0 +1,137 @@
+// Package apptest runs a fully wired App on an httptest.Server with people in memory, so integration tests
+// of services that embed the api need no mongo db. requests go through the same router, middlewares and
+// request validation as the real server, only routes that need mongo db like imports and jobs fail.
+//
+//	server := apptest.New(t, apptest.WithFixtures("testdata/people.yaml"))
+//	person := server.Post("/person", model.NewPerson("john", "doe", "john_doe", "john@example.com", nil)).
+//		Status(http.StatusCreated).Person()
+//	server.Get("/person/" + person.ID.Hex()).Status(http.StatusOK)
+package apptest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/katoozi/golang-mongodb-rest-api/app"
+	"github.com/katoozi/golang-mongodb-rest-api/app/codec"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"github.com/katoozi/golang-mongodb-rest-api/config"
+)
+
+// Server is an App that is served on an httptest.Server. requests of its Requester have no credentials,
+// use WithBearer or WithHeader for authenticated requests.
+type Server struct {
+	*httptest.Server
+	Requester
+	App    *app.App
+	People *store.Memory
+
+	t testing.TB
+}
+
+// options of New
+type options struct {
+	config      *config.Config
+	middlewares []mux.MiddlewareFunc
+	fixtures    []string
+	people      []*model.Person
+}
+
+// Option changes the Server of New.
+type Option func(*options)
+
+// WithConfig will create the app with configuration, the default config only has the development option
+// so contract violations of responses are logged.
+func WithConfig(configuration *config.Config) Option {
+	return func(o *options) {
+		o.config = configuration
+	}
+}
+
+// WithMiddleware will add middlewares after the middlewares of app, like the authentication of a service
+// that embeds the api.
+func WithMiddleware(middlewares ...mux.MiddlewareFunc) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// WithFixtures will load the people of fixture files before the server starts, see LoadFixtures.
+func WithFixtures(paths ...string) Option {
+	return func(o *options) {
+		o.fixtures = append(o.fixtures, paths...)
+	}
+}
+
+// WithPeople will add people before the server starts, their ids are set.
+func WithPeople(people ...*model.Person) Option {
+	return func(o *options) {
+		o.people = append(o.people, people...)
+	}
+}
+
+// New will start a Server that is closed when the test ends.
+func New(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	o := &options{config: &config.Config{Development: true}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	people, _ := store.NewMemory()
+	server := &Server{App: app.New(o.config, people), People: people, t: t}
+	for _, middleware := range o.middlewares {
+		server.App.UseMiddleware(middleware)
+	}
+	for _, path := range o.fixtures {
+		server.LoadFixtures(path)
+	}
+	server.AddPeople(o.people...)
+	server.Server = httptest.NewServer(server.App.Router)
+	server.Requester = Requester{server: server, header: make(http.Header)}
+	t.Cleanup(server.Close)
+	return server
+}
+
+// AddPeople will insert people like POST /person and set their ids, the test fails if one is not inserted.
+func (server *Server) AddPeople(people ...*model.Person) []*model.Person {
+	server.t.Helper()
+	for _, person := range people {
+		if err := server.People.Create(context.Background(), person); err != nil {
+			server.t.Fatalf("Error while adding person %q: %v", person.Username, err)
+		}
+	}
+	return people
+}
+
+// LoadFixtures will insert the people of a json or yaml file, it is a list of people in their json form.
+// the inserted people are returned with their ids.
+func (server *Server) LoadFixtures(path string) []*model.Person {
+	server.t.Helper()
+	mediaType := "application/json"
+	if extension := strings.ToLower(filepath.Ext(path)); extension == ".yaml" || extension == ".yml" {
+		mediaType = "application/yaml"
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		server.t.Fatalf("Error while opening fixtures: %v", err)
+	}
+	defer file.Close()
+	var people []*model.Person
+	fixtureCodec, _ := codec.Lookup(mediaType)
+	if err := fixtureCodec.Decode(file, &people); err != nil {
+		server.t.Fatalf("Error while decoding fixtures of %s: %v", path, err)
+	}
+	return server.AddPeople(people...)
+}
+
+// Reset will delete all people.
+func (server *Server) Reset() {
+	server.People.DeleteAll(context.Background())
+}
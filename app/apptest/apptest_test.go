@@ -0,0 +1,185 @@
+package apptest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"github.com/katoozi/golang-mongodb-rest-api/config"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+func TestPeopleRoutes(t *testing.T) {
+	server := New(t)
+	person := server.Post("/person", model.NewPerson("john", "doe", " john_doe ", "john@example.com", nil)).
+		Status(http.StatusCreated).Person()
+	if person.ID.IsZero() || person.Username != "john_doe" {
+		t.Fatalf("%s created person must have an id and a trimmed username: %+v", failed, person)
+	}
+	got := server.Get("/person/" + person.ID.Hex()).Status(http.StatusOK).Person()
+	if got.ID != person.ID || got.Email != "john@example.com" {
+		t.Fatalf("%s got person is wrong: %+v", failed, got)
+	}
+
+	conflict := server.Post("/person", model.NewPerson("", "", "JOHN_DOE", "other@example.com", nil)).Error(handler.CodeDuplicateField)
+	var content map[string]interface{}
+	if conflict.Content(&content); content["field"] != "username" {
+		t.Fatalf("%s conflict must be on username: %s", failed, conflict.Raw)
+	}
+
+	server.Patch("/person/"+person.ID.Hex(), map[string]interface{}{"data": map[string]interface{}{"age": 30}}).Status(http.StatusAccepted)
+	server.Patch("/person/"+person.ID.Hex(), map[string]interface{}{"email": "garbage"}).Error(handler.CodeInvalidRequest)
+	server.Do(http.MethodPut, "/person/"+person.ID.Hex(), map[string]interface{}{"username": ""}).Error(handler.CodeInvalidRequest)
+	server.Patch("/person/"+person.ID.Hex(), map[string]interface{}{"_id": person.ID.Hex()}).Error(handler.CodeInvalidBody)
+	if got := server.Get("/person/" + person.ID.Hex()).Person(); got.Data["age"] != 30.0 {
+		t.Fatalf("%s person must be updated: %+v", failed, got)
+	}
+	server.Delete("/person/" + person.ID.Hex()).Status(http.StatusOK).Message("person is deleted.")
+	server.WithHeader("Accept", "application/problem+json").Get("/person/" + person.ID.Hex()).Error(handler.CodePersonNotFound)
+	server.Get("/person/wrong").Error(handler.CodeInvalidID)
+
+	html := server.WithHeader("Accept", "text/html")
+	html.Post("/person", model.NewPerson("jane", "doe", "jane_doe", "jane@example.com", nil)).Error(handler.CodeNotAcceptable)
+	if people, _ := server.People.Find(context.Background(), store.Filter{Username: "jane_doe"}, 0, 1); len(people) != 0 {
+		t.Fatalf("%s person must not be created by a request that is not acceptable: %+v", failed, people)
+	}
+	html.Get("/docs").Status(http.StatusOK)
+	t.Logf("%s Testing people routes on memory store is successful", succeed)
+}
+
+func TestFixturesAndVersions(t *testing.T) {
+	server := New(t, WithFixtures("testdata/people.yaml"), WithPeople(model.NewPerson("", "", "extra", "extra@example.net", nil)))
+	people := server.Get("/person").Status(http.StatusOK).People()
+	if len(people) != 3 || people[0].Username != "extra" || people[2].Username != "jane_doe" {
+		t.Fatalf("%s people must be the fixtures newest first: %+v", failed, people)
+	}
+	if envelope := server.Get("/v2/person").Status(http.StatusOK).Envelope(); envelope.Status != http.StatusOK {
+		t.Fatalf("%s status of v2 envelope must be taken from status line: %+v", failed, envelope)
+	}
+	var result struct {
+		Data struct {
+			People struct {
+				Items []struct{ Username string }
+			}
+		}
+	}
+	server.Post("/graphql", map[string]interface{}{"query": `{ people(filter: {username: "KATOOZI"}) { items { username } } }`}).
+		Status(http.StatusOK).Decode(&result)
+	if items := result.Data.People.Items; len(items) != 1 || items[0].Username != "katoozi" {
+		t.Fatalf("%s graphql must read the memory store: %+v", failed, result)
+	}
+	server.Reset()
+	if people := server.Get("/person").People(); len(people) != 0 {
+		t.Fatalf("%s Reset must delete all people: %+v", failed, people)
+	}
+	t.Logf("%s Testing fixtures and versions is successful", succeed)
+}
+
+func TestAuthenticatedRequests(t *testing.T) {
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "Bearer secret" {
+				handler.ErrorResponse(res, req, handler.CodeInvalidRequest, "token is wrong.", nil)
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+	server := New(t, WithMiddleware(mux.MiddlewareFunc(authenticate)))
+	server.Get("/person").Status(http.StatusBadRequest).Message("token is wrong.")
+	authenticated := server.WithBearer("secret")
+	authenticated.Get("/person").Status(http.StatusOK)
+	if header := server.header.Get("Authorization"); header != "" {
+		t.Fatalf("%s WithBearer must not change the requester of server: %s", failed, header)
+	}
+	if basic := server.WithBasicAuth("john", "pass").header.Get("Authorization"); !strings.HasPrefix(basic, "Basic ") {
+		t.Fatalf("%s WithBasicAuth must set basic authorization: %s", failed, basic)
+	}
+	t.Logf("%s Testing authenticated requests is successful", succeed)
+}
+
+func TestMongoRoutesFail(t *testing.T) {
+	server := New(t)
+	problems := server.WithHeader("Accept", "application/problem+json")
+	problems.Get("/jobs/5f0c9a3e8b3c2a0001a1b2c3").Error(handler.CodeInternal)
+	problems.Post("/person/5f0c9a3e8b3c2a0001a1b2c3/erase", nil).Error(handler.CodeInternal)
+	problems.Get("/person/5f0c9a3e8b3c2a0001a1b2c3/gdpr-export").Error(handler.CodeInternal)
+	t.Logf("%s Testing routes that need mongo db is successful", succeed)
+}
+
+func TestPersonCacheMetrics(t *testing.T) {
+	server := New(t, WithConfig(&config.Config{PersonCacheSize: 10}))
+	person := server.AddPeople(model.NewPerson("john", "doe", "john_doe", "john@example.com", nil))[0]
+	server.Get("/person/" + person.ID.Hex()).Status(http.StatusOK)
+	server.Get("/person/" + person.ID.Hex()).Status(http.StatusOK)
+	server.Patch("/person/"+person.ID.Hex(), map[string]interface{}{"first_name": "johnny"}).Status(http.StatusAccepted)
+	if got := server.Get("/person/" + person.ID.Hex()).Person(); got.FirstName != "johnny" {
+		t.Fatalf("%s update must invalidate cached person: %+v", failed, got)
+	}
+	metrics := string(server.Get("/metrics").Status(http.StatusOK).Raw)
+	for _, line := range []string{"person_cache_enabled 1", "person_cache_hits_total 1", "person_cache_misses_total 2", "person_cache_invalidations_total 1"} {
+		if !strings.Contains(metrics, "\n"+line+"\n") {
+			t.Fatalf("%s metrics must have %q: %s", failed, line, metrics)
+		}
+	}
+	t.Logf("%s Testing person cache metrics is successful", succeed)
+}
+
+func TestTenancy(t *testing.T) {
+	server := New(t, WithConfig(&config.Config{Development: true, Tenancy: "shared", TenantSource: "header", TenantHeader: "X-Tenant-ID"}))
+	acme, globex := server.WithHeader("X-Tenant-ID", "acme"), server.WithHeader("X-Tenant-ID", "globex")
+	person := acme.Post("/person", model.NewPerson("", "", "john", "john@example.com", nil)).Status(http.StatusCreated).Person()
+	globex.Post("/person", model.NewPerson("", "", "John", "john@example.com", nil)).Status(http.StatusCreated)
+
+	acme.Get("/person/" + person.ID.Hex()).Status(http.StatusOK)
+	globex.Get("/person/" + person.ID.Hex()).Error(handler.CodePersonNotFound)
+	globex.Delete("/person/" + person.ID.Hex()).Error(handler.CodePersonNotFound)
+	if people := globex.Get("/person").Status(http.StatusOK).People(); len(people) != 1 || people[0].ID == person.ID {
+		t.Fatalf("%s tenants must only list their people: %+v", failed, people)
+	}
+
+	problems := server.WithHeader("Accept", "application/problem+json")
+	problems.Get("/person").Error(handler.CodeInvalidTenant)
+	problems.WithHeader("X-Tenant-ID", "../acme").Get("/person").Error(handler.CodeInvalidTenant)
+	server.Get("/openapi.json").Status(http.StatusOK)
+	t.Logf("%s Testing tenant isolation of routes is successful", succeed)
+}
+
+func TestFieldEncryption(t *testing.T) {
+	server := New(t, WithConfig(&config.Config{
+		Development:           true,
+		EncryptionKeyringFile: "testdata/keyring.yaml",
+		EncryptedFields:       "username:deterministic,email:deterministic,first_name,last_name,data.ssn",
+	}))
+	person := server.Post("/person", model.NewPerson("John", "Doe", "John_Doe", "john@example.com", map[string]interface{}{"ssn": "078-05-1120"})).
+		Status(http.StatusCreated).Person()
+	raw, err := server.People.Get(context.Background(), person.ID)
+	if err != nil || !strings.HasPrefix(raw.Username, "enc:2024-06:") || !strings.HasPrefix(raw.FirstName, "enc:") || raw.Data["ssn"] == "078-05-1120" {
+		t.Fatalf("%s people must be saved encrypted: %+v %v", failed, raw, err)
+	}
+	if got := server.Get("/person/" + person.ID.Hex()).Status(http.StatusOK).Person(); got.Username != "John_Doe" || got.LastName != "Doe" || got.Data["ssn"] != "078-05-1120" {
+		t.Fatalf("%s person must be decrypted: %+v", failed, got)
+	}
+	server.Post("/person", model.NewPerson("", "", "JOHN_DOE", "other@example.com", nil)).Error(handler.CodeDuplicateField)
+
+	var result struct {
+		Data struct {
+			People struct {
+				Items []struct{ Email string }
+			}
+		}
+	}
+	server.Post("/graphql", map[string]interface{}{"query": `{ people(filter: {username: "John_Doe"}) { items { email } } }`}).
+		Status(http.StatusOK).Decode(&result)
+	if items := result.Data.People.Items; len(items) != 1 || items[0].Email != "john@example.com" {
+		t.Fatalf("%s people must be found by encrypted username: %+v", failed, result)
+	}
+	t.Logf("%s Testing field encryption of routes is successful", succeed)
+}
@@ -0,0 +1,110 @@
+package apptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Requester sends requests to a Server, its headers are added to every request. the With methods
+// return a copy, so a Requester can be shared by tests.
+type Requester struct {
+	server *Server
+	header http.Header
+}
+
+// WithHeader will return a Requester that sets header key to value.
+func (r Requester) WithHeader(key, value string) Requester {
+	header := r.header.Clone()
+	header.Set(key, value)
+	return Requester{server: r.server, header: header}
+}
+
+// WithBearer will return a Requester that authenticates with a bearer token.
+func (r Requester) WithBearer(token string) Requester {
+	return r.WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithBasicAuth will return a Requester that authenticates with username and password.
+func (r Requester) WithBasicAuth(username, password string) Requester {
+	req := http.Request{Header: make(http.Header)}
+	req.SetBasicAuth(username, password)
+	return r.WithHeader("Authorization", req.Header.Get("Authorization"))
+}
+
+// Get will send a get request to path of server.
+func (r Requester) Get(path string) *Response {
+	r.server.t.Helper()
+	return r.Do(http.MethodGet, path, nil)
+}
+
+// Post will send body to path of server.
+func (r Requester) Post(path string, body interface{}) *Response {
+	r.server.t.Helper()
+	return r.Do(http.MethodPost, path, body)
+}
+
+// Put will send body to path of server.
+func (r Requester) Put(path string, body interface{}) *Response {
+	r.server.t.Helper()
+	return r.Do(http.MethodPut, path, body)
+}
+
+// Patch will send body to path of server.
+func (r Requester) Patch(path string, body interface{}) *Response {
+	r.server.t.Helper()
+	return r.Do(http.MethodPatch, path, body)
+}
+
+// Delete will send a delete request to path of server.
+func (r Requester) Delete(path string) *Response {
+	r.server.t.Helper()
+	return r.Do(http.MethodDelete, path, nil)
+}
+
+// Do will send a request to path of server. body can be nil, an io.Reader, []byte or string that is sent
+// as it is, or a value that is encoded as json. Accept is application/json if it is not set.
+func (r Requester) Do(method, path string, body interface{}) *Response {
+	t := r.server.t
+	t.Helper()
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = body
+	case []byte:
+		reader = bytes.NewReader(body)
+	case string:
+		reader = strings.NewReader(body)
+	default:
+		content, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Error while encoding body of %s %s: %v", method, path, err)
+		}
+		reader = bytes.NewReader(content)
+	}
+	req, err := http.NewRequest(method, r.server.URL+path, reader)
+	if err != nil {
+		t.Fatalf("Error while creating request %s %s: %v", method, path, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	res, err := r.server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Error while sending request %s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("Error while reading response of %s %s: %v", method, path, err)
+	}
+	return &Response{Response: res, Raw: content, t: t}
+}
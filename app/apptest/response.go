@@ -0,0 +1,120 @@
+package apptest
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"testing"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+)
+
+// Response is a response of Server, its body is read so it can be asserted many times. assertions fail
+// the test with the body and return the response, so they can be chained.
+type Response struct {
+	*http.Response
+	Raw []byte // body of response
+
+	t testing.TB
+}
+
+// Status will assert the status code of response.
+func (res *Response) Status(want int) *Response {
+	res.t.Helper()
+	if res.StatusCode != want {
+		res.t.Fatalf("%s %s responded %d, want %d: %s", res.Request.Method, res.Request.URL.Path, res.StatusCode, want, res.Raw)
+	}
+	return res
+}
+
+// Decode will decode the json body of response to v.
+func (res *Response) Decode(v interface{}) *Response {
+	res.t.Helper()
+	if err := json.Unmarshal(res.Raw, v); err != nil {
+		res.t.Fatalf("Error while decoding response of %s %s: %v: %s", res.Request.Method, res.Request.URL.Path, err, res.Raw)
+	}
+	return res
+}
+
+// Envelope will return the model.Response of body. status of v2 bodies is taken from the status line.
+func (res *Response) Envelope() model.Response {
+	res.t.Helper()
+	if res.isProblem() {
+		res.t.Fatalf("%s %s responded a problem, not an envelope: %s", res.Request.Method, res.Request.URL.Path, res.Raw)
+	}
+	var envelope model.Response
+	res.Decode(&envelope)
+	if envelope.Status == 0 {
+		envelope.Status = res.StatusCode
+	}
+	return envelope
+}
+
+// Message will assert the message of envelope.
+func (res *Response) Message(want string) *Response {
+	res.t.Helper()
+	if message := res.Envelope().Message; message != want {
+		res.t.Fatalf("%s %s responded message %q, want %q", res.Request.Method, res.Request.URL.Path, message, want)
+	}
+	return res
+}
+
+// Content will decode the content of envelope to v.
+func (res *Response) Content(v interface{}) *Response {
+	res.t.Helper()
+	var envelope struct {
+		Content json.RawMessage `json:"content"`
+	}
+	res.Envelope()
+	res.Decode(&envelope)
+	if err := json.Unmarshal(envelope.Content, v); err != nil {
+		res.t.Fatalf("Error while decoding content of %s %s: %v: %s", res.Request.Method, res.Request.URL.Path, err, res.Raw)
+	}
+	return res
+}
+
+// Person will return the person of envelope content.
+func (res *Response) Person() *model.Person {
+	res.t.Helper()
+	person := new(model.Person)
+	res.Content(person)
+	return person
+}
+
+// People will return the people of envelope content.
+func (res *Response) People() []model.Person {
+	res.t.Helper()
+	var people []model.Person
+	res.Content(&people)
+	return people
+}
+
+// Problem will return the problem details of body, requests get them with Accept application/problem+json.
+func (res *Response) Problem() handler.Problem {
+	res.t.Helper()
+	if !res.isProblem() {
+		res.t.Fatalf("%s %s responded %s, not a problem: %s", res.Request.Method, res.Request.URL.Path, res.Header.Get("Content-Type"), res.Raw)
+	}
+	var problem handler.Problem
+	res.Decode(&problem)
+	return problem
+}
+
+// Error will assert that response is the error of code. the code of problem details is compared too,
+// envelopes only have the status of code.
+func (res *Response) Error(code handler.ErrorCode) *Response {
+	res.t.Helper()
+	res.Status(code.Status)
+	if res.isProblem() {
+		if problem := res.Problem(); problem.Code != code.Code {
+			res.t.Fatalf("%s %s responded problem %s, want %s: %s", res.Request.Method, res.Request.URL.Path, problem.Code, code.Code, res.Raw)
+		}
+	}
+	return res
+}
+
+func (res *Response) isProblem() bool {
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	return mediaType == "application/problem+json"
+}
@@ -0,0 +1,13 @@
+package codec
+
+import (
+	"reflect"
+
+	ugorji "github.com/ugorji/go/codec"
+)
+
+func init() {
+	handle := new(ugorji.CborHandle)
+	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	Register(binaryCodec{"application/cbor", handle}, "application/cbor")
+}
@@ -0,0 +1,220 @@
+// Package codec is the registry of body formats that api can read and write.
+//
+// every codec registers itself with its media types in an init function, one file per format.
+// values are converted to the generic tree of their json form before they are encoded in
+// other formats, so json tags, ObjectID and time encodings are the same in all formats.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec encodes and decodes the values of a body format.
+type Codec interface {
+	// ContentType is the content-type header of encoded bodies.
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// Typed will return false for codecs that decode all values as strings, like xml.
+// codecs can implement Typed() bool method to report it.
+func Typed(codec Codec) bool {
+	typed, ok := codec.(interface{ Typed() bool })
+	return !ok || typed.Typed()
+}
+
+// errors of negotiation
+var (
+	ErrNotAcceptable        = errors.New("none of the accepted media types is supported")
+	ErrUnsupportedMediaType = errors.New("media type is not supported")
+)
+
+var registry = struct {
+	sync.RWMutex
+	codecs     map[string]Codec
+	mediaTypes []string // registration order, used to match wildcards
+}{codecs: make(map[string]Codec)}
+
+// Register will add codec to registry for media types. the last registered codec of a media type is used.
+func Register(codec Codec, mediaTypes ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, mediaType := range mediaTypes {
+		mediaType = strings.ToLower(mediaType)
+		if _, ok := registry.codecs[mediaType]; !ok {
+			registry.mediaTypes = append(registry.mediaTypes, mediaType)
+		}
+		registry.codecs[mediaType] = codec
+	}
+}
+
+// MediaTypes will return the registered media types in registration order.
+func MediaTypes() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return append([]string(nil), registry.mediaTypes...)
+}
+
+// Default is the codec of requests and clients that have no preference, it is json.
+func Default() Codec {
+	codec, _ := Lookup(jsonMediaType)
+	return codec
+}
+
+// Lookup will return the codec of media type. types with a structured syntax suffix like
+// application/problem+json use the codec of their suffix.
+func Lookup(mediaType string) (Codec, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	mediaType = strings.ToLower(mediaType)
+	if codec, ok := registry.codecs[mediaType]; ok {
+		return codec, true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		codec, ok := registry.codecs["application/"+mediaType[i+1:]]
+		return codec, ok
+	}
+	return nil, false
+}
+
+// ForContentType will return the codec of a content-type header, empty header is the default codec.
+func ForContentType(contentType string) (Codec, error) {
+	if strings.TrimSpace(contentType) == "" {
+		return Default(), nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+	codec, ok := Lookup(mediaType)
+	if !ok {
+		return nil, ErrUnsupportedMediaType
+	}
+	return codec, nil
+}
+
+// Negotiate will return the codec of the most preferred media type of an accept header.
+// empty header and wildcards that match json use the default codec.
+func Negotiate(accept string) (Codec, error) {
+	if strings.TrimSpace(accept) == "" {
+		return Default(), nil
+	}
+	type preference struct {
+		mediaType string
+		quality   float64
+	}
+	var preferences []preference
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+		if quality > 0 {
+			preferences = append(preferences, preference{mediaType, quality})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].quality > preferences[j].quality })
+	for _, preference := range preferences {
+		if codec, ok := match(preference.mediaType); ok {
+			return codec, nil
+		}
+	}
+	return nil, ErrNotAcceptable
+}
+
+// match will return the codec of a media type or a media range like text/*.
+func match(mediaRange string) (Codec, bool) {
+	if !strings.HasSuffix(mediaRange, "/*") {
+		return Lookup(mediaRange)
+	}
+	prefix := strings.TrimSuffix(mediaRange, "*")
+	if prefix == "*/" || strings.HasPrefix(jsonMediaType, prefix) {
+		return Default(), true
+	}
+	for _, mediaType := range MediaTypes() {
+		if strings.HasPrefix(mediaType, prefix) {
+			return Lookup(mediaType)
+		}
+	}
+	return nil, false
+}
+
+// toTree will convert v to the generic tree of its json form. maps are map[string]interface{},
+// arrays are []interface{} and numbers are int64 or float64.
+func toTree(v interface{}) (interface{}, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return normalize(tree), nil
+}
+
+// fromTree will set v from a generic tree that is decoded by a codec.
+func fromTree(tree interface{}, v interface{}) error {
+	content, err := json.Marshal(normalize(tree))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
+
+// normalize will convert the maps of decoders to map[string]interface{} and json numbers to go numbers.
+func normalize(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = normalize(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			converted[keyString(key)] = normalize(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalize(item)
+		}
+		return value
+	case []byte:
+		return string(value)
+	case json.Number:
+		if number, err := value.Int64(); err == nil {
+			return number
+		}
+		number, _ := value.Float64()
+		return number
+	}
+	return value
+}
+
+func keyString(key interface{}) string {
+	switch key := key.(type) {
+	case string:
+		return key
+	case []byte:
+		return string(key)
+	}
+	content, _ := json.Marshal(key)
+	return strings.Trim(string(content), `"`)
+}
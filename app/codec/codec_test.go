@@ -0,0 +1,82 @@
+package codec
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+func TestRoundTrip(t *testing.T) {
+	person := model.NewPerson("john", "doe", "john_doe", "john@gmail.com", map[string]interface{}{
+		"age":      int64(21),
+		"tags":     []interface{}{"a", "b"},
+		"home dir": "/home/john",
+	})
+	person.ID = primitive.NewObjectID()
+	for _, mediaType := range []string{"application/json", "application/msgpack", "application/cbor", "application/xml", "application/yaml"} {
+		codec, ok := Lookup(mediaType)
+		if !ok {
+			t.Fatalf("%s %s codec is not registered", failed, mediaType)
+		}
+		var buffer bytes.Buffer
+		if err := codec.Encode(&buffer, model.NewResponse(200, "", person)); err != nil {
+			t.Fatalf("%s %s encode is failed: %v", failed, mediaType, err)
+		}
+		var decoded struct {
+			Status  interface{}  `json:"status"`
+			Content model.Person `json:"content"`
+		}
+		if err := codec.Decode(&buffer, &decoded); err != nil {
+			t.Fatalf("%s %s decode is failed: %v", failed, mediaType, err)
+		}
+		want := *person
+		if mediaType == "application/xml" {
+			// xml values are strings
+			want.Data = map[string]interface{}{"age": "21", "tags": []interface{}{"a", "b"}, "home dir": "/home/john"}
+		} else {
+			want.Data = map[string]interface{}{"age": float64(21), "tags": []interface{}{"a", "b"}, "home dir": "/home/john"}
+		}
+		if !reflect.DeepEqual(decoded.Content, want) {
+			t.Errorf("%s %s round trip is wrong: got %+v want %+v", failed, mediaType, decoded.Content, want)
+		}
+	}
+	t.Logf("%s Testing codec round trips is successful", succeed)
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                                       "application/json; charset=UTF-8",
+		"*/*":                                    "application/json; charset=UTF-8",
+		"application/*":                          "application/json; charset=UTF-8",
+		"application/problem+json":               "application/json; charset=UTF-8",
+		"application/x-msgpack":                  "application/msgpack",
+		"text/html, application/cbor;q=0.5":      "application/cbor",
+		"application/json;q=0.5, text/xml":       "application/xml; charset=UTF-8",
+		"application/yaml;q=0, application/json": "application/json; charset=UTF-8",
+		"text/*":                                 "application/xml; charset=UTF-8",
+	}
+	for accept, want := range tests {
+		codec, err := Negotiate(accept)
+		if err != nil || codec.ContentType() != want {
+			t.Errorf("%s Negotiate(%q) is wrong: got %v %v want %s", failed, accept, codec, err, want)
+		}
+	}
+	for _, accept := range []string{"text/html", "application/json;q=0", "image/*"} {
+		if _, err := Negotiate(accept); err != ErrNotAcceptable {
+			t.Errorf("%s Negotiate(%q) must not be acceptable: %v", failed, accept, err)
+		}
+	}
+	if _, err := ForContentType("text/plain"); err != ErrUnsupportedMediaType {
+		t.Errorf("%s text/plain content type must not be supported: %v", failed, err)
+	}
+	if codec, err := ForContentType("application/yaml; charset=utf-8"); err != nil || codec.ContentType() != "application/yaml; charset=UTF-8" {
+		t.Errorf("%s yaml content type is wrong: %v %v", failed, codec, err)
+	}
+	t.Logf("%s Testing negotiation is successful", succeed)
+}
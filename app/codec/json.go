@@ -0,0 +1,27 @@
+package codec
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const jsonMediaType = "application/json"
+
+func init() {
+	Register(jsonCodec{}, jsonMediaType)
+}
+
+// jsonCodec is the default codec, values are encoded with encoding/json directly.
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json; charset=UTF-8"
+}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
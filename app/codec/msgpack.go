@@ -0,0 +1,42 @@
+package codec
+
+import (
+	"io"
+	"reflect"
+
+	ugorji "github.com/ugorji/go/codec"
+)
+
+func init() {
+	handle := new(ugorji.MsgpackHandle)
+	handle.WriteExt = true // strings are written as str, not raw bytes.
+	handle.RawToString = true
+	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	Register(binaryCodec{"application/msgpack", handle}, "application/msgpack", "application/x-msgpack", "application/vnd.msgpack")
+}
+
+// binaryCodec encodes the generic tree of values with a ugorji handle, it is used for msgpack and cbor.
+type binaryCodec struct {
+	contentType string
+	handle      ugorji.Handle
+}
+
+func (c binaryCodec) ContentType() string {
+	return c.contentType
+}
+
+func (c binaryCodec) Encode(w io.Writer, v interface{}) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	return ugorji.NewEncoder(w, c.handle).Encode(tree)
+}
+
+func (c binaryCodec) Decode(r io.Reader, v interface{}) error {
+	var tree interface{}
+	if err := ugorji.NewDecoder(r, c.handle).Decode(&tree); err != nil {
+		return err
+	}
+	return fromTree(tree, v)
+}
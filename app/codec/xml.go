@@ -0,0 +1,191 @@
+package codec
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	// xmlRoot is the element of encoded values.
+	xmlRoot = "response"
+	// xmlItem is the element of array items.
+	xmlItem = "item"
+	// xmlEntry is the element of map keys that are not valid xml names, the key is in its key attribute.
+	xmlEntry = "entry"
+)
+
+func init() {
+	Register(xmlCodec{}, "application/xml", "text/xml")
+}
+
+// xmlCodec writes maps as elements of their keys and arrays as item elements. xml has no types,
+// so decoded values are strings, null values are empty elements with nil="true" attribute.
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml; charset=UTF-8"
+}
+
+// Typed is false because decoded values are strings.
+func (xmlCodec) Typed() bool {
+	return false
+}
+
+func (xmlCodec) Encode(w io.Writer, v interface{}) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	if err := encodeXML(encoder, xmlRoot, tree); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func encodeXML(encoder *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !validXMLName(name) {
+		start.Name.Local = xmlEntry
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "key"}, Value: name})
+	}
+	if value == nil {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "nil"}, Value: "true"})
+	}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := encodeXML(encoder, key, value[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if err := encodeXML(encoder, xmlItem, item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := encoder.EncodeToken(xml.CharData(fmt.Sprint(value))); err != nil {
+			return err
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+// validXMLName will return true if name can be used as an element name.
+func validXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		letter := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !letter && (i == 0 || !(r == '-' || r == '.' || (r >= '0' && r <= '9'))) {
+			return false
+		}
+	}
+	return true
+}
+
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			tree, err := decodeXML(decoder, start)
+			if err != nil {
+				return err
+			}
+			return fromTree(tree, v)
+		}
+	}
+}
+
+// decodeXML will read the content of start element. elements that have only item children are
+// arrays, elements that have other children are maps and the others are strings.
+func decodeXML(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	var (
+		keys   []string
+		values []interface{}
+		text   strings.Builder
+	)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			value, err := decodeXML(decoder, token)
+			if err != nil {
+				return nil, err
+			}
+			key := token.Name.Local
+			if key == xmlEntry {
+				key = xmlAttr(token, "key", key)
+			}
+			keys = append(keys, key)
+			values = append(values, value)
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			return xmlValue(start, keys, values, text.String()), nil
+		}
+	}
+}
+
+func xmlValue(start xml.StartElement, keys []string, values []interface{}, text string) interface{} {
+	if xmlAttr(start, "nil", "") == "true" {
+		return nil
+	}
+	if len(keys) == 0 {
+		return text
+	}
+	items := true
+	for _, key := range keys {
+		items = items && key == xmlItem
+	}
+	if items {
+		return values
+	}
+	object := make(map[string]interface{}, len(keys))
+	for i, key := range keys {
+		// repeated elements are collected in an array.
+		switch current := object[key].(type) {
+		case nil:
+			object[key] = values[i]
+		case []interface{}:
+			object[key] = append(current, values[i])
+		default:
+			object[key] = []interface{}{current, values[i]}
+		}
+	}
+	return object
+}
+
+func xmlAttr(element xml.StartElement, name, defaultValue string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,43 @@
+package codec
+
+import (
+	"io"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	Register(yamlCodec{}, "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml")
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) ContentType() string {
+	return "application/yaml; charset=UTF-8"
+}
+
+func (yamlCodec) Encode(w io.Writer, v interface{}) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	content, err := yaml.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+func (yamlCodec) Decode(r io.Reader, v interface{}) error {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var tree interface{}
+	if err := yaml.Unmarshal(content, &tree); err != nil {
+		return err
+	}
+	return fromTree(tree, v)
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"github.com/katoozi/golang-mongodb-rest-api/config"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/net/context"
+)
+
+const (
+	// secretFilesWatchInterval is how often the secret files are checked for new credentials.
+	secretFilesWatchInterval = 10 * time.Second
+	// disconnectGracePeriod is how long the old mongo client is kept for requests that are still using it.
+	disconnectGracePeriod = 30 * time.Second
+)
+
+// Database will return the current mongo database.
+func (app *App) Database() *mongo.Database {
+	app.dbLock.RLock()
+	defer app.dbLock.RUnlock()
+	return app.DB
+}
+
+// rotateCredentials will connect to mongo with the new credentials of config and replace the
+// database of app and job workers. the old client is disconnected after a grace period.
+// the current connection is kept if the new credentials do not work.
+func (app *App) rotateCredentials(config *config.Config) {
+	database, err := db.Connect(config.MongoDatabase, config.MongoURI())
+	if err == nil {
+		err = db.Ping(database)
+		if err != nil {
+			database.Client().Disconnect(context.Background())
+		}
+	}
+	if err != nil {
+		log.Printf("Error while connecting with new mongo credentials, old connection is kept: %v\n", err)
+		return
+	}
+
+	app.dbLock.Lock()
+	old := app.DB
+	app.DB = database
+	app.config = config
+	app.dbLock.Unlock()
+	log.Println("MongoDB credentials are rotated.")
+
+	time.AfterFunc(disconnectGracePeriod, func() {
+		if err := old.Client().Disconnect(context.Background()); err != nil {
+			log.Printf("Error while disconnecting old mongo client: %v\n", err)
+		}
+	})
+}
@@ -11,11 +11,27 @@ import (
 
 // InitialConnection will create new connection to mongo db
 func InitialConnection(dbName string, mongoURI string) *mongo.Database {
+	database, err := Connect(dbName, mongoURI)
+	if err != nil {
+		log.Fatalf("Error while connecting to mongo: %v\n", err)
+	}
+	return database
+}
+
+// Connect will create new connection to mongo db and return the error instead of exiting.
+func Connect(dbName string, mongoURI string) (*mongo.Database, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
 	if err != nil {
-		log.Fatalf("Error while connecting to mongo: %v\n", err)
+		return nil, err
 	}
-	return client.Database(dbName)
+	return client.Database(dbName), nil
+}
+
+// Ping will check that mongo db is reachable and the credentials are accepted.
+func Ping(database *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return database.Client().Ping(ctx, nil)
 }
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// duplicateKeyCode is the mongo db error code of unique index violations.
+const duplicateKeyCode = 11000
+
+// IsDuplicateKey will return true if err is a unique index violation.
+func IsDuplicateKey(err error) bool {
+	switch e := err.(type) {
+	case mongo.WriteException:
+		for _, writeErr := range e.WriteErrors {
+			if writeErr.Code == duplicateKeyCode {
+				return true
+			}
+		}
+	case mongo.BulkWriteException:
+		for _, writeErr := range e.WriteErrors {
+			if writeErr.Code == duplicateKeyCode {
+				return true
+			}
+		}
+	case mongo.WriteError:
+		return e.Code == duplicateKeyCode
+	case mongo.BulkWriteError:
+		return e.Code == duplicateKeyCode
+	case mongo.CommandError:
+		return e.Code == duplicateKeyCode
+	}
+	return false
+}
+
+// dupKeyPattern finds the index name and the first field of a duplicate key error message, e.g.
+// E11000 duplicate key error collection: golang.people index: email_unique_ci dup key: { email: "john@gmail.com" }
+var dupKeyPattern = regexp.MustCompile(`index: (\S+) dup key: \{ ?(\w*)`)
+
+// DuplicateKeyField will return the field of unique index that err violates. index is looked up
+// in specs and the field of error message is used for unknown indexes, the field of index is used if
+// it is set and the tenant field of tenant indexes is skipped. it is empty if err is not a duplicate
+// key error.
+func DuplicateKeyField(err error, specs []Index) string {
+	if !IsDuplicateKey(err) {
+		return ""
+	}
+	match := dupKeyPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return ""
+	}
+	for _, index := range specs {
+		if index.Name != match[1] {
+			continue
+		}
+		if index.Field != "" {
+			return index.Field
+		}
+		for _, key := range index.Keys {
+			if key.Key != TenantField {
+				return key.Key
+			}
+		}
+	}
+	return match[2]
+}
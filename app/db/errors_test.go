@@ -0,0 +1,43 @@
+package db
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestDuplicateKeyField(t *testing.T) {
+	err := mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    11000,
+		Message: `E11000 duplicate key error collection: golang.people index: email_unique_ci dup key: { email: "John@x.com" }`,
+	}}}
+	if field := DuplicateKeyField(err, Indexes); field != "email" {
+		t.Errorf("%s field of known index is wrong: got %q want %q", failed, field, "email")
+	}
+
+	err.WriteErrors[0].Message = `E11000 duplicate key error collection: golang.people index: nickname_1 dup key: { nickname: "john" }`
+	if field := DuplicateKeyField(err, Indexes); field != "nickname" {
+		t.Errorf("%s field of unknown index is wrong: got %q want %q", failed, field, "nickname")
+	}
+
+	err.WriteErrors[0].Code = 121
+	if field := DuplicateKeyField(err, Indexes); field != "" {
+		t.Errorf("%s other write errors must not have a field: got %q", failed, field)
+	}
+	t.Logf("%s Testing duplicate key field is successful", succeed)
+}
+
+func TestDuplicateKeyFieldOfTenantIndexes(t *testing.T) {
+	err := mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    11000,
+		Message: `E11000 duplicate key error collection: golang.people index: username_unique_ci dup key: { tenant_id: "acme", username: "john" }`,
+	}}}
+	if field := DuplicateKeyField(err, TenantIndexes(Indexes)); field != "username" {
+		t.Errorf("%s field of tenant index is wrong: got %q want %q", failed, field, "username")
+	}
+	err.WriteErrors[0].Message = `E11000 duplicate key error collection: golang.people index: email_blind_index_unique dup key: { tenant_id: "acme", blind_index.email: "q83vEj" }`
+	if field := DuplicateKeyField(err, TenantIndexes(Indexes)); field != "email" {
+		t.Errorf("%s field of blind index is wrong: got %q want %q", failed, field, "email")
+	}
+	t.Logf("%s Testing duplicate key field of tenant indexes is successful", succeed)
+}
@@ -2,25 +2,377 @@ package db
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/x/bsonx"
 )
 
-// SetIndexes will create mongo indexes for collection and keys that sent.
-func SetIndexes(collection *mongo.Collection, keys bsonx.Doc) {
-	index := mongo.IndexModel{}
-	index.Keys = keys
-	unique := true
-	index.Options = &options.IndexOptions{
-		Unique: &unique,
+// namespaceNotFoundCode is the mongo db error code of listing the indexes of a collection that does not exist.
+const namespaceNotFoundCode = 26
+
+// Index is the declarative spec of a collection index. indexes are matched by name.
+type Index struct {
+	Collection      string
+	Name            string
+	Keys            bson.D // {field: 1}, {field: -1} or {field: "text"}
+	Unique          bool
+	Sparse          bool
+	PartialFilter   bson.D             // filter expression of partial index
+	ExpireAfter     time.Duration      // ttl of documents, zero means no ttl
+	Collation       *options.Collation // only locale and strength are compared with the existing index
+	Weights         bson.D             // weights of text index fields, default weight is 1
+	DefaultLanguage string             // language of text index
+	Retired         bool               // index of an old version, it is dropped if it exists and never created
+	Field           string             // field of duplicate key errors if it is not the first key, it is not compared
+}
+
+// model will create the mongo index model of index.
+func (index Index) model() mongo.IndexModel {
+	opts := options.Index().SetName(index.Name)
+	if index.Unique {
+		opts.SetUnique(true)
+	}
+	if index.Sparse {
+		opts.SetSparse(true)
+	}
+	if index.PartialFilter != nil {
+		opts.SetPartialFilterExpression(index.PartialFilter)
+	}
+	if index.ExpireAfter > 0 {
+		opts.SetExpireAfterSeconds(int32(index.ExpireAfter / time.Second))
+	}
+	if index.Collation != nil {
+		opts.SetCollation(index.Collation)
+	}
+	if index.Weights != nil {
+		opts.SetWeights(index.Weights)
+	}
+	if index.DefaultLanguage != "" {
+		opts.SetDefaultLanguage(index.DefaultLanguage)
+	}
+	return mongo.IndexModel{Keys: index.Keys, Options: opts}
+}
+
+// textWeights will return the weights of text fields, it is nil for non text indexes.
+func (index Index) textWeights() map[string]float64 {
+	var weights map[string]float64
+	for _, key := range index.Keys {
+		if key.Value == "text" {
+			if weights == nil {
+				weights = make(map[string]float64)
+			}
+			weights[key.Key] = 1
+		}
+	}
+	for _, weight := range index.Weights {
+		if weights != nil {
+			weights[weight.Key] = number(weight.Value)
+		}
+	}
+	return weights
+}
+
+// existingIndex is an index that is returned by listIndexes command.
+type existingIndex struct {
+	Name                    string `bson:"name"`
+	Key                     bson.D `bson:"key"`
+	Unique                  bool   `bson:"unique"`
+	Sparse                  bool   `bson:"sparse"`
+	PartialFilterExpression bson.D `bson:"partialFilterExpression"`
+	ExpireAfterSeconds      *int64 `bson:"expireAfterSeconds"`
+	Collation               *struct {
+		Locale   string `bson:"locale"`
+		Strength int    `bson:"strength"`
+	} `bson:"collation"`
+	Weights         bson.M `bson:"weights"`
+	DefaultLanguage string `bson:"default_language"`
+}
+
+// drift will return the differences of existing index and spec.
+func (existing existingIndex) drift(index Index) []string {
+	var reasons []string
+	if weights := index.textWeights(); weights != nil {
+		current := make(map[string]float64, len(existing.Weights))
+		for field, weight := range existing.Weights {
+			current[field] = number(weight)
+		}
+		if !reflect.DeepEqual(current, weights) {
+			reasons = append(reasons, fmt.Sprintf("text weights are %v, want %v", current, weights))
+		}
+		if index.DefaultLanguage != "" && existing.DefaultLanguage != index.DefaultLanguage {
+			reasons = append(reasons, fmt.Sprintf("default language is %q, want %q", existing.DefaultLanguage, index.DefaultLanguage))
+		}
+	} else if !sameKeys(existing.Key, index.Keys) {
+		reasons = append(reasons, fmt.Sprintf("keys are %s, want %s", extJSON(existing.Key), extJSON(index.Keys)))
+	}
+	if existing.Unique != index.Unique {
+		reasons = append(reasons, fmt.Sprintf("unique is %t, want %t", existing.Unique, index.Unique))
+	}
+	if existing.Sparse != index.Sparse {
+		reasons = append(reasons, fmt.Sprintf("sparse is %t, want %t", existing.Sparse, index.Sparse))
+	}
+	if extJSON(existing.PartialFilterExpression) != extJSON(index.PartialFilter) {
+		reasons = append(reasons, fmt.Sprintf("partial filter is %s, want %s", extJSON(existing.PartialFilterExpression), extJSON(index.PartialFilter)))
 	}
-	opts := options.CreateIndexes().SetMaxTime(10 * time.Second)
-	_, err := collection.Indexes().CreateOne(context.Background(), index, opts)
+	var ttl int64
+	if existing.ExpireAfterSeconds != nil {
+		ttl = *existing.ExpireAfterSeconds
+	}
+	if want := int64(index.ExpireAfter / time.Second); ttl != want {
+		reasons = append(reasons, fmt.Sprintf("ttl is %ds, want %ds", ttl, want))
+	}
+	var locale, wantLocale string
+	var strength, wantStrength int
+	if existing.Collation != nil {
+		locale, strength = existing.Collation.Locale, existing.Collation.Strength
+	}
+	if index.Collation != nil {
+		wantLocale, wantStrength = index.Collation.Locale, index.Collation.Strength
+	}
+	if locale != wantLocale || (wantStrength != 0 && strength != wantStrength) {
+		reasons = append(reasons, fmt.Sprintf("collation is %s/%d, want %s/%d", locale, strength, wantLocale, wantStrength))
+	}
+	return reasons
+}
+
+func sameKeys(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || fmt.Sprint(toFloat(a[i].Value)) != fmt.Sprint(toFloat(b[i].Value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// toFloat will convert the numbers of any bson type to float64, other values are returned as they are.
+func toFloat(value interface{}) interface{} {
+	switch number := value.(type) {
+	case int:
+		return float64(number)
+	case int32:
+		return float64(number)
+	case int64:
+		return float64(number)
+	}
+	return value
+}
+
+// number will return the float64 of a bson number, it is zero for other values.
+func number(value interface{}) float64 {
+	if value, ok := value.(float64); ok {
+		return value
+	}
+	converted, _ := toFloat(value).(float64)
+	return converted
+}
+
+// extJSON will return the relaxed extended json of document, it is used to compare documents
+// that have different number types. nil and empty documents are the same.
+func extJSON(document bson.D) string {
+	if len(document) == 0 {
+		return "{}"
+	}
+	content, err := bson.MarshalExtJSON(document, false, false)
 	if err != nil {
-		log.Fatalf("Error while creating indexs: %v", err)
+		return fmt.Sprint(document)
+	}
+	return string(content)
+}
+
+// Action is the change that a plan makes to an index.
+type Action string
+
+// plan actions. drifted and unknown indexes are only changed when plan is applied with drop, retired
+// indexes are always dropped.
+const (
+	ActionNone     Action = "none"
+	ActionCreate   Action = "create"
+	ActionRecreate Action = "recreate"
+	ActionDrop     Action = "drop"
+)
+
+// Change is the planned action of an index.
+type Change struct {
+	Action     Action
+	Collection string
+	Name       string
+	Reasons    []string
+	index      Index
+}
+
+// Plan is the list of changes that reconcile the existing indexes with specs.
+type Plan []Change
+
+// PlanIndexes will compare the specs with existing indexes of their collections. indexes that are
+// not in specs or are retired are planned to be dropped, _id index is never touched.
+func PlanIndexes(ctx context.Context, database *mongo.Database, specs []Index) (Plan, error) {
+	var plan Plan
+	var collections []string
+	byCollection := make(map[string][]Index)
+	for _, index := range specs {
+		if _, ok := byCollection[index.Collection]; !ok {
+			collections = append(collections, index.Collection)
+		}
+		byCollection[index.Collection] = append(byCollection[index.Collection], index)
+	}
+	for _, collection := range collections {
+		existing, err := listIndexes(ctx, database.Collection(collection))
+		if err != nil {
+			return nil, fmt.Errorf("could not list %s indexes: %v", collection, err)
+		}
+		for _, index := range byCollection[collection] {
+			change := Change{Action: ActionNone, Collection: collection, Name: index.Name, index: index}
+			current, ok := existing[index.Name]
+			if index.Retired {
+				if ok {
+					change.Action, change.Reasons = ActionDrop, []string{"index is retired"}
+					plan = append(plan, change)
+				}
+				delete(existing, index.Name)
+				continue
+			}
+			if !ok {
+				change.Action = ActionCreate
+			} else if reasons := current.drift(index); len(reasons) > 0 {
+				change.Action = ActionRecreate
+				change.Reasons = reasons
+			}
+			delete(existing, index.Name)
+			plan = append(plan, change)
+		}
+		for name := range existing {
+			if name == "_id_" {
+				continue
+			}
+			plan = append(plan, Change{Action: ActionDrop, Collection: collection, Name: name, Reasons: []string{"index is not in specs"}})
+		}
+	}
+	return plan, nil
+}
+
+func listIndexes(ctx context.Context, collection *mongo.Collection) (map[string]existingIndex, error) {
+	existing := make(map[string]existingIndex)
+	curser, err := collection.Indexes().List(ctx)
+	if e, ok := err.(mongo.CommandError); ok && e.Code == namespaceNotFoundCode {
+		return existing, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var indexes []existingIndex
+	if err := curser.All(ctx, &indexes); err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		existing[index.Name] = index
+	}
+	return existing, nil
+}
+
+// Apply will create the missing indexes and drop the retired ones. drifted indexes are recreated and
+// unknown indexes are dropped only if drop is true. it continues after errors and returns all of them
+// as IndexErrors.
+func (plan Plan) Apply(ctx context.Context, database *mongo.Database, drop bool) error {
+	var errs IndexErrors
+	for _, change := range plan {
+		indexes := database.Collection(change.Collection).Indexes()
+		opts := options.CreateIndexes().SetMaxTime(time.Minute)
+		var err error
+		switch change.Action {
+		case ActionCreate:
+			_, err = indexes.CreateOne(ctx, change.index.model(), opts)
+		case ActionRecreate:
+			if !drop {
+				continue
+			}
+			if _, err = indexes.DropOne(ctx, change.Name); err == nil {
+				_, err = indexes.CreateOne(ctx, change.index.model(), opts)
+			}
+		case ActionDrop:
+			if !drop && !change.Retired() {
+				continue
+			}
+			_, err = indexes.DropOne(ctx, change.Name)
+		}
+		if err != nil {
+			errs = append(errs, &IndexError{Change: change, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Changes will return the changes that are not none.
+func (plan Plan) Changes() Plan {
+	var changes Plan
+	for _, change := range plan {
+		if change.Action != ActionNone {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// Print will write plan in a human readable format.
+func (plan Plan) Print(w io.Writer) {
+	for _, change := range plan {
+		fmt.Fprintf(w, "%-9s %s.%s\n", change.Action, change.Collection, change.Name)
+		for _, reason := range change.Reasons {
+			fmt.Fprintf(w, "          - %s\n", reason)
+		}
+	}
+}
+
+// Unique will return true if the index of change is unique.
+func (change Change) Unique() bool {
+	return change.index.Unique
+}
+
+// Retired will return true if the index of change is retired.
+func (change Change) Retired() bool {
+	return change.index.Retired
+}
+
+// IndexError is the error of applying a change.
+type IndexError struct {
+	Change Change
+	Err    error
+}
+
+func (err *IndexError) Error() string {
+	return fmt.Sprintf("%s index %s.%s: %v", err.Change.Action, err.Change.Collection, err.Change.Name, err.Err)
+}
+
+// IndexErrors holds the errors of applying a plan.
+type IndexErrors []error
+
+func (errs IndexErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unique will return the errors of unique indexes that could not be created, without them the
+// uniqueness of their fields is not enforced.
+func (errs IndexErrors) Unique() IndexErrors {
+	var unique IndexErrors
+	for _, err := range errs {
+		if err, ok := err.(*IndexError); ok && err.Change.Action != ActionDrop && err.Change.Unique() {
+			unique = append(unique, err)
+		}
 	}
+	return unique
 }
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+func TestIndexDrift(t *testing.T) {
+	ttl := int64(60)
+	existing := existingIndex{
+		Name:                    "email_unique",
+		Key:                     bson.D{{Key: "email", Value: int32(1)}},
+		Unique:                  true,
+		PartialFilterExpression: bson.D{{Key: "deleted", Value: int64(0)}},
+		ExpireAfterSeconds:      &ttl,
+	}
+	index := Index{
+		Name:          "email_unique",
+		Keys:          bson.D{{Key: "email", Value: 1}},
+		Unique:        true,
+		PartialFilter: bson.D{{Key: "deleted", Value: int32(0)}},
+		ExpireAfter:   time.Minute,
+	}
+	if reasons := existing.drift(index); len(reasons) != 0 {
+		t.Errorf("%s same index with different number types must not drift: %v", failed, reasons)
+	}
+
+	index.Unique = false
+	index.Collation = &options.Collation{Locale: "en", Strength: 2}
+	if reasons := existing.drift(index); len(reasons) != 2 {
+		t.Errorf("%s unique and collation must drift: got %v", failed, reasons)
+	}
+	t.Logf("%s Testing index drift is successful", succeed)
+}
+
+func TestTextIndexDrift(t *testing.T) {
+	existing := existingIndex{
+		Name:    "search",
+		Key:     bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}},
+		Weights: bson.M{"first_name": int32(1), "username": int32(5)},
+	}
+	index := Index{
+		Name:    "search",
+		Keys:    bson.D{{Key: "first_name", Value: "text"}, {Key: "username", Value: "text"}},
+		Weights: bson.D{{Key: "username", Value: 5}},
+	}
+	if reasons := existing.drift(index); len(reasons) != 0 {
+		t.Errorf("%s text index must be compared by weights: %v", failed, reasons)
+	}
+	index.Keys = append(index.Keys, bson.E{Key: "last_name", Value: "text"})
+	if reasons := existing.drift(index); len(reasons) != 1 {
+		t.Errorf("%s new text field must drift: got %v", failed, reasons)
+	}
+	t.Logf("%s Testing text index drift is successful", succeed)
+}
+
+func TestUniqueIndexErrors(t *testing.T) {
+	errs := IndexErrors{
+		&IndexError{Change: Change{Action: ActionCreate, Collection: "people", Name: "username_unique_ci", index: Indexes[0]}, Err: errors.New("E11000")},
+		&IndexError{Change: Change{Action: ActionCreate, Collection: "jobs", Name: "state_created_at", index: Index{}}, Err: errors.New("timeout")},
+		&IndexError{Change: Change{Action: ActionDrop, Collection: "people", Name: "username_1_email_1", index: Index{Unique: true, Retired: true}}, Err: errors.New("timeout")},
+	}
+	if unique := errs.Unique(); len(unique) != 1 || unique[0].(*IndexError).Change.Name != "username_unique_ci" {
+		t.Fatalf("%s only unique indexes that are not created must be returned: %v", failed, unique)
+	}
+	t.Logf("%s Testing unique index errors is successful", succeed)
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CaseInsensitive is the collation of case insensitive unique fields. queries on these
+// fields must use it too, so they match the same documents as the index.
+var CaseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+// Indexes are the index specs of app collections. they are created when app initializes
+// and can be planned and applied with the indexes command. handlers use them to find the
+// field of a duplicate key error.
+var Indexes = []Index{
+	// username and email are unique on their own and case insensitive.
+	{
+		Collection: "people",
+		Name:       "username_unique_ci",
+		Keys:       bson.D{{Key: "username", Value: 1}},
+		Unique:     true,
+		Collation:  CaseInsensitive,
+	},
+	{
+		Collection: "people",
+		Name:       "email_unique_ci",
+		Keys:       bson.D{{Key: "email", Value: 1}},
+		Unique:     true,
+		Collation:  CaseInsensitive,
+	},
+	// encrypted username and email are unique by their blind index, it is the same for every encryption key.
+	// people that are not encrypted have no blind index, so they are not in these indexes.
+	{
+		Collection:    "people",
+		Name:          "username_blind_index_unique",
+		Keys:          bson.D{{Key: "blind_index.username", Value: 1}},
+		Unique:        true,
+		PartialFilter: bson.D{{Key: "blind_index.username", Value: bson.D{{Key: "$gt", Value: ""}}}},
+		Field:         "username",
+	},
+	{
+		Collection:    "people",
+		Name:          "email_blind_index_unique",
+		Keys:          bson.D{{Key: "blind_index.email", Value: 1}},
+		Unique:        true,
+		PartialFilter: bson.D{{Key: "blind_index.email", Value: bson.D{{Key: "$gt", Value: ""}}}},
+		Field:         "email",
+	},
+	// the first versions had one case sensitive index of both fields, so a username could be used twice.
+	{
+		Collection: "people",
+		Name:       "username_1_email_1",
+		Retired:    true,
+	},
+	// workers claim the oldest queued jobs and running jobs that their lease is expired.
+	{
+		Collection: "jobs",
+		Name:       "state_created_at",
+		Keys:       bson.D{{Key: "state", Value: 1}, {Key: "created_at", Value: 1}},
+	},
+	{
+		Collection:    "jobs",
+		Name:          "running_lease_expires_at",
+		Keys:          bson.D{{Key: "lease_expires_at", Value: 1}},
+		PartialFilter: bson.D{{Key: "state", Value: "running"}},
+	},
+	{
+		Collection: "import_errors",
+		Name:       "report_id_line",
+		Keys:       bson.D{{Key: "report_id", Value: 1}, {Key: "line", Value: 1}},
+	},
+	// uploads of imports that never run are removed a day after they are saved.
+	{
+		Collection: "import_uploads",
+		Name:       "upload_id_n",
+		Keys:       bson.D{{Key: "upload_id", Value: 1}, {Key: "n", Value: 1}},
+	},
+	{
+		Collection:  "import_uploads",
+		Name:        "created_at_ttl",
+		Keys:        bson.D{{Key: "created_at", Value: 1}},
+		ExpireAfter: 24 * time.Hour,
+	},
+	// entries of the erasure log are chained in sequence order, a person is erased once.
+	{
+		Collection: "erasures",
+		Name:       "sequence_unique",
+		Keys:       bson.D{{Key: "sequence", Value: 1}},
+		Unique:     true,
+	},
+	{
+		Collection: "erasures",
+		Name:       "person_id_unique",
+		Keys:       bson.D{{Key: "person_id", Value: 1}},
+		Unique:     true,
+	},
+	// expired migration locks of crashed processes are removed.
+	{
+		Collection:  "migration_locks",
+		Name:        "expires_at_ttl",
+		Keys:        bson.D{{Key: "expires_at", Value: 1}},
+		ExpireAfter: time.Second,
+	},
+}
+
+// TenantField is the tenant of documents when tenants share the collections.
+const TenantField = "tenant_id"
+
+// TenantIndexes will return specs with tenant field prepended to the keys of unique indexes, so unique
+// fields are unique per tenant when tenants share the collections. names are kept, so switching the
+// mode of tenancy shows the indexes as drifted and they are recreated when plan is applied with drop.
+func TenantIndexes(specs []Index) []Index {
+	indexes := make([]Index, len(specs))
+	for i, index := range specs {
+		if index.Unique {
+			index.Keys = append(bson.D{{Key: TenantField, Value: 1}}, index.Keys...)
+		}
+		indexes[i] = index
+	}
+	return indexes
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/encryption"
+	"github.com/katoozi/golang-mongodb-rest-api/app/jobs"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+	"github.com/katoozi/golang-mongodb-rest-api/config"
+)
+
+// ReencryptJob is the job type that encrypts people again with the active key of keyring.
+const ReencryptJob = "reencrypt-people"
+
+// Cipher will return the cipher of encryption options, it is nil if encryption_keyring_file is empty.
+func Cipher(config *config.Config) (*encryption.Cipher, error) {
+	if config == nil || config.EncryptionKeyringFile == "" {
+		return nil, nil
+	}
+	keyring, err := encryption.LoadKeyring(config.EncryptionKeyringFile)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := encryption.ParseFields(config.EncryptedFields)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.NewCipher(keyring, fields)
+}
+
+// reencryptPeople is the function of ReencryptJob. tenant param is the tenant of people, all tenants are
+// encrypted again if it is empty and tenants share the collection. it is required in database mode.
+func (app *App) reencryptPeople(ctx context.Context, job *model.Job, progress jobs.ProgressFunc) (map[string]interface{}, error) {
+	if mode := app.tenancy(); mode != "" {
+		id, _ := job.Params["tenant"].(string)
+		if id == "" {
+			id = job.TenantID
+		}
+		if id != "" {
+			var err error
+			if id, err = tenant.ParseID(id); err != nil {
+				return nil, err
+			}
+		} else if mode == tenant.ModeDatabase {
+			return nil, fmt.Errorf("tenant param is required when tenants have their own databases")
+		}
+		ctx = tenant.NewContext(ctx, tenant.Tenant{ID: id, Mode: mode})
+	}
+	people := app.peopleStore().(*store.Encrypted)
+	scanned, updated, err := people.Reencrypt(ctx, func(scanned int64) {
+		progress(scanned, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"scanned": scanned, "reencrypted": updated}, nil
+}
@@ -0,0 +1,216 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Mode is how the values of a field are encrypted.
+type Mode string
+
+// modes of fields
+const (
+	Deterministic Mode = "deterministic" // same value has same ciphertext, fields can be searched and unique by their blind index
+	Randomized    Mode = "randomized"    // every write has a new nonce, fields can not be searched
+)
+
+// prefix is the start of ciphertexts, values without it are plaintext.
+const prefix = "enc:"
+
+// BlindIndexField is the document of people that has the blind indexes of their deterministic fields.
+const BlindIndexField = "blind_index"
+
+// kinds of plaintext, strings are kept as they are and other values are encoded with bson.
+const (
+	kindString byte = 's'
+	kindBSON   byte = 'b'
+)
+
+// ErrDecrypt is returned for ciphertexts that are changed, are of another field or their key is not in keyring.
+var ErrDecrypt = errors.New("value can not be decrypted")
+
+// key is a keyring key and the keys that are derived from it.
+type key struct {
+	aead cipher.AEAD
+	mac  []byte // key of the synthetic nonces of deterministic values
+}
+
+// Cipher encrypts and decrypts the fields of people with the keys of a keyring.
+type Cipher struct {
+	keyring *Keyring
+	keys    map[string]key
+	index   []byte // key of blind indexes, it is derived from the index key of keyring
+	fields  map[string]Mode
+}
+
+// NewCipher is the Cipher struct factory function, fields are the encrypted fields of people.
+func NewCipher(keyring *Keyring, fields []Field) (*Cipher, error) {
+	c := &Cipher{keyring: keyring, keys: make(map[string]key, len(keyring.keys)), fields: make(map[string]Mode, len(fields))}
+	for id, material := range keyring.keys {
+		block, err := aes.NewCipher(derive(material, "encryption"))
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.keys[id] = key{aead: aead, mac: derive(material, "deterministic")}
+	}
+	for _, field := range fields {
+		c.fields[field.Name] = field.Mode
+		if field.Mode == Deterministic && keyring.index == nil {
+			return nil, fmt.Errorf("%s is deterministic, its blind index needs index_key in keyring", field.Name)
+		}
+	}
+	if keyring.index != nil {
+		c.index = derive(keyring.index, "blind index")
+	}
+	return c, nil
+}
+
+// derive will return the key of purpose from keyring key material.
+func derive(material []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, material)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// Mode will return the mode of field, ok is false if field is not encrypted.
+func (c *Cipher) Mode(field string) (mode Mode, ok bool) {
+	mode, ok = c.fields[field]
+	return mode, ok
+}
+
+// Encrypt will encrypt value of field with the active key.
+func (c *Cipher) Encrypt(field string, value interface{}) (string, error) {
+	return c.encrypt(c.keyring.active, field, value)
+}
+
+// BlindIndex will return the blind index of a deterministic value, it is the hmac of field and lower
+// cased value with the index key. it is compared case insensitive like unique fields and it does not
+// change when encryption keys are rotated, so it is searched and unique instead of the ciphertexts.
+func (c *Cipher) BlindIndex(field, value string) (string, error) {
+	if c.fields[field] != Deterministic {
+		return "", fmt.Errorf("%s is not a deterministic field", field)
+	}
+	mac := hmac.New(sha256.New, c.index)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strings.ToLower(value)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// encrypt will return enc:<key id>:<base64 of nonce and sealed plaintext>. field is the additional data
+// of gcm, so ciphertexts can not be moved to other fields.
+func (c *Cipher) encrypt(keyID, field string, value interface{}) (string, error) {
+	plaintext, err := marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("%s can not be encrypted: %v", field, err)
+	}
+	k := c.keys[keyID]
+	nonce := make([]byte, k.aead.NonceSize())
+	if c.fields[field] == Deterministic {
+		// the synthetic nonce is the hmac of field and value, so it is only repeated for the same plaintext.
+		mac := hmac.New(sha256.New, k.mac)
+		mac.Write([]byte(field))
+		mac.Write([]byte{0})
+		mac.Write(plaintext)
+		copy(nonce, mac.Sum(nil))
+	} else if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := k.aead.Seal(nonce, nonce, plaintext, []byte(field))
+	return prefix + keyID + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt will return the value of ciphertext of field.
+func (c *Cipher) Decrypt(field, ciphertext string) (interface{}, error) {
+	id := KeyID(ciphertext)
+	k, ok := c.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: key %q of %s is not in keyring", ErrDecrypt, id, field)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(ciphertext[len(prefix)+len(id)+1:])
+	if err != nil || len(sealed) < k.aead.NonceSize() {
+		return nil, fmt.Errorf("%w: %s is not a ciphertext", ErrDecrypt, field)
+	}
+	nonce, sealed := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, sealed, []byte(field))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is changed or is not of this field", ErrDecrypt, field)
+	}
+	return unmarshal(plaintext)
+}
+
+// IsEncrypted will return true if value is a ciphertext.
+func IsEncrypted(value interface{}) bool {
+	text, ok := value.(string)
+	return ok && strings.HasPrefix(text, prefix) && KeyID(text) != ""
+}
+
+// KeyID will return the id of the key of ciphertext, it is empty for plaintext values.
+func KeyID(ciphertext string) string {
+	if !strings.HasPrefix(ciphertext, prefix) {
+		return ""
+	}
+	rest := ciphertext[len(prefix):]
+	end := strings.IndexByte(rest, ':')
+	if end < 1 {
+		return ""
+	}
+	return rest[:end]
+}
+
+func marshal(value interface{}) ([]byte, error) {
+	if text, ok := value.(string); ok {
+		return append([]byte{kindString}, text...), nil
+	}
+	document, err := bson.Marshal(bson.D{{Key: "v", Value: value}})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{kindBSON}, document...), nil
+}
+
+func unmarshal(plaintext []byte) (interface{}, error) {
+	if len(plaintext) == 0 {
+		return nil, ErrDecrypt
+	}
+	switch plaintext[0] {
+	case kindString:
+		return string(plaintext[1:]), nil
+	case kindBSON:
+		var document bson.M
+		if err := bson.Unmarshal(plaintext[1:], &document); err != nil {
+			return nil, err
+		}
+		return document["v"], nil
+	}
+	return nil, ErrDecrypt
+}
+
+type contextKey struct{}
+
+// NewContext will return a copy of ctx that has cipher, writers that do not use the people store like
+// imports read it from their context.
+func NewContext(ctx context.Context, cipher *Cipher) context.Context {
+	return context.WithValue(ctx, contextKey{}, cipher)
+}
+
+// FromContext will return the cipher of ctx, it is nil if field encryption is disabled.
+func FromContext(ctx context.Context) *Cipher {
+	cipher, _ := ctx.Value(contextKey{}).(*Cipher)
+	return cipher
+}
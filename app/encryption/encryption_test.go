@@ -0,0 +1,192 @@
+package encryption
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+func newTestCipher(t *testing.T, active string, fields string) *Cipher {
+	keyring, err := NewKeyring(active, map[string][]byte{
+		"old": bytes.Repeat([]byte{1}, KeySize),
+		"new": bytes.Repeat([]byte{2}, KeySize),
+	}, bytes.Repeat([]byte{3}, KeySize))
+	if err != nil {
+		t.Fatalf("%s keyring must be valid: %v", failed, err)
+	}
+	parsed, err := ParseFields(fields)
+	if err != nil {
+		t.Fatalf("%s fields must be valid: %v", failed, err)
+	}
+	cipher, err := NewCipher(keyring, parsed)
+	if err != nil {
+		t.Fatalf("%s cipher must be created: %v", failed, err)
+	}
+	return cipher
+}
+
+func TestKeyring(t *testing.T) {
+	keyring, err := ParseKeyring([]byte("active: b\nkeys:\n  a: " + strings.Repeat("A", 43) + "=\n  b: " + strings.Repeat("B", 43) + "=\nindex_key: " + strings.Repeat("C", 43) + "=\n"))
+	if err != nil {
+		t.Fatalf("%s keyring file must be parsed: %v", failed, err)
+	}
+	if ids := keyring.IDs(); len(ids) != 2 || ids[0] != "b" || keyring.Active() != "b" {
+		t.Fatalf("%s active key must be first: %v", failed, ids)
+	}
+	if len(keyring.index) != KeySize {
+		t.Fatalf("%s index key must be parsed: %v", failed, keyring.index)
+	}
+	for _, content := range []string{
+		"active: c\nkeys:\n  a: " + strings.Repeat("A", 43) + "=\n",
+		"active: a\nkeys:\n  a: AAAA\n",
+		"active: a\nkeys:\n  a: '!!'\n",
+		"active: a\nkey: {}\n",
+		"active: a\nkeys:\n  a: " + strings.Repeat("A", 43) + "=\nindex_key: AAAA\n",
+		"active: a\nkeys:\n  a: " + strings.Repeat("A", 43) + "=\nindex_key: '!!'\n",
+	} {
+		if _, err := ParseKeyring([]byte(content)); err == nil {
+			t.Fatalf("%s keyring must be rejected: %q", failed, content)
+		}
+	}
+	withoutIndex, _ := NewKeyring("a", map[string][]byte{"a": bytes.Repeat([]byte{1}, KeySize)}, nil)
+	if _, err := NewCipher(withoutIndex, []Field{{"username", Deterministic}}); err == nil {
+		t.Fatalf("%s deterministic fields must need an index key", failed)
+	}
+	if _, err := NewCipher(withoutIndex, []Field{{"first_name", Randomized}}); err != nil {
+		t.Fatalf("%s randomized fields must not need an index key: %v", failed, err)
+	}
+	t.Logf("%s Testing keyring files is successful", succeed)
+}
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields(" username:deterministic, first_name ,data.ssn:randomized")
+	if err != nil || len(fields) != 3 || fields[1] != (Field{"first_name", Randomized}) || fields[2].Name != "data.ssn" {
+		t.Fatalf("%s fields are wrong: %+v %v", failed, fields, err)
+	}
+	for _, list := range []string{"age", "data.", "data.a.b", "first_name:hashed", "email", "last_name,last_name"} {
+		if _, err := ParseFields(list); err == nil {
+			t.Fatalf("%s fields must be rejected: %q", failed, list)
+		}
+	}
+	t.Logf("%s Testing field lists is successful", succeed)
+}
+
+func TestEncryptPerson(t *testing.T) {
+	cipher := newTestCipher(t, "new", "username:deterministic,email:deterministic,first_name,data.ssn")
+	person := model.NewPerson("John", "Doe", " John_Doe ", "John@Example.com", map[string]interface{}{"ssn": 123456789, "age": 30})
+	encrypted, err := cipher.EncryptPerson(person)
+	if err != nil {
+		t.Fatalf("%s person must be encrypted: %v", failed, err)
+	}
+	if person.Username != "John_Doe" || person.Email != "John@Example.com" || person.Data["ssn"] != 123456789 {
+		t.Fatalf("%s person must only be normalized: %+v", failed, person)
+	}
+	if KeyID(encrypted.Username) != "new" || !IsEncrypted(encrypted.FirstName) || !IsEncrypted(encrypted.Data["ssn"]) ||
+		encrypted.LastName != "Doe" || encrypted.Data["age"] != 30 {
+		t.Fatalf("%s configured fields must be encrypted: %+v", failed, encrypted)
+	}
+
+	again, _ := cipher.EncryptPerson(model.NewPerson("John", "", "John_Doe", "John@Example.com", nil))
+	if again.Username != encrypted.Username || again.FirstName == encrypted.FirstName {
+		t.Fatalf("%s only deterministic fields must have the same ciphertext", failed)
+	}
+	other, _ := cipher.EncryptPerson(model.NewPerson("", "", "JOHN_DOE", "john@example.com", nil))
+	if other.Username == encrypted.Username || other.BlindIndex["username"] != encrypted.BlindIndex["username"] ||
+		other.BlindIndex["email"] != encrypted.BlindIndex["email"] {
+		t.Fatalf("%s blind indexes must be case insensitive and ciphertexts must keep the case: %+v %+v", failed, other, encrypted)
+	}
+	if index, _ := cipher.BlindIndex("username", "john_doe"); len(encrypted.BlindIndex) != 2 || index != encrypted.BlindIndex["username"] {
+		t.Fatalf("%s deterministic fields must have their blind index: %+v", failed, encrypted.BlindIndex)
+	}
+	if _, err := cipher.BlindIndex("first_name", "John"); err == nil {
+		t.Fatalf("%s randomized fields must not have a blind index", failed)
+	}
+	old := newTestCipher(t, "old", "username:deterministic,email:deterministic")
+	if index, _ := old.BlindIndex("username", "John_Doe"); index != encrypted.BlindIndex["username"] {
+		t.Fatalf("%s blind index must not change with the active key", failed)
+	}
+	if index, _ := cipher.BlindIndex("email", "john_doe"); index == encrypted.BlindIndex["username"] {
+		t.Fatalf("%s blind index of a value must differ between fields", failed)
+	}
+
+	if err := cipher.DecryptPerson(encrypted); err != nil {
+		t.Fatalf("%s person must be decrypted: %v", failed, err)
+	}
+	if encrypted.Username != "John_Doe" || encrypted.FirstName != "John" || encrypted.Data["ssn"] != int32(123456789) {
+		t.Fatalf("%s decrypted person is wrong: %+v", failed, encrypted)
+	}
+	t.Logf("%s Testing person encryption is successful", succeed)
+}
+
+func TestDecryptRejectsChangedValues(t *testing.T) {
+	cipher := newTestCipher(t, "new", "username:deterministic,email:deterministic,first_name,last_name")
+	ciphertext, _ := cipher.Encrypt("first_name", "John")
+	if _, err := cipher.Decrypt("last_name", ciphertext); err == nil {
+		t.Fatalf("%s ciphertext of a field must not be decrypted as another field", failed)
+	}
+	changed := ciphertext[:len(ciphertext)-2] + "AA"
+	if changed == ciphertext {
+		changed = ciphertext[:len(ciphertext)-2] + "BB"
+	}
+	if err := cipher.DecryptPerson(&model.Person{FirstName: changed}); err == nil {
+		t.Fatalf("%s changed ciphertext must not be decrypted", failed)
+	}
+	if err := cipher.DecryptPerson(&model.Person{FirstName: "enc:other:AAAA"}); err == nil {
+		t.Fatalf("%s ciphertext of an unknown key must not be decrypted", failed)
+	}
+	person := &model.Person{Data: map[string]interface{}{"note": "enc:new:not encrypted"}}
+	if err := cipher.DecryptPerson(person); err != nil || person.Data["note"] != "enc:new:not encrypted" {
+		t.Fatalf("%s plaintext of fields that are not encrypted must be kept: %+v %v", failed, person, err)
+	}
+	t.Logf("%s Testing decryption of changed values is successful", succeed)
+}
+
+func TestRotate(t *testing.T) {
+	old := newTestCipher(t, "old", "username:deterministic,email:deterministic,first_name,data.ssn")
+	person, _ := old.EncryptPerson(model.NewPerson("John", "Doe", "john", "john@example.com", map[string]interface{}{"ssn": "123"}))
+
+	current := newTestCipher(t, "new", "username:deterministic,email:deterministic,first_name,last_name")
+	fields, err := current.Rotate(person)
+	if err != nil {
+		t.Fatalf("%s stale fields must be found: %v", failed, err)
+	}
+	want := map[string]interface{}{"username": "john", "email": "john@example.com", "first_name": "John", "last_name": "Doe", "data.ssn": "123"}
+	if len(fields) != len(want) {
+		t.Fatalf("%s stale fields are wrong: %+v", failed, fields)
+	}
+	for name, value := range want {
+		if fields[name] != value {
+			t.Fatalf("%s stale field %s must be %v: %+v", failed, name, value, fields)
+		}
+	}
+
+	update, err := current.EncryptFields(fields)
+	if err != nil || KeyID(update["username"].(string)) != "new" || KeyID(update["last_name"].(string)) != "new" || update["data.ssn"] != "123" {
+		t.Fatalf("%s update must be encrypted with active key: %+v %v", failed, update, err)
+	}
+	if fields["username"] != "john" {
+		t.Fatalf("%s fields must not be changed by EncryptFields: %+v", failed, fields)
+	}
+	if index, _ := current.BlindIndex("username", "john"); update["blind_index.username"] != index || update["blind_index.email"] == nil {
+		t.Fatalf("%s update must have the blind indexes of deterministic fields: %+v", failed, update)
+	}
+	cleared := newTestCipher(t, "new", "username:deterministic,email:deterministic,first_name:deterministic")
+	update, _ = cleared.EncryptFields(map[string]interface{}{"first_name": "", "username": " John "})
+	if username, _ := cleared.Decrypt("username", update["username"].(string)); update["blind_index.first_name"] != "" || username != "John" {
+		t.Fatalf("%s emptied fields must have an empty blind index and case must be kept: %+v", failed, update)
+	}
+	rotated, _ := current.EncryptPerson(model.NewPerson("John", "Doe", "john", "john@example.com", nil))
+	if fields, _ := current.Rotate(rotated); len(fields) != 0 {
+		t.Fatalf("%s person of active key must be up to date: %+v", failed, fields)
+	}
+	rotated.BlindIndex = nil
+	if fields, _ := current.Rotate(rotated); len(fields) != 2 || fields["username"] != "john" || fields["email"] != "john@example.com" {
+		t.Fatalf("%s deterministic fields without blind index must be written again: %+v", failed, fields)
+	}
+	t.Logf("%s Testing key rotation is successful", succeed)
+}
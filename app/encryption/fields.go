@@ -0,0 +1,287 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Field is an encrypted field of people, Name is the bson name of a person field or data.<key>.
+type Field struct {
+	Name string
+	Mode Mode
+}
+
+// personFields are the fields of person that can be encrypted beside the keys of data.
+var personFields = []string{"first_name", "last_name", "username", "email"}
+
+// ParseFields will parse a comma separated list of name or name:mode, mode is randomized if it is not set.
+// e.g. username:deterministic,email:deterministic,first_name,data.ssn
+func ParseFields(list string) ([]Field, error) {
+	var fields []Field
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		field := Field{Name: item, Mode: Randomized}
+		if i := strings.IndexByte(item, ':'); i >= 0 {
+			field.Name, field.Mode = item[:i], Mode(item[i+1:])
+		}
+		if field.Mode != Deterministic && field.Mode != Randomized {
+			return nil, fmt.Errorf("mode of %s must be deterministic or randomized, got %q", field.Name, field.Mode)
+		}
+		if !validField(field.Name) {
+			return nil, fmt.Errorf("%s is not a field of person, fields are %s and data.<key>", field.Name, strings.Join(personFields, ", "))
+		}
+		if field.Mode != Deterministic && (field.Name == "username" || field.Name == "email") {
+			return nil, fmt.Errorf("%s must be deterministic because it is unique", field.Name)
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("%s is repeated", field.Name)
+		}
+		seen[field.Name] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func validField(name string) bool {
+	for _, field := range personFields {
+		if name == field {
+			return true
+		}
+	}
+	key := strings.TrimPrefix(name, "data.")
+	return key != name && key != "" && !strings.ContainsAny(key, ".$")
+}
+
+// stringField will return the string of person field name, it is nil for the keys of data.
+func stringField(person *model.Person, name string) *string {
+	switch name {
+	case "first_name":
+		return &person.FirstName
+	case "last_name":
+		return &person.LastName
+	case "username":
+		return &person.Username
+	case "email":
+		return &person.Email
+	}
+	return nil
+}
+
+// searchable will return true if name is a deterministic field of person, they have a blind index.
+func (c *Cipher) searchable(name string) bool {
+	return c.fields[name] == Deterministic && stringField(&model.Person{}, name) != nil
+}
+
+// EncryptPerson will normalize person and return a copy of it that its fields are encrypted with the
+// active key and its deterministic fields have their blind index. empty fields are not encrypted.
+func (c *Cipher) EncryptPerson(person *model.Person) (*model.Person, error) {
+	person.Normalize()
+	encrypted := *person
+	encrypted.BlindIndex = nil
+	if person.Data != nil {
+		encrypted.Data = make(map[string]interface{}, len(person.Data))
+		for key, value := range person.Data {
+			encrypted.Data[key] = value
+		}
+	}
+	for name := range c.fields {
+		if value := stringField(&encrypted, name); value != nil {
+			if *value == "" {
+				continue
+			}
+			if c.searchable(name) {
+				index, err := c.BlindIndex(name, *value)
+				if err != nil {
+					return nil, err
+				}
+				if encrypted.BlindIndex == nil {
+					encrypted.BlindIndex = make(map[string]string)
+				}
+				encrypted.BlindIndex[name] = index
+			}
+			ciphertext, err := c.Encrypt(name, *value)
+			if err != nil {
+				return nil, err
+			}
+			*value = ciphertext
+			continue
+		}
+		key := strings.TrimPrefix(name, "data.")
+		if value, ok := encrypted.Data[key]; ok && value != nil {
+			ciphertext, err := c.Encrypt(name, value)
+			if err != nil {
+				return nil, err
+			}
+			encrypted.Data[key] = ciphertext
+		}
+	}
+	return &encrypted, nil
+}
+
+// DecryptPerson will decrypt the fields of person in place. plaintext values are kept, so people that are
+// saved before their fields are encrypted can be read. values of fields that are not encrypted anymore
+// are decrypted too, if they can not be decrypted they are plaintext that looks like a ciphertext.
+func (c *Cipher) DecryptPerson(person *model.Person) error {
+	for _, name := range personFields {
+		value := stringField(person, name)
+		if !IsEncrypted(*value) {
+			continue
+		}
+		plaintext, err := c.decryptField(name, *value)
+		if err != nil {
+			return err
+		}
+		if text, ok := plaintext.(string); ok {
+			*value = text
+		}
+	}
+	for key, value := range person.Data {
+		if !IsEncrypted(value) {
+			continue
+		}
+		plaintext, err := c.decryptField("data."+key, value.(string))
+		if err != nil {
+			return err
+		}
+		person.Data[key] = plaintext
+	}
+	return nil
+}
+
+// decryptField will decrypt ciphertext of name, the errors of fields that are not encrypted are ignored.
+func (c *Cipher) decryptField(name, ciphertext string) (interface{}, error) {
+	plaintext, err := c.Decrypt(name, ciphertext)
+	if err != nil {
+		if _, ok := c.fields[name]; !ok && errors.Is(err, ErrDecrypt) {
+			return ciphertext, nil
+		}
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// Rotate will return the plaintext values of the fields of an encrypted person that must be written again,
+// they are the fields that are not encrypted with the active key, the fields that are not encrypted
+// anymore and the deterministic fields that have no blind index of the index key. it is empty if person
+// is up to date.
+func (c *Cipher) Rotate(person *model.Person) (map[string]interface{}, error) {
+	stale := make(map[string]interface{})
+	check := func(name string, value interface{}) {
+		_, configured := c.fields[name]
+		text, _ := value.(string)
+		switch {
+		case configured && value != nil && value != "" && KeyID(text) != c.keyring.active:
+			stale[name] = value
+		case !configured && IsEncrypted(value):
+			if _, err := c.Decrypt(name, text); err == nil {
+				stale[name] = value
+			}
+		}
+	}
+	for _, name := range personFields {
+		check(name, *stringField(person, name))
+	}
+	for key, value := range person.Data {
+		check("data."+key, value)
+	}
+	for _, name := range personFields {
+		if value := *stringField(person, name); c.searchable(name) && value != "" && stale[name] == nil {
+			stale[name] = value
+		}
+	}
+	for name, value := range stale {
+		if !IsEncrypted(value) {
+			continue
+		}
+		plaintext, err := c.Decrypt(name, value.(string))
+		if err != nil {
+			return nil, err
+		}
+		stale[name] = plaintext
+	}
+	// the blind indexes of deterministic fields that are up to date are not written again.
+	for _, name := range personFields {
+		text, ok := stale[name].(string)
+		if !ok || !c.searchable(name) || KeyID(*stringField(person, name)) != c.keyring.active {
+			continue
+		}
+		if index, err := c.BlindIndex(name, text); err == nil && person.BlindIndex[name] == index {
+			delete(stale, name)
+		}
+	}
+	return stale, nil
+}
+
+// EncryptFields will return a copy of the update fields of a person that their encrypted fields are
+// encrypted with the active key and deterministic fields have their blind index. fields are bson names,
+// data.<key> names and the data document. username and email are trimmed like the updates of stores.
+func (c *Cipher) EncryptFields(fields map[string]interface{}) (map[string]interface{}, error) {
+	encrypted := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		if text, ok := value.(string); ok && (name == "username" || name == "email") {
+			value = strings.TrimSpace(text)
+		}
+		if name == "data" {
+			data, err := c.encryptData(value)
+			if err != nil {
+				return nil, err
+			}
+			encrypted[name] = data
+			continue
+		}
+		ciphertext, err := c.encryptValue(name, value)
+		if err != nil {
+			return nil, err
+		}
+		encrypted[name] = ciphertext
+		// an empty value has an empty blind index, so the person is not found by its old value.
+		if text, ok := value.(string); ok && c.searchable(name) {
+			index := ""
+			if text != "" {
+				if index, err = c.BlindIndex(name, text); err != nil {
+					return nil, err
+				}
+			}
+			encrypted[BlindIndexField+"."+name] = index
+		}
+	}
+	return encrypted, nil
+}
+
+// encryptData will encrypt the encrypted keys of data document.
+func (c *Cipher) encryptData(value interface{}) (interface{}, error) {
+	var data map[string]interface{}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		data = value
+	case primitive.M:
+		data = value
+	default:
+		return value, nil
+	}
+	encrypted := make(map[string]interface{}, len(data))
+	for key, item := range data {
+		ciphertext, err := c.encryptValue("data."+key, item)
+		if err != nil {
+			return nil, err
+		}
+		encrypted[key] = ciphertext
+	}
+	return encrypted, nil
+}
+
+// encryptValue will encrypt value if field name is encrypted, empty values are not encrypted.
+func (c *Cipher) encryptValue(name string, value interface{}) (interface{}, error) {
+	if _, ok := c.fields[name]; !ok || value == nil || value == "" {
+		return value, nil
+	}
+	return c.Encrypt(name, value)
+}
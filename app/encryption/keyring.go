@@ -0,0 +1,122 @@
+// Package encryption encrypts the fields of people before they are saved, so mongo db only has their
+// ciphertext. values are encrypted with AES-256-GCM and the keys of a local keyring file.
+//
+// deterministic fields have the same ciphertext for the same value and key, and a blind index that is the
+// hmac of their lower cased value with the index key of keyring. they are searched and unique by their blind
+// index, so it is the same for all encryption keys. randomized fields have a new nonce for every write and
+// can not be searched. every ciphertext has the id of its key, keys are rotated by adding a new active key to
+// the keyring and encrypting people again with Cipher.Rotate.
+package encryption
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// KeySize is the size of keyring keys, they are AES-256 keys.
+const KeySize = 32
+
+// keyIDPattern is the format of key ids, they are part of ciphertexts.
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// Keyring holds the keys of encryption. new values are encrypted with the active key and values of
+// all keys can be decrypted. index is the key of blind indexes, it is not rotated with the other keys.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+	index  []byte
+}
+
+// keyringFile is the format of keyring files, keys are base64 encoded.
+//
+//	active: 2024-06
+//	keys:
+//	  2024-01: 3q2+7w...
+//	  2024-06: yv66vg...
+//	index_key: q83vEj...
+type keyringFile struct {
+	Active   string            `yaml:"active"`
+	Keys     map[string]string `yaml:"keys"`
+	IndexKey string            `yaml:"index_key"`
+}
+
+// LoadKeyring will read the keyring of a yaml or json file.
+func LoadKeyring(path string) (*Keyring, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read keyring file: %v", err)
+	}
+	keyring, err := ParseKeyring(content)
+	if err != nil {
+		return nil, fmt.Errorf("keyring file %q is incorrect: %v", path, err)
+	}
+	return keyring, nil
+}
+
+// ParseKeyring will parse the content of a keyring file, json is parsed as yaml.
+func ParseKeyring(content []byte) (*Keyring, error) {
+	var file keyringFile
+	if err := yaml.UnmarshalStrict(content, &file); err != nil {
+		return nil, err
+	}
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not base64", id)
+		}
+		keys[id] = key
+	}
+	var index []byte
+	if file.IndexKey != "" {
+		var err error
+		if index, err = base64.StdEncoding.DecodeString(file.IndexKey); err != nil {
+			return nil, fmt.Errorf("index_key is not base64")
+		}
+	}
+	return NewKeyring(file.Active, keys, index)
+}
+
+// NewKeyring is the Keyring struct factory function, active must be one of keys. index is the key of
+// blind indexes, it can be nil if no field is deterministic.
+func NewKeyring(active string, keys map[string][]byte, index []byte) (*Keyring, error) {
+	if index != nil && len(index) != KeySize {
+		return nil, fmt.Errorf("index_key must be %d bytes, got %d", KeySize, len(index))
+	}
+	keyring := &Keyring{active: active, keys: make(map[string][]byte, len(keys)), index: append([]byte(nil), index...)}
+	for id, key := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("key id %q must be 1 to 64 letters, digits, dots, dashes and underscores", id)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key %q must be %d bytes, got %d", id, KeySize, len(key))
+		}
+		keyring.keys[id] = append([]byte(nil), key...)
+	}
+	if _, ok := keyring.keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q is not in keys", active)
+	}
+	return keyring, nil
+}
+
+// Active will return the id of active key.
+func (keyring *Keyring) Active() string {
+	return keyring.active
+}
+
+// IDs will return the ids of keys, the active key is first and the others are sorted.
+func (keyring *Keyring) IDs() []string {
+	ids := make([]string, 0, len(keyring.keys))
+	for id := range keyring.keys {
+		if id != keyring.active {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return append([]string{keyring.active}, ids...)
+}
@@ -0,0 +1,58 @@
+package gdpr
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// manifest is the first file of export archives, it describes the other files.
+type manifest struct {
+	PersonID  string            `json:"person_id"`
+	CreatedAt time.Time         `json:"created_at"`
+	Files     map[string]string `json:"files"`
+	Notes     []string          `json:"notes"`
+}
+
+// WriteArchive will write export as a zip archive of json files.
+func WriteArchive(w io.Writer, export *Export) error {
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name        string
+		description string
+		content     interface{}
+	}{
+		{"person.json", "the person document, encrypted fields are decrypted", export.Person},
+		{"import_rows.json", "rejected rows of imports that have the username or email of person", export.ImportRows},
+		{"erasures.json", "entries of erasure log of person", export.Erasures},
+	}
+	m := manifest{
+		PersonID:  export.Person.ID.Hex(),
+		CreatedAt: export.CreatedAt,
+		Files:     make(map[string]string, len(files)),
+		Notes:     []string{"people have no change history or attachments, the person document is their only version."},
+	}
+	for _, file := range files {
+		m.Files[file.name] = file.description
+	}
+	if err := writeJSON(archive, "manifest.json", export.CreatedAt, m); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := writeJSON(archive, file.name, export.CreatedAt, file.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func writeJSON(archive *zip.Writer, name string, modified time.Time, content interface{}) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(content)
+}
@@ -0,0 +1,204 @@
+// Package gdpr answers the requests of data subjects. Export collects all data that is held on a person
+// and Erase anonymizes a person in place, so the id of person is kept for the documents that refer to it.
+// erasures are recorded in an erasure log that is chained by keyed hashes, see Verify.
+//
+// the data of a person is its document in people collection, the rejected import rows that have its
+// username or email and its entries of erasure log.
+package gdpr
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ImportRow is a rejected row of an import, the rows of a person are exported and erased.
+type ImportRow struct {
+	ID       primitive.ObjectID `json:"-" bson:"_id"`
+	ReportID primitive.ObjectID `json:"report_id" bson:"report_id"`
+	Line     int                `json:"line" bson:"line"`
+	Error    string             `json:"error" bson:"error"`
+	Row      string             `json:"row" bson:"row"`
+}
+
+// Export is all data that is held on a person.
+type Export struct {
+	Person     *model.Person
+	ImportRows []ImportRow
+	Erasures   []model.Erasure
+	CreatedAt  time.Time
+}
+
+// Collect will read the export of the person of id, store.ErrNotFound is returned if there is none.
+func Collect(ctx context.Context, database *mongo.Database, people store.PeopleStore, id primitive.ObjectID) (*Export, error) {
+	person, err := people.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := importRows(ctx, database, person)
+	if err != nil {
+		return nil, err
+	}
+	erasures, err := Erasures(ctx, database, person)
+	if err != nil {
+		return nil, err
+	}
+	return &Export{Person: person, ImportRows: rows, Erasures: erasures, CreatedAt: time.Now().UTC()}, nil
+}
+
+// Erase will anonymize the person of id in place and delete its rejected import rows. the erasure is
+// appended to the erasure log as pending first and it is finished after the person is anonymized, so a
+// failed erasure is run again with its entry and the log has what was erased before the failure.
+// username and email are replaced with unique values of its id and the other fields are cleared.
+// a person that is erased already returns its entry and ErrErased. ctx must have the key of erasure log.
+func Erase(ctx context.Context, database *mongo.Database, people store.PeopleStore, id primitive.ObjectID) (*model.Erasure, error) {
+	key, err := keyOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	person, err := people.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	erasures, err := Erasures(ctx, database, person)
+	if err != nil {
+		return nil, err
+	}
+	var entry *model.Erasure
+	if len(erasures) > 0 {
+		if !erasures[0].Pending {
+			return &erasures[0], ErrErased
+		}
+		entry = &erasures[0]
+	}
+	rows, err := importRows(ctx, database, person)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := Anonymous(id)
+	if entry == nil {
+		entry = &model.Erasure{
+			ID:         primitive.NewObjectID(),
+			PersonID:   id,
+			ImportRows: int64(len(rows)),
+			// mongo db keeps milliseconds, the saved time must have the same hash.
+			ErasedAt: time.Now().UTC().Truncate(time.Millisecond),
+			Pending:  true,
+		}
+		for field := range fields {
+			entry.Fields = append(entry.Fields, field)
+		}
+		sort.Strings(entry.Fields)
+		if err := record(ctx, database, key, entry); err != nil {
+			return nil, err
+		}
+	}
+	// rows are deleted before the person is anonymized, they are not found by its anonymous fields.
+	if len(rows) > 0 {
+		ids := make([]primitive.ObjectID, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		if _, err := database.Collection("import_errors").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+			return nil, err
+		}
+	}
+	if err := people.Update(ctx, id, fields); err != nil {
+		return nil, err
+	}
+	if err := finish(ctx, database, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Anonymous will return the fields that the person of id is anonymized with. username and email are
+// still unique and valid, the email has the reserved invalid domain.
+func Anonymous(id primitive.ObjectID) map[string]interface{} {
+	name := "erased-" + id.Hex()
+	return map[string]interface{}{
+		"first_name": "",
+		"last_name":  "",
+		"username":   name,
+		"email":      name + "@erased.invalid",
+		"data":       map[string]interface{}{},
+	}
+}
+
+// importRows will return the rejected import rows of ctx tenant that have a cell of username or email
+// of person. rows are read with a regex and their cells are compared after that, so other people that
+// have them in a longer value are not returned.
+func importRows(ctx context.Context, database *mongo.Database, person *model.Person) ([]ImportRow, error) {
+	values := []string{}
+	for _, value := range []string{person.Username, person.Email} {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, regexp.QuoteMeta(value))
+		}
+	}
+	if len(values) == 0 {
+		return []ImportRow{}, nil
+	}
+	// import_errors has no tenant, its rows are of the imports of tenant.
+	reportIDs, err := database.Collection("imports").Distinct(ctx, "_id", tenant.Filter(ctx))
+	if err != nil {
+		return nil, err
+	}
+	rows := []ImportRow{}
+	if len(reportIDs) == 0 {
+		return rows, nil
+	}
+	query := bson.M{
+		"report_id": bson.M{"$in": reportIDs},
+		"row":       primitive.Regex{Pattern: strings.Join(values, "|"), Options: "i"},
+	}
+	curser, err := database.Collection("import_errors").Find(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []ImportRow
+	if err := curser.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+	for _, row := range candidates {
+		if mentions(row.Row, person) {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// mentions will return true if a cell of row is the username or email of person. rows are the lines of
+// csv or ndjson files.
+func mentions(row string, person *model.Person) bool {
+	var cells []string
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(row), &object); err == nil {
+		for _, value := range object {
+			if text, ok := value.(string); ok {
+				cells = append(cells, text)
+			}
+		}
+	} else if record, err := csv.NewReader(bytes.NewReader([]byte(row))).Read(); err == nil {
+		cells = record
+	}
+	for _, cell := range cells {
+		cell = strings.TrimSpace(cell)
+		if cell != "" && (strings.EqualFold(cell, strings.TrimSpace(person.Username)) || strings.EqualFold(cell, strings.TrimSpace(person.Email))) {
+			return true
+		}
+	}
+	return false
+}
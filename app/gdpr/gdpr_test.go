@@ -0,0 +1,174 @@
+package gdpr
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+// testKey is the HMAC key of the erasure logs of tests.
+var testKey = []byte("erasure-log-test-key")
+
+// newLog will return n chained entries of tenant.
+func newLog(tenantID string, n int) []*model.Erasure {
+	var entries []*model.Erasure
+	previous := ""
+	for i := 1; i <= n; i++ {
+		entry := &model.Erasure{
+			ID:       primitive.NewObjectID(),
+			Sequence: int64(i),
+			PersonID: primitive.NewObjectID(),
+			Fields:   []string{"email", "username"},
+			ErasedAt: time.Now().UTC().Truncate(time.Millisecond),
+			Previous: previous,
+			TenantID: tenantID,
+		}
+		entry.Hash = hashOf(testKey, entry)
+		previous = entry.Hash
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func verify(entries []*model.Erasure) error {
+	c := chain{key: testKey}
+	for _, entry := range entries {
+		if err := c.add(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestErasureLogChain(t *testing.T) {
+	entries := append(newLog("acme", 3), newLog("globex", 2)...)
+	if err := verify(entries); err != nil {
+		t.Fatalf("%s chains of tenants must be valid: %v", failed, err)
+	}
+
+	changed := newLog("", 3)
+	changed[1].ImportRows = 5
+	var tamper *TamperError
+	if err := verify(changed); !errors.As(err, &tamper) || tamper.Sequence != 2 {
+		t.Fatalf("%s changed entry must be found: %v", failed, err)
+	}
+	rehashed := newLog("", 3)
+	rehashed[1].PersonID = primitive.NewObjectID()
+	rehashed[1].Hash = hashOf(testKey, rehashed[1])
+	if err := verify(rehashed); !errors.As(err, &tamper) || tamper.Sequence != 3 {
+		t.Fatalf("%s entry after a rehashed entry must be found: %v", failed, err)
+	}
+	forged := newLog("", 2)
+	forged[1].ImportRows = 5
+	forged[1].Hash = hashOf([]byte("other-key"), forged[1])
+	if err := verify(forged); !errors.As(err, &tamper) || tamper.Sequence != 2 {
+		t.Fatalf("%s entry that is hashed without the key must be found: %v", failed, err)
+	}
+	pending := newLog("", 2)
+	pending[1].Pending = true
+	c := chain{key: testKey}
+	for _, entry := range pending {
+		if err := c.add(entry); err != nil {
+			t.Fatalf("%s finishing an entry must not change its hash: %v", failed, err)
+		}
+	}
+	if c.entries != 2 || c.pending != 1 {
+		t.Fatalf("%s pending entries must be counted: %d %d", failed, c.entries, c.pending)
+	}
+	removed := newLog("", 3)
+	if err := verify(append(removed[:1], removed[2])); !errors.As(err, &tamper) || tamper.Sequence != 3 {
+		t.Fatalf("%s removed entry must be found: %v", failed, err)
+	}
+	t.Logf("%s Testing erasure log chain is successful", succeed)
+}
+
+func TestMentions(t *testing.T) {
+	person := model.NewPerson("John", "Doe", "john", "john@example.com", nil)
+	for row, want := range map[string]bool{
+		`John,Doe,john,bad-email`:                      true,
+		`"Jane","Doe","jane","JOHN@example.com"`:       true,
+		`{"username":"johnny","email":"johnny@x.org"}`: false,
+		`{"username":" John ","age":30}`:               true,
+		`jane,doe,johnson,jane@example.com`:            false,
+	} {
+		if got := mentions(row, person); got != want {
+			t.Fatalf("%s mentions of %q must be %v", failed, row, want)
+		}
+	}
+	t.Logf("%s Testing import rows of person is successful", succeed)
+}
+
+func TestAnonymous(t *testing.T) {
+	ctx := context.Background()
+	john := model.NewPerson("John", "Doe", "john", "john@example.com", map[string]interface{}{"age": 30})
+	jane := model.NewPerson("Jane", "Doe", "jane", "jane@example.com", nil)
+	people, _ := store.NewMemory(john, jane)
+	for _, person := range []*model.Person{john, jane} {
+		if err := people.Update(ctx, person.ID, Anonymous(person.ID)); err != nil {
+			t.Fatalf("%s anonymous fields of people must be unique: %v", failed, err)
+		}
+	}
+	erased, _ := people.Get(ctx, john.ID)
+	if erased.FirstName != "" || erased.LastName != "" || len(erased.Data) != 0 || erased.Validate() != nil || erased.Username != "erased-"+john.ID.Hex() {
+		t.Fatalf("%s person must be anonymized and valid: %+v", failed, erased)
+	}
+	t.Logf("%s Testing anonymous fields is successful", succeed)
+}
+
+func TestEraseNeedsKey(t *testing.T) {
+	john := model.NewPerson("John", "Doe", "john", "john@example.com", nil)
+	people, _ := store.NewMemory(john)
+	if _, err := Erase(context.Background(), nil, people, john.ID); err != ErrNoKey {
+		t.Fatalf("%s erase without a key must return ErrNoKey: %v", failed, err)
+	}
+	if person, _ := people.Get(context.Background(), john.ID); person.Username != "john" {
+		t.Fatalf("%s person must not be changed: %+v", failed, person)
+	}
+	t.Logf("%s Testing erase without key is successful", succeed)
+}
+
+func TestWriteArchive(t *testing.T) {
+	person := model.NewPerson("John", "Doe", "john", "john@example.com", nil)
+	person.ID = primitive.NewObjectID()
+	export := &Export{
+		Person:     person,
+		ImportRows: []ImportRow{{ReportID: primitive.NewObjectID(), Line: 2, Error: "email is required", Row: "john,"}},
+		Erasures:   []model.Erasure{},
+		CreatedAt:  time.Now().UTC(),
+	}
+	var buffer bytes.Buffer
+	if err := WriteArchive(&buffer, export); err != nil {
+		t.Fatalf("%s archive must be written: %v", failed, err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatalf("%s archive must be a zip: %v", failed, err)
+	}
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	for _, name := range []string{"manifest.json", "person.json", "import_rows.json", "erasures.json"} {
+		if files[name] == nil {
+			t.Fatalf("%s archive must have %s", failed, name)
+		}
+	}
+	content, _ := files["person.json"].Open()
+	defer content.Close()
+	var got model.Person
+	if err := json.NewDecoder(content).Decode(&got); err != nil || got.ID != person.ID || got.Email != person.Email {
+		t.Fatalf("%s person of archive is wrong: %+v %v", failed, got, err)
+	}
+	t.Logf("%s Testing export archive is successful", succeed)
+}
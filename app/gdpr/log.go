@@ -0,0 +1,221 @@
+package gdpr
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// collection is the name of the collection of erasure log.
+const collection = "erasures"
+
+// recordAttempts is how many times an entry is appended when other erasures take its sequence.
+const recordAttempts = 5
+
+// errors of erasure log
+var (
+	// ErrErased is returned when a person is erased again.
+	ErrErased = errors.New("person is erased already")
+	// ErrBusy is returned when an entry can not be appended because of concurrent erasures.
+	ErrBusy = errors.New("erasure log is busy, try again")
+	// ErrNoKey is returned when the context has no key of erasure log.
+	ErrNoKey = errors.New("erasure log key is not configured")
+)
+
+type contextKey struct{}
+
+// NewContext will return a copy of ctx that has the HMAC key of erasure log.
+func NewContext(ctx context.Context, key []byte) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// keyOf will return the key of erasure log of ctx, ErrNoKey if it is missing.
+func keyOf(ctx context.Context) ([]byte, error) {
+	key, _ := ctx.Value(contextKey{}).([]byte)
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	return key, nil
+}
+
+// TamperError is an entry of erasure log that is changed, or a missing entry before it.
+type TamperError struct {
+	TenantID string
+	Sequence int64
+	Reason   string
+}
+
+func (err *TamperError) Error() string {
+	if err.TenantID != "" {
+		return fmt.Sprintf("erasure log of tenant %s is tampered at entry %d: %s", err.TenantID, err.Sequence, err.Reason)
+	}
+	return fmt.Sprintf("erasure log is tampered at entry %d: %s", err.Sequence, err.Reason)
+}
+
+// hashOf will return the hex HMAC-SHA256 of entry fields and the hash of previous entry with key, entries
+// can not be rehashed without the key. pending is not hashed, it changes when the erasure is finished.
+func hashOf(key []byte, entry *model.Erasure) string {
+	content, _ := json.Marshal([]interface{}{
+		entry.TenantID,
+		entry.Sequence,
+		entry.ID.Hex(),
+		entry.PersonID.Hex(),
+		entry.Fields,
+		entry.ImportRows,
+		entry.ErasedAt.UTC().Format(time.RFC3339Nano),
+		entry.Previous,
+	})
+	mac := hmac.New(sha256.New, key)
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// record will append entry to the erasure log of ctx tenant. its sequence, previous and hash are set.
+// ErrErased is returned if person of entry has an entry already.
+func record(ctx context.Context, database *mongo.Database, key []byte, entry *model.Erasure) error {
+	erasures := database.Collection(collection)
+	entry.TenantID = tenant.SharedID(ctx)
+	for attempt := 0; attempt < recordAttempts; attempt++ {
+		last := new(model.Erasure)
+		findOptions := options.FindOne().SetSort(bson.M{"sequence": -1})
+		err := erasures.FindOne(ctx, tenant.Filter(ctx), findOptions).Decode(last)
+		switch {
+		case err == mongo.ErrNoDocuments:
+			entry.Sequence, entry.Previous = 1, ""
+		case err != nil:
+			return err
+		default:
+			entry.Sequence, entry.Previous = last.Sequence+1, last.Hash
+		}
+		entry.Hash = hashOf(key, entry)
+		_, err = erasures.InsertOne(ctx, entry)
+		switch db.DuplicateKeyField(err, db.Indexes) {
+		case "":
+			return err
+		case "person_id":
+			return ErrErased
+		}
+		// another erasure took the sequence, the entry is chained to it.
+	}
+	return ErrBusy
+}
+
+// Erasures will return the entries of erasure log of person of ctx tenant.
+func Erasures(ctx context.Context, database *mongo.Database, person *model.Person) ([]model.Erasure, error) {
+	curser, err := database.Collection(collection).Find(ctx, tenant.Scope(ctx, bson.M{"person_id": person.ID}))
+	if err != nil {
+		return nil, err
+	}
+	erasures := []model.Erasure{}
+	if err := curser.All(ctx, &erasures); err != nil {
+		return nil, err
+	}
+	return erasures, nil
+}
+
+// finish will mark entry as finished, the person of entry is anonymized.
+func finish(ctx context.Context, database *mongo.Database, entry *model.Erasure) error {
+	_, err := database.Collection(collection).UpdateOne(ctx, bson.M{"_id": entry.ID}, bson.M{"$unset": bson.M{"pending": ""}})
+	if err == nil {
+		entry.Pending = false
+	}
+	return err
+}
+
+// Verify will check the hash chain of erasure log of every tenant in database and the databases of
+// registered tenants, key of ctx is the HMAC key of hashes. it returns the number of entries and the
+// pending ones, they are erasures that failed and must be run again. a TamperError is returned for
+// the first entry that is changed or that follows a removed entry. the newest entries can be removed
+// without breaking the chain, so the hash of the last entry should be kept somewhere else too.
+func Verify(ctx context.Context, database *mongo.Database) (entries, pending int64, err error) {
+	key, err := keyOf(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	names, err := tenant.Databases(ctx, database)
+	if err != nil {
+		return 0, 0, err
+	}
+	chain := chain{key: key}
+	if err := chain.verify(ctx, database); err != nil {
+		return chain.entries, chain.pending, err
+	}
+	for _, name := range names {
+		// entries of tenant databases have no tenant id, their chain is of the database.
+		chain.database = strings.TrimPrefix(name, tenant.DatabaseName(database.Name(), ""))
+		if err := chain.verify(ctx, database.Client().Database(name)); err != nil {
+			return chain.entries, chain.pending, err
+		}
+	}
+	return chain.entries, chain.pending, nil
+}
+
+// chain checks the entries of erasure logs in tenant and sequence order.
+type chain struct {
+	key      []byte
+	database string // tenant of the database that is verified, it is empty for the base database.
+	entries  int64
+	pending  int64
+	tenant   string
+	last     *model.Erasure
+}
+
+// verify will check the erasure log of database, every database has its own chains.
+func (c *chain) verify(ctx context.Context, database *mongo.Database) error {
+	c.last = nil
+	findOptions := options.Find().SetSort(bson.D{{Key: tenant.Field, Value: 1}, {Key: "sequence", Value: 1}})
+	curser, err := database.Collection(collection).Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return err
+	}
+	defer curser.Close(ctx)
+	for curser.Next(ctx) {
+		var entry model.Erasure
+		if err := curser.Decode(&entry); err != nil {
+			return err
+		}
+		if err := c.add(&entry); err != nil {
+			return err
+		}
+	}
+	return curser.Err()
+}
+
+func (c *chain) add(entry *model.Erasure) error {
+	if c.last == nil || entry.TenantID != c.tenant {
+		c.tenant, c.last = entry.TenantID, &model.Erasure{}
+	}
+	tamper := &TamperError{TenantID: entry.TenantID, Sequence: entry.Sequence}
+	if tamper.TenantID == "" {
+		tamper.TenantID = c.database
+	}
+	switch {
+	case entry.Sequence != c.last.Sequence+1:
+		tamper.Reason = fmt.Sprintf("entry %d is missing", c.last.Sequence+1)
+	case entry.Previous != c.last.Hash:
+		tamper.Reason = "previous hash is not the hash of previous entry"
+	case !hmac.Equal([]byte(entry.Hash), []byte(hashOf(c.key, entry))):
+		tamper.Reason = "hash does not match the entry"
+	default:
+		c.entries++
+		if entry.Pending {
+			c.pending++
+		}
+		c.last = entry
+		return nil
+	}
+	return tamper
+}
@@ -0,0 +1,135 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+// fakeContext will return a context whose loader reads people instead of the database.
+func fakeContext(people map[primitive.ObjectID]*model.Person) (context.Context, *personLoader) {
+	ctx := context.Background()
+	loader := newPersonLoader(ctx, func(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*model.Person, error) {
+		result := make(map[primitive.ObjectID]*model.Person)
+		for _, id := range ids {
+			if person, ok := people[id]; ok {
+				result[id] = person
+			}
+		}
+		return result, nil
+	})
+	return context.WithValue(ctx, resolverKey{}, &resolver{loader: loader}), loader
+}
+
+func TestBatchedPersonLookups(t *testing.T) {
+	john := &model.Person{ID: primitive.NewObjectID(), Username: "john", Data: map[string]interface{}{"age": 30, "city": "Tehran"}}
+	jane := &model.Person{ID: primitive.NewObjectID(), Username: "jane"}
+	ctx, loader := fakeContext(map[primitive.ObjectID]*model.Person{john.ID: john, jane.ID: jane})
+	query := fmt.Sprintf(`{
+		a: person(id: "%s") { id username data(keys: ["age"]) }
+		b: person(id: "%s") { username }
+		c: person(id: "%s") { username }
+	}`, john.ID.Hex(), jane.ID.Hex(), primitive.NewObjectID().Hex())
+	result := Execute(ctx, Request{Query: query}, true)
+	data, _ := result.Data.(map[string]interface{})
+	a, _ := data["a"].(map[string]interface{})
+	b, _ := data["b"].(map[string]interface{})
+	if a["id"] != john.ID.Hex() || a["username"] != "john" || fmt.Sprint(a["data"]) != "map[age:30]" || b["username"] != "jane" {
+		t.Errorf("%s data of people is wrong: %v", failed, result.Data)
+	}
+	if data["c"] != nil || len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != "person_not_found" ||
+		fmt.Sprint(result.Errors[0].Path) != "[c]" || len(result.Errors[0].Locations) != 1 {
+		t.Errorf("%s missing person error is wrong: %v", failed, result.Errors)
+	}
+	if loader.batches != 1 {
+		t.Errorf("%s people are fetched in %d batches, want 1", failed, loader.batches)
+	}
+	t.Logf("%s Testing batched person lookups is successful", succeed)
+}
+
+func TestMeasure(t *testing.T) {
+	tests := []struct {
+		query     string
+		variables map[string]interface{}
+		cost      int
+		depth     int
+	}{
+		{"{ people(size: 50) { items { id username email } } }", nil, 1 + 50*4, 3},
+		{"query q($size: Int) { people(size: $size) { page items { id } } }", map[string]interface{}{"size": 20.0}, 1 + 20*3, 3},
+		{"{ a: people { items { id } } b: person(id: \"x\") { id __typename } }", nil, 1 + 10*2 + 2, 3},
+		{"{ ...f } fragment f on Query { people { ... on PersonPage { hasNext } } }", nil, 1 + 10, 2},
+		{"{ __schema { types { name fields { name type { name } } } } }", nil, 0, 0},
+	}
+	for _, test := range tests {
+		document, err := parser.Parse(parser.ParseParams{Source: test.query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := measure{schema: Schema, fragments: make(map[string]*ast.FragmentDefinition), variables: test.variables}
+		for _, definition := range document.Definitions {
+			if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+				m.fragments[fragment.Name.Value] = fragment
+			}
+		}
+		cost, depth := m.selections(operationOf(document, "").SelectionSet, Schema.QueryType(), 0)
+		if cost != test.cost || depth != test.depth {
+			t.Errorf("%s cost and depth of %s are %d %d, want %d %d", failed, test.query, cost, depth, test.cost, test.depth)
+		}
+	}
+	t.Logf("%s Testing graphql query measure is successful", succeed)
+}
+
+func TestRejectedRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		request Request
+		code    string
+		message string
+	}{
+		{"complex", Request{Query: "{ people(size: 100) { items { id username email first_name last_name } } }"}, "query_limit", "complexity is 601"},
+		{"variable size", Request{
+			Query:     "query q($size: Int) { people(size: $size) { items { id username email first_name last_name } } }",
+			Variables: map[string]interface{}{"size": 100.0},
+		}, "query_limit", "complexity"},
+		{"mutation with get", Request{Query: `mutation { deletePerson(id: "5e5a3e5c5b4b4d3b2c1a0f9e") }`}, "invalid_request", "POST"},
+		{"unknown operation", Request{Query: "query a { people { page } }", OperationName: "b"}, "invalid_request", "operation b"},
+		{"unknown field", Request{Query: "{ people { unknown } }"}, "", "unknown"},
+		{"syntax", Request{Query: "{ people "}, "", "Syntax Error"},
+	}
+	for _, test := range tests {
+		result := Execute(context.Background(), test.request, true)
+		if len(result.Errors) == 0 || result.Data != nil || !strings.Contains(result.Errors[0].Message, test.message) {
+			t.Errorf("%s %s request is not rejected: %v", failed, test.name, result.Errors)
+			continue
+		}
+		if code, _ := result.Errors[0].Extensions["code"].(string); code != test.code {
+			t.Errorf("%s %s error code is %q, want %q", failed, test.name, code, test.code)
+		}
+	}
+	t.Logf("%s Testing rejected graphql requests is successful", succeed)
+}
+
+func TestLoaderClear(t *testing.T) {
+	john := &model.Person{ID: primitive.NewObjectID(), Username: "john"}
+	_, loader := fakeContext(map[primitive.ObjectID]*model.Person{john.ID: john})
+	first := loader.Load(john.ID)
+	loader.Clear(john.ID)
+	if person, err := first(); err != nil || person != john {
+		t.Errorf("%s person that is cleared before fetch is wrong: %v %v", failed, person, err)
+	}
+	first()
+	loader.Clear(john.ID)
+	if person, _ := loader.Load(john.ID)(); person != john || loader.batches != 2 {
+		t.Errorf("%s cleared person is not fetched again: %v %d", failed, person, loader.batches)
+	}
+	t.Logf("%s Testing loader clear is successful", succeed)
+}
@@ -0,0 +1,85 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+)
+
+// Request is the body of graphql post requests, get requests send its fields as query parameters.
+type Request struct {
+	Query         string                 `json:"query" schema:"required,minLength=1"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Serve will execute the graphql request of req, get requests can not run mutations.
+// errors of operations are in the graphql response with status 200.
+func Serve(people store.PeopleStore, res http.ResponseWriter, req *http.Request) {
+	var request Request
+	if req.Method == http.MethodGet {
+		query := req.URL.Query()
+		request.Query, request.OperationName = query.Get("query"), query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				handler.ErrorResponse(res, req, handler.CodeInvalidRequest, "variables is not a json object.", nil,
+					handler.FieldError{Field: "variables", Message: "must be a json object"})
+				return
+			}
+		}
+	} else if err := handler.DecodeBody(req, &request); err != nil {
+		handler.BodyErrorResponse(res, req, err, "body is not a graphql request")
+		return
+	}
+	result := Execute(withResolver(req.Context(), people), request, req.Method == http.MethodGet)
+	res.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(res).Encode(result)
+}
+
+// Execute will run request on Schema after its depth and complexity are checked. mutations are
+// rejected if readOnly is true. ctx must have the resolver of withResolver.
+func Execute(ctx context.Context, request Request, readOnly bool) *graphql.Result {
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(request.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return errorResult(err)
+	}
+	if validation := graphql.ValidateDocument(&Schema, document, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+	operation := operationOf(document, request.OperationName)
+	if operation == nil {
+		return errorResult(&codedError{code: handler.CodeInvalidRequest, message: "operation " + request.OperationName + " is not in query"})
+	}
+	if readOnly && operation.Operation == ast.OperationTypeMutation {
+		return errorResult(&codedError{code: handler.CodeInvalidRequest, message: "mutations must be sent with POST"})
+	}
+	if err := checkLimits(Schema, document, operation, request.Variables); err != nil {
+		return errorResult(err)
+	}
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        Schema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       ctx,
+	})
+}
+
+// errorResult will return the result of an operation that is not executed, extensions of coded errors are kept.
+func errorResult(err error) *graphql.Result {
+	if _, ok := err.(*codedError); ok {
+		err = gqlerrors.NewError(err.Error(), nil, "", nil, nil, err)
+	}
+	return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+}
@@ -0,0 +1,146 @@
+package gql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+)
+
+// limits of operations, they are checked before an operation is executed.
+const (
+	MaxDepth      = 8
+	MaxComplexity = 500
+)
+
+// operationOf will return the operation of document that has name, or its only operation if name is empty.
+func operationOf(document *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" && found != nil {
+			return nil // validation needs a name when there are several operations
+		}
+		if name == "" || (operation.Name != nil && operation.Name.Value == name) {
+			found = operation
+		}
+	}
+	return found
+}
+
+// measure walks the selections of an operation. every field costs one, the fields under a field that
+// has a size argument cost size times. introspection fields are free.
+type measure struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// checkLimits will return an error if operation is deeper than MaxDepth or more complex than MaxComplexity.
+func checkLimits(schema graphql.Schema, document *ast.Document, operation *ast.OperationDefinition, variables map[string]interface{}) error {
+	m := measure{schema: schema, fragments: make(map[string]*ast.FragmentDefinition), variables: variables}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			m.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	root := schema.QueryType()
+	if operation.Operation == ast.OperationTypeMutation {
+		root = schema.MutationType()
+	}
+	cost, depth := m.selections(operation.SelectionSet, root, 0)
+	if depth > MaxDepth {
+		return &codedError{code: handler.CodeQueryLimit, message: fmt.Sprintf("query depth is %d, it must be at most %d", depth, MaxDepth)}
+	}
+	if cost > MaxComplexity {
+		return &codedError{code: handler.CodeQueryLimit, message: fmt.Sprintf("query complexity is %d, it must be at most %d", cost, MaxComplexity)}
+	}
+	return nil
+}
+
+// selections will return the cost and the depth of the fields of set, parent is the type of set.
+func (m *measure) selections(set *ast.SelectionSet, parent graphql.Type, depth int) (cost, maxDepth int) {
+	if set == nil {
+		return 0, depth
+	}
+	maxDepth = depth
+	for _, selection := range set.Selections {
+		var selectionCost, selectionDepth int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			selectionCost, selectionDepth = m.field(selection, parent, depth+1)
+		case *ast.InlineFragment:
+			fragmentType := parent
+			if selection.TypeCondition != nil {
+				fragmentType = m.schema.Type(selection.TypeCondition.Name.Value)
+			}
+			selectionCost, selectionDepth = m.selections(selection.SelectionSet, fragmentType, depth)
+		case *ast.FragmentSpread:
+			fragment, ok := m.fragments[selection.Name.Value]
+			if !ok {
+				continue
+			}
+			selectionCost, selectionDepth = m.selections(fragment.SelectionSet, m.schema.Type(fragment.TypeCondition.Name.Value), depth)
+		}
+		cost += selectionCost
+		if selectionDepth > maxDepth {
+			maxDepth = selectionDepth
+		}
+	}
+	return cost, maxDepth
+}
+
+// field will return the cost and the depth of field and its selections.
+func (m *measure) field(field *ast.Field, parent graphql.Type, depth int) (cost, maxDepth int) {
+	object, ok := graphql.GetNamed(parent).(*graphql.Object)
+	if !ok {
+		return 1, depth
+	}
+	definition, ok := object.Fields()[field.Name.Value]
+	if !ok {
+		return 1, depth
+	}
+	cost, maxDepth = m.selections(field.SelectionSet, definition.Type, depth)
+	return 1 + m.size(field, definition)*cost, maxDepth
+}
+
+// size will return the size argument of field, 1 if it has none.
+func (m *measure) size(field *ast.Field, definition *graphql.FieldDefinition) int {
+	var size interface{}
+	for _, arg := range definition.Args {
+		if arg.Name() == "size" {
+			size = arg.DefaultValue
+		}
+	}
+	if size == nil {
+		return 1
+	}
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "size" {
+			continue
+		}
+		switch value := arg.Value.(type) {
+		case *ast.Variable:
+			if variable, ok := m.variables[value.Name.Value]; ok {
+				size = variable
+			}
+		default:
+			size = literalValue(value)
+		}
+	}
+	switch size := size.(type) {
+	case int:
+		return size
+	case float64:
+		return int(size)
+	}
+	return 1
+}
@@ -0,0 +1,76 @@
+package gql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fetchFunc will return the people of ids, ids that have no person are not in result.
+type fetchFunc func(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*model.Person, error)
+
+// loadResult is a person of loader, person is nil if it does not exist.
+type loadResult struct {
+	person *model.Person
+	err    error
+}
+
+// personLoader batches the person lookups of a request. resolvers get a thunk from Load, the ids that
+// are loaded before the first thunk is called are fetched with one query. results are cached for
+// the request, writes must Clear their ids.
+type personLoader struct {
+	ctx     context.Context
+	fetch   fetchFunc
+	mu      sync.Mutex
+	pending []primitive.ObjectID
+	results map[primitive.ObjectID]*loadResult
+	batches int // number of fetches, for tests
+}
+
+func newPersonLoader(ctx context.Context, fetch fetchFunc) *personLoader {
+	return &personLoader{ctx: ctx, fetch: fetch, results: make(map[primitive.ObjectID]*loadResult)}
+}
+
+// Load will add id to the next batch and return the thunk of its person.
+func (loader *personLoader) Load(id primitive.ObjectID) func() (*model.Person, error) {
+	loader.mu.Lock()
+	if _, ok := loader.results[id]; !ok {
+		loader.results[id] = nil
+		loader.pending = append(loader.pending, id)
+	}
+	loader.mu.Unlock()
+	return func() (*model.Person, error) {
+		loader.mu.Lock()
+		defer loader.mu.Unlock()
+		if loader.results[id] == nil {
+			if _, ok := loader.results[id]; !ok {
+				loader.pending = append(loader.pending, id) // cleared before it is fetched
+			}
+			loader.dispatch()
+		}
+		result := loader.results[id]
+		return result.person, result.err
+	}
+}
+
+// dispatch will fetch the pending ids, mu must be locked.
+func (loader *personLoader) dispatch() {
+	ids := loader.pending
+	loader.pending = nil
+	loader.batches++
+	people, err := loader.fetch(loader.ctx, ids)
+	for _, id := range ids {
+		loader.results[id] = &loadResult{person: people[id], err: err}
+	}
+}
+
+// Clear will remove the cached person of id.
+func (loader *personLoader) Clear(id primitive.ObjectID) {
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+	if result := loader.results[id]; result != nil {
+		delete(loader.results, id)
+	}
+}
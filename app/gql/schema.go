@@ -0,0 +1,290 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxPageSize is the largest size of people pages.
+const MaxPageSize = 100
+
+// codedError is an error of graphql response, its extensions have the code of handler catalog.
+type codedError struct {
+	code    handler.ErrorCode
+	message string
+	fields  []handler.FieldError
+}
+
+func (err *codedError) Error() string {
+	return err.message
+}
+
+// Extensions will return the code and field errors of err, they are written in graphql error.
+func (err *codedError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": err.code.Code}
+	if len(err.fields) > 0 {
+		extensions["errors"] = err.fields
+	}
+	return extensions
+}
+
+func invalidError(fields ...handler.FieldError) error {
+	return &codedError{handler.CodeInvalidRequest, "request is not valid: " + fields[0].Field + " " + fields[0].Message, fields}
+}
+
+// storeError will convert an error of store to a coded error.
+func storeError(err error) error {
+	var duplicate *store.DuplicateError
+	var notSearchable *store.NotSearchableError
+	var invalid *store.ValidationError
+	switch {
+	case err == store.ErrNotFound:
+		return &codedError{code: handler.CodePersonNotFound, message: err.Error()}
+	case errors.As(err, &duplicate):
+		return &codedError{handler.CodeDuplicateField, duplicate.Error(), []handler.FieldError{{Field: duplicate.Field, Message: "already exists"}}}
+	case errors.As(err, &notSearchable):
+		return invalidError(handler.FieldError{Field: "filter." + notSearchable.Field, Message: "can not be searched because it is encrypted"})
+	case errors.As(err, &invalid):
+		fields := make([]handler.FieldError, len(invalid.Fields))
+		for i, field := range invalid.Fields {
+			fields[i] = handler.FieldError{Field: strings.TrimSuffix("input."+field.Field, "."), Message: field.Message}
+		}
+		return invalidError(fields...)
+	}
+	log.Printf("Error of people store: %v\n", err)
+	return &codedError{code: handler.CodeInternal, message: "there is an error on server"}
+}
+
+// resolver holds the data access of a request, it is in the context of resolvers.
+type resolver struct {
+	people store.PeopleStore
+	loader *personLoader
+}
+
+type resolverKey struct{}
+
+func withResolver(ctx context.Context, people store.PeopleStore) context.Context {
+	r := &resolver{people: people}
+	r.loader = newPersonLoader(ctx, people.GetMany)
+	return context.WithValue(ctx, resolverKey{}, r)
+}
+
+func resolverOf(ctx context.Context) *resolver {
+	return ctx.Value(resolverKey{}).(*resolver)
+}
+
+func parseID(field string, value interface{}) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(fmt.Sprint(value))
+	if err != nil {
+		return id, invalidError(handler.FieldError{Field: field, Message: "must be a hex object id"})
+	}
+	return id, nil
+}
+
+// Schema is the graphql schema of people.
+var Schema = newSchema()
+
+func newSchema() graphql.Schema {
+	person := objectOf("Person", "Person is the data structure that we will save and receive.", model.Person{})
+	personInput := inputOf("PersonInput", "Fields of a new person.", model.Person{}, true)
+	personUpdate := inputOf("PersonUpdate", "Fields that are set on a person, null fields are not changed.", model.Person{}, false)
+	personFilter := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "PersonFilter",
+		Description: "People that match all fields, username and email are case insensitive.",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"ids":        {Type: graphql.NewList(graphql.NewNonNull(graphql.ID))},
+			"first_name": {Type: graphql.String},
+			"last_name":  {Type: graphql.String},
+			"username":   {Type: graphql.String},
+			"email":      {Type: graphql.String},
+		},
+	})
+	personPage := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "PersonPage",
+		Description: "A page of people, newest first.",
+		Fields: graphql.Fields{
+			"items":   {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(person)))},
+			"page":    {Type: graphql.NewNonNull(graphql.Int)},
+			"size":    {Type: graphql.NewNonNull(graphql.Int)},
+			"hasNext": {Type: graphql.NewNonNull(graphql.Boolean)},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+		"person": {
+			Type:        person,
+			Description: "Person of id, lookups of a query are fetched together.",
+			Args:        graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+			Resolve:     resolvePerson,
+		},
+		"people": {
+			Type:        graphql.NewNonNull(personPage),
+			Description: "Page of people that match filter.",
+			Args: graphql.FieldConfigArgument{
+				"filter": {Type: personFilter},
+				"page":   {Type: graphql.Int, DefaultValue: 0, Description: "zero based page number"},
+				"size":   {Type: graphql.Int, DefaultValue: int(store.PageSize), Description: fmt.Sprintf("people per page, at most %d", MaxPageSize)},
+			},
+			Resolve: resolvePeople,
+		},
+	}})
+	mutation := graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{
+		"createPerson": {
+			Type:    graphql.NewNonNull(person),
+			Args:    graphql.FieldConfigArgument{"input": {Type: graphql.NewNonNull(personInput)}},
+			Resolve: resolveCreate,
+		},
+		"updatePerson": {
+			Type: graphql.NewNonNull(person),
+			Args: graphql.FieldConfigArgument{
+				"id":    {Type: graphql.NewNonNull(graphql.ID)},
+				"input": {Type: graphql.NewNonNull(personUpdate)},
+			},
+			Resolve: resolveUpdate,
+		},
+		"deletePerson": {
+			Type:        graphql.NewNonNull(graphql.ID),
+			Description: "Delete person and return its id.",
+			Args:        graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+			Resolve:     resolveDelete,
+		},
+	}})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+	if err != nil {
+		panic(fmt.Sprintf("gql: schema is not valid: %v", err))
+	}
+	return schema
+}
+
+func resolvePerson(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID("id", p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	load := resolverOf(p.Context).loader.Load(id)
+	return func() (interface{}, error) {
+		person, err := load()
+		if err != nil {
+			panic(locatedError(p, storeError(err)))
+		}
+		if person == nil {
+			panic(locatedError(p, &codedError{code: handler.CodePersonNotFound, message: "person not found"}))
+		}
+		return person, nil
+	}, nil
+}
+
+// locatedError will return err at the field of p. errors that thunks return are formatted without their
+// extensions, so thunks panic with a located error that is passed through as it is.
+func locatedError(p graphql.ResolveParams, err error) error {
+	nodes := make([]ast.Node, len(p.Info.FieldASTs))
+	for i, field := range p.Info.FieldASTs {
+		nodes[i] = field
+	}
+	return gqlerrors.NewErrorWithPath(err.Error(), nodes, "", nil, nil, p.Info.Path.AsArray(), err)
+}
+
+func resolvePeople(p graphql.ResolveParams) (interface{}, error) {
+	page, size := p.Args["page"].(int), p.Args["size"].(int)
+	var fields []handler.FieldError
+	if page < 0 {
+		fields = append(fields, handler.FieldError{Field: "page", Message: "must be at least 0"})
+	}
+	if size < 1 || size > MaxPageSize {
+		fields = append(fields, handler.FieldError{Field: "size", Message: fmt.Sprintf("must be between 1 and %d", MaxPageSize)})
+	}
+	var filter store.Filter
+	if input, ok := p.Args["filter"].(map[string]interface{}); ok {
+		filter.FirstName, _ = input["first_name"].(string)
+		filter.LastName, _ = input["last_name"].(string)
+		filter.Username, _ = input["username"].(string)
+		filter.Email, _ = input["email"].(string)
+		ids, _ := input["ids"].([]interface{})
+		for _, value := range ids {
+			id, err := parseID("filter.ids", value)
+			if err != nil {
+				return nil, err
+			}
+			filter.IDs = append(filter.IDs, id)
+		}
+	}
+	if len(fields) > 0 {
+		return nil, invalidError(fields...)
+	}
+	// one more person is read to know if there is a next page.
+	personList, err := resolverOf(p.Context).people.Find(p.Context, filter, int64(page*size), int64(size+1))
+	if err != nil {
+		return nil, storeError(err)
+	}
+	hasNext := len(personList) > size
+	if hasNext {
+		personList = personList[:size]
+	}
+	if personList == nil {
+		personList = []model.Person{}
+	}
+	return map[string]interface{}{"items": personList, "page": page, "size": size, "hasNext": hasNext}, nil
+}
+
+func resolveCreate(p graphql.ResolveParams) (interface{}, error) {
+	person := new(model.Person)
+	if err := decodeInput(p.Args["input"], person); err != nil {
+		return nil, invalidError(handler.FieldError{Field: "input", Message: err.Error()})
+	}
+	if err := resolverOf(p.Context).people.Create(p.Context, person); err != nil {
+		return nil, storeError(err)
+	}
+	return person, nil
+}
+
+func resolveUpdate(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID("id", p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	input := p.Args["input"].(map[string]interface{})
+	if err := decodeInput(input, new(model.Person)); err != nil {
+		return nil, invalidError(handler.FieldError{Field: "input", Message: err.Error()})
+	}
+	// null fields are not changed, the others are validated by the store.
+	updates := make(map[string]interface{}, len(input))
+	for name, value := range input {
+		if value != nil {
+			updates[name] = value
+		}
+	}
+	r := resolverOf(p.Context)
+	if err := r.people.Update(p.Context, id, updates); err != nil {
+		return nil, storeError(err)
+	}
+	r.loader.Clear(id)
+	updated, err := r.people.Get(p.Context, id)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return updated, nil
+}
+
+func resolveDelete(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID("id", p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	r := resolverOf(p.Context)
+	if err := r.people.Delete(p.Context, id); err != nil {
+		return nil, storeError(err)
+	}
+	r.loader.Clear(id)
+	return id.Hex(), nil
+}
@@ -0,0 +1,188 @@
+package gql
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	mapType      = reflect.TypeOf(map[string]interface{}{})
+)
+
+// jsonScalar is an arbitrary json value, go values are written in their json form so ObjectIDs
+// and dates are the same as the rest api.
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Arbitrary json value.",
+	Serialize: func(value interface{}) interface{} {
+		content, err := json.Marshal(value)
+		if err != nil {
+			return nil
+		}
+		var tree interface{}
+		json.Unmarshal(content, &tree)
+		return tree
+	},
+	ParseValue:   func(value interface{}) interface{} { return value },
+	ParseLiteral: literalValue,
+})
+
+// literalValue will convert an inline value of query to its go value.
+func literalValue(value ast.Value) interface{} {
+	switch value := value.(type) {
+	case *ast.ObjectValue:
+		object := make(map[string]interface{}, len(value.Fields))
+		for _, field := range value.Fields {
+			object[field.Name.Value] = literalValue(field.Value)
+		}
+		return object
+	case *ast.ListValue:
+		list := make([]interface{}, len(value.Values))
+		for i, item := range value.Values {
+			list[i] = literalValue(item)
+		}
+		return list
+	case *ast.IntValue, *ast.FloatValue:
+		var number float64
+		json.Unmarshal([]byte(value.GetValue().(string)), &number)
+		return number
+	case *ast.BooleanValue:
+		return value.Value
+	case *ast.StringValue:
+		return value.Value
+	case *ast.EnumValue:
+		return value.Value
+	}
+	return nil
+}
+
+// structField is a field of a struct that has a json name.
+type structField struct {
+	name     string // graphql name, the json name without leading underscores
+	index    int
+	typ      reflect.Type
+	required bool // schema tag has required
+}
+
+// structFields will return the fields of struct type t that are written in json.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		required := false
+		for _, rule := range strings.Split(field.Tag.Get("schema"), ",") {
+			required = required || rule == "required"
+		}
+		fields = append(fields, structField{strings.TrimLeft(name, "_"), i, field.Type, required})
+	}
+	return fields
+}
+
+// scalarOf will return the graphql type of a go type that is not a struct, values that have
+// no graphql type are JSON.
+func scalarOf(t reflect.Type) graphql.Type {
+	if t == objectIDType {
+		return graphql.ID
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return graphql.String
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return graphql.Int
+	case reflect.Float32, reflect.Float64:
+		return graphql.Float
+	}
+	return jsonScalar
+}
+
+// objectOf will generate the object type of a struct value from its json tags. id fields are
+// non null, map fields take a keys argument that selects their keys.
+func objectOf(name, description string, value interface{}) *graphql.Object {
+	fields := graphql.Fields{}
+	for _, field := range structFields(reflect.TypeOf(value)) {
+		field := field
+		fieldType := scalarOf(field.typ)
+		if fieldType == graphql.ID {
+			fieldType = graphql.NewNonNull(fieldType)
+		}
+		var args graphql.FieldConfigArgument
+		if field.typ == mapType {
+			args = graphql.FieldConfigArgument{"keys": {
+				Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+				Description: "keys of map that are returned, all keys are returned if it is null.",
+			}}
+		}
+		fields[field.name] = &graphql.Field{
+			Type: fieldType,
+			Args: args,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				source := reflect.Indirect(reflect.ValueOf(p.Source))
+				return outputValue(source.Field(field.index).Interface(), p.Args), nil
+			},
+		}
+	}
+	return graphql.NewObject(graphql.ObjectConfig{Name: name, Description: description, Fields: fields})
+}
+
+// outputValue will convert a field value to its graphql value.
+func outputValue(value interface{}, args map[string]interface{}) interface{} {
+	switch value := value.(type) {
+	case primitive.ObjectID:
+		return value.Hex()
+	case map[string]interface{}:
+		keys, ok := args["keys"].([]interface{})
+		if value == nil || !ok {
+			return value
+		}
+		selected := make(map[string]interface{}, len(keys))
+		for _, key := range keys {
+			if item, ok := value[key.(string)]; ok {
+				selected[key.(string)] = item
+			}
+		}
+		return selected
+	}
+	return value
+}
+
+// inputOf will generate the input type of a struct value from its json tags, ids are not inputs.
+// fields with the required schema rule are non null if required is true.
+func inputOf(name, description string, value interface{}, required bool) *graphql.InputObject {
+	fields := graphql.InputObjectConfigFieldMap{}
+	for _, field := range structFields(reflect.TypeOf(value)) {
+		fieldType := scalarOf(field.typ)
+		if fieldType == graphql.ID {
+			continue
+		}
+		if required && field.required {
+			fieldType = graphql.NewNonNull(fieldType)
+		}
+		fields[field.name] = &graphql.InputObjectFieldConfig{Type: fieldType}
+	}
+	return graphql.NewInputObject(graphql.InputObjectConfig{Name: name, Description: description, Fields: fields})
+}
+
+// decodeInput will set v from an input object, its keys are the json names of v fields.
+func decodeInput(input interface{}, v interface{}) error {
+	content, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
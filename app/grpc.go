@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/rpc"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// serveGRPC will start the grpc server of people on grpc_host, servers are nil if grpc is disabled.
+func (app *App) serveGRPC() (*grpc.Server, *health.Server) {
+	if app.config.GRPCHost == "" {
+		return nil, nil
+	}
+	listener, err := net.Listen("tcp", app.config.GRPCHost)
+	if err != nil {
+		log.Fatalf("Error while listening for grpc: %v", err)
+	}
+	var opts []grpc.ServerOption
+	if app.tenancy() != "" {
+		opts = append(opts, grpc.UnaryInterceptor(app.tenantUnaryInterceptor), grpc.StreamInterceptor(app.tenantStreamInterceptor))
+	}
+	server, healthServer := rpc.NewGRPCServer(rpc.NewServer(app.people), app.config.GRPCReflection, opts...)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Fatalf("Error while serving grpc: %v", err)
+		}
+	}()
+	log.Printf("gRPC server is listning on %s\n", app.config.GRPCHost)
+	return server, healthServer
+}
+
+// personServicePrefix is the prefix of PersonService methods.
+const personServicePrefix = "/person.v1.PersonService/"
+
+// tenantContext will resolve the tenant of a PersonService call from its metadata like the tenant of http
+// requests, health checking and reflection are served without a tenant.
+func (app *App) tenantContext(ctx context.Context, method string) (context.Context, error) {
+	if !strings.HasPrefix(method, personServicePrefix) {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	req := &http.Request{Header: make(http.Header)}
+	for key, values := range md {
+		if key == ":authority" && len(values) > 0 {
+			req.Host = values[0]
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	id, err := app.tenantResolver()(req)
+	switch {
+	case errors.Is(err, tenant.ErrUnauthorized):
+		return nil, status.Error(codes.Unauthenticated, "bearer token is not valid")
+	case err != nil:
+		return nil, status.Errorf(codes.InvalidArgument, "tenant of request is missing or not valid: %v", err)
+	}
+	return tenant.NewContext(ctx, tenant.Tenant{ID: id, Mode: app.tenancy()}), nil
+}
+
+func (app *App) tenantUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := app.tenantContext(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (app *App) tenantStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := app.tenantContext(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &tenantStream{ServerStream: stream, ctx: ctx})
+}
+
+// tenantStream is a server stream that has the context of its tenant.
+type tenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *tenantStream) Context() context.Context {
+	return stream.ctx
+}
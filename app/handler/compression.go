@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encoder is a compression writer that can be reused with Reset.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoders are the supported content codings in server preference order, every coding has a pool of writers.
+var encoders = []struct {
+	name string
+	pool *sync.Pool
+}{
+	{"zstd", &sync.Pool{New: func() interface{} {
+		writer, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+		return writer
+	}}},
+	{"br", &sync.Pool{New: func() interface{} { return brotli.NewWriterLevel(nil, 4) }}},
+	{"gzip", &sync.Pool{New: func() interface{} {
+		writer, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return writer
+	}}},
+}
+
+// Compression is the config of response compression and request decompression.
+type Compression struct {
+	MinSize      int      // responses that are smaller than MinSize bytes are not compressed.
+	ContentTypes []string // media types that are compressed, type/* matches all subtypes of type and +suffix all types of suffix.
+	MaxBodySize  int64    // limit of decompressed request bodies.
+}
+
+// DefaultCompression is the Compression of text and structured responses larger than 1KB.
+func DefaultCompression() *Compression {
+	return &Compression{
+		MinSize: 1024,
+		ContentTypes: []string{
+			"text/*",
+			"application/json",
+			"application/xml",
+			"application/yaml",
+			"application/msgpack",
+			"application/cbor",
+			"application/x-ndjson",
+			// problem details and the vendor media types of api versions.
+			"+json",
+			"+xml",
+			"+yaml",
+			"+msgpack",
+			"+cbor",
+		},
+		MaxBodySize: 256 << 20,
+	}
+}
+
+// Middleware will compress responses with the most preferred coding of Accept-Encoding header and
+// decompress gzip request bodies. requests with other content codings get 415.
+func (c *Compression) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			body, err := gzip.NewReader(r.Body)
+			if err != nil {
+				ErrorResponse(w, r, CodeInvalidBody, "body is not valid gzip.", nil)
+				return
+			}
+			defer body.Close()
+			r.Body = http.MaxBytesReader(w, body, c.MaxBodySize)
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+		default:
+			w.Header().Set("Accept-Encoding", "gzip")
+			ErrorResponse(w, r, CodeUnsupportedEncoding, encoding+" content encoding is not supported.", nil)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, compression: c, encoding: negotiateEncoding(r.Header.Get("Accept-Encoding"))}
+		if r.Method == http.MethodHead {
+			cw.encoding = ""
+		}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding will return the supported coding that has the highest quality in accept encoding header,
+// ties are broken by server preference. it is empty if none of them is accepted.
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+		qualities[coding] = quality
+	}
+	best, bestQuality := "", 0.0
+	for _, encoder := range encoders {
+		quality, ok := qualities[encoder.name]
+		if !ok {
+			quality = qualities["*"]
+		}
+		if quality > bestQuality {
+			best, bestQuality = encoder.name, quality
+		}
+	}
+	return best
+}
+
+func encoderPool(name string) *sync.Pool {
+	for _, encoder := range encoders {
+		if encoder.name == name {
+			return encoder.pool
+		}
+	}
+	return nil
+}
+
+// compressWriter buffers the response until MinSize bytes are written, then decides to compress it.
+// responses that are finished before MinSize are written without compression.
+type compressWriter struct {
+	http.ResponseWriter
+	compression *Compression
+	encoding    string // negotiated coding, it is empty if client accepts none of them.
+	status      int
+	buffer      []byte
+	decided     bool
+	encoder     encoder
+}
+
+// Unwrap will return the original http.ResponseWriter.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buffer = append(w.buffer, p...)
+		if len(w.buffer) < w.compression.MinSize {
+			return len(p), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush will send the buffered response, streamed responses are compressed regardless of their size.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			return
+		}
+		w.decide(true)
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close will send the buffered response and finish the compressed stream.
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if w.status == 0 {
+			return nil
+		}
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.encoder == nil {
+		return nil
+	}
+	err := w.encoder.Close()
+	w.encoder.Reset(nil)
+	encoderPool(w.encoding).Put(w.encoder)
+	w.encoder = nil
+	return err
+}
+
+// decide will write the header and buffered body, compressed if large is true and response can be compressed.
+func (w *compressWriter) decide(large bool) error {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buffer) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buffer))
+	}
+	allowed := w.compression.allowed(header.Get("Content-Type"))
+	if allowed {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	bodyless := w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified
+	if large && allowed && !bodyless && w.encoding != "" && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.encoder = encoderPool(w.encoding).Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buffer := w.buffer
+	w.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(buffer)
+	} else {
+		_, err = w.ResponseWriter.Write(buffer)
+	}
+	return err
+}
+
+// allowed will return true if content type is in the compressed types.
+func (c *Compression) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range c.ContentTypes {
+		switch {
+		case allowed == mediaType,
+			strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*")),
+			strings.HasPrefix(allowed, "+") && strings.HasSuffix(mediaType, allowed):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                        "",
+		"identity":                "",
+		"gzip":                    "gzip",
+		"gzip, deflate, br":       "br",
+		"gzip, br, zstd":          "zstd",
+		"zstd;q=0.5, gzip":        "gzip",
+		"*":                       "zstd",
+		"*, zstd;q=0, br;q=0":     "gzip",
+		"gzip;q=0, deflate":       "",
+		"GZIP;q=0.8, br;q=0.1, x": "gzip",
+	}
+	for acceptEncoding, want := range tests {
+		if got := negotiateEncoding(acceptEncoding); got != want {
+			t.Errorf("%s negotiateEncoding(%q) is wrong: got %q want %q", failed, acceptEncoding, got, want)
+		}
+	}
+	t.Logf("%s Testing encoding negotiation is successful", succeed)
+}
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat(`{"username": "john_doe"}`, 100)
+	var body string
+	endpoint := DefaultCompression().Middleware(NegotiationMiddleware(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		content, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			res.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		if len(content) > 0 {
+			body = string(content)
+		}
+		ResponseWriter(res, http.StatusOK, req.URL.Query().Get("message"), nil)
+	})))
+	readers := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+	}
+	for encoding, newReader := range readers {
+		req := httptest.NewRequest("GET", "/person?message="+url.QueryEscape(large), nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		rr := httptest.NewRecorder()
+		endpoint.ServeHTTP(rr, req)
+		if rr.Header().Get("Content-Encoding") != encoding || !strings.Contains(strings.Join(rr.Header()["Vary"], ","), "Accept-Encoding") {
+			t.Fatalf("%s %s response headers are wrong: %v", failed, encoding, rr.Header())
+		}
+		reader, err := newReader(rr.Body)
+		if err != nil {
+			t.Fatalf("%s %s reader is failed: %v", failed, encoding, err)
+		}
+		content, err := ioutil.ReadAll(reader)
+		if err != nil || !strings.Contains(string(content), "john_doe") {
+			t.Errorf("%s %s body is wrong: %v %s", failed, encoding, err, content)
+		}
+	}
+
+	// small responses are not compressed
+	req := httptest.NewRequest("GET", "/person?message=small", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	endpoint.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "" || !strings.Contains(rr.Body.String(), "small") {
+		t.Errorf("%s small response must not be compressed: %v %s", failed, rr.Header(), rr.Body)
+	}
+
+	// vendor media types of versions are compressed
+	versioned := DefaultCompression().Middleware(NegotiationMiddleware(Versions.Middleware(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		ResponseWriter(res, http.StatusOK, large, nil)
+	}))))
+	req = httptest.NewRequest("GET", "/person", nil)
+	req.Header.Set("Accept", "application/vnd.person.v2+json")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr = httptest.NewRecorder()
+	versioned.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(rr.Header().Get("Content-Type"), "application/vnd.person.v2+json") {
+		t.Errorf("%s vendor media type must be compressed: %v", failed, rr.Header())
+	}
+
+	// gzip request bodies are decompressed
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(large))
+	writer.Close()
+	req = httptest.NewRequest("POST", "/person/import", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	rr = httptest.NewRecorder()
+	endpoint.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || body != large {
+		t.Errorf("%s gzip request body is wrong: %d %q", failed, rr.Code, body)
+	}
+
+	req = httptest.NewRequest("POST", "/person/import", strings.NewReader(large))
+	req.Header.Set("Content-Encoding", "compress")
+	rr = httptest.NewRecorder()
+	endpoint.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("%s unsupported content encoding status is wrong: %d", failed, rr.Code)
+	}
+	t.Logf("%s Testing compression is successful", succeed)
+}
@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// conflictResponse will write the conflict response of a unique field that already exists.
+func conflictResponse(res http.ResponseWriter, req *http.Request, field string) {
+	ErrorResponse(res, req, CodeDuplicateField, fmt.Sprintf("%s already exists.", field),
+		map[string]string{"field": field}, FieldError{field, "already exists"})
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/katoozi/golang-mongodb-rest-api/app/gdpr"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ExportPerson will give us all data that we hold on a person as a zip archive of json files
+func ExportPerson(people store.PeopleStore, db *mongo.Database, res http.ResponseWriter, req *http.Request) {
+	var params = mux.Vars(req)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		ErrorResponse(res, req, CodeInvalidID, "id that you sent is wrong!!!", nil)
+		return
+	}
+	export, err := gdpr.Collect(req.Context(), db, people, id)
+	if err != nil {
+		storeErrorResponse(res, req, err, "error in exporting person!!!")
+		return
+	}
+	res.Header().Set("content-type", "application/zip")
+	res.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=\"person-%s-gdpr-export.zip\"", id.Hex()))
+	res.Header().Set("cache-control", "no-store")
+	if err := gdpr.WriteArchive(res, export); err != nil {
+		log.Printf("Error while writing export archive: %v\n", err)
+	}
+}
+
+// ErasePerson will anonymize a person in place and record it in the erasure log, it can not be undone
+func ErasePerson(people store.PeopleStore, db *mongo.Database, res http.ResponseWriter, req *http.Request) {
+	var params = mux.Vars(req)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		ErrorResponse(res, req, CodeInvalidID, "id that you sent is wrong!!!", nil)
+		return
+	}
+	erasure, err := gdpr.Erase(req.Context(), db, people, id)
+	switch {
+	case errors.Is(err, gdpr.ErrErased):
+		ErrorResponse(res, req, CodePersonErased, err.Error(), erasure)
+	case err == gdpr.ErrNoKey:
+		log.Printf("Error while erasing person: %v\n", err)
+		ErrorResponse(res, req, CodeInternal, "erasure log key is not configured.", nil)
+	case err != nil:
+		storeErrorResponse(res, req, err, "error in erasing person!!!")
+	default:
+		ResponseWriter(res, http.StatusOK, "person is erased.", erasure)
+	}
+}
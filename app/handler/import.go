@@ -0,0 +1,683 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/katoozi/golang-mongodb-rest-api/app/jobs"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// importBatchSize is the number of rows that will be written to database in one bulk write.
+	importBatchSize = 500
+	// maxImportMemory is the size of upload that will be kept in memory, the rest goes to temp files.
+	maxImportMemory = 32 << 20
+	// maxReportedErrors is the number of rejected rows that will be returned in import response.
+	maxReportedErrors = 100
+	// maxNDJSONLine is the biggest line that we accept in ndjson uploads.
+	maxNDJSONLine = 1 << 20
+	// uploadChunkSize is the size of the documents that uploads are saved in until their import runs.
+	uploadChunkSize = 1 << 20
+)
+
+// ImportJob is the job type of people imports, see RunImport.
+const ImportJob = "import-people"
+
+// importFields are the person fields that a column can be mapped to.
+var importFields = map[string]bool{
+	"first_name": true,
+	"last_name":  true,
+	"username":   true,
+	"email":      true,
+}
+
+// ImportPeople will queue the bulk import of people from csv or ndjson uploads. the upload is saved and an
+// ImportJob runs it, the response is the job and the report of import is its result.
+// form fields:
+//
+//	file: the uploaded file
+//	format: csv or ndjson, it will be detected from file name if it is empty
+//	mapping: json object that maps a column to a person field or to a data key (data.<key>), "-" will ignore the column
+//	dry_run: if it is true nothing will be written, not even the report and rejected rows, and the report of
+//	job shows what would happen. it has the first rejected rows and no error report.
+func ImportPeople(pool *jobs.Pool, db *mongo.Database, res http.ResponseWriter, req *http.Request) {
+	if err := req.ParseMultipartForm(maxImportMemory); err != nil {
+		ErrorResponse(res, req, CodeInvalidForm, "request must be a multipart form!!!", nil)
+		return
+	}
+	defer req.MultipartForm.RemoveAll()
+	file, header, err := req.FormFile("file")
+	if err != nil {
+		ErrorResponse(res, req, CodeInvalidForm, "file field is required!!!", nil, FieldError{"file", "file is required"})
+		return
+	}
+	defer file.Close()
+	mapping := req.FormValue("mapping")
+	if _, err := parseImportMapping(mapping); err != nil {
+		ErrorResponse(res, req, CodeInvalidForm, fmt.Sprintf("mapping is incorrect: %v", err), nil, FieldError{"mapping", err.Error()})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(req.FormValue("dry_run"))
+	format := importFormat(req.FormValue("format"), header)
+	if format != "csv" && format != "ndjson" {
+		message := fmt.Sprintf("format %q is not supported, use csv or ndjson", format)
+		ErrorResponse(res, req, CodeInvalidForm, message, nil, FieldError{"format", message})
+		return
+	}
+
+	upload, err := saveUpload(req.Context(), db, file)
+	if err != nil {
+		log.Printf("Error while saving upload: %v\n", err)
+		ErrorResponse(res, req, CodeInternal, "Error happend while saving data", nil)
+		return
+	}
+	job, err := pool.Enqueue(req.Context(), ImportJob, map[string]interface{}{
+		"upload":   upload.Hex(),
+		"filename": header.Filename,
+		"format":   format,
+		"mapping":  mapping,
+		"dry_run":  dryRun,
+	})
+	if err != nil {
+		log.Printf("Error while queuing import: %v\n", err)
+		ErrorResponse(res, req, CodeInternal, "Error happend while saving data", nil)
+		return
+	}
+	ResponseWriter(res, http.StatusAccepted, "import is queued, its report will be the result of job.", job)
+}
+
+// RunImport is the function of ImportJob, it reads the upload of job and writes its rows with people. db is
+// the database of job tenant. the report of import has the id of job and it is the result of job, progress
+// is the number of read rows. the upload is removed when the import is done, uploads of failed jobs are
+// removed by their ttl index.
+func RunImport(ctx context.Context, people store.PeopleStore, db *mongo.Database, job *model.Job, progress jobs.ProgressFunc) (map[string]interface{}, error) {
+	params := make(map[string]string)
+	for _, name := range []string{"upload", "filename", "format", "mapping"} {
+		params[name], _ = job.Params[name].(string)
+	}
+	dryRun, _ := job.Params["dry_run"].(bool)
+	upload, err := primitive.ObjectIDFromHex(params["upload"])
+	if err != nil {
+		return nil, fmt.Errorf("upload param is wrong: %v", err)
+	}
+	mapping, err := parseImportMapping(params["mapping"])
+	if err != nil {
+		return nil, fmt.Errorf("mapping is incorrect: %v", err)
+	}
+	file, err := openUpload(ctx, db, upload)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := newRowReader(params["format"], file, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	report := model.NewImportReport(params["filename"], params["format"], dryRun)
+	report.ID = job.ID
+	importer := newImporter(ctx, people, db, report)
+	importer.progress = progress
+	if err := importer.run(reader); err != nil {
+		return nil, err
+	}
+	if _, err := db.Collection(uploadCollection).DeleteMany(ctx, bson.M{"upload_id": upload}); err != nil {
+		log.Printf("Error while removing upload %s: %v\n", upload.Hex(), err)
+	}
+	// the result is the json of report, so jobs have the rejected rows of dry runs too.
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	return result, json.Unmarshal(data, &result)
+}
+
+// uploadCollection is the collection that uploads are saved in until their import runs.
+const uploadCollection = "import_uploads"
+
+// uploadChunk is a part of an upload, n is its number.
+type uploadChunk struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	UploadID  primitive.ObjectID `bson:"upload_id"`
+	N         int                `bson:"n"`
+	Data      []byte             `bson:"data"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// saveUpload will save the content of file in chunks and return the id of upload, an empty file has one
+// empty chunk.
+func saveUpload(ctx context.Context, db *mongo.Database, file io.Reader) (primitive.ObjectID, error) {
+	id := primitive.NewObjectID()
+	buffer := make([]byte, uploadChunkSize)
+	for n := 0; ; n++ {
+		size, err := io.ReadFull(file, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return id, err
+		}
+		if size > 0 || n == 0 {
+			chunk := uploadChunk{ID: primitive.NewObjectID(), UploadID: id, N: n, Data: buffer[:size], CreatedAt: time.Now()}
+			if _, err := db.Collection(uploadCollection).InsertOne(ctx, chunk); err != nil {
+				return id, err
+			}
+		}
+		if err != nil {
+			return id, nil
+		}
+	}
+}
+
+// uploadReader reads the chunks of an upload in order.
+type uploadReader struct {
+	ctx    context.Context
+	curser *mongo.Cursor
+	chunk  []byte
+}
+
+// openUpload will return the reader of upload, an error is returned if it does not exist.
+func openUpload(ctx context.Context, db *mongo.Database, id primitive.ObjectID) (*uploadReader, error) {
+	findOptions := options.Find().SetSort(bson.M{"n": 1})
+	curser, err := db.Collection(uploadCollection).Find(ctx, bson.M{"upload_id": id}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	reader := &uploadReader{ctx: ctx, curser: curser}
+	if err := reader.next(); err != nil {
+		curser.Close(ctx)
+		if err == io.EOF {
+			return nil, fmt.Errorf("upload %s is not found, it may be expired", id.Hex())
+		}
+		return nil, err
+	}
+	return reader, nil
+}
+
+// next will read the next chunk, io.EOF is returned after the last one.
+func (r *uploadReader) next() error {
+	if !r.curser.Next(r.ctx) {
+		if err := r.curser.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	var chunk uploadChunk
+	if err := r.curser.Decode(&chunk); err != nil {
+		return err
+	}
+	r.chunk = chunk.Data
+	return nil
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+// Close will close the curser of chunks.
+func (r *uploadReader) Close() error {
+	return r.curser.Close(r.ctx)
+}
+
+// GetImportErrors will give us the rejected rows of an import as a csv file
+func GetImportErrors(db *mongo.Database, res http.ResponseWriter, req *http.Request) {
+	var params = mux.Vars(req)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		ErrorResponse(res, req, CodeInvalidID, "id that you sent is wrong!!!", nil)
+		return
+	}
+	ctx := req.Context()
+	count, err := db.Collection("imports").CountDocuments(ctx, tenant.Scope(ctx, bson.M{"_id": id}))
+	if err != nil {
+		log.Printf("Error while quering collection: %v\n", err)
+		ErrorResponse(res, req, CodeInternal, "Error happend while reading data", nil)
+		return
+	}
+	if count == 0 {
+		ErrorResponse(res, req, CodeImportNotFound, "import not found", nil)
+		return
+	}
+	findOptions := options.Find().SetSort(bson.M{"line": 1})
+	curser, err := db.Collection("import_errors").Find(ctx, bson.M{"report_id": id}, findOptions)
+	if err != nil {
+		log.Printf("Error while quering collection: %v\n", err)
+		ErrorResponse(res, req, CodeInternal, "Error happend while reading data", nil)
+		return
+	}
+	defer curser.Close(ctx)
+
+	res.Header().Set("content-type", "text/csv; charset=UTF-8")
+	res.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=\"import-%s-errors.csv\"", id.Hex()))
+	writer := csv.NewWriter(res)
+	writer.Write([]string{"line", "error", "row"})
+	for curser.Next(ctx) {
+		var rowError model.ImportRowError
+		if err := curser.Decode(&rowError); err != nil {
+			log.Printf("Error while decode to go struct:%v\n", err)
+			break
+		}
+		writer.Write([]string{strconv.Itoa(rowError.Line), rowError.Error, rowError.Row})
+	}
+	writer.Flush()
+	if err := curser.Err(); err != nil {
+		log.Printf("Error in curser: %v", err)
+	}
+}
+
+// importFormat will detect the upload format from format field, file extension or file content type.
+func importFormat(format string, header *multipart.FileHeader) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+	switch strings.ToLower(filepath.Ext(header.Filename)) {
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".csv":
+		return "csv"
+	}
+	if strings.Contains(header.Header.Get("Content-Type"), "ndjson") {
+		return "ndjson"
+	}
+	return "csv"
+}
+
+// importMapping maps a column of uploaded file to a person field or to a data key (data.<key>).
+// a column that is mapped to "-" will be ignored.
+type importMapping map[string]string
+
+// parseImportMapping will decode and check the mapping json object.
+func parseImportMapping(value string) (importMapping, error) {
+	mapping := importMapping{}
+	if value == "" {
+		return mapping, nil
+	}
+	if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+		return nil, err
+	}
+	for column, target := range mapping {
+		if target == "-" || importFields[target] || (strings.HasPrefix(target, "data.") && len(target) > len("data.")) {
+			continue
+		}
+		return nil, fmt.Errorf("column %q is mapped to unknown field %q", column, target)
+	}
+	return mapping, nil
+}
+
+// target will return the field that column will be saved in.
+// columns without mapping that are not person fields will be saved in data.
+func (mapping importMapping) target(column string) string {
+	if target, ok := mapping[column]; ok {
+		return target
+	}
+	column = strings.TrimSpace(column)
+	if importFields[column] || column == "data" || strings.HasPrefix(column, "data.") {
+		return column
+	}
+	return "data." + column
+}
+
+// set will save the value of column in person. empty values will be skipped.
+func (mapping importMapping) set(person *model.Person, column string, value interface{}) {
+	if text, ok := value.(string); ok {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return
+		}
+		value = text
+	}
+	if value == nil {
+		return
+	}
+	switch target := mapping.target(column); target {
+	case "-":
+	case "first_name":
+		person.FirstName = fmt.Sprint(value)
+	case "last_name":
+		person.LastName = fmt.Sprint(value)
+	case "username":
+		person.Username = fmt.Sprint(value)
+	case "email":
+		person.Email = fmt.Sprint(value)
+	case "data":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			setData(person, "data", value)
+			return
+		}
+		for key, item := range object {
+			setData(person, key, item)
+		}
+	default:
+		setData(person, strings.TrimPrefix(target, "data."), value)
+	}
+}
+
+func setData(person *model.Person, key string, value interface{}) {
+	if person.Data == nil {
+		person.Data = make(map[string]interface{})
+	}
+	person.Data[key] = value
+}
+
+// importRow is a parsed row of an uploaded file. err is filled when the row could not be parsed.
+type importRow struct {
+	line   int
+	raw    string
+	person *model.Person
+	err    error
+}
+
+// rowReader will read the rows of an uploaded file one by one. it returns io.EOF at the end of file.
+type rowReader interface {
+	Next() (*importRow, error)
+}
+
+// newRowReader will create the row reader of format.
+func newRowReader(format string, r io.Reader, mapping importMapping) (rowReader, error) {
+	switch format {
+	case "csv":
+		return newCSVRowReader(r, mapping)
+	case "ndjson":
+		return newNDJSONRowReader(r, mapping), nil
+	default:
+		return nil, fmt.Errorf("format %q is not supported, use csv or ndjson", format)
+	}
+}
+
+// csvRowReader reads csv files. the first record is the header and holds the column names.
+type csvRowReader struct {
+	reader  *csv.Reader
+	header  []string
+	mapping importMapping
+	line    int
+}
+
+func newCSVRowReader(r io.Reader, mapping importMapping) (*csvRowReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read csv header: %v", err)
+	}
+	return &csvRowReader{reader: reader, header: header, mapping: mapping, line: 1}, nil
+}
+
+// Next will return the next record. line is the record number and header is the first line.
+func (c *csvRowReader) Next() (*importRow, error) {
+	record, err := c.reader.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+	c.line++
+	row := &importRow{line: c.line, raw: joinCSV(record)}
+	if err != nil {
+		row.err = err
+		return row, nil
+	}
+	if len(record) != len(c.header) {
+		row.err = fmt.Errorf("row has %d columns but header has %d", len(record), len(c.header))
+		return row, nil
+	}
+	row.person = new(model.Person)
+	for i, column := range c.header {
+		c.mapping.set(row.person, column, record[i])
+	}
+	return row, nil
+}
+
+func joinCSV(record []string) string {
+	if len(record) == 0 {
+		return ""
+	}
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write(record)
+	writer.Flush()
+	return strings.TrimRight(buffer.String(), "\n")
+}
+
+// ndjsonRowReader reads newline delimited json files, every line is a json object.
+type ndjsonRowReader struct {
+	scanner *bufio.Scanner
+	mapping importMapping
+	line    int
+}
+
+func newNDJSONRowReader(r io.Reader, mapping importMapping) *ndjsonRowReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxNDJSONLine)
+	return &ndjsonRowReader{scanner: scanner, mapping: mapping}
+}
+
+// Next will return the next json object. blank lines will be skipped.
+func (n *ndjsonRowReader) Next() (*importRow, error) {
+	for n.scanner.Scan() {
+		n.line++
+		text := strings.TrimSpace(n.scanner.Text())
+		if text == "" {
+			continue
+		}
+		row := &importRow{line: n.line, raw: text}
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &object); err != nil {
+			row.err = fmt.Errorf("json is incorrect: %v", err)
+			return row, nil
+		}
+		row.person = new(model.Person)
+		for key, value := range object {
+			n.mapping.set(row.person, key, value)
+		}
+		return row, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// importer will write the rows of an import in batches and fill the report of it.
+type importer struct {
+	ctx      context.Context
+	people   store.PeopleStore // store of handlers, it validates and writes the batches
+	db       *mongo.Database
+	progress jobs.ProgressFunc // nil if import is not a job
+	report   *model.ImportReport
+	batch    []*importRow
+	rejected []interface{}  // rejected rows that are not saved in import_errors collection yet.
+	seen     map[string]int // username and email values of file and the line that they are seen first.
+}
+
+func newImporter(ctx context.Context, people store.PeopleStore, db *mongo.Database, report *model.ImportReport) *importer {
+	report.TenantID = tenant.SharedID(ctx)
+	return &importer{
+		ctx:    ctx,
+		people: people,
+		db:     db,
+		report: report,
+		batch:  make([]*importRow, 0, importBatchSize),
+		seen:   make(map[string]int),
+	}
+}
+
+// run will read all rows of reader, write them and save the report in imports collection. dry runs only
+// fill the report. the rejected rows of an earlier run of report are removed first and the report is
+// replaced, so a job that runs again after its worker failed does not report its rows twice.
+func (imp *importer) run(reader rowReader) error {
+	if !imp.report.DryRun {
+		if _, err := imp.db.Collection("import_errors").DeleteMany(imp.ctx, bson.M{"report_id": imp.report.ID}); err != nil {
+			return err
+		}
+	}
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the rest of file can not be read, so we stop here and report it.
+			imp.report.Total++
+			if err := imp.reject(&importRow{}, fmt.Sprintf("could not read file: %v", err)); err != nil {
+				return err
+			}
+			break
+		}
+		imp.report.Total++
+		if err := imp.add(row); err != nil {
+			return err
+		}
+	}
+	if err := imp.flush(); err != nil {
+		return err
+	}
+	if imp.report.DryRun {
+		return nil
+	}
+	if err := imp.flushErrors(); err != nil {
+		return err
+	}
+	if imp.report.Rejected > 0 {
+		imp.report.ErrorReport = fmt.Sprintf("/person/import/%s/errors", imp.report.ID.Hex())
+	}
+	_, err := imp.db.Collection("imports").ReplaceOne(imp.ctx, bson.M{"_id": imp.report.ID}, imp.report, options.Replace().SetUpsert(true))
+	return err
+}
+
+// add will validate row and add it to the current batch.
+func (imp *importer) add(row *importRow) error {
+	if row.err == nil {
+		row.person.Normalize()
+		row.err = row.person.Validate()
+	}
+	if row.err == nil {
+		row.err = imp.checkDuplicate(row)
+	}
+	if row.err != nil {
+		return imp.reject(row, row.err.Error())
+	}
+	imp.batch = append(imp.batch, row)
+	if len(imp.batch) >= importBatchSize {
+		return imp.flush()
+	}
+	return nil
+}
+
+// checkDuplicate will reject the rows that use a username or email of a previous row in the file.
+// they are compared case insensitive like the unique indexes.
+func (imp *importer) checkDuplicate(row *importRow) error {
+	keys := map[string]string{
+		"username": row.person.Username,
+		"email":    row.person.Email,
+	}
+	for field, value := range keys {
+		if line, ok := imp.seen[field+":"+strings.ToLower(value)]; ok {
+			return fmt.Errorf("%s %q is duplicated, it is used on line %d", field, value, line)
+		}
+	}
+	for field, value := range keys {
+		imp.seen[field+":"+strings.ToLower(value)] = row.line
+	}
+	return nil
+}
+
+// flush will write the current batch with the store. people with an existing username will be updated and
+// the rest will be created.
+func (imp *importer) flush() error {
+	if len(imp.batch) == 0 {
+		return nil
+	}
+	defer func() { imp.batch = imp.batch[:0] }()
+
+	personList := make([]*model.Person, len(imp.batch))
+	for i, row := range imp.batch {
+		personList[i] = row.person
+	}
+	results, err := imp.people.ImportMany(imp.ctx, personList, imp.report.DryRun)
+	if err != nil {
+		return err
+	}
+	for i, result := range results {
+		switch {
+		case result.Err != nil:
+			if err := imp.reject(imp.batch[i], importError(result.Err)); err != nil {
+				return err
+			}
+		case result.Updated:
+			imp.report.Updated++
+		default:
+			imp.report.Created++
+		}
+	}
+	if imp.progress != nil {
+		imp.progress(int64(imp.report.Total), 0)
+	}
+	return nil
+}
+
+// importError will return the message of a row that the store did not write.
+func importError(err error) string {
+	var duplicate *store.DuplicateError
+	if errors.As(err, &duplicate) {
+		return fmt.Sprintf("%s already exists.", duplicate.Field)
+	}
+	return err.Error()
+}
+
+// reject will add row to the report as a rejected row.
+func (imp *importer) reject(row *importRow, message string) error {
+	rowError := model.ImportRowError{
+		ReportID: imp.report.ID,
+		Line:     row.line,
+		Error:    message,
+		Row:      row.raw,
+	}
+	imp.report.Rejected++
+	if len(imp.report.Errors) < maxReportedErrors {
+		imp.report.Errors = append(imp.report.Errors, rowError)
+	}
+	if imp.report.DryRun {
+		return nil
+	}
+	imp.rejected = append(imp.rejected, rowError)
+	if len(imp.rejected) >= importBatchSize {
+		return imp.flushErrors()
+	}
+	return nil
+}
+
+// flushErrors will save the rejected rows in import_errors collection.
+func (imp *importer) flushErrors() error {
+	if len(imp.rejected) == 0 {
+		return nil
+	}
+	_, err := imp.db.Collection("import_errors").InsertMany(imp.ctx, imp.rejected)
+	imp.rejected = imp.rejected[:0]
+	return err
+}
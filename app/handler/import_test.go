@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+)
+
+func TestCSVRowReader(t *testing.T) {
+	mapping, err := parseImportMapping(`{"Mail": "email", "Dept": "data.department", "Notes": "-"}`)
+	if err != nil {
+		t.Fatalf("%s parse mapping is failed: %v", failed, err)
+	}
+	file := "username,Mail,first_name,Dept,Notes,age\n" +
+		"john_doe,john@gmail.com,John,sales,skip me,21\n" +
+		"jane_doe,jane@gmail.com\n"
+	reader, err := newRowReader("csv", strings.NewReader(file), mapping)
+	if err != nil {
+		t.Fatalf("%s create csv reader is failed: %v", failed, err)
+	}
+
+	row, err := reader.Next()
+	if err != nil || row.err != nil {
+		t.Fatalf("%s read first row is failed: %v %v", failed, err, row.err)
+	}
+	person := row.person
+	if person.Username != "john_doe" || person.Email != "john@gmail.com" || person.FirstName != "John" {
+		t.Errorf("%s person fields are not mapped: %+v", failed, person)
+	}
+	if person.Data["department"] != "sales" || person.Data["age"] != "21" || person.Data["Notes"] != nil {
+		t.Errorf("%s data keys are not mapped: %v", failed, person.Data)
+	}
+	if row.line != 2 {
+		t.Errorf("%s wrong line number: got %d want %d", failed, row.line, 2)
+	}
+
+	row, err = reader.Next()
+	if err != nil || row.err == nil {
+		t.Errorf("%s row with missing columns must be rejected", failed)
+	}
+
+	if _, err = reader.Next(); err != io.EOF {
+		t.Errorf("%s reader must return io.EOF at the end of file: got %v", failed, err)
+	}
+	t.Logf("%s Testing csv row reader is successful", succeed)
+}
+
+func TestNDJSONRowReader(t *testing.T) {
+	file := `{"username": "john_doe", "email": "john@gmail.com", "data": {"age": 21}, "city": "Tehran"}` + "\n" +
+		"\n" +
+		`{"username": "jane_doe",` + "\n"
+	reader, _ := newRowReader("ndjson", strings.NewReader(file), importMapping{})
+
+	row, err := reader.Next()
+	if err != nil || row.err != nil {
+		t.Fatalf("%s read first row is failed: %v %v", failed, err, row.err)
+	}
+	if row.person.Username != "john_doe" || row.person.Data["age"] != float64(21) || row.person.Data["city"] != "Tehran" {
+		t.Errorf("%s json object is not mapped: %+v", failed, row.person)
+	}
+
+	row, err = reader.Next()
+	if err != nil || row.err == nil || row.line != 3 {
+		t.Errorf("%s broken json line must be rejected on line 3", failed)
+	}
+	t.Logf("%s Testing ndjson row reader is successful", succeed)
+}
+
+func TestParseImportMapping(t *testing.T) {
+	if _, err := parseImportMapping(`{"Mail": "mail"}`); err == nil {
+		t.Errorf("%s mapping to unknown field must fail", failed)
+	}
+	if _, err := parseImportMapping(`{"Mail": "data."}`); err == nil {
+		t.Errorf("%s mapping to empty data key must fail", failed)
+	}
+	t.Logf("%s Testing import mapping validation is successful", succeed)
+}
+
+func TestDryRunImportWritesNothing(t *testing.T) {
+	file := "username,email\n" +
+		",missing@gmail.com\n" +
+		"john_doe,not an email\n"
+	reader, err := newRowReader("csv", strings.NewReader(file), nil)
+	if err != nil {
+		t.Fatalf("%s create csv reader is failed: %v", failed, err)
+	}
+	// a dry run without database fails if it writes the report or rejected rows.
+	imp := newImporter(context.Background(), nil, nil, model.NewImportReport("people.csv", "csv", true))
+	if err := imp.run(reader); err != nil {
+		t.Fatalf("%s dry run must not write: %v", failed, err)
+	}
+	report := imp.report
+	if report.Total != 2 || report.Rejected != 2 || len(report.Errors) != 2 || report.ErrorReport != "" || len(imp.rejected) != 0 {
+		t.Fatalf("%s report of dry run must only be in response: %+v", failed, report)
+	}
+	t.Logf("%s Testing dry run import is successful", succeed)
+}
+
+func TestDryRunImportRejectsExistingEmails(t *testing.T) {
+	john := model.NewPerson("", "", "john", "john@example.com", nil)
+	memory, _ := store.NewMemory(john)
+	file := "username,email,first_name\n" +
+		"JOHN,johnny@example.com,John\n" +
+		"jane,John@example.com,Jane\n" +
+		"bob,bob@example.com,Bob\n"
+	reader, err := newRowReader("csv", strings.NewReader(file), nil)
+	if err != nil {
+		t.Fatalf("%s create csv reader is failed: %v", failed, err)
+	}
+	imp := newImporter(context.Background(), store.NewValidated(memory), nil, model.NewImportReport("people.csv", "csv", true))
+	if err := imp.run(reader); err != nil {
+		t.Fatalf("%s dry run returned error: %v", failed, err)
+	}
+	report := imp.report
+	if report.Updated != 1 || report.Created != 1 || report.Rejected != 1 || report.Errors[0].Line != 3 || report.Errors[0].Error != "email already exists." {
+		t.Fatalf("%s dry run must reject the email of john: %+v", failed, report)
+	}
+	if personList, _ := memory.Find(context.Background(), store.Filter{}, 0, 0); len(personList) != 1 || personList[0].FirstName != "" {
+		t.Fatalf("%s dry run must not write people: %+v", failed, personList)
+	}
+	t.Logf("%s Testing dry run import of existing emails is successful", succeed)
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/katoozi/golang-mongodb-rest-api/app/jobs"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetJob will give us the state, progress and result of a background job
+func GetJob(db *mongo.Database, res http.ResponseWriter, req *http.Request) {
+	var params = mux.Vars(req)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		ErrorResponse(res, req, CodeInvalidID, "id that you sent is wrong!!!", nil)
+		return
+	}
+	job, err := jobs.Get(req.Context(), db, id)
+	if err != nil {
+		switch err {
+		case jobs.ErrNotFound:
+			ErrorResponse(res, req, CodeJobNotFound, err.Error(), nil)
+		default:
+			log.Printf("Error while reading job: %v\n", err)
+			ErrorResponse(res, req, CodeInternal, "there is an error on server!!!", nil)
+		}
+		return
+	}
+	ResponseWriter(res, http.StatusOK, "", job)
+}
+
+// CancelJob will cancel a background job. queued jobs are cancelled right away and
+// running jobs will be stopped by their worker, so we return accepted status for them.
+func CancelJob(db *mongo.Database, res http.ResponseWriter, req *http.Request) {
+	var params = mux.Vars(req)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		ErrorResponse(res, req, CodeInvalidID, "id that you sent is wrong!!!", nil)
+		return
+	}
+	job, err := jobs.Cancel(req.Context(), db, id)
+	if err != nil {
+		switch err {
+		case jobs.ErrNotFound:
+			ErrorResponse(res, req, CodeJobNotFound, err.Error(), nil)
+		case jobs.ErrFinished:
+			ErrorResponse(res, req, CodeJobFinished, err.Error(), job)
+		default:
+			log.Printf("Error while cancelling job: %v\n", err)
+			ErrorResponse(res, req, CodeInternal, "there is an error on server!!!", nil)
+		}
+		return
+	}
+	if job.Finished() {
+		ResponseWriter(res, http.StatusOK, "", job)
+	} else {
+		ResponseWriter(res, http.StatusAccepted, "cancel is requested, job will be stopped soon.", job)
+	}
+}
@@ -1,11 +1,34 @@
 package handler
 
-import "net/http"
+import (
+	"net/http"
 
-// JSONContentTypeMiddleware will add the json content type header for all endpoints
-func JSONContentTypeMiddleware(next http.Handler) http.Handler {
+	"github.com/katoozi/golang-mongodb-rest-api/app/codec"
+)
+
+// Negotiation is the config of content negotiation, it picks the response codec of endpoints from Accept header.
+type Negotiation struct {
+	// OwnFormat reports the requests whose endpoint writes its own format, like csv downloads. they are
+	// served whatever their Accept header is, nil means that all endpoints write with the codecs.
+	OwnFormat func(req *http.Request) bool
+}
+
+// NegotiationMiddleware is the Middleware of a Negotiation without endpoints that write their own format.
+func NegotiationMiddleware(next http.Handler) http.Handler {
+	return Negotiation{}.Middleware(next)
+}
+
+// Middleware will pick the response codec of endpoints from Accept header. requests that accept none of
+// the codecs get 406 before their endpoint runs, so they have no side effects.
+func (n Negotiation) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("content-type", "application/json; charset=UTF-8")
-		next.ServeHTTP(w, r)
+		w.Header().Add("Vary", "Accept")
+		responseCodec, _ := codec.Negotiate(r.Header.Get("Accept"))
+		writer := &negotiatedWriter{ResponseWriter: w, codec: responseCodec}
+		if responseCodec == nil && (n.OwnFormat == nil || !n.OwnFormat(r)) {
+			ErrorResponse(writer, r, CodeNotAcceptable, CodeNotAcceptable.Title, codec.MediaTypes())
+			return
+		}
+		next.ServeHTTP(writer, r)
 	})
 }
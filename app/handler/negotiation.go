@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/codec"
+)
+
+// negotiatedWriter is the http.ResponseWriter of NegotiationMiddleware, codec is nil if client accepts none of the codecs.
+type negotiatedWriter struct {
+	http.ResponseWriter
+	codec codec.Codec
+}
+
+// Unwrap will return the original http.ResponseWriter.
+func (w *negotiatedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// responseCodec will find the negotiated codec of res. writers that are not wrapped by
+// NegotiationMiddleware use the default codec. ok is false if client accepts none of the codecs.
+func responseCodec(res http.ResponseWriter) (responseCodec codec.Codec, ok bool) {
+	for _, w := range writers(res) {
+		if w, ok := w.(*negotiatedWriter); ok {
+			return w.codec, w.codec != nil
+		}
+	}
+	return codec.Default(), true
+}
+
+// writers will return res and the writers that it wraps, outermost first.
+func writers(res http.ResponseWriter) []http.ResponseWriter {
+	all := []http.ResponseWriter{res}
+	for {
+		wrapper, ok := res.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return all
+		}
+		res = wrapper.Unwrap()
+		all = append(all, res)
+	}
+}
+
+// DecodeBody will decode request body with the codec of its content type, body without content type is json.
+// it returns codec.ErrUnsupportedMediaType if there is no codec for content type.
+func DecodeBody(req *http.Request, v interface{}) error {
+	requestCodec, err := codec.ForContentType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	return requestCodec.Decode(req.Body, v)
+}
+
+// BodyErrorResponse will write the error of DecodeBody, message is the detail of invalid bodies.
+func BodyErrorResponse(res http.ResponseWriter, req *http.Request, err error, message string) {
+	if err == codec.ErrUnsupportedMediaType {
+		ErrorResponse(res, req, CodeUnsupportedMediaType, "content type is not supported.", codec.MediaTypes())
+		return
+	}
+	ErrorResponse(res, req, CodeInvalidBody, message, nil)
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/codec"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+)
+
+func TestNegotiation(t *testing.T) {
+	called := false
+	endpoint := NegotiationMiddleware(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		called = true
+		person := new(model.Person)
+		if err := DecodeBody(req, person); err != nil {
+			BodyErrorResponse(res, req, err, "body is incorrect")
+			return
+		}
+		ResponseWriter(res, http.StatusOK, "", person)
+	}))
+	msgpack, _ := codec.Lookup("application/msgpack")
+	var body bytes.Buffer
+	msgpack.Encode(&body, model.NewPerson("john", "doe", "john_doe", "john@gmail.com", nil))
+
+	req := httptest.NewRequest("POST", "/person", bytes.NewReader(body.Bytes()))
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Accept", "application/yaml")
+	rr := httptest.NewRecorder()
+	endpoint.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("content-type") != "application/yaml; charset=UTF-8" {
+		t.Fatalf("%s yaml response is wrong: %d %s", failed, rr.Code, rr.Header().Get("content-type"))
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte("username: john_doe")) {
+		t.Errorf("%s yaml body is wrong: %s", failed, rr.Body)
+	}
+
+	called = false
+	req = httptest.NewRequest("POST", "/person", bytes.NewReader(body.Bytes()))
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Accept", "text/html")
+	rr = httptest.NewRecorder()
+	endpoint.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotAcceptable {
+		t.Errorf("%s not acceptable status is wrong: %d", failed, rr.Code)
+	}
+	if called {
+		t.Errorf("%s endpoint must not run for a request that accepts none of the codecs", failed)
+	}
+
+	ownFormat := Negotiation{OwnFormat: func(req *http.Request) bool { return req.URL.Path == "/report.csv" }}.Middleware(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("content-type", "text/csv")
+		res.Write([]byte("line,error\n"))
+	}))
+	req = httptest.NewRequest("GET", "/report.csv", nil)
+	req.Header.Set("Accept", "text/csv")
+	rr = httptest.NewRecorder()
+	ownFormat.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("content-type") != "text/csv" {
+		t.Errorf("%s endpoints of their own format must be served: %d", failed, rr.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/person", bytes.NewBufferString("john"))
+	req.Header.Set("Content-Type", "text/plain")
+	rr = httptest.NewRecorder()
+	endpoint.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("%s unsupported media type status is wrong: %d", failed, rr.Code)
+	}
+	t.Logf("%s Testing content negotiation is successful", succeed)
+}
@@ -1,126 +1,120 @@
 package handler
 
 import (
-	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/katoozi/golang-mongodb-rest-api/app/model"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"golang.org/x/net/context"
 )
 
-// results count per page
-var limit int64 = 10
-
 // CreatePerson will handle the create person post request
-func CreatePerson(db *mongo.Database, res http.ResponseWriter, req *http.Request) {
+func CreatePerson(people store.PeopleStore, res http.ResponseWriter, req *http.Request) {
 	person := new(model.Person)
-	err := json.NewDecoder(req.Body).Decode(person)
+	err := DecodeBody(req, person)
 	if err != nil {
-		ResponseWriter(res, http.StatusBadRequest, "body json request have issues!!!", nil)
+		BodyErrorResponse(res, req, err, "body json request have issues!!!")
 		return
 	}
-	result, err := db.Collection("people").InsertOne(nil, person)
-	if err != nil {
-		switch err.(type) {
-		case mongo.WriteException:
-			ResponseWriter(res, http.StatusNotAcceptable, "username or email already exists in database.", nil)
-		default:
-			ResponseWriter(res, http.StatusInternalServerError, "Error while inserting data.", nil)
-		}
+	if err := people.Create(req.Context(), person); err != nil {
+		storeErrorResponse(res, req, err, "Error while inserting data.")
 		return
 	}
-	person.ID = result.InsertedID.(primitive.ObjectID)
 	ResponseWriter(res, http.StatusCreated, "", person)
 }
 
 // GetPersons will handle people list get request
-func GetPersons(db *mongo.Database, res http.ResponseWriter, req *http.Request) {
-	var personList []model.Person
+func GetPersons(people store.PeopleStore, res http.ResponseWriter, req *http.Request) {
 	pageString := req.FormValue("page")
 	page, err := strconv.ParseInt(pageString, 10, 64)
 	if err != nil {
 		page = 0
 	}
-	page = page * limit
-	findOptions := options.FindOptions{
-		Skip:  &page,
-		Limit: &limit,
-		Sort: bson.M{
-			"_id": -1, // -1 for descending and 1 for ascending
-		},
-	}
-	curser, err := db.Collection("people").Find(nil, bson.M{}, &findOptions)
+	personList, err := people.List(req.Context(), page)
 	if err != nil {
 		log.Printf("Error while quering collection: %v\n", err)
-		ResponseWriter(res, http.StatusInternalServerError, "Error happend while reading data", nil)
-		return
-	}
-	err = curser.All(context.Background(), &personList)
-	if err != nil {
-		log.Fatalf("Error in curser: %v", err)
-		ResponseWriter(res, http.StatusInternalServerError, "Error happend while reading data", nil)
+		ErrorResponse(res, req, CodeInternal, "Error happend while reading data", nil)
 		return
 	}
 	ResponseWriter(res, http.StatusOK, "", personList)
 }
 
 // GetPerson will give us person with special id
-func GetPerson(db *mongo.Database, res http.ResponseWriter, req *http.Request) {
+func GetPerson(people store.PeopleStore, res http.ResponseWriter, req *http.Request) {
 	var params = mux.Vars(req)
 	id, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		ResponseWriter(res, http.StatusBadRequest, "id that you sent is wrong!!!", nil)
+		ErrorResponse(res, req, CodeInvalidID, "id that you sent is wrong!!!", nil)
 		return
 	}
-	var person model.Person
-	err = db.Collection("people").FindOne(nil, model.Person{ID: id}).Decode(&person)
+	person, err := people.Get(req.Context(), id)
 	if err != nil {
-		switch err {
-		case mongo.ErrNoDocuments:
-			ResponseWriter(res, http.StatusNotFound, "person not found", nil)
-		default:
-			log.Printf("Error while decode to go struct:%v\n", err)
-			ResponseWriter(res, http.StatusInternalServerError, "there is an error on server!!!", nil)
-		}
+		storeErrorResponse(res, req, err, "there is an error on server!!!")
 		return
 	}
 	ResponseWriter(res, http.StatusOK, "", person)
 }
 
 // UpdatePerson will handle the person update endpoint
-func UpdatePerson(db *mongo.Database, res http.ResponseWriter, req *http.Request) {
+func UpdatePerson(people store.PeopleStore, res http.ResponseWriter, req *http.Request) {
 	var updateData map[string]interface{}
-	err := json.NewDecoder(req.Body).Decode(&updateData)
+	err := DecodeBody(req, &updateData)
 	if err != nil {
-		ResponseWriter(res, http.StatusBadRequest, "json body is incorrect", nil)
+		BodyErrorResponse(res, req, err, "json body is incorrect")
 		return
 	}
-	// we dont handle the json decode return error because all our fields have the omitempty tag.
 	var params = mux.Vars(req)
 	oid, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		ResponseWriter(res, http.StatusBadRequest, "id that you sent is wrong!!!", nil)
+		ErrorResponse(res, req, CodeInvalidID, "id that you sent is wrong!!!", nil)
 		return
 	}
-	update := bson.M{
-		"$set": updateData,
+	if err := people.Update(req.Context(), oid, updateData); err != nil {
+		storeErrorResponse(res, req, err, "error in updating document!!!")
+		return
 	}
-	result, err := db.Collection("people").UpdateOne(context.Background(), model.Person{ID: oid}, update)
+	ResponseWriter(res, http.StatusAccepted, "", &updateData)
+}
+
+// DeletePerson will handle the person delete endpoint
+func DeletePerson(people store.PeopleStore, res http.ResponseWriter, req *http.Request) {
+	var params = mux.Vars(req)
+	id, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		log.Printf("Error while updateing document: %v", err)
-		ResponseWriter(res, http.StatusInternalServerError, "error in updating document!!!", nil)
+		ErrorResponse(res, req, CodeInvalidID, "id that you sent is wrong!!!", nil)
 		return
 	}
-	if result.MatchedCount == 1 {
-		ResponseWriter(res, http.StatusAccepted, "", &updateData)
-	} else {
-		ResponseWriter(res, http.StatusNotFound, "person not found", nil)
+	if err := people.Delete(req.Context(), id); err != nil {
+		storeErrorResponse(res, req, err, "error in deleting document!!!")
+		return
+	}
+	ResponseWriter(res, http.StatusOK, "person is deleted.", map[string]string{"id": id.Hex()})
+}
+
+// storeErrorResponse will write the error of a store call, message is the detail of internal errors.
+func storeErrorResponse(res http.ResponseWriter, req *http.Request, err error, message string) {
+	var duplicate *store.DuplicateError
+	var invalid *store.ValidationError
+	switch {
+	case err == store.ErrNotFound:
+		ErrorResponse(res, req, CodePersonNotFound, "person not found", nil)
+	case errors.As(err, &duplicate):
+		conflictResponse(res, req, duplicate.Field)
+	case errors.As(err, &invalid):
+		fields := make([]FieldError, len(invalid.Fields))
+		for i, field := range invalid.Fields {
+			if field.Field == "" {
+				field.Field = "body"
+			}
+			fields[i] = FieldError{field.Field, field.Message}
+		}
+		ErrorResponse(res, req, CodeInvalidBody, invalid.Error(), nil, fields...)
+	default:
+		log.Printf("Error of people store: %v\n", err)
+		ErrorResponse(res, req, CodeInternal, message, nil)
 	}
 }
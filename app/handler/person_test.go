@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -10,22 +11,21 @@ import (
 
 	"github.com/katoozi/golang-mongodb-rest-api/app/db"
 	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
 	"github.com/katoozi/golang-mongodb-rest-api/config"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/x/bsonx"
 )
 
 const succeed = "\u2713"
 const failed = "\u2717"
 
-func handleRequest(db *mongo.Database, handler func(db *mongo.Database, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+func handleRequest(people store.PeopleStore, handler func(people store.PeopleStore, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		handler(db, w, r)
+		handler(people, w, r)
 	}
 }
 
 func createNewRequestNewRecorder(method, endpoint string, body io.Reader) (*http.Request, *httptest.ResponseRecorder) {
-	req, _ := http.NewRequest("POST", "/person", body)
+	req, _ := http.NewRequest(method, endpoint, body)
 	rr := httptest.NewRecorder()
 	return req, rr
 }
@@ -36,7 +36,7 @@ func TestCreatePerson(t *testing.T) {
 
 	person, _ := json.Marshal(model.NewPerson("john", "doe", "john_doe", "john@gmail.com", nil))
 
-	httpHandler := http.HandlerFunc(handleRequest(dbConnection, CreatePerson))
+	httpHandler := http.HandlerFunc(handleRequest(store.NewPeople(dbConnection), CreatePerson))
 
 	// check http created status
 	req, rr := createNewRequestNewRecorder("POST", "/person", bytes.NewBuffer(person))
@@ -56,22 +56,29 @@ func TestCreatePerson(t *testing.T) {
 		t.Logf("%s check StatusBadRequest is successfull.", succeed)
 	}
 
-	// check http not acceptable status
-	// username or email that you sent already exists collection
-	// username and email will be unique.
-	keys := bsonx.Doc{
-		{Key: "username", Value: bsonx.Int32(1)},
-		{Key: "email", Value: bsonx.Int32(1)},
-	}
-	people := dbConnection.Collection("people")
-	db.SetIndexes(people, keys)
+	// check http conflict status
+	// username and email are unique on their own and case insensitive.
+	plan, _ := db.PlanIndexes(context.Background(), dbConnection, db.Indexes)
+	plan.Apply(context.Background(), dbConnection, false)
 
 	req, rr = createNewRequestNewRecorder("POST", "/person", bytes.NewBuffer(person))
 	httpHandler.ServeHTTP(rr, req)
-	if status := rr.Code; status != http.StatusNotAcceptable {
-		t.Errorf("%s check StatusNotAcceptable return wrong status code: got %d want %d", failed, status, http.StatusNotAcceptable)
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("%s check StatusConflict return wrong status code: got %d want %d", failed, status, http.StatusConflict)
+	} else {
+		t.Logf("%s check StatusConflict is successfull.", succeed)
+	}
+
+	// same username in another case with a new email must conflict on username.
+	sameUsername, _ := json.Marshal(model.NewPerson("john", "doe", "JOHN_DOE", "other@gmail.com", nil))
+	req, rr = createNewRequestNewRecorder("POST", "/person", bytes.NewBuffer(sameUsername))
+	httpHandler.ServeHTTP(rr, req)
+	var response model.Response
+	json.NewDecoder(rr.Body).Decode(&response)
+	if content, _ := response.Content.(map[string]interface{}); rr.Code != http.StatusConflict || content["field"] != "username" {
+		t.Errorf("%s check username conflict is failed: got %d %v", failed, rr.Code, response.Content)
 	} else {
-		t.Logf("%s check StatusNotAcceptable is successfull.", succeed)
+		t.Logf("%s check username conflict is successfull.", succeed)
 	}
 
 	// check http internal server error status
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// problemMediaType is the media type of RFC 7807 problem details.
+const problemMediaType = "application/problem+json"
+
+// ErrorCode is an entry of error catalog. codes are stable, clients can rely on them instead of messages.
+type ErrorCode struct {
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+}
+
+// error catalog
+var (
+	CodeInvalidBody    = ErrorCode{"invalid_body", http.StatusBadRequest, "Request body is not valid"}
+	CodeInvalidID      = ErrorCode{"invalid_id", http.StatusBadRequest, "Id is not valid"}
+	CodeInvalidForm    = ErrorCode{"invalid_form", http.StatusBadRequest, "Form is not valid"}
+	CodeInvalidRequest = ErrorCode{"invalid_request", http.StatusBadRequest, "Request does not match the api document"}
+	CodeInvalidTenant  = ErrorCode{"invalid_tenant", http.StatusBadRequest, "Tenant of request is missing or not valid"}
+	CodeUnauthorized   = ErrorCode{"unauthorized", http.StatusUnauthorized, "Credentials are missing or not valid"}
+	CodePersonNotFound = ErrorCode{"person_not_found", http.StatusNotFound, "Person not found"}
+	CodeImportNotFound = ErrorCode{"import_not_found", http.StatusNotFound, "Import not found"}
+	CodeJobNotFound    = ErrorCode{"job_not_found", http.StatusNotFound, "Job not found"}
+	CodeJobFinished    = ErrorCode{"job_finished", http.StatusConflict, "Job is already finished"}
+	CodePersonErased   = ErrorCode{"person_erased", http.StatusConflict, "Person is already erased"}
+	CodeDuplicateField = ErrorCode{"duplicate_field", http.StatusConflict, "Unique field already exists"}
+	CodeQueryLimit     = ErrorCode{"query_limit", http.StatusBadRequest, "GraphQL query is too deep or too complex"}
+	CodeInternal       = ErrorCode{"internal_error", http.StatusInternalServerError, "Internal server error"}
+
+	CodeNotAcceptable        = ErrorCode{"not_acceptable", http.StatusNotAcceptable, "None of the accepted media types is supported"}
+	CodeUnsupportedMediaType = ErrorCode{"unsupported_media_type", http.StatusUnsupportedMediaType, "Content type of body is not supported"}
+	CodeUnsupportedEncoding  = ErrorCode{"unsupported_encoding", http.StatusUnsupportedMediaType, "Content encoding of body is not supported"}
+)
+
+// Catalog is all the errors that api returns.
+var Catalog = []ErrorCode{
+	CodeInvalidBody,
+	CodeInvalidID,
+	CodeInvalidForm,
+	CodeInvalidRequest,
+	CodeInvalidTenant,
+	CodeUnauthorized,
+	CodePersonNotFound,
+	CodeImportNotFound,
+	CodeJobNotFound,
+	CodeJobFinished,
+	CodePersonErased,
+	CodeDuplicateField,
+	CodeQueryLimit,
+	CodeInternal,
+	CodeNotAcceptable,
+	CodeUnsupportedMediaType,
+	CodeUnsupportedEncoding,
+}
+
+type (
+	// Problem is the RFC 7807 problem details response. code and errors are extension members.
+	Problem struct {
+		Type     string       `json:"type"`
+		Title    string       `json:"title"`
+		Status   int          `json:"status"`
+		Detail   string       `json:"detail,omitempty"`
+		Instance string       `json:"instance,omitempty"`
+		Code     string       `json:"code"`
+		Errors   []FieldError `json:"errors,omitempty"`
+	}
+
+	// FieldError is the problem of a request field.
+	FieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+)
+
+// problemType will return the type uri of code, it is served by GetProblem.
+func problemType(code string) string {
+	return "/problems/" + code
+}
+
+// ErrorResponse will write an error of catalog. if client accepts application/problem+json it is
+// written as a Problem, otherwise the legacy Response envelope with detail as message and content is written.
+func ErrorResponse(res http.ResponseWriter, req *http.Request, code ErrorCode, detail string, content interface{}, fields ...FieldError) error {
+	if !acceptsProblem(req) {
+		return ResponseWriter(res, code.Status, detail, content)
+	}
+	problem := Problem{
+		Type:     problemType(code.Code),
+		Title:    code.Title,
+		Status:   code.Status,
+		Detail:   detail,
+		Instance: req.URL.RequestURI(),
+		Code:     code.Code,
+		Errors:   fields,
+	}
+	res.Header().Set("content-type", problemMediaType)
+	res.WriteHeader(code.Status)
+	return json.NewEncoder(res).Encode(problem)
+}
+
+// acceptsProblem will return true if Accept header prefers application/problem+json to application/json.
+func acceptsProblem(req *http.Request) bool {
+	problemQuality, jsonQuality := -1.0, -1.0
+	for _, part := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+		switch mediaType {
+		case problemMediaType:
+			problemQuality = quality
+		case "application/json":
+			jsonQuality = quality
+		}
+	}
+	return problemQuality > 0 && problemQuality >= jsonQuality
+}
+
+// GetProblem will give us the catalog entry of a problem type
+func GetProblem(res http.ResponseWriter, req *http.Request) {
+	code := mux.Vars(req)["code"]
+	for _, entry := range Catalog {
+		if entry.Code == code {
+			ResponseWriter(res, http.StatusOK, "", entry)
+			return
+		}
+	}
+	ResponseWriter(res, http.StatusNotFound, "problem type not found", nil)
+}
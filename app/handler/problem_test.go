@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+)
+
+func TestAcceptsProblem(t *testing.T) {
+	cases := map[string]bool{
+		"":                         false,
+		"application/json":         false,
+		"*/*":                      false,
+		"application/problem+json": true,
+		"application/json, application/problem+json;q=0.5": false,
+		"application/json;q=0.5, application/problem+json": true,
+		"application/problem+json;q=0":                     false,
+	}
+	for accept, want := range cases {
+		req := httptest.NewRequest("GET", "/person/1", nil)
+		req.Header.Set("Accept", accept)
+		if got := acceptsProblem(req); got != want {
+			t.Errorf("%s accepts problem of %q is wrong: got %t want %t", failed, accept, got, want)
+		}
+	}
+	t.Logf("%s Testing problem content negotiation is successful", succeed)
+}
+
+func TestErrorResponse(t *testing.T) {
+	req := httptest.NewRequest("POST", "/person?x=1", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	rr := httptest.NewRecorder()
+	conflictResponse(rr, req, "email")
+
+	if rr.Code != http.StatusConflict || rr.Header().Get("content-type") != problemMediaType {
+		t.Fatalf("%s problem status or content type is wrong: %d %s", failed, rr.Code, rr.Header().Get("content-type"))
+	}
+	var problem Problem
+	json.NewDecoder(rr.Body).Decode(&problem)
+	if problem.Code != "duplicate_field" || problem.Type != "/problems/duplicate_field" || problem.Instance != "/person?x=1" ||
+		len(problem.Errors) != 1 || problem.Errors[0].Field != "email" {
+		t.Errorf("%s problem body is wrong: %+v", failed, problem)
+	}
+
+	// legacy clients get the response envelope
+	req.Header.Del("Accept")
+	rr = httptest.NewRecorder()
+	conflictResponse(rr, req, "email")
+	var response model.Response
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Status != http.StatusConflict || response.Message != "email already exists." {
+		t.Errorf("%s legacy response is wrong: %+v", failed, response)
+	}
+	t.Logf("%s Testing error response formats is successful", succeed)
+}
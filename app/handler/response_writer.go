@@ -1,16 +1,56 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
+	"strings"
 
-	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/codec"
 )
 
-// ResponseWriter will write result in http.ResponseWriter
+// ResponseWriter will write result in http.ResponseWriter with the negotiated codec and the serializer of
+// api version. if client accepts none of the codecs, 406 with the supported media types is written as json.
 func ResponseWriter(res http.ResponseWriter, statusCode int, message string, data interface{}) error {
+	responseCodec, ok := responseCodec(res)
+	if !ok {
+		responseCodec = codec.Default()
+		statusCode, message, data = CodeNotAcceptable.Status, CodeNotAcceptable.Title, codec.MediaTypes()
+	}
+	version, mediaType := ResponseVersion(res)
+	contentType := responseCodec.ContentType()
+	if vendorCodec, ok := codec.Lookup(mediaType); ok && vendorCodec == responseCodec {
+		// clients that ask for a version with its media type get it back.
+		if i := strings.Index(contentType, ";"); i >= 0 {
+			mediaType += contentType[i:]
+		}
+		contentType = mediaType
+	}
+	res.Header().Set("content-type", contentType)
+	httpResponse := version.Serializer(statusCode, message, data)
+	for _, w := range writers(res) {
+		if w, ok := w.(*validatingWriter); ok {
+			w.validate(version, statusCode, contentType, httpResponse)
+		}
+	}
 	res.WriteHeader(statusCode)
-	httpResponse := model.NewResponse(statusCode, message, data)
-	err := json.NewEncoder(res).Encode(httpResponse)
+	err := responseCodec.Encode(res, httpResponse)
 	return err
 }
+
+// ResponseValidator checks a response of ResponseWriter before it is written, body is the serialized response of version.
+type ResponseValidator func(version *Version, statusCode int, contentType string, body interface{})
+
+// validatingWriter is the http.ResponseWriter of WithResponseValidator.
+type validatingWriter struct {
+	http.ResponseWriter
+	validate ResponseValidator
+}
+
+// Unwrap will return the original http.ResponseWriter.
+func (w *validatingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// WithResponseValidator will wrap res, responses that are written to it by ResponseWriter are passed to validate.
+func WithResponseValidator(res http.ResponseWriter, validate ResponseValidator) http.ResponseWriter {
+	return &validatingWriter{ResponseWriter: res, validate: validate}
+}
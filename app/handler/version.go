@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+)
+
+// Serializer will create the response body of ResponseWriter.
+type Serializer func(statusCode int, message string, data interface{}) interface{}
+
+// EnvelopeSerializer writes the model.Response envelope, status is repeated in body.
+func EnvelopeSerializer(statusCode int, message string, data interface{}) interface{} {
+	return model.NewResponse(statusCode, message, data)
+}
+
+// ContentSerializer writes the model.ContentResponse envelope, status is only in the status line.
+func ContentSerializer(statusCode int, message string, data interface{}) interface{} {
+	return model.NewContentResponse(message, data)
+}
+
+// Version is an api version. all versions share the handlers, only their response bodies are different.
+type Version struct {
+	Name        string // path prefix and media type version, like v2.
+	Serializer  Serializer
+	Deprecation time.Time // the version is deprecated since this date, zero if it is not deprecated.
+	Sunset      time.Time // the version is removed after this date, zero if it is not known.
+}
+
+// api versions, the dates of v1 are set from config when app is initialized.
+var (
+	V1 = &Version{Name: "v1", Serializer: EnvelopeSerializer}
+	V2 = &Version{Name: "v2", Serializer: ContentSerializer}
+)
+
+// Versions are the api versions, the first one is used for requests that do not ask for a version.
+var Versions = VersionList{V1, V2}
+
+// Deprecated will return true if version has a deprecation date.
+func (version *Version) Deprecated() bool {
+	return !version.Deprecation.IsZero()
+}
+
+// Middleware will write the responses of next with the serializer of version and add the
+// Deprecation (RFC 9745) and Sunset (RFC 8594) headers of deprecated versions.
+func (version *Version) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		version.serve(next, res, req, "", true)
+	})
+}
+
+// serve will call next with version, mediaType is the vendor media type that client asked for version with.
+// deprecation headers are only added if client asked for version explicitly, clients of the default
+// version have not chosen it.
+func (version *Version) serve(next http.Handler, res http.ResponseWriter, req *http.Request, mediaType string, explicit bool) {
+	if explicit && version.Deprecated() {
+		res.Header().Set("Deprecation", "@"+strconv.FormatInt(version.Deprecation.Unix(), 10))
+		if !version.Sunset.IsZero() {
+			res.Header().Set("Sunset", version.Sunset.UTC().Format(http.TimeFormat))
+		}
+	}
+	next.ServeHTTP(&versionedWriter{ResponseWriter: res, version: version, mediaType: mediaType}, req)
+}
+
+// versionedWriter is the http.ResponseWriter of version middlewares.
+type versionedWriter struct {
+	http.ResponseWriter
+	version   *Version
+	mediaType string
+}
+
+// Unwrap will return the original http.ResponseWriter.
+func (w *versionedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ResponseVersion will return the api version of res and the vendor media type that client asked for it
+// with. writers that are not wrapped by a version middleware are the default version.
+func ResponseVersion(res http.ResponseWriter) (version *Version, mediaType string) {
+	for _, w := range writers(res) {
+		if w, ok := w.(*versionedWriter); ok {
+			return w.version, w.mediaType
+		}
+	}
+	return Versions[0], ""
+}
+
+// VersionList is a list of api versions.
+type VersionList []*Version
+
+// Lookup will return the version of name.
+func (versions VersionList) Lookup(name string) (*Version, bool) {
+	for _, version := range versions {
+		if version.Name == name {
+			return version, true
+		}
+	}
+	return nil, false
+}
+
+// vendorMediaType matches the media types of versions, like application/vnd.person.v2+json.
+var vendorMediaType = regexp.MustCompile(`^application/vnd\.person\.(v[0-9]+)(\+[a-z0-9.-]+)?$`)
+
+// Negotiate will return the version of the first vendor media type of accept header. requests without
+// a vendor media type are the default version, name is the unknown version if ok is false.
+func (versions VersionList) Negotiate(accept string) (version *Version, mediaType, name string, ok bool) {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		match := vendorMediaType.FindStringSubmatch(mediaType)
+		if match == nil {
+			continue
+		}
+		version, ok := versions.Lookup(match[1])
+		return version, mediaType, match[1], ok
+	}
+	return versions[0], "", versions[0].Name, true
+}
+
+// Middleware will serve next with the version of Accept vendor media type, unknown versions get 406.
+// requests without a vendor media type are the default version and get no deprecation headers.
+func (versions VersionList) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		version, mediaType, name, ok := versions.Negotiate(req.Header.Get("Accept"))
+		if !ok {
+			names := make([]string, len(versions))
+			for i, version := range versions {
+				names[i] = version.Name
+			}
+			ErrorResponse(res, req, CodeNotAcceptable, fmt.Sprintf("api version %s is not supported.", name), names)
+			return
+		}
+		version.serve(next, res, req, mediaType, mediaType != "")
+	})
+}
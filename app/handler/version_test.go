@@ -0,0 +1,24 @@
+package handler
+
+import "testing"
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := map[string]struct {
+		version, mediaType string
+		ok                 bool
+	}{
+		"":                               {"v1", "", true},
+		"application/json":               {"v1", "", true},
+		"application/vnd.person.v2+json": {"v2", "application/vnd.person.v2+json", true},
+		"text/html, application/vnd.person.v1+xml": {"v1", "application/vnd.person.v1+xml", true},
+		"application/vnd.person.v2+json;q=0":       {"v1", "", true},
+		"application/vnd.person.v9+json":           {"v9", "application/vnd.person.v9+json", false},
+	}
+	for accept, test := range tests {
+		version, mediaType, name, ok := Versions.Negotiate(accept)
+		if ok != test.ok || name != test.version || mediaType != test.mediaType || (ok && version.Name != test.version) {
+			t.Errorf("%s version of %q is %s %q %v, expected %s %q %v", failed, accept, name, mediaType, ok, test.version, test.mediaType, test.ok)
+		}
+	}
+	t.Logf("%s Testing version negotiation is successful", succeed)
+}
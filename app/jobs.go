@@ -0,0 +1,27 @@
+package app
+
+import (
+	"context"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/jobs"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+)
+
+// registerJobs will add the job types of app to the job pool.
+func (app *App) registerJobs(pool *jobs.Pool) {
+	pool.Register(handler.ImportJob, app.importPeople)
+	if app.cipher != nil {
+		pool.Register(ReencryptJob, app.reencryptPeople)
+	}
+}
+
+// importPeople is the function of ImportJob, it writes people with the store of handlers in the tenant that
+// queued the job.
+func (app *App) importPeople(ctx context.Context, job *model.Job, progress jobs.ProgressFunc) (map[string]interface{}, error) {
+	if mode := app.tenancy(); mode != "" {
+		ctx = tenant.NewContext(ctx, tenant.Tenant{ID: job.TenantID, Mode: mode})
+	}
+	return handler.RunImport(ctx, app.people(), tenant.Database(ctx, app.Database()), job, progress)
+}
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// collection is the name of the collection that jobs are saved in. jobs of all tenants are saved in the
+// base database, also when tenants have their own databases, so the workers of a pool find them all.
+const collection = "jobs"
+
+var (
+	// ErrNotFound is returned when there is no job with the given id.
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when a finished job is cancelled.
+	ErrFinished = errors.New("job is already finished")
+)
+
+// Enqueue will save a new queued job of ctx tenant. one of workers will claim and run it, db must be the
+// base database.
+func Enqueue(ctx context.Context, db *mongo.Database, jobType string, params map[string]interface{}) (*model.Job, error) {
+	job := newJob(ctx, jobType, params)
+	if _, err := db.Collection(collection).InsertOne(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// newJob will create a queued job of ctx tenant.
+func newJob(ctx context.Context, jobType string, params map[string]interface{}) *model.Job {
+	job := model.NewJob(jobType, params)
+	if current, ok := tenant.FromContext(ctx); ok {
+		job.TenantID = current.ID
+	}
+	return job
+}
+
+// scope will add the tenant of ctx to query in every mode of tenancy, jobs of all tenants share the
+// collection of base database.
+func scope(ctx context.Context, query bson.M) bson.M {
+	if current, ok := tenant.FromContext(ctx); ok && !current.All() {
+		query[tenant.Field] = current.ID
+	}
+	return query
+}
+
+// Get will return the job with id of base database, jobs of other tenants are not found.
+func Get(ctx context.Context, db *mongo.Database, id primitive.ObjectID) (*model.Job, error) {
+	job := new(model.Job)
+	err := db.Collection(collection).FindOne(ctx, scope(ctx, bson.M{"_id": id})).Decode(job)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Cancel will cancel the job with id of base database. queued jobs are cancelled right away and running jobs
+// are asked to stop, the worker will move them to cancelled state on its next heartbeat.
+func Cancel(ctx context.Context, db *mongo.Database, id primitive.ObjectID) (*model.Job, error) {
+	jobs := db.Collection(collection)
+	now := time.Now().UTC()
+	_, err := jobs.UpdateOne(ctx,
+		scope(ctx, bson.M{"_id": id, "state": model.JobQueued}),
+		bson.M{"$set": bson.M{"state": model.JobCancelled, "cancel_requested": true, "finished_at": now}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = jobs.UpdateOne(ctx,
+		scope(ctx, bson.M{"_id": id, "state": model.JobRunning}),
+		bson.M{"$set": bson.M{"cancel_requested": true}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	job, err := Get(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() && job.State != model.JobCancelled {
+		return job, ErrFinished
+	}
+	return job, nil
+}
@@ -0,0 +1,240 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Func is the function that runs a job type. it must stop when ctx is done and
+// it can report the job progress with progress function.
+type Func func(ctx context.Context, job *model.Job, progress ProgressFunc) (map[string]interface{}, error)
+
+// ProgressFunc will save the job progress.
+type ProgressFunc func(done, total int64)
+
+// reasons that a running job is stopped before its function returns.
+const (
+	stopNone int32 = iota
+	stopCancelled
+	stopLeaseLost
+)
+
+// Pool is the worker pool that claims and runs jobs of a job store.
+// a job is claimed with a lease that worker renews while job is running, if the server
+// crashes the lease will expire and another worker will claim the job again.
+// workers only claim the jobs of registered types, so a pool without types does not start.
+type Pool struct {
+	store         Store
+	owner         string
+	workers       int
+	funcs         map[string]Func
+	PollInterval  time.Duration // how long an idle worker waits before looking for new jobs.
+	LeaseDuration time.Duration // how long a claimed job belongs to a worker without a heartbeat.
+	MaxAttempts   int           // a job that is claimed more than this will fail.
+
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+// NewPool is the Pool struct factory function.
+func NewPool(store Store, workers int) *Pool {
+	hostname, _ := os.Hostname()
+	return &Pool{
+		store:         store,
+		owner:         fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), primitive.NewObjectID().Hex()),
+		workers:       workers,
+		funcs:         make(map[string]Func),
+		PollInterval:  time.Second,
+		LeaseDuration: 30 * time.Second,
+		MaxAttempts:   3,
+	}
+}
+
+// Register will add a job type to pool. it must be called before Start.
+func (pool *Pool) Register(jobType string, fn Func) {
+	pool.funcs[jobType] = fn
+}
+
+// Enqueue will save a new job of a registered job type.
+func (pool *Pool) Enqueue(ctx context.Context, jobType string, params map[string]interface{}) (*model.Job, error) {
+	if _, ok := pool.funcs[jobType]; !ok {
+		return nil, fmt.Errorf("job type %q is not registered", jobType)
+	}
+	job := newJob(ctx, jobType, params)
+	if err := pool.store.Insert(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Start will run the workers in background. workers are not started if there is no registered job type,
+// jobs of types that no server registers stay queued.
+func (pool *Pool) Start() {
+	if len(pool.funcs) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.cancel = cancel
+	for i := 0; i < pool.workers; i++ {
+		pool.wg.Add(1)
+		go pool.work(ctx)
+	}
+}
+
+// Stop will stop the workers and wait for them. running jobs will be queued again.
+func (pool *Pool) Stop() {
+	if pool.cancel == nil {
+		return
+	}
+	pool.cancel()
+	pool.wg.Wait()
+}
+
+func (pool *Pool) types() []string {
+	types := make([]string, 0, len(pool.funcs))
+	for jobType := range pool.funcs {
+		types = append(types, jobType)
+	}
+	return types
+}
+
+// work is the worker loop. it claims jobs one by one until ctx is done, a job that is released on
+// stop must not be claimed again.
+func (pool *Pool) work(ctx context.Context) {
+	defer pool.wg.Done()
+	for ctx.Err() == nil {
+		job, err := pool.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error while claiming job: %v\n", err)
+		}
+		if job != nil {
+			pool.run(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(pool.PollInterval):
+		}
+	}
+}
+
+// claim will take the oldest queued job or a running job that its lease is expired.
+func (pool *Pool) claim(ctx context.Context) (*model.Job, error) {
+	job, err := pool.store.Claim(ctx, pool.types(), pool.owner, pool.LeaseDuration)
+	if job == nil || err != nil {
+		return nil, err
+	}
+	// a job that is cancelled or failed too many times while its worker was down will be finished here.
+	if job.CancelRequested {
+		return job, pool.finish(job, model.JobCancelled, nil, "")
+	}
+	if job.Attempts > pool.MaxAttempts {
+		return job, pool.finish(job, model.JobFailed, nil, fmt.Sprintf("job is failed after %d attempts", pool.MaxAttempts))
+	}
+	return job, nil
+}
+
+// run will run job function and save its result.
+func (pool *Pool) run(ctx context.Context, job *model.Job) {
+	if job.Finished() {
+		return
+	}
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stopped int32
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		pool.heartbeat(jobCtx, job, func(reason int32) {
+			atomic.StoreInt32(&stopped, reason)
+			cancel()
+		})
+	}()
+
+	result, err := pool.call(jobCtx, job)
+	cancel()
+	<-heartbeatDone
+
+	switch {
+	case atomic.LoadInt32(&stopped) == stopLeaseLost:
+		log.Printf("Job %s lease is lost, result is ignored\n", job.ID.Hex())
+		return
+	case atomic.LoadInt32(&stopped) == stopCancelled:
+		err = pool.finish(job, model.JobCancelled, nil, "")
+	case ctx.Err() != nil:
+		err = pool.release(job)
+	case err != nil:
+		err = pool.finish(job, model.JobFailed, nil, err.Error())
+	default:
+		err = pool.finish(job, model.JobSucceeded, result, "")
+	}
+	if err != nil {
+		log.Printf("Error while saving job %s: %v\n", job.ID.Hex(), err)
+	}
+}
+
+// call will run job function and turn its panics to errors.
+func (pool *Pool) call(ctx context.Context, job *model.Job) (result map[string]interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	progress := func(done, total int64) {
+		err := pool.store.SetProgress(ctx, job.ID, pool.owner, model.JobProgress{Done: done, Total: total})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error while saving job %s progress: %v\n", job.ID.Hex(), err)
+		}
+	}
+	return pool.funcs[job.Type](ctx, job, progress)
+}
+
+// heartbeat will renew the job lease until ctx is done. stop is called when job is
+// cancelled or its lease is taken by another worker.
+func (pool *Pool) heartbeat(ctx context.Context, job *model.Job, stop func(reason int32)) {
+	ticker := time.NewTicker(pool.LeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current, err := pool.store.Renew(ctx, job.ID, pool.owner, pool.LeaseDuration)
+		switch {
+		case err == ErrLeaseLost:
+			stop(stopLeaseLost)
+			return
+		case err != nil:
+			if ctx.Err() == nil {
+				log.Printf("Error while renewing job %s lease: %v\n", job.ID.Hex(), err)
+			}
+		case current.CancelRequested:
+			stop(stopCancelled)
+			return
+		}
+	}
+}
+
+// finish will save the final state of job if worker still owns it.
+func (pool *Pool) finish(job *model.Job, state model.JobState, result map[string]interface{}, message string) error {
+	now := time.Now().UTC()
+	job.State = state
+	job.Result = result
+	job.Error = message
+	job.FinishedAt = &now
+	return pool.store.Finish(context.Background(), job, pool.owner)
+}
+
+// release will queue job again, it is used when the pool stops while job is running.
+func (pool *Pool) release(job *model.Job) error {
+	return pool.store.Release(context.Background(), job.ID, pool.owner)
+}
@@ -0,0 +1,377 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+// memoryStore is the Store of tests, it keeps jobs in a map like jobs collection.
+type memoryStore struct {
+	lock sync.Mutex
+	jobs map[primitive.ObjectID]*model.Job
+}
+
+func newMemoryStore(jobs ...*model.Job) *memoryStore {
+	store := &memoryStore{jobs: make(map[primitive.ObjectID]*model.Job)}
+	for _, job := range jobs {
+		store.jobs[job.ID] = job
+	}
+	return store
+}
+
+// get will return a copy of the job with id.
+func (store *memoryStore) get(id primitive.ObjectID) *model.Job {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	job := *store.jobs[id]
+	return &job
+}
+
+// update will change the job with id like the updates of other processes.
+func (store *memoryStore) update(id primitive.ObjectID, fn func(job *model.Job)) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	fn(store.jobs[id])
+}
+
+func (store *memoryStore) Insert(ctx context.Context, job *model.Job) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	saved := *job
+	store.jobs[job.ID] = &saved
+	return nil
+}
+
+func (store *memoryStore) Claim(ctx context.Context, types []string, owner string, lease time.Duration) (*model.Job, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	now := time.Now().UTC()
+	var claimable []*model.Job
+	for _, job := range store.jobs {
+		expired := job.State == model.JobRunning && job.LeaseExpiresAt != nil && job.LeaseExpiresAt.Before(now)
+		if contains(types, job.Type) && (job.State == model.JobQueued || expired) {
+			claimable = append(claimable, job)
+		}
+	}
+	if len(claimable) == 0 {
+		return nil, nil
+	}
+	sort.Slice(claimable, func(i, j int) bool { return claimable[i].CreatedAt.Before(claimable[j].CreatedAt) })
+	job := claimable[0]
+	expires := now.Add(lease)
+	job.State = model.JobRunning
+	job.LeaseOwner = owner
+	job.LeaseExpiresAt = &expires
+	job.StartedAt = &now
+	job.Attempts++
+	claimed := *job
+	return &claimed, nil
+}
+
+func (store *memoryStore) Renew(ctx context.Context, id primitive.ObjectID, owner string, lease time.Duration) (*model.Job, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	job := store.jobs[id]
+	if job == nil || job.LeaseOwner != owner || job.State != model.JobRunning {
+		return nil, ErrLeaseLost
+	}
+	expires := time.Now().UTC().Add(lease)
+	job.LeaseExpiresAt = &expires
+	renewed := *job
+	return &renewed, nil
+}
+
+func (store *memoryStore) SetProgress(ctx context.Context, id primitive.ObjectID, owner string, progress model.JobProgress) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if job := store.jobs[id]; job != nil && job.LeaseOwner == owner {
+		job.Progress = progress
+	}
+	return nil
+}
+
+func (store *memoryStore) Finish(ctx context.Context, finished *model.Job, owner string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if job := store.jobs[finished.ID]; job != nil && job.LeaseOwner == owner {
+		job.State, job.Result, job.Error, job.FinishedAt = finished.State, finished.Result, finished.Error, finished.FinishedAt
+		job.LeaseOwner, job.LeaseExpiresAt = "", nil
+	}
+	return nil
+}
+
+func (store *memoryStore) Release(ctx context.Context, id primitive.ObjectID, owner string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if job := store.jobs[id]; job != nil && job.LeaseOwner == owner {
+		job.State = model.JobQueued
+		job.Attempts--
+		job.LeaseOwner, job.LeaseExpiresAt = "", nil
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// newTestPool will create a pool of store with short intervals, so leases expire in tests.
+func newTestPool(store Store, jobType string, fn Func) *Pool {
+	pool := NewPool(store, 2)
+	pool.PollInterval = 5 * time.Millisecond
+	pool.LeaseDuration = 30 * time.Millisecond
+	pool.Register(jobType, fn)
+	return pool
+}
+
+// waitFor will wait until condition is true or fail the test after a while.
+func waitFor(t *testing.T, message string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s %s", failed, message)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+// blockingJob will return a job function that runs until its ctx is done and the channel that is closed when it starts.
+func blockingJob() (Func, chan struct{}) {
+	started := make(chan struct{})
+	var once sync.Once
+	return func(ctx context.Context, job *model.Job, progress ProgressFunc) (map[string]interface{}, error) {
+		once.Do(func() { close(started) })
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, started
+}
+
+func TestPoolRunsJobs(t *testing.T) {
+	store := newMemoryStore()
+	pool := newTestPool(store, "count", func(ctx context.Context, job *model.Job, progress ProgressFunc) (map[string]interface{}, error) {
+		progress(3, 3)
+		return map[string]interface{}{"counted": job.Params["to"]}, nil
+	})
+	if _, err := pool.Enqueue(context.Background(), "unknown", nil); err == nil {
+		t.Fatalf("%s jobs of types that are not registered must not be queued", failed)
+	}
+	job, err := pool.Enqueue(context.Background(), "count", map[string]interface{}{"to": 3})
+	if err != nil {
+		t.Fatalf("%s enqueue must save job: %v", failed, err)
+	}
+	pool.Start()
+	defer pool.Stop()
+
+	waitFor(t, "queued job must be run", func() bool { return store.get(job.ID).Finished() })
+	saved := store.get(job.ID)
+	if saved.State != model.JobSucceeded || saved.Result["counted"] != 3 || saved.Progress.Done != 3 {
+		t.Fatalf("%s result and progress of job must be saved: %+v", failed, saved)
+	}
+	if saved.Attempts != 1 || saved.LeaseOwner != "" || saved.LeaseExpiresAt != nil || saved.FinishedAt == nil {
+		t.Fatalf("%s finished job must have no lease: %+v", failed, saved)
+	}
+	t.Logf("%s Testing running jobs of pool is successful", succeed)
+}
+
+func TestPoolFailsJobs(t *testing.T) {
+	failing := model.NewJob("fail", nil)
+	panicking := model.NewJob("panic", nil)
+	store := newMemoryStore(failing, panicking)
+	pool := newTestPool(store, "fail", func(ctx context.Context, job *model.Job, progress ProgressFunc) (map[string]interface{}, error) {
+		return nil, errors.New("file is missing")
+	})
+	pool.Register("panic", func(ctx context.Context, job *model.Job, progress ProgressFunc) (map[string]interface{}, error) {
+		panic("nil map")
+	})
+	pool.Start()
+	defer pool.Stop()
+
+	waitFor(t, "jobs must be finished", func() bool { return store.get(failing.ID).Finished() && store.get(panicking.ID).Finished() })
+	if saved := store.get(failing.ID); saved.State != model.JobFailed || saved.Error != "file is missing" {
+		t.Fatalf("%s error of job must be saved: %+v", failed, saved)
+	}
+	if saved := store.get(panicking.ID); saved.State != model.JobFailed || saved.Error != "job panicked: nil map" {
+		t.Fatalf("%s panics of job must be saved as errors: %+v", failed, saved)
+	}
+	t.Logf("%s Testing failed jobs of pool is successful", succeed)
+}
+
+func TestPoolReclaimsExpiredLeases(t *testing.T) {
+	expired := time.Now().UTC().Add(-time.Minute)
+	alive := time.Now().UTC().Add(time.Hour)
+	// the worker of crashed stopped renewing its lease, the worker of busy is still running its job.
+	crashed := model.NewJob("work", nil)
+	crashed.State, crashed.LeaseOwner, crashed.LeaseExpiresAt, crashed.Attempts = model.JobRunning, "crashed", &expired, 1
+	busy := model.NewJob("work", nil)
+	busy.State, busy.LeaseOwner, busy.LeaseExpiresAt, busy.Attempts = model.JobRunning, "busy", &alive, 1
+	// exhausted has crashed its workers too many times.
+	exhausted := model.NewJob("work", nil)
+	exhausted.State, exhausted.LeaseOwner, exhausted.LeaseExpiresAt, exhausted.Attempts = model.JobRunning, "crashed", &expired, 3
+	store := newMemoryStore(crashed, busy, exhausted)
+
+	var lock sync.Mutex
+	ran := make(map[primitive.ObjectID]int)
+	pool := newTestPool(store, "work", func(ctx context.Context, job *model.Job, progress ProgressFunc) (map[string]interface{}, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		ran[job.ID]++
+		return nil, nil
+	})
+	pool.Start()
+	defer pool.Stop()
+
+	waitFor(t, "jobs of expired leases must be claimed", func() bool {
+		return store.get(crashed.ID).Finished() && store.get(exhausted.ID).Finished()
+	})
+	if saved := store.get(crashed.ID); saved.State != model.JobSucceeded || saved.Attempts != 2 {
+		t.Fatalf("%s job of an expired lease must be run again: %+v", failed, saved)
+	}
+	if saved := store.get(exhausted.ID); saved.State != model.JobFailed || saved.Error != "job is failed after 3 attempts" {
+		t.Fatalf("%s job must fail after max attempts: %+v", failed, saved)
+	}
+	if saved := store.get(busy.ID); saved.State != model.JobRunning || saved.LeaseOwner != "busy" {
+		t.Fatalf("%s job of a live lease must not be claimed: %+v", failed, saved)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if ran[crashed.ID] != 1 || ran[exhausted.ID] != 0 || ran[busy.ID] != 0 {
+		t.Fatalf("%s jobs must be run once after they are claimed: %v", failed, ran)
+	}
+	t.Logf("%s Testing reclaiming expired leases is successful", succeed)
+}
+
+func TestPoolRenewsLeases(t *testing.T) {
+	job := model.NewJob("block", nil)
+	store := newMemoryStore(job)
+	fn, started := blockingJob()
+	pool := newTestPool(store, "block", fn)
+	pool.Start()
+	defer pool.Stop()
+
+	<-started
+	// a job that runs longer than its lease must keep it, so other workers do not claim it.
+	time.Sleep(3 * pool.LeaseDuration)
+	saved := store.get(job.ID)
+	if saved.State != model.JobRunning || saved.Attempts != 1 || !saved.LeaseExpiresAt.After(time.Now()) {
+		t.Fatalf("%s lease of running job must be renewed: %+v", failed, saved)
+	}
+	t.Logf("%s Testing lease renewal of pool is successful", succeed)
+}
+
+func TestPoolCancelsJobs(t *testing.T) {
+	job := model.NewJob("block", nil)
+	store := newMemoryStore(job)
+	fn, started := blockingJob()
+	pool := newTestPool(store, "block", fn)
+	pool.Start()
+	defer pool.Stop()
+
+	<-started
+	store.update(job.ID, func(job *model.Job) { job.CancelRequested = true })
+	waitFor(t, "cancelled job must be stopped", func() bool { return store.get(job.ID).Finished() })
+	if saved := store.get(job.ID); saved.State != model.JobCancelled || saved.LeaseOwner != "" {
+		t.Fatalf("%s running job must be cancelled on heartbeat: %+v", failed, saved)
+	}
+
+	// jobs that are cancelled while their worker is down are finished when they are claimed.
+	expired := time.Now().UTC().Add(-time.Minute)
+	orphan := model.NewJob("block", nil)
+	orphan.State, orphan.LeaseOwner, orphan.LeaseExpiresAt, orphan.CancelRequested = model.JobRunning, "crashed", &expired, true
+	store.Insert(context.Background(), orphan)
+	waitFor(t, "cancelled job of a crashed worker must be finished", func() bool { return store.get(orphan.ID).Finished() })
+	if saved := store.get(orphan.ID); saved.State != model.JobCancelled || saved.Attempts != 1 {
+		t.Fatalf("%s cancelled job must not be run: %+v", failed, saved)
+	}
+	t.Logf("%s Testing cancelling jobs of pool is successful", succeed)
+}
+
+func TestPoolLostLeases(t *testing.T) {
+	job := model.NewJob("block", nil)
+	store := newMemoryStore(job)
+	fn, started := blockingJob()
+	pool := newTestPool(store, "block", fn)
+	pool.Start()
+	defer pool.Stop()
+
+	<-started
+	// another worker took the job, for example after this process was paused longer than the lease.
+	store.update(job.ID, func(job *model.Job) {
+		expires := time.Now().UTC().Add(time.Hour)
+		job.LeaseOwner, job.LeaseExpiresAt = "other", &expires
+	})
+	time.Sleep(3 * pool.LeaseDuration)
+	if saved := store.get(job.ID); saved.State != model.JobRunning || saved.LeaseOwner != "other" || saved.FinishedAt != nil {
+		t.Fatalf("%s worker must stop and ignore the result of a lost lease: %+v", failed, saved)
+	}
+	t.Logf("%s Testing lost leases of pool is successful", succeed)
+}
+
+func TestPoolReleasesJobsOnStop(t *testing.T) {
+	job := model.NewJob("block", nil)
+	store := newMemoryStore(job)
+	fn, started := blockingJob()
+	pool := newTestPool(store, "block", fn)
+	pool.Start()
+
+	<-started
+	pool.Stop()
+	saved := store.get(job.ID)
+	if saved.State != model.JobQueued || saved.Attempts != 0 || saved.LeaseOwner != "" || saved.LeaseExpiresAt != nil {
+		t.Fatalf("%s running job must be queued again when pool stops: %+v", failed, saved)
+	}
+	t.Logf("%s Testing releasing jobs on stop is successful", succeed)
+}
+
+func TestPoolWithoutTypes(t *testing.T) {
+	job := model.NewJob("unknown", nil)
+	store := newMemoryStore(job)
+	pool := NewPool(store, 2)
+	pool.PollInterval = 5 * time.Millisecond
+	pool.Start()
+	time.Sleep(20 * time.Millisecond)
+	pool.Stop()
+	if saved := store.get(job.ID); saved.State != model.JobQueued || saved.Attempts != 0 {
+		t.Fatalf("%s pool without types must not claim jobs: %+v", failed, saved)
+	}
+	t.Logf("%s Testing pool without job types is successful", succeed)
+}
+
+func TestPoolKeepsTenantOfJobs(t *testing.T) {
+	store := newMemoryStore()
+	pool := newTestPool(store, "count", func(ctx context.Context, job *model.Job, progress ProgressFunc) (map[string]interface{}, error) {
+		return nil, nil
+	})
+	for _, mode := range []tenant.Mode{tenant.ModeShared, tenant.ModeDatabase} {
+		ctx := tenant.NewContext(context.Background(), tenant.Tenant{ID: "acme", Mode: mode})
+		job, err := pool.Enqueue(ctx, "count", nil)
+		if err != nil || store.get(job.ID).TenantID != "acme" {
+			t.Fatalf("%s job must have the tenant of %s mode, jobs of all tenants are in the base database: %+v %v", failed, mode, job, err)
+		}
+		if query := scope(ctx, bson.M{"_id": job.ID}); query[tenant.Field] != "acme" {
+			t.Fatalf("%s queries of jobs must be scoped by tenant in %s mode: %v", failed, mode, query)
+		}
+	}
+	all := tenant.NewContext(context.Background(), tenant.Tenant{Mode: tenant.ModeDatabase})
+	if query := scope(all, bson.M{}); len(query) != 0 {
+		t.Fatalf("%s queries of all tenants must not be scoped: %v", failed, query)
+	}
+	t.Logf("%s Testing tenants of jobs is successful", succeed)
+}
@@ -0,0 +1,140 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrLeaseLost is returned when a worker renews the lease of a job that it does not own anymore.
+var ErrLeaseLost = errors.New("job lease is lost")
+
+// Store keeps the jobs that workers claim. a claimed job belongs to its lease owner, the updates
+// of other owners are ignored.
+type Store interface {
+	// Insert will save a new queued job.
+	Insert(ctx context.Context, job *model.Job) error
+	// Claim will take the oldest queued job of types or a running one that its lease is expired, it
+	// returns nil if there is no job.
+	Claim(ctx context.Context, types []string, owner string, lease time.Duration) (*model.Job, error)
+	// Renew will extend the lease of a running job of owner and return the job.
+	Renew(ctx context.Context, id primitive.ObjectID, owner string, lease time.Duration) (*model.Job, error)
+	// SetProgress will save the progress of a job of owner.
+	SetProgress(ctx context.Context, id primitive.ObjectID, owner string, progress model.JobProgress) error
+	// Finish will save the state, result and error of a job of owner and remove its lease.
+	Finish(ctx context.Context, job *model.Job, owner string) error
+	// Release will queue a job of owner again, its attempt is not counted.
+	Release(ctx context.Context, id primitive.ObjectID, owner string) error
+}
+
+// Mongo is the Store of jobs collection. database is called for every query, so it can be replaced
+// when mongo client reconnects.
+type Mongo struct {
+	database func() *mongo.Database
+}
+
+// NewMongo is the Mongo struct factory function.
+func NewMongo(database func() *mongo.Database) *Mongo {
+	return &Mongo{database: database}
+}
+
+func (store *Mongo) collection() *mongo.Collection {
+	return store.database().Collection(collection)
+}
+
+// Insert will save a new queued job.
+func (store *Mongo) Insert(ctx context.Context, job *model.Job) error {
+	_, err := store.collection().InsertOne(ctx, job)
+	return err
+}
+
+// Claim will take the oldest queued job of types or a running one that its lease is expired.
+func (store *Mongo) Claim(ctx context.Context, types []string, owner string, lease time.Duration) (*model.Job, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"type": bson.M{"$in": types},
+		"$or": []bson.M{
+			{"state": model.JobQueued},
+			{"state": model.JobRunning, "lease_expires_at": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"state":            model.JobRunning,
+			"lease_owner":      owner,
+			"lease_expires_at": now.Add(lease),
+			"started_at":       now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.M{"created_at": 1}).
+		SetReturnDocument(options.After)
+	job := new(model.Job)
+	err := store.collection().FindOneAndUpdate(ctx, filter, update, findOptions).Decode(job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Renew will extend the lease of a running job of owner, ErrLeaseLost is returned if job is not
+// running or another worker has claimed it.
+func (store *Mongo) Renew(ctx context.Context, id primitive.ObjectID, owner string, lease time.Duration) (*model.Job, error) {
+	job := new(model.Job)
+	err := store.collection().FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "lease_owner": owner, "state": model.JobRunning},
+		bson.M{"$set": bson.M{"lease_expires_at": time.Now().UTC().Add(lease)}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(job)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrLeaseLost
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// SetProgress will save the progress of a job of owner.
+func (store *Mongo) SetProgress(ctx context.Context, id primitive.ObjectID, owner string, progress model.JobProgress) error {
+	_, err := store.collection().UpdateOne(ctx,
+		bson.M{"_id": id, "lease_owner": owner},
+		bson.M{"$set": bson.M{"progress": progress}},
+	)
+	return err
+}
+
+// Finish will save the state, result and error of a job of owner and remove its lease.
+func (store *Mongo) Finish(ctx context.Context, job *model.Job, owner string) error {
+	_, err := store.collection().UpdateOne(ctx,
+		bson.M{"_id": job.ID, "lease_owner": owner},
+		bson.M{
+			"$set":   bson.M{"state": job.State, "result": job.Result, "error": job.Error, "finished_at": job.FinishedAt},
+			"$unset": bson.M{"lease_owner": "", "lease_expires_at": ""},
+		},
+	)
+	return err
+}
+
+// Release will queue a job of owner again, its attempt is not counted.
+func (store *Mongo) Release(ctx context.Context, id primitive.ObjectID, owner string) error {
+	_, err := store.collection().UpdateOne(ctx,
+		bson.M{"_id": id, "lease_owner": owner},
+		bson.M{
+			"$set":   bson.M{"state": model.JobQueued},
+			"$inc":   bson.M{"attempts": -1},
+			"$unset": bson.M{"lease_owner": "", "lease_expires_at": ""},
+		},
+	)
+	return err
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+)
+
+// metric is a value of metrics endpoint.
+type metric struct {
+	name  string
+	kind  string // counter or gauge
+	help  string
+	value float64
+}
+
+// metrics will return the metrics of app, counters of a disabled cache are zero.
+func (app *App) metrics() []metric {
+	var stats store.CacheStats
+	enabled, capacity := 0.0, 0.0
+	if app.Cache != nil {
+		stats = app.Cache.Stats()
+		enabled, capacity = 1, float64(app.config.PersonCacheSize)
+	}
+	return []metric{
+		{"person_cache_enabled", "gauge", "1 if the read cache of people is enabled.", enabled},
+		{"person_cache_capacity", "gauge", "Max people of the read cache.", capacity},
+		{"person_cache_entries", "gauge", "People in the read cache.", float64(stats.Entries)},
+		{"person_cache_hits_total", "counter", "Person reads that are answered from cache.", float64(stats.Hits)},
+		{"person_cache_misses_total", "counter", "Person reads that are not in cache or are expired.", float64(stats.Misses)},
+		{"person_cache_evictions_total", "counter", "Least recently used people that are removed for new ones.", float64(stats.Evictions)},
+		{"person_cache_invalidations_total", "counter", "Cached people that are removed because they are written.", float64(stats.Invalidations)},
+	}
+}
+
+// metricsHandler will write the metrics of app in prometheus text format.
+func (app *App) metricsHandler(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range app.metrics() {
+		fmt.Fprintf(res, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", m.name, m.help, m.name, m.kind, m.name, m.value)
+	}
+}
@@ -0,0 +1,274 @@
+// Package migrations runs the versioned schema and data migrations of database.
+//
+// a migration is a Go function pair that is registered with Register in an init function,
+// one file per migration named v<version>_<description>.go. migrations run in version order
+// and the applied versions are saved in schema_migrations collection. a lock in
+// migration_locks collection makes sure that only one server runs migrations at a time.
+// mongo db has no transactions for schema changes, so migrations must be safe to run again.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// collection is the name of collection that applied migrations are saved in.
+	collection = "schema_migrations"
+	// lockCollection is the name of collection that holds the migration lock.
+	lockCollection = "migration_locks"
+	lockID         = "migrate"
+)
+
+var (
+	// ErrLocked is returned when another server holds the migration lock.
+	ErrLocked = errors.New("migrations are locked by another process")
+	// ErrLockLost is returned when another server takes the lock while migrations run, they are stopped.
+	ErrLockLost = errors.New("migration lock is lost, migrations are stopped")
+)
+
+// Func is the up or down function of a migration.
+type Func func(ctx context.Context, db *mongo.Database) error
+
+// Migration is a versioned change of database.
+type Migration struct {
+	Version     int64
+	Description string
+	Up          Func
+	Down        Func // it can be nil if migration can not be reverted.
+}
+
+// Status is a migration and the time that it is applied, AppliedAt is nil for pending migrations.
+// Unknown is true for applied versions that have no registered migration.
+type Status struct {
+	Version     int64      `json:"version"`
+	Description string     `json:"description"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	Unknown     bool       `json:"unknown,omitempty"`
+}
+
+// record is the document that is saved in schema_migrations collection for an applied migration.
+type record struct {
+	Version     int64     `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+var registry = make(map[int64]Migration)
+
+// Register will add migration to registry. it panics on duplicate versions.
+func Register(migration Migration) {
+	if _, ok := registry[migration.Version]; ok {
+		panic(fmt.Sprintf("migrations: version %d is registered twice", migration.Version))
+	}
+	if migration.Up == nil {
+		panic(fmt.Sprintf("migrations: version %d has no up function", migration.Version))
+	}
+	registry[migration.Version] = migration
+}
+
+// Migrator runs the registered migrations on a database.
+type Migrator struct {
+	db          *mongo.Database
+	store       store
+	owner       string
+	migrations  []Migration
+	LockTimeout time.Duration // lock of a crashed process expires after this time, it is renewed while migrations run.
+	LockWait    time.Duration // how long to wait for a lock that is held by another process.
+}
+
+// New is the Migrator struct factory function. it uses the registered migrations.
+func New(db *mongo.Database) *Migrator {
+	migrations := make([]Migration, 0, len(registry))
+	for _, migration := range registry {
+		migrations = append(migrations, migration)
+	}
+	return newMigrator(db, mongoStore{db: db}, migrations)
+}
+
+func newMigrator(db *mongo.Database, store store, migrations []Migration) *Migrator {
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	hostname, _ := os.Hostname()
+	return &Migrator{
+		db:          db,
+		store:       store,
+		owner:       fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), primitive.NewObjectID().Hex()),
+		migrations:  migrations,
+		LockTimeout: time.Minute,
+		LockWait:    time.Minute,
+	}
+}
+
+// Status will return all registered and applied migrations in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Description: migration.Description}
+		if rec, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &rec.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, rec := range applied {
+		appliedAt := rec.AppliedAt
+		statuses = append(statuses, Status{Version: rec.Version, Description: rec.Description, AppliedAt: &appliedAt, Unknown: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Pending will return the migrations that are not applied yet.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up will apply all pending migrations in version order and return the applied ones.
+// it stops on the first error.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(ctx context.Context) error {
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range pending {
+			if err := migration.Up(ctx, m.db); err != nil {
+				return fmt.Errorf("migration %d (%s) is failed: %v", migration.Version, migration.Description, err)
+			}
+			rec := record{Version: migration.Version, Description: migration.Description, AppliedAt: time.Now().UTC()}
+			if err := m.store.insert(ctx, rec); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down will revert the last steps applied migrations in reverse version order and return the reverted ones.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %d (%s) can not be reverted", migration.Version, migration.Description)
+			}
+			if err := migration.Down(ctx, m.db); err != nil {
+				return fmt.Errorf("reverting migration %d (%s) is failed: %v", migration.Version, migration.Description, err)
+			}
+			if err := m.store.delete(ctx, migration.Version); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// applied will return the applied migration records by version.
+func (m *Migrator) applied(ctx context.Context) (map[int64]record, error) {
+	records, err := m.store.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]record, len(records))
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+	return applied, nil
+}
+
+// locked will run fn while it holds the migration lock. the lock is renewed until fn returns, if
+// another process takes it the ctx of fn is cancelled and ErrLockLost is returned, because both
+// processes could run the same migrations.
+func (m *Migrator) locked(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer m.store.unlock(context.Background(), m.owner)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var lost int32
+	renewDone := make(chan struct{})
+	go func() {
+		defer close(renewDone)
+		if m.renew(ctx) == ErrLocked {
+			atomic.StoreInt32(&lost, 1)
+			cancel()
+		}
+	}()
+	err := fn(ctx)
+	cancel()
+	<-renewDone
+	if atomic.LoadInt32(&lost) == 1 {
+		return ErrLockLost
+	}
+	return err
+}
+
+// lock will take the migration lock. it waits for LockWait if another process holds it.
+func (m *Migrator) lock(ctx context.Context) error {
+	deadline := time.Now().Add(m.LockWait)
+	for {
+		err := m.store.lock(ctx, m.owner, m.LockTimeout)
+		if err != ErrLocked || time.Now().After(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.LockWait / 60):
+		}
+	}
+}
+
+// renew will extend the lock until ctx is done. errors are retried on the next tick while the lock is
+// still valid, it returns ErrLocked when another process has taken the lock.
+func (m *Migrator) renew(ctx context.Context) error {
+	ticker := time.NewTicker(m.LockTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if err := m.store.lock(ctx, m.owner, m.LockTimeout); err == ErrLocked {
+			return err
+		}
+	}
+}
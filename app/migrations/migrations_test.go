@@ -0,0 +1,308 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+// memoryStore is the store of tests, it keeps the records and lock like their collections.
+type memoryStore struct {
+	mutex     sync.Mutex
+	records   map[int64]record
+	owner     string
+	expiresAt time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: make(map[int64]record)}
+}
+
+// setLock will change the lock like the lock of another process.
+func (store *memoryStore) setLock(owner string, expiresAt time.Time) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.owner, store.expiresAt = owner, expiresAt
+}
+
+func (store *memoryStore) holder() string {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.owner
+}
+
+func (store *memoryStore) applied(ctx context.Context) ([]record, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	var records []record
+	for _, rec := range store.records {
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func (store *memoryStore) insert(ctx context.Context, rec record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.records[rec.Version] = rec
+	return nil
+}
+
+func (store *memoryStore) delete(ctx context.Context, version int64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.records, version)
+	return nil
+}
+
+func (store *memoryStore) lock(ctx context.Context, owner string, timeout time.Duration) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	now := time.Now()
+	if store.owner != "" && store.owner != owner && store.expiresAt.After(now) {
+		return ErrLocked
+	}
+	store.owner, store.expiresAt = owner, now.Add(timeout)
+	return nil
+}
+
+func (store *memoryStore) unlock(ctx context.Context, owner string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.owner == owner {
+		store.owner = ""
+	}
+	return nil
+}
+
+// journal records the up and down functions that migrations run.
+type journal struct {
+	lock  sync.Mutex
+	steps []string
+}
+
+func (j *journal) step(name string) Func {
+	return func(ctx context.Context, db *mongo.Database) error {
+		j.lock.Lock()
+		defer j.lock.Unlock()
+		j.steps = append(j.steps, name)
+		return nil
+	}
+}
+
+func (j *journal) list() []string {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return append([]string(nil), j.steps...)
+}
+
+func newTestMigrator(store store, migrations ...Migration) *Migrator {
+	migrator := newMigrator(nil, store, migrations)
+	migrator.LockTimeout = 30 * time.Millisecond
+	migrator.LockWait = 60 * time.Millisecond
+	return migrator
+}
+
+func versions(migrations []Migration) []int64 {
+	var versions []int64
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+	return versions
+}
+
+func TestUpAndStatus(t *testing.T) {
+	ctx := context.Background()
+	j := new(journal)
+	store := newMemoryStore()
+	// an applied version that is removed from the code.
+	store.insert(ctx, record{Version: 9, Description: "removed", AppliedAt: time.Now().UTC()})
+	migrator := newTestMigrator(store,
+		Migration{Version: 2, Description: "second", Up: j.step("up 2")},
+		Migration{Version: 1, Description: "first", Up: j.step("up 1")},
+	)
+
+	done, err := migrator.Up(ctx)
+	if err != nil || !reflect.DeepEqual(versions(done), []int64{1, 2}) || !reflect.DeepEqual(j.list(), []string{"up 1", "up 2"}) {
+		t.Fatalf("%s up must apply pending migrations in version order: %v %v %v", failed, versions(done), j.list(), err)
+	}
+	if done, err := migrator.Up(ctx); err != nil || len(done) != 0 {
+		t.Fatalf("%s up must not apply migrations twice: %v %v", failed, versions(done), err)
+	}
+	if store.holder() != "" {
+		t.Fatalf("%s lock must be released after up: %q", failed, store.holder())
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil || len(statuses) != 3 {
+		t.Fatalf("%s status must have registered and applied migrations: %+v %v", failed, statuses, err)
+	}
+	for i, version := range []int64{1, 2, 9} {
+		if statuses[i].Version != version || statuses[i].AppliedAt == nil || statuses[i].Unknown != (version == 9) {
+			t.Fatalf("%s status of version %d is wrong: %+v", failed, version, statuses[i])
+		}
+	}
+	t.Logf("%s Testing up and status of migrations is successful", succeed)
+}
+
+func TestUpStopsOnError(t *testing.T) {
+	ctx := context.Background()
+	j := new(journal)
+	store := newMemoryStore()
+	migrator := newTestMigrator(store,
+		Migration{Version: 1, Description: "first", Up: j.step("up 1")},
+		Migration{Version: 2, Description: "broken", Up: func(ctx context.Context, db *mongo.Database) error { return errors.New("boom") }},
+		Migration{Version: 3, Description: "third", Up: j.step("up 3")},
+	)
+
+	done, err := migrator.Up(ctx)
+	if err == nil || err.Error() != "migration 2 (broken) is failed: boom" || !reflect.DeepEqual(versions(done), []int64{1}) {
+		t.Fatalf("%s up must stop on the first error: %v %v", failed, versions(done), err)
+	}
+	pending, _ := migrator.Pending(ctx)
+	if !reflect.DeepEqual(versions(pending), []int64{2, 3}) || !reflect.DeepEqual(j.list(), []string{"up 1"}) {
+		t.Fatalf("%s failed and later migrations must stay pending: %v %v", failed, versions(pending), j.list())
+	}
+	if store.holder() != "" {
+		t.Fatalf("%s lock must be released after errors: %q", failed, store.holder())
+	}
+	t.Logf("%s Testing errors of up is successful", succeed)
+}
+
+func TestDown(t *testing.T) {
+	ctx := context.Background()
+	j := new(journal)
+	store := newMemoryStore()
+	migrator := newTestMigrator(store,
+		Migration{Version: 1, Description: "first", Up: j.step("up 1")},
+		Migration{Version: 2, Description: "second", Up: j.step("up 2"), Down: j.step("down 2")},
+		Migration{Version: 3, Description: "third", Up: j.step("up 3"), Down: j.step("down 3")},
+	)
+	migrator.Up(ctx)
+
+	done, err := migrator.Down(ctx, 2)
+	if err != nil || !reflect.DeepEqual(versions(done), []int64{3, 2}) {
+		t.Fatalf("%s down must revert the last migrations in reverse order: %v %v", failed, versions(done), err)
+	}
+	if steps := j.list(); !reflect.DeepEqual(steps[3:], []string{"down 3", "down 2"}) {
+		t.Fatalf("%s down functions must be run: %v", failed, steps)
+	}
+	if pending, _ := migrator.Pending(ctx); !reflect.DeepEqual(versions(pending), []int64{2, 3}) {
+		t.Fatalf("%s reverted migrations must be pending: %v", failed, versions(pending))
+	}
+	done, err = migrator.Down(ctx, 1)
+	if err == nil || err.Error() != "migration 1 (first) can not be reverted" || len(done) != 0 {
+		t.Fatalf("%s migrations without down must not be reverted: %v %v", failed, versions(done), err)
+	}
+	if store.holder() != "" {
+		t.Fatalf("%s lock must be released after down: %q", failed, store.holder())
+	}
+	t.Logf("%s Testing down of migrations is successful", succeed)
+}
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	j := new(journal)
+	store := newMemoryStore()
+	migrator := newTestMigrator(store, Migration{Version: 1, Description: "first", Up: j.step("up 1")})
+
+	store.setLock("other", time.Now().Add(time.Hour))
+	if _, err := migrator.Up(ctx); err != ErrLocked || len(j.list()) != 0 {
+		t.Fatalf("%s up must wait for the lock of another process and fail: %v %v", failed, err, j.list())
+	}
+	if store.holder() != "other" {
+		t.Fatalf("%s lock of another process must not be released: %q", failed, store.holder())
+	}
+
+	// the lock of a crashed process expires.
+	store.setLock("other", time.Now().Add(-time.Second))
+	if done, err := migrator.Up(ctx); err != nil || len(done) != 1 {
+		t.Fatalf("%s expired lock must be taken: %v %v", failed, versions(done), err)
+	}
+	t.Logf("%s Testing migration lock is successful", succeed)
+}
+
+func TestLockIsRenewed(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	slow := Migration{Version: 1, Description: "slow", Up: func(ctx context.Context, db *mongo.Database) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(150 * time.Millisecond):
+			return nil
+		}
+	}}
+	migrator := newTestMigrator(store, slow)
+	other := newTestMigrator(store, slow)
+	other.LockWait = 0
+
+	result := make(chan error)
+	go func() {
+		_, err := migrator.Up(ctx)
+		result <- err
+	}()
+	// the migration runs longer than LockTimeout, its lock must not expire.
+	time.Sleep(100 * time.Millisecond)
+	if _, err := other.Up(ctx); err != ErrLocked {
+		t.Fatalf("%s lock of running migrations must be renewed: %v", failed, err)
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("%s migration must be applied: %v", failed, err)
+	}
+	t.Logf("%s Testing migration lock renewal is successful", succeed)
+}
+
+func TestLockIsLost(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	migrator := newTestMigrator(store, Migration{Version: 1, Description: "blocking", Up: func(ctx context.Context, db *mongo.Database) error {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	}})
+
+	result := make(chan error)
+	go func() {
+		_, err := migrator.Up(ctx)
+		result <- err
+	}()
+	<-started
+	// another process took the lock, for example after this one was paused longer than LockTimeout.
+	store.setLock("other", time.Now().Add(time.Hour))
+	if err := <-result; err != ErrLockLost {
+		t.Fatalf("%s migrations must be stopped when their lock is lost: %v", failed, err)
+	}
+	<-stopped
+	if pending, _ := migrator.Pending(ctx); len(pending) != 1 || store.holder() != "other" {
+		t.Fatalf("%s stopped migration must not be saved or release the lock of another process: %v %q", failed, versions(pending), store.holder())
+	}
+	t.Logf("%s Testing lost migration lock is successful", succeed)
+}
+
+func TestRegisteredMigrations(t *testing.T) {
+	migrator := New(nil)
+	if len(migrator.migrations) != len(registry) {
+		t.Fatalf("%s migrator must use the registered migrations: %d", failed, len(migrator.migrations))
+	}
+	for i := 1; i < len(migrator.migrations); i++ {
+		if migrator.migrations[i-1].Version >= migrator.migrations[i].Version {
+			t.Fatalf("%s migrations must be sorted by version: %v", failed, versions(migrator.migrations))
+		}
+	}
+	t.Logf("%s Testing registered migrations is successful", succeed)
+}
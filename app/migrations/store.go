@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// store keeps the applied migrations and the migration lock.
+type store interface {
+	// applied will return the records of applied migrations.
+	applied(ctx context.Context) ([]record, error)
+	// insert will save the record of an applied migration.
+	insert(ctx context.Context, rec record) error
+	// delete will remove the record of a reverted migration.
+	delete(ctx context.Context, version int64) error
+	// lock will take or renew the lock for owner until timeout, it returns ErrLocked when another owner holds it.
+	lock(ctx context.Context, owner string, timeout time.Duration) error
+	// unlock will remove the lock if owner holds it.
+	unlock(ctx context.Context, owner string) error
+}
+
+// mongoStore is the store of schema_migrations and migration_locks collections.
+type mongoStore struct {
+	db *mongo.Database
+}
+
+func (store mongoStore) applied(ctx context.Context) ([]record, error) {
+	curser, err := store.db.Collection(collection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var records []record
+	if err := curser.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (store mongoStore) insert(ctx context.Context, rec record) error {
+	_, err := store.db.Collection(collection).InsertOne(ctx, rec)
+	return err
+}
+
+func (store mongoStore) delete(ctx context.Context, version int64) error {
+	_, err := store.db.Collection(collection).DeleteOne(ctx, bson.M{"_id": version})
+	return err
+}
+
+// lock will take the lock if it is free, expired or already ours. the upsert fails with a
+// duplicate key error when another process holds the lock.
+func (store mongoStore) lock(ctx context.Context, owner string, timeout time.Duration) error {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id": lockID,
+		"$or": []bson.M{
+			{"owner": owner},
+			{"expires_at": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": owner, "expires_at": now.Add(timeout)}}
+	_, err := store.db.Collection(lockCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if db.IsDuplicateKey(err) {
+		return ErrLocked
+	}
+	return err
+}
+
+func (store mongoStore) unlock(ctx context.Context, owner string) error {
+	_, err := store.db.Collection(lockCollection).DeleteOne(ctx, bson.M{"_id": lockID, "owner": owner})
+	return err
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// username and email are normalized before they are saved, so the old people that have spaces
+// around them are updated. it fails if a trimmed value is already used by another person.
+func init() {
+	Register(Migration{
+		Version:     1,
+		Description: "trim username and email of people",
+		Up:          trimUsernameEmail,
+	})
+}
+
+func trimUsernameEmail(ctx context.Context, db *mongo.Database) error {
+	people := db.Collection("people")
+	spaces := primitive.Regex{Pattern: `^\s|\s$`}
+	curser, err := people.Find(ctx, bson.M{"$or": []bson.M{
+		{"username": spaces},
+		{"email": spaces},
+	}})
+	if err != nil {
+		return err
+	}
+	defer curser.Close(ctx)
+	var failed []string
+	for curser.Next(ctx) {
+		var person model.Person
+		if err := curser.Decode(&person); err != nil {
+			return err
+		}
+		update := bson.M{"$set": bson.M{
+			"username": strings.TrimSpace(person.Username),
+			"email":    strings.TrimSpace(person.Email),
+		}}
+		if _, err := people.UpdateOne(ctx, bson.M{"_id": person.ID}, update); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", person.ID.Hex(), err))
+		}
+	}
+	if err := curser.Err(); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not update %d people: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
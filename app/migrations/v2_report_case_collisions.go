@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// username and email are unique and case insensitive, but the first versions only had a case
+// sensitive index of both fields. people whose username or email differ only by case, like Bob
+// and bob, can not be merged automatically, so they are reported and the migration fails until
+// they are changed. the unique indexes can not be created while they exist.
+func init() {
+	Register(Migration{
+		Version:     2,
+		Description: "report people whose username or email differ only by case",
+		Up:          reportCaseCollisions,
+		Down:        func(ctx context.Context, db *mongo.Database) error { return nil },
+	})
+}
+
+// collision is a group of people that have the same value of a unique field in a tenant.
+type collision struct {
+	Key struct {
+		TenantID string `bson:"tenant_id"`
+		Value    string `bson:"value"`
+	} `bson:"_id"`
+	IDs []primitive.ObjectID `bson:"ids"`
+}
+
+func reportCaseCollisions(ctx context.Context, database *mongo.Database) error {
+	var report []string
+	for _, field := range []string{"username", "email"} {
+		collisions, err := caseCollisions(ctx, database.Collection("people"), field)
+		if err != nil {
+			return err
+		}
+		for _, c := range collisions {
+			ids := make([]string, len(c.IDs))
+			for i, id := range c.IDs {
+				ids[i] = id.Hex()
+			}
+			line := fmt.Sprintf("%s %q is used by %s", field, c.Key.Value, strings.Join(ids, ", "))
+			if c.Key.TenantID != "" {
+				line += " of tenant " + c.Key.TenantID
+			}
+			report = append(report, line)
+		}
+	}
+	if len(report) > 0 {
+		return fmt.Errorf("%d values differ only by case, change them and run migrations again: %s", len(report), strings.Join(report, "; "))
+	}
+	return nil
+}
+
+// caseCollisions will return the values of field that more than one person has after they are
+// lowercased, they are unique per tenant when tenants share the collection.
+func caseCollisions(ctx context.Context, people *mongo.Collection, field string) ([]collision, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"tenant_id": "$" + db.TenantField,
+				"value":     bson.M{"$toLower": "$" + field},
+			},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	curser, err := people.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var collisions []collision
+	if err := curser.All(ctx, &collisions); err != nil {
+		return nil, err
+	}
+	return collisions, nil
+}
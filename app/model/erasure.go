@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Erasure is an entry of the erasure log, it records that the personal data of a person is erased. it
+// has no personal data. entries are saved in erasures collection and every entry has the HMAC of the
+// previous one, so changed or removed entries can be found.
+type Erasure struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id"`
+	Sequence   int64              `json:"sequence" bson:"sequence"` // position of entry in the log of its tenant, the first one is 1
+	PersonID   primitive.ObjectID `json:"person_id" bson:"person_id"`
+	Fields     []string           `json:"fields" bson:"fields"`           // fields of person that are anonymized
+	ImportRows int64              `json:"import_rows" bson:"import_rows"` // rejected import rows of person that are deleted
+	ErasedAt   time.Time          `json:"erased_at" bson:"erased_at"`
+	Previous   string             `json:"previous" bson:"previous"` // hash of previous entry, it is empty for the first entry
+	Hash       string             `json:"hash" bson:"hash"`
+	TenantID   string             `json:"-" bson:"tenant_id,omitempty"` // tenant of erasure when tenants share the collections.
+	// Pending is true while the person is not anonymized yet, an erasure that fails is finished when it
+	// is run again.
+	Pending bool `json:"pending,omitempty" bson:"pending,omitempty"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type (
+	// ImportReport is the summary of a people import. it will be saved in imports collection.
+	ImportReport struct {
+		ID          primitive.ObjectID `json:"_id" bson:"_id"`
+		Filename    string             `json:"filename" bson:"filename"`
+		Format      string             `json:"format" bson:"format"`
+		DryRun      bool               `json:"dry_run" bson:"dry_run"`
+		Total       int                `json:"total" bson:"total"`
+		Created     int                `json:"created" bson:"created"`
+		Updated     int                `json:"updated" bson:"updated"`
+		Rejected    int                `json:"rejected" bson:"rejected"`
+		ErrorReport string             `json:"error_report,omitempty" bson:"-"`
+		Errors      []ImportRowError   `json:"errors,omitempty" bson:"-"` // first rejected rows, the full list is in error report.
+		CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+		TenantID    string             `json:"-" bson:"tenant_id,omitempty"` // tenant of import when tenants share the collections.
+	}
+
+	// ImportRowError is a rejected row of an import. they will be saved in import_errors collection.
+	ImportRowError struct {
+		ReportID primitive.ObjectID `json:"-" bson:"report_id"`
+		Line     int                `json:"line" bson:"line"`
+		Error    string             `json:"error" bson:"error"`
+		Row      string             `json:"row" bson:"row"`
+	}
+)
+
+// NewImportReport is the ImportReport struct factory function.
+func NewImportReport(filename, format string, dryRun bool) *ImportReport {
+	return &ImportReport{
+		ID:        primitive.NewObjectID(),
+		Filename:  filename,
+		Format:    format,
+		DryRun:    dryRun,
+		CreatedAt: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JobState is the state of a background job.
+type JobState string
+
+// job states. succeeded, failed and cancelled are the final states.
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+type (
+	// Job is a long running operation that will be run by job workers. jobs are saved in jobs collection.
+	Job struct {
+		ID              primitive.ObjectID     `json:"_id" bson:"_id"`
+		Type            string                 `json:"type" bson:"type"`
+		State           JobState               `json:"state" bson:"state" schema:"enum=queued|running|succeeded|failed|cancelled"`
+		Params          map[string]interface{} `json:"params,omitempty" bson:"params,omitempty"`
+		Progress        JobProgress            `json:"progress" bson:"progress"`
+		Result          map[string]interface{} `json:"result,omitempty" bson:"result,omitempty"`
+		Error           string                 `json:"error,omitempty" bson:"error,omitempty"`
+		Attempts        int                    `json:"attempts" bson:"attempts"`
+		CancelRequested bool                   `json:"cancel_requested" bson:"cancel_requested"`
+		LeaseOwner      string                 `json:"-" bson:"lease_owner,omitempty"`      // worker that is running the job.
+		LeaseExpiresAt  *time.Time             `json:"-" bson:"lease_expires_at,omitempty"` // job can be claimed again after this time.
+		CreatedAt       time.Time              `json:"created_at" bson:"created_at"`
+		StartedAt       *time.Time             `json:"started_at,omitempty" bson:"started_at,omitempty"`
+		FinishedAt      *time.Time             `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+		TenantID        string                 `json:"-" bson:"tenant_id,omitempty"` // tenant of job, jobs of all tenants are in the base database.
+	}
+
+	// JobProgress is the progress that job reports while it is running.
+	JobProgress struct {
+		Done  int64 `json:"done" bson:"done"`
+		Total int64 `json:"total" bson:"total"`
+	}
+)
+
+// NewJob is the Job struct factory function. job will be in queued state.
+func NewJob(jobType string, params map[string]interface{}) *Job {
+	return &Job{
+		ID:        primitive.NewObjectID(),
+		Type:      jobType,
+		State:     JobQueued,
+		Params:    params,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// Finished will return true if job is in a final state.
+func (job *Job) Finished() bool {
+	switch job.State {
+	case JobSucceeded, JobFailed, JobCancelled:
+		return true
+	}
+	return false
+}
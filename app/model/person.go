@@ -1,15 +1,26 @@
 package model
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // Person is the data structure that we will save and receive.
 type Person struct {
 	ID        primitive.ObjectID     `json:"_id,omitempty" bson:"_id,omitempty"`
 	FirstName string                 `json:"first_name,omitempty" bson:"first_name,omitempty"`
 	LastName  string                 `json:"last_name,omitempty" bson:"last_name,omitempty"`
-	Username  string                 `json:"username,omitempty" bson:"username,omitempty"`
-	Email     string                 `json:"email,omitempty" bson:"email,omitempty"`
+	Username  string                 `json:"username,omitempty" bson:"username,omitempty" schema:"required,minLength=1"`
+	Email     string                 `json:"email,omitempty" bson:"email,omitempty" schema:"required,format=email"`
 	Data      map[string]interface{} `json:"data,omitempty" bson:"data,omitempty"` // data is a optional fields that can hold anything in key:value format.
+	TenantID  string                 `json:"-" bson:"tenant_id,omitempty"`         // tenant of person when tenants share the collections.
+	// BlindIndex has the blind indexes of encrypted deterministic fields by field name, they are searched
+	// and unique instead of the ciphertexts.
+	BlindIndex map[string]string `json:"-" bson:"blind_index,omitempty"`
 }
 
 // NewPerson will return a Person{} instance, Person structure factory function
@@ -22,3 +33,36 @@ func NewPerson(firstName, lastName, userName, email string, data map[string]inte
 		Data:      data,
 	}
 }
+
+// Normalize will remove the spaces around username and email. they are unique and case insensitive,
+// so the saved case is kept and the unique indexes compare them with case insensitive collation.
+func (person *Person) Normalize() {
+	person.Username = strings.TrimSpace(person.Username)
+	person.Email = strings.TrimSpace(person.Email)
+}
+
+// Validate will check that required fields are filled and email has a valid format.
+func (person *Person) Validate() error {
+	return person.ValidateFields("username", "email")
+}
+
+// ValidateFields will check the fields of person that are named by their json names, it validates
+// partial updates. fields that have no rules are valid.
+func (person *Person) ValidateFields(fields ...string) error {
+	for _, field := range fields {
+		switch field {
+		case "username":
+			if strings.TrimSpace(person.Username) == "" {
+				return errors.New("username is required")
+			}
+		case "email":
+			if strings.TrimSpace(person.Email) == "" {
+				return errors.New("email is required")
+			}
+			if _, err := mail.ParseAddress(person.Email); err != nil {
+				return fmt.Errorf("email %q is not valid", person.Email)
+			}
+		}
+	}
+	return nil
+}
@@ -8,6 +8,12 @@ type (
 		Content interface{} `json:"content"`
 	}
 
+	// ContentResponse is the http response schema of v2, status is only in the status line.
+	ContentResponse struct {
+		Message string      `json:"message,omitempty"`
+		Content interface{} `json:"content"`
+	}
+
 	// PaginatedResponse is the paginated response json schema
 	// we not use it yet
 	PaginatedResponse struct {
@@ -27,6 +33,14 @@ func NewResponse(status int, message string, content interface{}) *Response {
 	}
 }
 
+// NewContentResponse is the ContentResponse struct factory function.
+func NewContentResponse(message string, content interface{}) *ContentResponse {
+	return &ContentResponse{
+		Message: message,
+		Content: content,
+	}
+}
+
 // NewPaginatedResponse will created http paginated response
 func NewPaginatedResponse(status, count int, message, next, prev string, results interface{}) *Response {
 	return &Response{
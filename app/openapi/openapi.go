@@ -0,0 +1,311 @@
+// Package openapi generates the OpenAPI 3.1 document of api from the operations of its routes.
+//
+// every route is added to a Spec with its Operation, the request and response bodies are go values
+// whose json encoding is described by generated schemas. named struct types are shared in components.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.1.0"
+
+type (
+	// Operation is the documentation of a route.
+	Operation struct {
+		ID          string
+		Summary     string
+		Description string
+		Tags        []string
+		Deprecated  bool
+		Parameters  []Parameter // query parameters and path parameters that are not strings.
+		Request     interface{} // value of request body type, nil for routes without body.
+		// RequestMediaTypes are the media types of request body, default is Spec.MediaTypes.
+		RequestMediaTypes []string
+		Responses         map[int]Response
+	}
+
+	// Parameter is a path or query parameter. path parameters are added from path template,
+	// they only need to be declared to change their schema or description.
+	Parameter struct {
+		Name        string  `json:"name"`
+		In          string  `json:"in"` // path or query
+		Description string  `json:"description,omitempty"`
+		Required    bool    `json:"required,omitempty"`
+		Schema      *Schema `json:"schema"`
+	}
+
+	// Response is a response of an operation.
+	Response struct {
+		Description string
+		Body        interface{} // value of body type, it is written in Spec.Envelope unless Raw is true.
+		MediaTypes  []string    // media types of body, default is Spec.MediaTypes.
+		Raw         bool
+		Error       bool // error responses are the legacy envelope or the problem details.
+	}
+)
+
+// Spec holds the operations of api routes.
+type Spec struct {
+	Title       string
+	Description string
+	Version     string
+	MediaTypes  []string                        // media types of request and response bodies.
+	Envelope    func(content *Schema) *Schema   // schema of responses that have content.
+	Problem     interface{}                     // value of problem details type of error responses.
+	Servers     []string                        // base paths of operation paths, like /v2.
+	Deprecated  bool                            // all operations are deprecated.
+	operations  map[string]map[string]Operation // path -> method -> operation
+	paths       []string
+
+	once     sync.Once
+	document []byte
+}
+
+// New is the Spec struct factory function.
+func New(title, version string) *Spec {
+	return &Spec{
+		Title:      title,
+		Version:    version,
+		MediaTypes: []string{"application/json"},
+		Envelope:   func(content *Schema) *Schema { return content },
+		operations: make(map[string]map[string]Operation),
+	}
+}
+
+// Copy will return a spec that has the operations of spec, documents of api versions that share
+// the routes are copies with their own envelope. operations that are added later are not copied.
+func (spec *Spec) Copy() *Spec {
+	return &Spec{
+		Title:       spec.Title,
+		Description: spec.Description,
+		Version:     spec.Version,
+		MediaTypes:  spec.MediaTypes,
+		Envelope:    spec.Envelope,
+		Problem:     spec.Problem,
+		Servers:     spec.Servers,
+		Deprecated:  spec.Deprecated,
+		operations:  spec.operations,
+		paths:       append([]string(nil), spec.paths...),
+	}
+}
+
+// pathVariable matches the variables of mux path templates, {name} or {name:pattern}.
+var pathVariable = regexp.MustCompile(`\{([^{}:]+)(?::(?:[^{}]|\{[^{}]*\})*)?\}`)
+
+// Path will convert a mux path template to the OpenAPI path, patterns of variables are removed.
+func Path(template string) string {
+	return pathVariable.ReplaceAllString(template, "{$1}")
+}
+
+// Add will add the operation of method and mux path template. queries are the mux query pairs of
+// route, their keys are added as optional query parameters. a method and path that are added
+// again are the same operation with more query parameters.
+func (spec *Spec) Add(method, path string, operation Operation, queries ...string) {
+	path = Path(path)
+	method = strings.ToUpper(method)
+	for _, match := range pathVariable.FindAllStringSubmatch(path, -1) {
+		if _, ok := parameter(operation.Parameters, match[1], "path"); !ok {
+			operation.Parameters = append(operation.Parameters, Parameter{Name: match[1], In: "path", Schema: &Schema{Type: "string"}})
+		}
+	}
+	for i := 0; i+1 < len(queries); i += 2 {
+		if _, ok := parameter(operation.Parameters, queries[i], "query"); !ok {
+			operation.Parameters = append(operation.Parameters, Parameter{Name: queries[i], In: "query", Schema: &Schema{Type: "string"}})
+		}
+	}
+	for i := range operation.Parameters {
+		if operation.Parameters[i].In == "path" {
+			operation.Parameters[i].Required = true
+		}
+	}
+
+	methods, ok := spec.operations[path]
+	if !ok {
+		methods = make(map[string]Operation)
+		spec.operations[path] = methods
+		spec.paths = append(spec.paths, path)
+	}
+	if existing, ok := methods[method]; ok {
+		for _, param := range operation.Parameters {
+			if _, ok := parameter(existing.Parameters, param.Name, param.In); !ok {
+				existing.Parameters = append(existing.Parameters, param)
+			}
+		}
+		operation = existing
+	}
+	methods[method] = operation
+}
+
+func parameter(parameters []Parameter, name, in string) (Parameter, bool) {
+	for _, param := range parameters {
+		if param.Name == name && param.In == in {
+			return param, true
+		}
+	}
+	return Parameter{}, false
+}
+
+// Operation will return the operation of method and mux path template.
+func (spec *Spec) Operation(method, path string) (Operation, bool) {
+	operation, ok := spec.operations[Path(path)][strings.ToUpper(method)]
+	return operation, ok
+}
+
+type (
+	// Document is the OpenAPI document.
+	Document struct {
+		OpenAPI    string                                 `json:"openapi"`
+		Info       Info                                   `json:"info"`
+		Servers    []Server                               `json:"servers,omitempty"`
+		Paths      map[string]map[string]*OperationObject `json:"paths"` // path -> lower case method -> operation
+		Components Components                             `json:"components"`
+	}
+
+	// Info is the metadata of api.
+	Info struct {
+		Title       string `json:"title"`
+		Description string `json:"description,omitempty"`
+		Version     string `json:"version"`
+	}
+
+	// Server is a base url of operation paths.
+	Server struct {
+		URL string `json:"url"`
+	}
+
+	// Components holds the shared schemas.
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	}
+
+	// OperationObject is the operation object of document.
+	OperationObject struct {
+		OperationID string                   `json:"operationId,omitempty"`
+		Summary     string                   `json:"summary,omitempty"`
+		Description string                   `json:"description,omitempty"`
+		Tags        []string                 `json:"tags,omitempty"`
+		Deprecated  bool                     `json:"deprecated,omitempty"`
+		Parameters  []Parameter              `json:"parameters,omitempty"`
+		RequestBody *RequestBody             `json:"requestBody,omitempty"`
+		Responses   map[string]*ResponseBody `json:"responses"`
+	}
+
+	// RequestBody is the request body object of document.
+	RequestBody struct {
+		Required bool                 `json:"required"`
+		Content  map[string]MediaType `json:"content"`
+	}
+
+	// ResponseBody is the response object of document.
+	ResponseBody struct {
+		Description string               `json:"description"`
+		Content     map[string]MediaType `json:"content,omitempty"`
+	}
+
+	// MediaType is the schema of a media type.
+	MediaType struct {
+		Schema *Schema `json:"schema"`
+	}
+)
+
+// problemMediaType is the media type of problem details.
+const problemMediaType = "application/problem+json"
+
+// Document will generate the OpenAPI document of added operations.
+func (spec *Spec) Document() *Document {
+	schemas := newSchemas()
+	document := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: spec.Title, Description: spec.Description, Version: spec.Version},
+		Paths:   make(map[string]map[string]*OperationObject),
+	}
+	for _, url := range spec.Servers {
+		document.Servers = append(document.Servers, Server{URL: url})
+	}
+	for _, path := range spec.paths {
+		item := make(map[string]*OperationObject)
+		for method, operation := range spec.operations[path] {
+			item[strings.ToLower(method)] = spec.operationObject(schemas, operation)
+		}
+		document.Paths[path] = item
+	}
+	document.Components.Schemas = schemas.components
+	return document
+}
+
+func (spec *Spec) operationObject(schemas *schemas, operation Operation) *OperationObject {
+	object := &OperationObject{
+		OperationID: operation.ID,
+		Summary:     operation.Summary,
+		Description: operation.Description,
+		Tags:        operation.Tags,
+		Deprecated:  operation.Deprecated || spec.Deprecated,
+		Parameters:  append([]Parameter(nil), operation.Parameters...),
+		Responses:   make(map[string]*ResponseBody),
+	}
+	sort.SliceStable(object.Parameters, func(i, j int) bool { return object.Parameters[i].In < object.Parameters[j].In })
+	if operation.Request != nil {
+		schema := schemas.of(operation.Request, false)
+		object.RequestBody = &RequestBody{Required: true, Content: content(schema, spec.mediaTypes(operation.RequestMediaTypes))}
+	}
+	for status, response := range operation.Responses {
+		body := &ResponseBody{Description: response.Description}
+		if body.Description == "" {
+			body.Description = http.StatusText(status)
+		}
+		if response.Raw {
+			body.Content = content(schemas.of(response.Body, true), spec.mediaTypes(response.MediaTypes))
+		} else {
+			body.Content = content(spec.Envelope(schemas.of(response.Body, true)), spec.mediaTypes(response.MediaTypes))
+		}
+		if response.Error && spec.Problem != nil {
+			body.Content[problemMediaType] = MediaType{schemas.of(spec.Problem, false)}
+		}
+		object.Responses[strconv.Itoa(status)] = body
+	}
+	return object
+}
+
+func (spec *Spec) mediaTypes(mediaTypes []string) []string {
+	if len(mediaTypes) > 0 {
+		return mediaTypes
+	}
+	return spec.MediaTypes
+}
+
+func content(schema *Schema, mediaTypes []string) map[string]MediaType {
+	content := make(map[string]MediaType, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		content[mediaType] = MediaType{schema}
+	}
+	return content
+}
+
+// JSON will return the json encoding of document, it is generated once.
+func (spec *Spec) JSON() []byte {
+	spec.once.Do(func() {
+		document, err := json.MarshalIndent(spec.Document(), "", "  ")
+		if err != nil {
+			panic(fmt.Sprintf("openapi: could not encode document: %v", err))
+		}
+		spec.document = document
+	})
+	return spec.document
+}
+
+// Handler will serve the json document.
+func (spec *Spec) Handler() http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("content-type", "application/json; charset=UTF-8")
+		res.Write(spec.JSON())
+	}
+}
@@ -0,0 +1,160 @@
+package openapi
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+type (
+	Address struct {
+		City string `json:"city"`
+	}
+
+	base struct {
+		ID primitive.ObjectID `json:"_id"`
+	}
+
+	Customer struct {
+		base
+		Name      string         `json:"name"`
+		Nickname  *string        `json:"nickname"`
+		Tags      []string       `json:"tags,omitempty"`
+		Address   *Address       `json:"address,omitempty"`
+		Scores    map[string]int `json:"scores"`
+		CreatedAt time.Time      `json:"created_at"`
+		Secret    string         `json:"-"`
+		internal  string
+	}
+)
+
+func TestSchemas(t *testing.T) {
+	schemas := newSchemas()
+	schema := schemas.of([]Customer{}, true)
+	if !reflect.DeepEqual(schema.Type, []string{"array", "null"}) || schema.Items.Ref != "#/components/schemas/Customer" {
+		t.Fatalf("%s list schema is wrong: %+v", failed, schema)
+	}
+	customer := schemas.components["Customer"]
+	want := []string{"_id", "name", "nickname", "scores", "created_at"}
+	if !reflect.DeepEqual(customer.Required, want) {
+		t.Errorf("%s required fields are wrong: got %v want %v", failed, customer.Required, want)
+	}
+	if len(customer.Properties) != 7 || customer.Properties["_id"].Pattern == "" || customer.Properties["created_at"].Format != "date-time" {
+		t.Errorf("%s properties are wrong: %+v", failed, customer.Properties)
+	}
+	if !reflect.DeepEqual(customer.Properties["nickname"].Type, []string{"string", "null"}) {
+		t.Errorf("%s nullable pointer is wrong: %+v", failed, customer.Properties["nickname"])
+	}
+	if customer.Properties["address"].Ref != "#/components/schemas/Address" || schemas.components["Address"] == nil {
+		t.Errorf("%s nested struct is not a component: %+v", failed, customer.Properties["address"])
+	}
+	if customer.Properties["scores"].AdditionalProperties.Type != "integer" {
+		t.Errorf("%s map values are wrong: %+v", failed, customer.Properties["scores"])
+	}
+	t.Logf("%s Testing schema generation is successful", succeed)
+}
+
+func TestAdd(t *testing.T) {
+	spec := New("test", "1")
+	operation := Operation{Summary: "list", Parameters: []Parameter{{Name: "page", In: "query", Schema: &Schema{Type: "integer"}}}}
+	spec.Add("GET", "/customer/{id:[0-9a-f]{24}}/orders", operation)
+	spec.Add("GET", "/customer/{id:[0-9a-f]{24}}/orders", operation, "page", "{page}", "sort", "{sort}")
+	operation, ok := spec.Operation("get", "/customer/{id}/orders")
+	if !ok {
+		t.Fatalf("%s operation is not found", failed)
+	}
+	var names []string
+	for _, param := range operation.Parameters {
+		names = append(names, param.In+":"+param.Name)
+		if param.In == "path" && !param.Required {
+			t.Errorf("%s path parameter must be required", failed)
+		}
+	}
+	if want := []string{"query:page", "path:id", "query:sort"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("%s parameters are wrong: got %v want %v", failed, names, want)
+	}
+	if _, ok := spec.Document().Paths["/customer/{id}/orders"]["get"]; !ok {
+		t.Errorf("%s document path is wrong: %v", failed, spec.Document().Paths)
+	}
+	t.Logf("%s Testing operations is successful", succeed)
+}
+
+type Signup struct {
+	Email string   `json:"email,omitempty" schema:"required,format=email"`
+	Age   int      `json:"age,omitempty" schema:"minimum=18"`
+	Tags  []string `json:"tags,omitempty"`
+	Nick  string   `json:"nick,omitempty" schema:"maxLength=3"`
+	Plan  string   `json:"plan,omitempty" schema:"enum=free|pro"`
+}
+
+func TestValidate(t *testing.T) {
+	spec := New("test", "1")
+	spec.Add("POST", "/signup/{id}", Operation{
+		Summary:    "signup",
+		Parameters: []Parameter{{Name: "id", In: "path", Schema: &Schema{Type: "string", Pattern: "^[0-9]+$"}}, {Name: "page", In: "query", Schema: &Schema{Type: "integer"}}},
+		Request:    Signup{},
+		Responses:  map[int]Response{201: {Body: Signup{}}},
+	})
+	validator := spec.Validator()
+	operation, ok := validator.Operation("POST", "/signup/{id}")
+	if !ok {
+		t.Fatalf("%s operation is not found", failed)
+	}
+
+	errs := validator.Parameters(operation, map[string]string{"id": "x1"}, map[string][]string{"page": {"two"}})
+	if len(errs) != 2 || errs[0].Field != "path.id" || errs[1].Field != "query.page" {
+		t.Errorf("%s parameter errors are wrong: %v", failed, errs)
+	}
+	if errs := validator.Parameters(operation, map[string]string{"id": "12"}, map[string][]string{"page": {"2"}}); len(errs) != 0 {
+		t.Errorf("%s valid parameters have errors: %v", failed, errs)
+	}
+
+	body := map[string]interface{}{"email": "john", "age": float64(12), "plan": "gold", "nick": "johnny", "tags": []interface{}{"ok", 2.0}}
+	errs = validator.Body(operation, "application/json", body, false)
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	if want := []string{"body.age", "body.email", "body.nick", "body.plan", "body.tags[1]"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("%s body errors are wrong: got %v want %v", failed, errs, want)
+	}
+	if errs := validator.Body(operation, "application/json", map[string]interface{}{"age": float64(20)}, false); len(errs) != 1 || errs[0].Message != "is required" {
+		t.Errorf("%s required error is wrong: %v", failed, errs)
+	}
+	if errs := validator.Body(operation, "application/xml", map[string]interface{}{"email": "john@gmail.com", "age": "20"}, true); len(errs) != 0 {
+		t.Errorf("%s coerced body has errors: %v", failed, errs)
+	}
+	if errs := validator.Body(operation, "application/json", nil, false); len(errs) != 1 || errs[0].Field != "body" {
+		t.Errorf("%s missing body error is wrong: %v", failed, errs)
+	}
+
+	if errs := validator.Response(operation, 201, "application/json", Signup{Email: "john@gmail.com"}); len(errs) != 0 {
+		t.Errorf("%s valid response has errors: %v", failed, errs)
+	}
+	if errs := validator.Response(operation, 200, "application/json", Signup{}); len(errs) != 1 || errs[0].Field != "status" {
+		t.Errorf("%s undocumented status error is wrong: %v", failed, errs)
+	}
+	t.Logf("%s Testing validation is successful", succeed)
+}
+
+func TestUIHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	New("People API", "1.0.0").UIHandler("openapi.json")(rr, httptest.NewRequest("GET", "/docs", nil))
+	page := rr.Body.String()
+	if !strings.Contains(page, `var documentURL = "openapi.json";`) || !strings.Contains(page, "<title>People API</title>") {
+		t.Fatalf("%s docs page must load the document of url: %s", failed, page)
+	}
+	for _, external := range []string{"http://", "https://", "src=", "<link"} {
+		if strings.Contains(page, external) {
+			t.Fatalf("%s docs page must not load %s resources", failed, external)
+		}
+	}
+	t.Logf("%s Testing docs page is successful", succeed)
+}
@@ -0,0 +1,222 @@
+package openapi
+
+import (
+	"fmt"
+	"go/ast"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Schema is a JSON Schema 2020-12 object, the subset that api types need.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"` // a type name or a list of them for nullable values.
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	ContentMediaType     string             `json:"contentMediaType,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// File is the type of file fields in multipart request bodies.
+type File []byte
+
+var (
+	fileType     = reflect.TypeOf(File(nil))
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// schemas generates the schemas of go types, named struct types are added to components.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// of will return the schema of the json encoding of value's type, nil value is any value.
+// nullable is true for response bodies, nil slices and maps are encoded as null.
+func (s *schemas) of(value interface{}, nullable bool) *Schema {
+	if value == nil {
+		return &Schema{}
+	}
+	if schema, ok := value.(*Schema); ok {
+		return schema
+	}
+	return s.schema(reflect.TypeOf(value), nullable)
+}
+
+// schema will return the schema of t. nullable is true for values that are encoded as null when they are nil.
+func (s *schemas) schema(t reflect.Type, nullable bool) *Schema {
+	switch t {
+	case fileType:
+		return &Schema{Type: "string", ContentMediaType: "application/octet-stream"}
+	case objectIDType:
+		return &Schema{Type: "string", Pattern: "^[0-9a-fA-F]{24}$"}
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.schema(t.Elem(), false)
+		if name, ok := schema.Type.(string); ok {
+			schema.Type = nullableType(name, nullable)
+		}
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: nullableType("array", nullable && t.Kind() == reflect.Slice), Items: s.schema(t.Elem(), false)}
+	case reflect.Map:
+		schema := &Schema{Type: nullableType("object", nullable)}
+		if t.Elem().Kind() != reflect.Interface {
+			schema.AdditionalProperties = s.schema(t.Elem(), false)
+		}
+		return schema
+	case reflect.Struct:
+		if !ast.IsExported(t.Name()) {
+			return s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.component(t)}
+	}
+	return &Schema{}
+}
+
+// component will add the schema of a named struct type to components and return its name.
+func (s *schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, ok := s.components[name]; ok {
+		// another package has a type with the same name.
+		name = strings.Title(t.String()[:strings.Index(t.String(), ".")]) + name
+	}
+	s.names[t] = name
+	s.components[name] = &Schema{} // placeholder for recursive types.
+	*s.components[name] = *s.object(t)
+	return name
+}
+
+// object will return the schema of struct fields. fields without omitempty are required.
+// schema tag of fields adds constraints, for example `schema:"required,format=email,maxLength=254"`.
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options := field.Name, ""
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			if i := strings.Index(tag, ","); i >= 0 {
+				options = tag[i:]
+				tag = tag[:i]
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // unexported
+		}
+		omitempty := strings.Contains(options, ",omitempty")
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields := s.object(embedded)
+				for name, property := range fields.Properties {
+					schema.Properties[name] = property
+				}
+				schema.Required = append(schema.Required, fields.Required...)
+				continue
+			}
+		}
+		property := s.schema(field.Type, !omitempty)
+		required := constrain(property, field.Tag.Get("schema"))
+		schema.Properties[name] = property
+		if !omitempty || required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+func nullableType(name string, nullable bool) interface{} {
+	if nullable {
+		return []string{name, "null"}
+	}
+	return name
+}
+
+// constrain will add the constraints of a schema tag to schema, it returns true if field is required.
+// enum values are separated by |.
+func constrain(schema *Schema, tag string) (required bool) {
+	for _, option := range strings.Split(tag, ",") {
+		key, value := option, ""
+		if i := strings.Index(option, "="); i >= 0 {
+			key, value = option[:i], option[i+1:]
+		}
+		switch key {
+		case "required":
+			required = true
+		case "format":
+			schema.Format = value
+		case "pattern":
+			schema.Pattern = value
+		case "enum":
+			for _, item := range strings.Split(value, "|") {
+				schema.Enum = append(schema.Enum, item)
+			}
+		case "minLength", "maxLength":
+			length, err := strconv.Atoi(value)
+			if err != nil {
+				panic(fmt.Sprintf("openapi: %s of schema tag %q is not a number", key, tag))
+			}
+			if key == "minLength" {
+				schema.MinLength = &length
+			} else {
+				schema.MaxLength = &length
+			}
+		case "minimum", "maximum":
+			limit, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				panic(fmt.Sprintf("openapi: %s of schema tag %q is not a number", key, tag))
+			}
+			if key == "minimum" {
+				schema.Minimum = &limit
+			} else {
+				schema.Maximum = &limit
+			}
+		}
+	}
+	return required
+}
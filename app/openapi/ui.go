@@ -0,0 +1,258 @@
+package openapi
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// docsPage is the api explorer of the document that is served on URL. its styles and scripts are part
+// of the page, so docs work offline and load nothing from other servers. operations are grouped by
+// their first tag and can be sent with the try it out form of their parameters and request body.
+var docsPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Title}}</title>
+  <style>
+    body { margin: 0; font: 14px/1.5 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; background: #fafafa; }
+    header { padding: 16px 24px; background: #1b1b1b; color: #fff; }
+    header h1 { margin: 0; font-size: 22px; }
+    header select { margin-left: 8px; }
+    main { max-width: 1100px; margin: 0 auto; padding: 16px 24px; }
+    h2 { margin: 24px 0 8px; font-size: 18px; border-bottom: 1px solid #ddd; }
+    details.operation { margin: 6px 0; background: #fff; border: 1px solid #ddd; border-radius: 4px; }
+    details.operation > summary { padding: 8px; cursor: pointer; font-family: monospace; font-size: 14px; }
+    details.operation.deprecated > summary { text-decoration: line-through; opacity: .6; }
+    .method { display: inline-block; min-width: 64px; margin-right: 8px; padding: 2px 6px; border-radius: 3px; color: #fff; text-align: center; font-weight: bold; }
+    .get { background: #2f80ed; } .post { background: #27ae60; } .put { background: #e2a03f; } .patch { background: #8e44ad; } .delete { background: #eb5757; }
+    .body { padding: 8px 16px 16px; border-top: 1px solid #eee; }
+    table { width: 100%; border-collapse: collapse; }
+    th, td { padding: 4px 8px; border-bottom: 1px solid #eee; text-align: left; vertical-align: top; }
+    input[type=text], textarea { width: 100%; box-sizing: border-box; font-family: monospace; }
+    textarea { min-height: 120px; }
+    pre { margin: 4px 0; padding: 8px; overflow: auto; background: #f4f4f4; border-radius: 3px; }
+    button { margin-top: 8px; padding: 4px 16px; cursor: pointer; }
+    .muted { color: #777; }
+    .error { color: #c0392b; }
+  </style>
+</head>
+<body>
+  <header><h1 id="title">{{.Title}}</h1><span id="info" class="muted"></span></header>
+  <main id="operations"><p class="muted">Loading the api document...</p></main>
+  <script>
+    (function () {
+      var documentURL = {{.URL}};
+      var methods = ["get", "post", "put", "patch", "delete"];
+      var api, server = "";
+
+      function element(name, attributes, children) {
+        var node = document.createElement(name);
+        Object.keys(attributes || {}).forEach(function (key) { node.setAttribute(key, attributes[key]); });
+        (children || []).forEach(function (child) {
+          node.appendChild(typeof child === "string" ? document.createTextNode(child) : child);
+        });
+        return node;
+      }
+
+      function resolve(schema) {
+        while (schema && schema.$ref) {
+          schema = api.components.schemas[schema.$ref.split("/").pop()];
+        }
+        return schema || {};
+      }
+
+      function typeOf(schema) {
+        var type = schema.type;
+        if (Array.isArray(type)) {
+          type = type.filter(function (name) { return name !== "null"; })[0];
+        }
+        return type || (schema.properties ? "object" : "string");
+      }
+
+      // example will return a value of schema, nested schemas are followed until depth is used.
+      function example(schema, depth) {
+        schema = resolve(schema);
+        if (schema.enum) { return schema.enum[0]; }
+        switch (typeOf(schema)) {
+          case "object":
+            var value = {};
+            if (depth > 0) {
+              Object.keys(schema.properties || {}).forEach(function (name) { value[name] = example(schema.properties[name], depth - 1); });
+            }
+            return value;
+          case "array": return depth > 0 ? [example(schema.items, depth - 1)] : [];
+          case "integer": case "number": return schema.minimum || 0;
+          case "boolean": return false;
+          default: return schema.format === "date-time" ? new Date().toISOString() : (schema.format || "string");
+        }
+      }
+
+      function describe(schema) {
+        var resolved = resolve(schema), text = typeOf(resolved);
+        if (resolved.format) { text += " (" + resolved.format + ")"; }
+        if (resolved.pattern) { text += " " + resolved.pattern; }
+        return text;
+      }
+
+      // content will return the json media type of media, or its first one if it has no json.
+      function content(media) {
+        var types = Object.keys(media || {});
+        var type = types.indexOf("application/json") >= 0 ? "application/json" : types[0];
+        return type ? { type: type, schema: media[type].schema } : null;
+      }
+
+      function parametersTable(operation, inputs) {
+        var rows = (operation.parameters || []).map(function (parameter) {
+          var input = element("input", { type: "text", placeholder: parameter.name });
+          inputs.push({ parameter: parameter, input: input });
+          return element("tr", {}, [
+            element("td", {}, [parameter.name + (parameter.required ? " *" : "")]),
+            element("td", {}, [parameter.in]),
+            element("td", {}, [describe(parameter.schema), element("div", { class: "muted" }, [parameter.description || ""])]),
+            element("td", {}, [input])
+          ]);
+        });
+        if (rows.length === 0) { return element("p", { class: "muted" }, ["No parameters."]); }
+        return element("table", {}, [element("tr", {}, [element("th", {}, ["Name"]), element("th", {}, ["In"]), element("th", {}, ["Schema"]), element("th", {}, ["Value"])])].concat(rows));
+      }
+
+      function requestBody(operation) {
+        var body = operation.requestBody && content(operation.requestBody.content);
+        if (!body) { return null; }
+        if (body.type === "multipart/form-data") {
+          var fields = [], schema = resolve(body.schema);
+          Object.keys(schema.properties || {}).forEach(function (name) {
+            var property = resolve(schema.properties[name]);
+            var file = property.contentMediaType || property.format === "binary";
+            fields.push({ name: name, file: file, input: element("input", { type: file ? "file" : "text", placeholder: name }) });
+          });
+          return {
+            node: element("div", {}, fields.map(function (field) { return element("p", {}, [field.name + " ", field.input]); })),
+            value: function () {
+              var form = new FormData();
+              fields.forEach(function (field) {
+                if (field.file && field.input.files.length > 0) { form.append(field.name, field.input.files[0]); }
+                if (!field.file && field.input.value !== "") { form.append(field.name, field.input.value); }
+              });
+              return { body: form };
+            }
+          };
+        }
+        var textarea = element("textarea", {}, [JSON.stringify(example(body.schema, 4), null, 2)]);
+        return {
+          node: element("div", {}, [element("p", { class: "muted" }, [body.type]), textarea]),
+          value: function () { return { body: textarea.value, type: body.type }; }
+        };
+      }
+
+      function responses(operation) {
+        var rows = Object.keys(operation.responses || {}).sort().map(function (status) {
+          var response = operation.responses[status], body = content(response.content);
+          var cells = [element("div", {}, [response.description || ""])];
+          if (body) {
+            cells.push(element("div", { class: "muted" }, [Object.keys(response.content).join(", ")]));
+            cells.push(element("pre", {}, [JSON.stringify(example(body.schema, 4), null, 2)]));
+          }
+          return element("tr", {}, [element("td", {}, [status]), element("td", {}, cells)]);
+        });
+        return element("table", {}, [element("tr", {}, [element("th", {}, ["Status"]), element("th", {}, ["Response"])])].concat(rows));
+      }
+
+      function send(method, path, inputs, body, output) {
+        var query = [], headers = {}, url = path;
+        inputs.forEach(function (item) {
+          var value = item.input.value, name = item.parameter.name;
+          if (value === "") { return; }
+          if (item.parameter.in === "path") { url = url.split("{" + name + "}").join(encodeURIComponent(value)); }
+          if (item.parameter.in === "query") { query.push(encodeURIComponent(name) + "=" + encodeURIComponent(value)); }
+          if (item.parameter.in === "header") { headers[name] = value; }
+        });
+        var request = { method: method.toUpperCase(), headers: headers };
+        if (body) {
+          var value = body.value();
+          request.body = value.body;
+          if (value.type) { headers["Content-Type"] = value.type; }
+        }
+        output.textContent = "Sending...";
+        output.className = "";
+        fetch(server + url + (query.length ? "?" + query.join("&") : ""), request).then(function (response) {
+          return response.text().then(function (text) {
+            try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (e) {}
+            output.textContent = response.status + " " + response.statusText + "\n" + (response.headers.get("content-type") || "") + "\n\n" + text;
+          });
+        }).catch(function (err) {
+          output.textContent = String(err);
+          output.className = "error";
+        });
+      }
+
+      function operationNode(method, path, operation) {
+        var inputs = [], body = requestBody(operation), output = element("pre", {}, []);
+        var button = element("button", { type: "button" }, ["Try it out"]);
+        button.addEventListener("click", function () { send(method, path, inputs, body, output); });
+        var children = [
+          element("p", {}, [operation.description || ""]),
+          element("h4", {}, ["Parameters"]), parametersTable(operation, inputs)
+        ];
+        if (body) { children.push(element("h4", {}, ["Request body"]), body.node); }
+        children.push(button, output, element("h4", {}, ["Responses"]), responses(operation));
+        return element("details", { class: "operation" + (operation.deprecated ? " deprecated" : "") }, [
+          element("summary", {}, [element("span", { class: "method " + method }, [method.toUpperCase()]), path + "  ", element("span", { class: "muted" }, [operation.summary || ""])]),
+          element("div", { class: "body" }, children)
+        ]);
+      }
+
+      function render() {
+        var groups = {}, names = [];
+        Object.keys(api.paths).sort().forEach(function (path) {
+          methods.forEach(function (method) {
+            var operation = api.paths[path][method];
+            if (!operation) { return; }
+            var tag = (operation.tags || ["default"])[0];
+            if (!groups[tag]) { groups[tag] = []; names.push(tag); }
+            groups[tag].push(operationNode(method, path, operation));
+          });
+        });
+        var main = document.getElementById("operations");
+        main.innerHTML = "";
+        if (api.info.description) { main.appendChild(element("p", {}, [api.info.description])); }
+        names.forEach(function (name) {
+          main.appendChild(element("h2", {}, [name]));
+          groups[name].forEach(function (node) { main.appendChild(node); });
+        });
+      }
+
+      fetch(documentURL).then(function (response) { return response.json(); }).then(function (document_) {
+        api = document_;
+        document.title = api.info.title;
+        document.getElementById("title").textContent = api.info.title;
+        var info = document.getElementById("info");
+        info.textContent = " " + api.info.version + " OpenAPI " + api.openapi;
+        var servers = (api.servers || []).map(function (item) { return item.url; });
+        if (servers.length > 0) {
+          server = servers[0] === "/" ? "" : servers[0];
+          var select = element("select", {}, servers.map(function (url) { return element("option", { value: url }, [url]); }));
+          select.addEventListener("change", function () { server = select.value === "/" ? "" : select.value; });
+          info.appendChild(select);
+        }
+        render();
+      }).catch(function (err) {
+        var main = document.getElementById("operations");
+        main.innerHTML = "";
+        main.appendChild(element("p", { class: "error" }, ["Could not load " + documentURL + ": " + err]));
+      });
+    })();
+  </script>
+</body>
+</html>
+`))
+
+// UIHandler will serve the docs page of the document that is served on url. the page is generated by
+// server and has no external scripts or styles.
+func (spec *Spec) UIHandler(url string) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("content-type", "text/html; charset=UTF-8")
+		docsPage.Execute(res, struct{ Title, URL string }{spec.Title, url})
+	}
+}
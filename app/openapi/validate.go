@@ -0,0 +1,283 @@
+package openapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidationError is a value that does not match its schema. Field is the location of value,
+// like body.email, query.page or path.id.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (err ValidationError) Error() string {
+	return err.Field + " " + err.Message
+}
+
+// Validator checks requests and responses against the document of a spec.
+type Validator struct {
+	document *Document
+	patterns sync.Map // pattern -> *regexp.Regexp
+}
+
+// Validator will create a Validator of the current operations of spec.
+func (spec *Spec) Validator() *Validator {
+	return &Validator{document: spec.Document()}
+}
+
+// Operation will return the operation object of method and mux path template.
+func (v *Validator) Operation(method, path string) (*OperationObject, bool) {
+	operation, ok := v.document.Paths[Path(path)][strings.ToLower(method)]
+	return operation, ok
+}
+
+// Parameters will validate the path variables and query of a request. parameters are strings,
+// they are valid if they can be parsed as the type of their schema.
+func (v *Validator) Parameters(operation *OperationObject, vars map[string]string, query url.Values) []ValidationError {
+	var errs []ValidationError
+	for _, param := range operation.Parameters {
+		field := param.In + "." + param.Name
+		var value string
+		var ok bool
+		switch param.In {
+		case "path":
+			value, ok = vars[param.Name]
+		case "query":
+			_, ok = query[param.Name]
+			value = query.Get(param.Name)
+		}
+		if !ok {
+			if param.Required {
+				errs = append(errs, ValidationError{field, "is required"})
+			}
+			continue
+		}
+		errs = append(errs, v.Validate(param.Schema, value, field, true)...)
+	}
+	return errs
+}
+
+// Body will validate the decoded request body of a media type. body is nil if request has no body.
+// coerce is true for formats that decode all values as strings, like xml.
+func (v *Validator) Body(operation *OperationObject, mediaType string, body interface{}, coerce bool) []ValidationError {
+	if operation.RequestBody == nil {
+		return nil
+	}
+	if body == nil && operation.RequestBody.Required {
+		return []ValidationError{{"body", "is required"}}
+	}
+	content, ok := operation.RequestBody.Content[mediaType]
+	if !ok {
+		return nil
+	}
+	return v.Validate(content.Schema, body, "body", coerce)
+}
+
+// Response will validate a response body of status, body is converted to its json form first.
+func (v *Validator) Response(operation *OperationObject, status int, mediaType string, body interface{}) []ValidationError {
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		response, ok = operation.Responses["default"]
+	}
+	if !ok {
+		return []ValidationError{{"status", fmt.Sprintf("%d is not documented", status)}}
+	}
+	content, ok := response.Content[mediaType]
+	if !ok {
+		return []ValidationError{{"content-type", fmt.Sprintf("%s is not documented for status %d", mediaType, status)}}
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return []ValidationError{{"body", err.Error()}}
+	}
+	var value interface{}
+	json.Unmarshal(encoded, &value)
+	return v.Validate(content.Schema, value, "body", false)
+}
+
+// Validate will check value with schema, value is a decoded json value. if coerce is true strings
+// are valid numbers and booleans if they can be parsed. errors are sorted by field.
+func (v *Validator) Validate(schema *Schema, value interface{}, field string, coerce bool) []ValidationError {
+	var errs []ValidationError
+	v.validate(schema, value, field, coerce, &errs, 0)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// maxDepth limits the references that are followed for recursive schemas.
+const maxDepth = 32
+
+func (v *Validator) validate(schema *Schema, value interface{}, field string, coerce bool, errs *[]ValidationError, depth int) {
+	if schema == nil || depth > maxDepth {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{field, fmt.Sprintf(format, args...)})
+	}
+	if schema.Ref != "" {
+		component, ok := v.document.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			fail("has unknown schema %s", schema.Ref)
+			return
+		}
+		v.validate(component, value, field, coerce, errs, depth+1)
+		return
+	}
+	types := schemaTypes(schema.Type)
+	if len(types) > 0 {
+		matched := ""
+		for _, name := range types {
+			if isType(name, value, coerce) {
+				matched = name
+				break
+			}
+		}
+		if matched == "" {
+			fail("must be %s", strings.Join(types, " or "))
+			return
+		}
+		if matched == "null" {
+			return
+		}
+	}
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, item := range schema.Enum {
+			found = found || fmt.Sprint(item) == fmt.Sprint(value)
+		}
+		if !found {
+			fail("must be one of %v", schema.Enum)
+		}
+	}
+	switch value := value.(type) {
+	case string:
+		v.validateString(schema, value, fail)
+		if coerce && (schema.Minimum != nil || schema.Maximum != nil) {
+			if number, err := strconv.ParseFloat(value, 64); err == nil {
+				validateNumber(schema, number, fail)
+			}
+		}
+	case float64:
+		validateNumber(schema, value, fail)
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				*errs = append(*errs, ValidationError{field + "." + name, "is required"})
+			}
+		}
+		for name, item := range value {
+			property, ok := schema.Properties[name]
+			if !ok {
+				property = schema.AdditionalProperties
+			}
+			v.validate(property, item, field+"."+name, coerce, errs, depth)
+		}
+	case []interface{}:
+		for i, item := range value {
+			v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", field, i), coerce, errs, depth)
+		}
+	}
+}
+
+func (v *Validator) validateString(schema *Schema, value string, fail func(string, ...interface{})) {
+	length := utf8.RuneCountInString(value)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		fail("must have at least %d characters", *schema.MinLength)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		fail("must have at most %d characters", *schema.MaxLength)
+	}
+	if schema.Pattern != "" && !v.pattern(schema.Pattern).MatchString(value) {
+		fail("must match %s", schema.Pattern)
+	}
+	switch schema.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			fail("must be a RFC 3339 date time")
+		}
+	case "email":
+		if address, err := mail.ParseAddress(value); err != nil || address.Address != value {
+			fail("must be an email address")
+		}
+	case "byte":
+		if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+			fail("must be base64 encoded")
+		}
+	}
+}
+
+func validateNumber(schema *Schema, value float64, fail func(string, ...interface{})) {
+	if schema.Minimum != nil && value < *schema.Minimum {
+		fail("must be at least %v", *schema.Minimum)
+	}
+	if schema.Maximum != nil && value > *schema.Maximum {
+		fail("must be at most %v", *schema.Maximum)
+	}
+}
+
+func (v *Validator) pattern(pattern string) *regexp.Regexp {
+	if compiled, ok := v.patterns.Load(pattern); ok {
+		return compiled.(*regexp.Regexp)
+	}
+	compiled := regexp.MustCompile(pattern)
+	v.patterns.Store(pattern, compiled)
+	return compiled
+}
+
+func schemaTypes(schemaType interface{}) []string {
+	switch schemaType := schemaType.(type) {
+	case string:
+		return []string{schemaType}
+	case []string:
+		return schemaType
+	}
+	return nil
+}
+
+// isType will return true if value is a json value of type name.
+func isType(name string, value interface{}, coerce bool) bool {
+	text, isString := value.(string)
+	switch name {
+	case "null":
+		return value == nil
+	case "string":
+		return isString
+	case "boolean":
+		if _, ok := value.(bool); ok {
+			return true
+		}
+		_, err := strconv.ParseBool(text)
+		return coerce && isString && err == nil
+	case "integer":
+		if number, ok := value.(float64); ok {
+			return number == float64(int64(number))
+		}
+		_, err := strconv.ParseInt(text, 10, 64)
+		return coerce && isString && err == nil
+	case "number":
+		if _, ok := value.(float64); ok {
+			return true
+		}
+		_, err := strconv.ParseFloat(text, 64)
+		return coerce && isString && err == nil
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok || (coerce && isString && text == "")
+	case "array":
+		_, ok := value.([]interface{})
+		return ok || (coerce && isString && text == "")
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/codec"
+	"github.com/katoozi/golang-mongodb-rest-api/app/gql"
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/openapi"
+)
+
+// operation tags
+var (
+	peopleTag = []string{"People"}
+	jobsTag   = []string{"Jobs"}
+	docsTag   = []string{"Docs"}
+	gqlTag    = []string{"GraphQL"}
+	opsTag    = []string{"Operations"}
+)
+
+// idParameter is the object id of a document.
+var idParameter = openapi.Parameter{Name: "id", In: "path", Description: "object id", Schema: &openapi.Schema{Type: "string", Pattern: "^[0-9a-fA-F]{24}$"}}
+
+// importForm is the multipart form of people import.
+type importForm struct {
+	File    openapi.File `json:"file"`
+	Format  string       `json:"format,omitempty"`  // csv or ndjson, it is detected from file name if it is empty.
+	Mapping string       `json:"mapping,omitempty"` // json object of column -> field, data.<key> or "-".
+	DryRun  bool         `json:"dry_run,omitempty"` // only the job has the report, nothing is saved.
+}
+
+// personUpdate is the body of person updates, its fields are set on person and the others are rejected.
+type personUpdate struct {
+	FirstName string                 `json:"first_name,omitempty"`
+	LastName  string                 `json:"last_name,omitempty"`
+	Username  string                 `json:"username,omitempty" schema:"minLength=1"`
+	Email     string                 `json:"email,omitempty" schema:"format=email"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// setRouters will register routes in router and version subrouters with their openapi operations.
+func (app *App) setRouters() {
+	app.setVersionRouters()
+	app.ownFormats = make(map[string]bool)
+	app.Post("/person/import", app.handleJobsRequest(handler.ImportPeople), openapi.Operation{
+		ID:                "import-people",
+		Summary:           "Import people from a csv or ndjson file",
+		Description:       "the import runs as a background job, the result of job is the report of import.",
+		Tags:              peopleTag,
+		Request:           importForm{},
+		RequestMediaTypes: []string{"multipart/form-data"},
+		Responses:         responses(http.StatusAccepted, model.Job{}, handler.CodeInvalidForm, handler.CodeInternal),
+	})
+	app.Get("/person/import/{id}/errors", app.handleRequest(handler.GetImportErrors), openapi.Operation{
+		ID:         "get-import-errors",
+		Summary:    "Download the rejected rows of an import as csv",
+		Tags:       peopleTag,
+		Parameters: []openapi.Parameter{idParameter},
+		Responses:  withResponse(responses(0, nil, handler.CodeInvalidID, handler.CodeImportNotFound, handler.CodeInternal), http.StatusOK, openapi.Response{Body: "", Raw: true, MediaTypes: []string{"text/csv"}}),
+	})
+	app.Post("/person", app.handlePeople(handler.CreatePerson), openapi.Operation{
+		ID:        "create-person",
+		Summary:   "Create a person",
+		Tags:      peopleTag,
+		Request:   model.Person{},
+		Responses: responses(http.StatusCreated, model.Person{}, handler.CodeInvalidBody, handler.CodeUnsupportedMediaType, handler.CodeDuplicateField, handler.CodeInternal),
+	})
+	updatePerson := openapi.Operation{
+		ID:          "update-person",
+		Summary:     "Update the fields of a person",
+		Description: "fields of body are set on person, the response is the body.",
+		Tags:        peopleTag,
+		Parameters:  []openapi.Parameter{idParameter},
+		Request:     personUpdate{},
+		Responses: responses(http.StatusAccepted, map[string]interface{}{},
+			handler.CodeInvalidBody, handler.CodeInvalidID, handler.CodeUnsupportedMediaType, handler.CodePersonNotFound, handler.CodeDuplicateField, handler.CodeInternal),
+	}
+	app.Patch("/person/{id}", app.handlePeople(handler.UpdatePerson), updatePerson)
+	updatePerson.ID = "replace-person"
+	app.Put("/person/{id}", app.handlePeople(handler.UpdatePerson), updatePerson)
+	app.Get("/person/{id}", app.handlePeople(handler.GetPerson), openapi.Operation{
+		ID:         "get-person",
+		Summary:    "Get a person",
+		Tags:       peopleTag,
+		Parameters: []openapi.Parameter{idParameter},
+		Responses:  responses(http.StatusOK, model.Person{}, handler.CodeInvalidID, handler.CodePersonNotFound, handler.CodeInternal),
+	})
+	app.Delete("/person/{id}", app.handlePeople(handler.DeletePerson), openapi.Operation{
+		ID:         "delete-person",
+		Summary:    "Delete a person",
+		Tags:       peopleTag,
+		Parameters: []openapi.Parameter{idParameter},
+		Responses:  responses(http.StatusOK, map[string]string{}, handler.CodeInvalidID, handler.CodePersonNotFound, handler.CodeInternal),
+	})
+	app.Get("/person/{id}/gdpr-export", app.handlePeopleRequest(handler.ExportPerson), openapi.Operation{
+		ID:          "export-person",
+		Summary:     "Download all data that is held on a person",
+		Description: "the archive is a zip of json files: the person, the rejected import rows that have its username or email and its erasure log entries.",
+		Tags:        peopleTag,
+		Parameters:  []openapi.Parameter{idParameter},
+		Responses: withResponse(responses(0, nil, handler.CodeInvalidID, handler.CodePersonNotFound, handler.CodeInternal),
+			http.StatusOK, openapi.Response{Body: "", Raw: true, MediaTypes: []string{"application/zip"}}),
+	})
+	app.Post("/person/{id}/erase", app.handlePeopleRequest(handler.ErasePerson), openapi.Operation{
+		ID:          "erase-person",
+		Summary:     "Anonymize a person irreversibly",
+		Description: "personal fields are replaced in place and the id is kept, the erasure is recorded in the erasure log.",
+		Tags:        peopleTag,
+		Parameters:  []openapi.Parameter{idParameter},
+		Responses:   responses(http.StatusOK, model.Erasure{}, handler.CodeInvalidID, handler.CodePersonNotFound, handler.CodePersonErased, handler.CodeInternal),
+	})
+	getPersons := openapi.Operation{
+		ID:      "list-people",
+		Summary: "List people, newest first",
+		Tags:    peopleTag,
+		Parameters: []openapi.Parameter{
+			{Name: "page", In: "query", Description: "zero based page number, every page has 10 people", Schema: &openapi.Schema{Type: "integer", Minimum: new(float64)}},
+		},
+		Responses: responses(http.StatusOK, []model.Person{}, handler.CodeInternal),
+	}
+	app.Get("/person", app.handlePeople(handler.GetPersons), getPersons)
+	app.Get("/person", app.handlePeople(handler.GetPersons), getPersons, "page", "{page}")
+	app.Get("/jobs/{id}", app.handleBaseRequest(handler.GetJob), openapi.Operation{
+		ID:         "get-job",
+		Summary:    "Get a background job",
+		Tags:       jobsTag,
+		Parameters: []openapi.Parameter{idParameter},
+		Responses:  responses(http.StatusOK, model.Job{}, handler.CodeInvalidID, handler.CodeJobNotFound, handler.CodeInternal),
+	})
+	app.Delete("/jobs/{id}", app.handleBaseRequest(handler.CancelJob), openapi.Operation{
+		ID:          "cancel-job",
+		Summary:     "Cancel a background job",
+		Description: "finished jobs are returned with 200, running jobs are stopped by their worker soon after 202.",
+		Tags:        jobsTag,
+		Parameters:  []openapi.Parameter{idParameter},
+		Responses: withResponse(responses(http.StatusOK, model.Job{}, handler.CodeInvalidID, handler.CodeJobNotFound, handler.CodeJobFinished, handler.CodeInternal),
+			http.StatusAccepted, openapi.Response{Description: "Cancel is requested", Body: model.Job{}}),
+	})
+	graphQLResponses := withResponse(responses(0, nil, handler.CodeInvalidBody, handler.CodeUnsupportedMediaType),
+		http.StatusOK, openapi.Response{Description: "GraphQL result, errors of operations are in its errors", Body: map[string]interface{}{}, Raw: true, MediaTypes: []string{"application/json"}})
+	app.Post("/graphql", app.handlePeople(gql.Serve), openapi.Operation{
+		ID:          "graphql",
+		Summary:     "Execute a GraphQL operation",
+		Description: fmt.Sprintf("queries deeper than %d or more complex than %d are rejected with query_limit.", gql.MaxDepth, gql.MaxComplexity),
+		Tags:        gqlTag,
+		Request:     gql.Request{},
+		Responses:   graphQLResponses,
+	})
+	app.Get("/graphql", app.handlePeople(gql.Serve), openapi.Operation{
+		ID:          "graphql-query",
+		Summary:     "Execute a GraphQL query",
+		Description: "mutations must be sent with POST.",
+		Tags:        gqlTag,
+		Parameters: []openapi.Parameter{
+			{Name: "query", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+			{Name: "variables", In: "query", Description: "json object of variables", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "operationName", In: "query", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Responses: graphQLResponses,
+	})
+	app.Get("/problems/{code}", handler.GetProblem, openapi.Operation{
+		ID:      "get-problem",
+		Summary: "Describe an error code of problem details",
+		Tags:    docsTag,
+		Responses: withResponse(responses(http.StatusOK, handler.ErrorCode{}),
+			http.StatusNotFound, openapi.Response{Description: "Problem type not found", Error: true}),
+	})
+	app.Get("/openapi.json", app.openAPIHandler, openapi.Operation{
+		ID:        "get-openapi",
+		Summary:   "OpenAPI document of api version",
+		Tags:      docsTag,
+		Responses: map[int]openapi.Response{http.StatusOK: {Body: map[string]interface{}{}, Raw: true, MediaTypes: []string{"application/json"}}},
+	})
+	app.Get("/docs", app.Spec.UIHandler("openapi.json"), openapi.Operation{
+		ID:        "get-docs",
+		Summary:   "Api explorer of the OpenAPI document",
+		Tags:      docsTag,
+		Responses: map[int]openapi.Response{http.StatusOK: {Body: "", Raw: true, MediaTypes: []string{"text/html"}}},
+	})
+	app.Get("/metrics", app.metricsHandler, openapi.Operation{
+		ID:        "get-metrics",
+		Summary:   "Metrics of server in prometheus text format",
+		Tags:      opsTag,
+		Responses: map[int]openapi.Response{http.StatusOK: {Body: "", Raw: true, MediaTypes: []string{"text/plain"}}},
+	})
+}
+
+// newSpec will create the openapi spec of api, bodies can be written in all registered codecs.
+func newSpec() *openapi.Spec {
+	spec := openapi.New("Golang RestApi With MongoDB", "1.0.0")
+	spec.Description = "simple restapi with golang in backend and mongodb as db."
+	spec.MediaTypes = bodyMediaTypes()
+	spec.Problem = handler.Problem{}
+	spec.Envelope = envelopes[handler.Versions[0].Name]
+	return spec
+}
+
+// bodyMediaTypes will return a media type of every registered codec.
+func bodyMediaTypes() []string {
+	seen := make(map[string]bool)
+	var mediaTypes []string
+	for _, mediaType := range codec.MediaTypes() {
+		bodyCodec, _ := codec.Lookup(mediaType)
+		contentType, _, _ := mime.ParseMediaType(bodyCodec.ContentType())
+		if !seen[contentType] {
+			seen[contentType] = true
+			mediaTypes = append(mediaTypes, contentType)
+		}
+	}
+	sort.Strings(mediaTypes)
+	return mediaTypes
+}
+
+// responses will return the success response of status with body and the error responses of codes.
+// codes that have the same status are one response. every response can be 406 and 400 of request validation.
+func responses(status int, body interface{}, codes ...handler.ErrorCode) map[int]openapi.Response {
+	result := make(map[int]openapi.Response)
+	if status != 0 {
+		result[status] = openapi.Response{Body: body}
+	}
+	titles := make(map[int][]string)
+	for _, code := range append(codes, handler.CodeInvalidRequest, handler.CodeNotAcceptable) {
+		titles[code.Status] = append(titles[code.Status], code.Title+" ("+code.Code+")")
+	}
+	for status, titles := range titles {
+		result[status] = openapi.Response{Description: strings.Join(titles, ", "), Error: true}
+	}
+	return result
+}
+
+func withResponse(responses map[int]openapi.Response, status int, response openapi.Response) map[int]openapi.Response {
+	responses[status] = response
+	return responses
+}
@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/rpc/personpb"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// toProto will convert person to its message. data is converted through its json form, so ids and
+// dates of data are the same as rest api.
+func toProto(person *model.Person) (*personpb.Person, error) {
+	message := &personpb.Person{
+		FirstName: person.FirstName,
+		LastName:  person.LastName,
+		Username:  person.Username,
+		Email:     person.Email,
+	}
+	if !person.ID.IsZero() {
+		message.Id = person.ID.Hex()
+	}
+	if len(person.Data) > 0 {
+		content, err := json.Marshal(person.Data)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "data of person %s could not be encoded", message.Id)
+		}
+		message.Data = new(structpb.Struct)
+		if err := protojson.Unmarshal(content, message.Data); err != nil {
+			return nil, status.Errorf(codes.Internal, "data of person %s could not be encoded", message.Id)
+		}
+	}
+	return message, nil
+}
+
+// fromProto will convert a message to person, id is zero if it is not a valid object id.
+func fromProto(message *personpb.Person) *model.Person {
+	person := model.NewPerson(message.GetFirstName(), message.GetLastName(), message.GetUsername(), message.GetEmail(), nil)
+	person.ID, _ = primitive.ObjectIDFromHex(message.GetId())
+	if message.GetData() != nil {
+		person.Data = message.GetData().AsMap()
+	}
+	return person
+}
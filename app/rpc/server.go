@@ -0,0 +1,267 @@
+// Package rpc serves the grpc api of people that is defined in personpb/person.proto.
+//
+// the service uses the store of http handlers that validates writes, errors are grpc status codes
+// with the catalog code of handler package in their ErrorInfo detail.
+package rpc
+
+//go:generate buf generate
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/rpc/personpb"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// errorDomain is the domain of ErrorInfo details.
+const errorDomain = "person.v1"
+
+// serviceName is the health checking name of PersonService.
+const serviceName = "person.v1.PersonService"
+
+// Server implements personpb.PersonServiceServer.
+type Server struct {
+	personpb.UnimplementedPersonServiceServer
+	people func() store.PeopleStore
+}
+
+// NewServer is the Server struct factory function. people is called for every request because
+// the database of app is replaced when credentials are rotated.
+func NewServer(people func() store.PeopleStore) *Server {
+	return &Server{people: people}
+}
+
+// NewGRPCServer will create a grpc server that serves server and grpc health checking, and reflection
+// if withReflection is true. health status of all services is serving, use Shutdown of health server
+// before stopping.
+func NewGRPCServer(server *Server, withReflection bool, opts ...grpc.ServerOption) (*grpc.Server, *health.Server) {
+	grpcServer := grpc.NewServer(opts...)
+	personpb.RegisterPersonServiceServer(grpcServer, server)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(serviceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	if withReflection {
+		reflection.Register(grpcServer)
+	}
+	return grpcServer, healthServer
+}
+
+// Create will insert a person.
+func (s *Server) Create(ctx context.Context, req *personpb.CreateRequest) (*personpb.Person, error) {
+	person := fromProto(req.GetPerson())
+	person.ID = primitive.NilObjectID
+	if err := s.people().Create(ctx, person); err != nil {
+		return nil, storeError(err)
+	}
+	return toProto(person)
+}
+
+// Get will return a person.
+func (s *Server) Get(ctx context.Context, req *personpb.GetRequest) (*personpb.Person, error) {
+	id, err := parseID("id", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	person, err := s.people().Get(ctx, id)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return toProto(person)
+}
+
+// List will return a page of people.
+func (s *Server) List(ctx context.Context, req *personpb.ListRequest) (*personpb.ListResponse, error) {
+	if req.GetPage() < 0 {
+		return nil, invalidArgument(fieldViolation("page", "must be at least 0"))
+	}
+	personList, err := s.people().List(ctx, req.GetPage())
+	if err != nil {
+		return nil, storeError(err)
+	}
+	res := &personpb.ListResponse{People: make([]*personpb.Person, len(personList))}
+	for i := range personList {
+		if res.People[i], err = toProto(&personList[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Update will set the fields of update mask and return the updated person.
+func (s *Server) Update(ctx context.Context, req *personpb.UpdateRequest) (*personpb.Person, error) {
+	id, err := parseID("person.id", req.GetPerson().GetId())
+	if err != nil {
+		return nil, err
+	}
+	fields, violations := updateFields(fromProto(req.GetPerson()), req.GetUpdateMask().GetPaths())
+	if len(violations) > 0 {
+		return nil, invalidArgument(violations...)
+	}
+	if err := s.people().Update(ctx, id, fields); err != nil {
+		return nil, storeError(err)
+	}
+	updated, err := s.people().Get(ctx, id)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return toProto(updated)
+}
+
+// Delete will remove a person.
+func (s *Server) Delete(ctx context.Context, req *personpb.DeleteRequest) (*emptypb.Empty, error) {
+	id, err := parseID("id", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.people().Delete(ctx, id); err != nil {
+		return nil, storeError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// eventTypes are the WatchResponse types of store events.
+var eventTypes = map[string]personpb.WatchResponse_Type{
+	store.EventCreated: personpb.WatchResponse_TYPE_CREATED,
+	store.EventUpdated: personpb.WatchResponse_TYPE_UPDATED,
+	store.EventDeleted: personpb.WatchResponse_TYPE_DELETED,
+}
+
+// Watch will stream the changes of people until client cancels.
+func (s *Server) Watch(req *personpb.WatchRequest, stream personpb.PersonService_WatchServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	events := make(chan store.Event)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.people().Watch(ctx, events)
+	}()
+	for {
+		select {
+		case event := <-events:
+			res := &personpb.WatchResponse{Type: eventTypes[event.Type], Id: event.ID.Hex()}
+			if event.Person != nil {
+				person, err := toProto(event.Person)
+				if err != nil {
+					return err
+				}
+				res.Person = person
+			}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		case err := <-done:
+			if store.ChangeStreamsNotSupported(err) {
+				return status.Error(codes.FailedPrecondition, "watch needs a mongo db replica set")
+			}
+			return storeError(err)
+		}
+	}
+}
+
+// updateFields will return the bson fields of paths, non empty fields of person are used if paths is empty.
+// their values are validated by the store.
+func updateFields(person *model.Person, paths []string) (map[string]interface{}, []*errdetails.BadRequest_FieldViolation) {
+	values := map[string]interface{}{
+		"first_name": person.FirstName,
+		"last_name":  person.LastName,
+		"username":   person.Username,
+		"email":      person.Email,
+		"data":       person.Data,
+	}
+	if len(paths) == 0 {
+		for name, value := range values {
+			if value != "" && value != nil && !(name == "data" && len(person.Data) == 0) {
+				paths = append(paths, name)
+			}
+		}
+		sort.Strings(paths)
+	}
+	fields := make(map[string]interface{}, len(paths))
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, path := range paths {
+		value, ok := values[path]
+		if !ok {
+			violations = append(violations, fieldViolation("update_mask", path+" is not a field of person"))
+			continue
+		}
+		fields[path] = value
+	}
+	return fields, violations
+}
+
+// parseID will parse the hex object id of field.
+func parseID(field, hex string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return id, invalidArgument(fieldViolation(field, "must be a hex object id"))
+	}
+	return id, nil
+}
+
+func fieldViolation(field, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{Field: field, Description: description}
+}
+
+// statusError will create the status of an error code of catalog with details.
+func statusError(grpcCode codes.Code, code handler.ErrorCode, message string, details ...*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(grpcCode, message)
+	info := &errdetails.ErrorInfo{Reason: code.Code, Domain: errorDomain}
+	var err error
+	if len(details) > 0 {
+		st, err = st.WithDetails(info, &errdetails.BadRequest{FieldViolations: details})
+	} else {
+		st, err = st.WithDetails(info)
+	}
+	if err != nil {
+		return status.Error(grpcCode, message)
+	}
+	return st.Err()
+}
+
+func invalidArgument(violations ...*errdetails.BadRequest_FieldViolation) error {
+	message := "request is not valid: " + violations[0].Field + " " + violations[0].Description
+	return statusError(codes.InvalidArgument, handler.CodeInvalidRequest, message, violations...)
+}
+
+// storeError will convert an error of store to its status.
+func storeError(err error) error {
+	var duplicate *store.DuplicateError
+	var invalid *store.ValidationError
+	switch {
+	case err == store.ErrNotFound:
+		return statusError(codes.NotFound, handler.CodePersonNotFound, err.Error())
+	case errors.As(err, &duplicate):
+		return statusError(codes.AlreadyExists, handler.CodeDuplicateField, duplicate.Error(), fieldViolation("person."+duplicate.Field, "already exists"))
+	case errors.As(err, &invalid):
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(invalid.Fields))
+		for i, field := range invalid.Fields {
+			// the whole update is the update mask of request.
+			name := "update_mask"
+			if field.Field != "" {
+				name = "person." + field.Field
+			}
+			violations[i] = fieldViolation(name, field.Message)
+		}
+		return invalidArgument(violations...)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	log.Printf("Error of people store: %v\n", err)
+	return statusError(codes.Internal, handler.CodeInternal, "there is an error on server")
+}
@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/rpc/personpb"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+// dial will serve a Server without database on an in-memory listener, its store only validates writes
+// and requests that pass validation panic.
+func dial(t *testing.T, withReflection bool) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	people := store.NewValidated(nil)
+	server, _ := NewGRPCServer(NewServer(func() store.PeopleStore { return people }), withReflection)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestHealthAndReflection(t *testing.T) {
+	conn := dial(t, true)
+	ctx := context.Background()
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: serviceName})
+	if err != nil || res.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("%s health of %s is wrong: %v %v", failed, serviceName, res, err)
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}})
+	reflection, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, service := range reflection.GetListServicesResponse().GetService() {
+		found = found || service.Name == serviceName
+	}
+	if !found {
+		t.Errorf("%s reflection does not list %s: %v", failed, serviceName, reflection)
+	}
+	t.Logf("%s Testing grpc health and reflection is successful", succeed)
+}
+
+func TestReflectionIsDisabled(t *testing.T) {
+	stream, err := reflectionpb.NewServerReflectionClient(dial(t, false)).ServerReflectionInfo(context.Background())
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("%s reflection must not be served when it is disabled: %v", failed, err)
+	}
+	t.Logf("%s Testing disabled grpc reflection is successful", succeed)
+}
+
+func TestInvalidRequests(t *testing.T) {
+	client := personpb.NewPersonServiceClient(dial(t, false))
+	ctx := context.Background()
+	id := primitive.NewObjectID().Hex()
+	tests := []struct {
+		name   string
+		call   func() error
+		fields []string
+	}{
+		{"create", func() error {
+			_, err := client.Create(ctx, &personpb.CreateRequest{Person: &personpb.Person{Email: "john"}})
+			return err
+		}, []string{"person.username", "person.email"}},
+		{"get", func() error {
+			_, err := client.Get(ctx, &personpb.GetRequest{Id: "123"})
+			return err
+		}, []string{"id"}},
+		{"list", func() error {
+			_, err := client.List(ctx, &personpb.ListRequest{Page: -1})
+			return err
+		}, []string{"page"}},
+		{"update", func() error {
+			_, err := client.Update(ctx, &personpb.UpdateRequest{Person: &personpb.Person{Id: id}})
+			return err
+		}, []string{"update_mask"}},
+		{"update mask", func() error {
+			_, err := client.Update(ctx, &personpb.UpdateRequest{
+				Person:     &personpb.Person{Id: id, Email: "john"},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email", "age"}},
+			})
+			return err
+		}, []string{"update_mask"}},
+		{"update email", func() error {
+			_, err := client.Update(ctx, &personpb.UpdateRequest{
+				Person:     &personpb.Person{Id: id, Email: "john", Username: " "},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email", "username"}},
+			})
+			return err
+		}, []string{"person.email", "person.username"}},
+		{"delete", func() error {
+			_, err := client.Delete(ctx, &personpb.DeleteRequest{})
+			return err
+		}, []string{"id"}},
+	}
+	for _, test := range tests {
+		st := status.Convert(test.call())
+		var fields []string
+		reason := ""
+		for _, detail := range st.Details() {
+			switch detail := detail.(type) {
+			case *errdetails.BadRequest:
+				for _, violation := range detail.FieldViolations {
+					fields = append(fields, violation.Field)
+				}
+			case *errdetails.ErrorInfo:
+				reason = detail.Reason
+			}
+		}
+		if st.Code() != codes.InvalidArgument || reason != "invalid_request" || !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("%s %s error is wrong: %v %s %v", failed, test.name, st.Code(), reason, fields)
+		}
+	}
+	t.Logf("%s Testing grpc request validation is successful", succeed)
+}
+
+func TestConvert(t *testing.T) {
+	id := primitive.NewObjectID()
+	person := &model.Person{ID: id, Username: "john", Email: "john@example.com", Data: map[string]interface{}{
+		"age": 30, "manager": id, "tags": []string{"a"},
+	}}
+	message, err := toProto(person)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted := fromProto(message)
+	want := map[string]interface{}{"age": 30.0, "manager": id.Hex(), "tags": []interface{}{"a"}}
+	if message.Id != id.Hex() || converted.ID != id || converted.Username != "john" || !reflect.DeepEqual(converted.Data, want) {
+		t.Errorf("%s converted person is wrong: %v %+v", failed, message, converted)
+	}
+	t.Logf("%s Testing person conversion is successful", succeed)
+}
@@ -0,0 +1,73 @@
+package seed
+
+// name is a localized name and its latin form for usernames and emails.
+type name struct {
+	local, latin string
+}
+
+// locale holds the fake data of a language and country.
+type locale struct {
+	firstNames []name
+	lastNames  []name
+	cities     []string
+	streets    []string
+	phone      string // format of phone numbers, # is a random digit
+	zip        string // format of postal codes, # is a random digit
+}
+
+// latin will return names that are written the same in latin.
+func latin(values ...string) []name {
+	names := make([]name, len(values))
+	for i, value := range values {
+		names[i] = name{value, value}
+	}
+	return names
+}
+
+// locales are the supported locales, keys are the values of Generator locales.
+var locales = map[string]locale{
+	"en_US": {
+		firstNames: latin("James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David",
+			"Elizabeth", "William", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Daniel", "Karen"),
+		lastNames: latin("Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez",
+			"Martinez", "Hernandez", "Lopez", "Wilson", "Anderson", "Taylor", "Moore", "Jackson", "Martin", "Lee", "Thompson"),
+		cities:  []string{"New York", "Los Angeles", "Chicago", "Houston", "Phoenix", "Philadelphia", "San Antonio", "San Diego", "Dallas", "Austin"},
+		streets: []string{"Main St", "Oak Ave", "Maple Dr", "Cedar Ln", "Pine St", "Elm St", "Washington Ave", "Lake Rd"},
+		phone:   "+1 555-01##", // 555-01xx numbers are reserved for fiction
+		zip:     "#####",
+	},
+	"de_DE": {
+		firstNames: latin("Lukas", "Anna", "Leon", "Lena", "Finn", "Marie", "Jonas", "Sophie", "Paul", "Emma",
+			"Felix", "Hannah", "Maximilian", "Mia", "Elias", "Lea", "Jürgen", "Jörg", "Günther", "Käthe"),
+		lastNames: latin("Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schulz",
+			"Hoffmann", "Schäfer", "Koch", "Bauer", "Richter", "Klein", "Wolf", "Schröder", "Neumann", "Schwarz", "Braun"),
+		cities:  []string{"Berlin", "Hamburg", "München", "Köln", "Frankfurt am Main", "Stuttgart", "Düsseldorf", "Leipzig", "Dresden", "Bremen"},
+		streets: []string{"Hauptstraße", "Schulstraße", "Gartenstraße", "Bahnhofstraße", "Dorfstraße", "Bergstraße", "Lindenstraße"},
+		phone:   "+49 30 #######",
+		zip:     "#####",
+	},
+	"es_ES": {
+		firstNames: latin("Antonio", "María", "Manuel", "Carmen", "José", "Ana", "Francisco", "Laura", "David", "Lucía",
+			"Juan", "Isabel", "Javier", "Cristina", "Sergio", "Marta", "Álvaro", "Sofía", "Pablo", "Raúl"),
+		lastNames: latin("García", "Rodríguez", "González", "Fernández", "López", "Martínez", "Sánchez", "Pérez", "Gómez",
+			"Martín", "Jiménez", "Ruiz", "Hernández", "Díaz", "Moreno", "Muñoz", "Álvarez", "Romero", "Alonso", "Navarro"),
+		cities:  []string{"Madrid", "Barcelona", "Valencia", "Sevilla", "Zaragoza", "Málaga", "Murcia", "Palma", "Bilbao", "Alicante"},
+		streets: []string{"Calle Mayor", "Calle Real", "Avenida de la Constitución", "Calle del Sol", "Plaza de España", "Calle Nueva"},
+		phone:   "+34 6## ### ###",
+		zip:     "#####",
+	},
+	"fa_IR": {
+		firstNames: []name{{"علی", "Ali"}, {"زهرا", "Zahra"}, {"محمد", "Mohammad"}, {"فاطمه", "Fatemeh"},
+			{"رضا", "Reza"}, {"مریم", "Maryam"}, {"حسین", "Hossein"}, {"سارا", "Sara"}, {"امیر", "Amir"},
+			{"نرگس", "Narges"}, {"مهدی", "Mahdi"}, {"نیلوفر", "Niloufar"}, {"سعید", "Saeed"}, {"الهام", "Elham"},
+			{"پویا", "Pouya"}, {"شیرین", "Shirin"}, {"کیوان", "Keyvan"}, {"لیلا", "Leila"}},
+		lastNames: []name{{"محمدی", "Mohammadi"}, {"حسینی", "Hosseini"}, {"احمدی", "Ahmadi"}, {"رضایی", "Rezaei"},
+			{"کریمی", "Karimi"}, {"موسوی", "Mousavi"}, {"جعفری", "Jafari"}, {"صادقی", "Sadeghi"}, {"رحیمی", "Rahimi"},
+			{"کاظمی", "Kazemi"}, {"قاسمی", "Ghasemi"}, {"اکبری", "Akbari"}, {"نوری", "Nouri"}, {"تهرانی", "Tehrani"},
+			{"کاتوزی", "Katoozi"}, {"شریفی", "Sharifi"}},
+		cities:  []string{"تهران", "مشهد", "اصفهان", "کرج", "شیراز", "تبریز", "قم", "اهواز", "کرمانشاه", "رشت"},
+		streets: []string{"خیابان ولیعصر", "خیابان انقلاب", "خیابان آزادی", "بلوار کشاورز", "خیابان شریعتی", "خیابان فردوسی"},
+		phone:   "+98 912 ### ####",
+		zip:     "##########",
+	},
+}
@@ -0,0 +1,184 @@
+// Package seed generates realistic fake people for development databases.
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+)
+
+// domains of emails, they are reserved for examples so seeded emails never reach a real mailbox.
+var domains = []string{"example.com", "example.org", "example.net"}
+
+var (
+	companies = []string{"Acme Corp", "Globex", "Initech", "Umbrella", "Hooli", "Stark Industries", "Wayne Enterprises", "Vandelay Industries"}
+	tags      = []string{"customer", "vip", "newsletter", "beta", "partner", "staff", "inactive", "trial"}
+	// accents are replaced in the latin names of usernames and emails.
+	accents = strings.NewReplacer("ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss", "á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ñ", "n",
+		"Ä", "Ae", "Ö", "Oe", "Ü", "Ue", "Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ñ", "N")
+)
+
+// Locales will return the names of supported locales.
+func Locales() []string {
+	names := make([]string, 0, len(locales))
+	for name := range locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generator creates fake people. people of the same seed and locales are the same, so a seeded
+// database can be created again. usernames and emails of a generator are unique case insensitive.
+type Generator struct {
+	rand    *rand.Rand
+	locales []string
+	now     time.Time
+	seen    map[string]bool // lower case usernames and emails
+}
+
+// NewGenerator is the Generator struct factory function, people are from all locales if locales is empty.
+func NewGenerator(seed int64, locales ...string) (*Generator, error) {
+	if len(locales) == 0 {
+		locales = Locales()
+	}
+	for _, name := range locales {
+		if _, ok := localeOf(name); !ok {
+			return nil, fmt.Errorf("locale %q is not supported, use %s", name, strings.Join(Locales(), ", "))
+		}
+	}
+	return &Generator{
+		rand:    rand.New(rand.NewSource(seed)),
+		locales: locales,
+		// dates are relative to a fixed day, so they do not change with the day of seeding.
+		now:  time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+		seen: make(map[string]bool),
+	}, nil
+}
+
+func localeOf(name string) (locale, bool) {
+	l, ok := locales[name]
+	return l, ok
+}
+
+// Person will return a new fake person, its data has a random part of the optional keys.
+func (g *Generator) Person() *model.Person {
+	localeName := g.locales[g.rand.Intn(len(g.locales))]
+	l := locales[localeName]
+	first, last := l.firstNames[g.rand.Intn(len(l.firstNames))], l.lastNames[g.rand.Intn(len(l.lastNames))]
+	username := g.unique(g.username(first.latin, last.latin), "_")
+	email := g.unique(g.emailName(first.latin, last.latin), ".") + "@" + domains[g.rand.Intn(len(domains))]
+	return model.NewPerson(first.local, last.local, username, email, g.data(l, localeName))
+}
+
+// username will return a username in one of the common styles.
+func (g *Generator) username(first, last string) string {
+	first, last = asciiName(first), asciiName(last)
+	switch g.rand.Intn(4) {
+	case 0:
+		return first + "." + last
+	case 1:
+		return first + last + strconv.Itoa(g.rand.Intn(99)+1)
+	case 2:
+		return first[:1] + "_" + last
+	default:
+		return last + first[:1]
+	}
+}
+
+func (g *Generator) emailName(first, last string) string {
+	first, last = asciiName(first), asciiName(last)
+	if g.rand.Intn(2) == 0 {
+		return first + "." + last
+	}
+	return first[:1] + last
+}
+
+// unique will add a number to value until it is not seen, values are compared lower case like the unique indexes.
+func (g *Generator) unique(value, separator string) string {
+	candidate := value
+	for i := 2; g.seen[strings.ToLower(candidate)]; i++ {
+		candidate = value + separator + strconv.Itoa(i)
+	}
+	g.seen[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// data will return the optional fields of a person, every key has its own chance to be set.
+func (g *Generator) data(l locale, localeName string) map[string]interface{} {
+	data := map[string]interface{}{"locale": localeName}
+	age := 18 + g.rand.Intn(63)
+	if g.chance(0.8) {
+		data["age"] = age
+	}
+	if g.chance(0.5) {
+		birthday := g.now.AddDate(-age, 0, -g.rand.Intn(365))
+		data["birthday"] = birthday.Format("2006-01-02")
+	}
+	if g.chance(0.7) {
+		data["phone"] = g.digits(l.phone)
+	}
+	if g.chance(0.6) {
+		data["address"] = map[string]interface{}{
+			"street": fmt.Sprintf("%s %d", l.streets[g.rand.Intn(len(l.streets))], 1+g.rand.Intn(200)),
+			"city":   l.cities[g.rand.Intn(len(l.cities))],
+			"zip":    g.digits(l.zip),
+		}
+	} else if g.chance(0.5) {
+		data["city"] = l.cities[g.rand.Intn(len(l.cities))]
+	}
+	if g.chance(0.4) {
+		data["company"] = companies[g.rand.Intn(len(companies))]
+	}
+	if g.chance(0.5) {
+		var selected []string
+		for _, i := range g.rand.Perm(len(tags))[:1+g.rand.Intn(3)] {
+			selected = append(selected, tags[i])
+		}
+		data["tags"] = selected
+	}
+	if g.chance(0.6) {
+		data["active"] = g.chance(0.8)
+	}
+	if g.chance(0.3) {
+		data["score"] = math.Round(g.rand.Float64()*10000) / 100
+	}
+	if g.chance(0.3) {
+		data["signed_up_at"] = g.now.Add(-time.Duration(g.rand.Int63n(int64(3 * 365 * 24 * time.Hour)))).Truncate(time.Second)
+	}
+	return data
+}
+
+func (g *Generator) chance(probability float64) bool {
+	return g.rand.Float64() < probability
+}
+
+// digits will replace the # of format with random digits.
+func (g *Generator) digits(format string) string {
+	var builder strings.Builder
+	for _, r := range format {
+		if r == '#' {
+			r = rune('0' + g.rand.Intn(10))
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// asciiName will return the lower case ascii form of a latin name.
+func asciiName(value string) string {
+	value = strings.ToLower(accents.Replace(value))
+	var builder strings.Builder
+	for _, r := range value {
+		if r >= 'a' && r <= 'z' {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,68 @@
+package seed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	first, err := NewGenerator(42)
+	if err != nil {
+		t.Fatalf("%s NewGenerator returned error: %v", failed, err)
+	}
+	second, _ := NewGenerator(42)
+	third, _ := NewGenerator(43)
+	different := false
+	for i := 0; i < 50; i++ {
+		a, b, c := first.Person(), second.Person(), third.Person()
+		if !reflect.DeepEqual(a, b) {
+			t.Fatalf("%s people of the same seed must be the same: %+v and %+v", failed, a, b)
+		}
+		different = different || !reflect.DeepEqual(a, c)
+	}
+	if !different {
+		t.Fatalf("%s people of different seeds must be different", failed)
+	}
+	t.Logf("%s Testing deterministic generator is successful", succeed)
+}
+
+func TestGeneratedPeopleAreUniqueAndValid(t *testing.T) {
+	generator, _ := NewGenerator(7)
+	usernames, emails := map[string]bool{}, map[string]bool{}
+	for i := 0; i < 2000; i++ {
+		person := generator.Person()
+		if err := person.Validate(); err != nil {
+			t.Fatalf("%s generated person must be valid: %+v: %v", failed, person, err)
+		}
+		username, email := strings.ToLower(person.Username), strings.ToLower(person.Email)
+		if usernames[username] || emails[email] {
+			t.Fatalf("%s username %q and email %q must be unique", failed, person.Username, person.Email)
+		}
+		usernames[username], emails[email] = true, true
+		for _, r := range person.Username + person.Email {
+			if r > 127 {
+				t.Fatalf("%s username %q and email %q must be ascii", failed, person.Username, person.Email)
+			}
+		}
+	}
+	t.Logf("%s Testing unique and valid people is successful", succeed)
+}
+
+func TestLocales(t *testing.T) {
+	generator, err := NewGenerator(1, "fa_IR")
+	if err != nil {
+		t.Fatalf("%s NewGenerator returned error: %v", failed, err)
+	}
+	person := generator.Person()
+	if person.Data["locale"] != "fa_IR" || !strings.ContainsAny(person.FirstName, "ابپتثجچحخدذرزژسشصضطظعغفقکگلمنوهی") {
+		t.Fatalf("%s person must be from fa_IR locale: %+v", failed, person)
+	}
+	if _, err := NewGenerator(1, "xx_XX"); err == nil {
+		t.Fatalf("%s unsupported locale must return error", failed)
+	}
+	t.Logf("%s Testing locales is successful", succeed)
+}
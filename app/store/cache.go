@@ -0,0 +1,345 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// changeStreamsNotSupported is the mongo db error code of change streams on standalone servers.
+const changeStreamsNotSupported = 40573
+
+// listenRetryInterval is how long Listen waits before it watches again after an error.
+const listenRetryInterval = 5 * time.Second
+
+// ChangeStreamsNotSupported will return true if err is the error of Watch on a standalone mongo db server.
+func ChangeStreamsNotSupported(err error) bool {
+	var commandErr mongo.CommandError
+	return errors.As(err, &commandErr) && commandErr.Code == changeStreamsNotSupported
+}
+
+// CacheStats are the counters of a Cache since it is created.
+type CacheStats struct {
+	Hits          uint64 // reads that are answered from cache
+	Misses        uint64 // reads that are not in cache or are expired
+	Evictions     uint64 // least recently used people that are removed for new ones
+	Invalidations uint64 // people that are removed because they are written
+	Entries       int    // people in cache now
+}
+
+// cacheEntry is a cached person, the elements of Cache order hold them.
+type cacheEntry struct {
+	tenant  string // id of the tenant that read person, people of tenants are cached apart
+	person  *model.Person
+	expires time.Time // zero if it does not expire
+}
+
+// Cache is a bounded LRU cache of person reads in front of a PeopleStore, people expire after ttl.
+// writes through the cache invalidate their people, Listen invalidates the writes of other replicas.
+// only Get and GetMany are cached, the other reads go to the store. people are cached for the tenant of
+// the context that reads them, so tenants never read the people of each other from cache.
+type Cache struct {
+	people func() PeopleStore
+	size   int
+	ttl    time.Duration
+	now    func() time.Time
+
+	lock       sync.Mutex
+	entries    map[primitive.ObjectID]map[string]*list.Element // id -> tenant -> element
+	order      *list.List                                      // front is the most recently used person
+	generation uint64                                          // it is increased by every invalidation, so reads that overlap a write are not cached
+	stats      CacheStats
+}
+
+var _ PeopleStore = (*Cache)(nil)
+
+// NewCache is the Cache struct factory function. people is called for every store call because the
+// database of app is replaced when credentials are rotated. ttl 0 keeps people until they are evicted.
+func NewCache(people func() PeopleStore, size int, ttl time.Duration) *Cache {
+	return &Cache{
+		people:  people,
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[primitive.ObjectID]map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Stats will return the counters of cache.
+func (cache *Cache) Stats() CacheStats {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	stats := cache.stats
+	stats.Entries = cache.order.Len()
+	return stats
+}
+
+// lookup will return a copy of the cached person of tenant and id and count the hit or miss, lock must be held.
+func (cache *Cache) lookup(tenant string, id primitive.ObjectID) (*model.Person, bool) {
+	element, ok := cache.entries[id][tenant]
+	if ok {
+		entry := element.Value.(*cacheEntry)
+		if entry.expires.IsZero() || cache.now().Before(entry.expires) {
+			cache.order.MoveToFront(element)
+			cache.stats.Hits++
+			return clonePerson(entry.person), true
+		}
+		cache.remove(element)
+	}
+	cache.stats.Misses++
+	return nil, false
+}
+
+// add will cache a copy of person for tenant if no write happened since generation, lock must be held.
+func (cache *Cache) add(tenant string, person *model.Person, generation uint64) {
+	if generation != cache.generation {
+		return
+	}
+	entry := &cacheEntry{tenant: tenant, person: clonePerson(person)}
+	if cache.ttl > 0 {
+		entry.expires = cache.now().Add(cache.ttl)
+	}
+	if element, ok := cache.entries[person.ID][tenant]; ok {
+		element.Value = entry
+		cache.order.MoveToFront(element)
+		return
+	}
+	if cache.entries[person.ID] == nil {
+		cache.entries[person.ID] = make(map[string]*list.Element, 1)
+	}
+	cache.entries[person.ID][tenant] = cache.order.PushFront(entry)
+	for cache.order.Len() > cache.size {
+		cache.remove(cache.order.Back())
+		cache.stats.Evictions++
+	}
+}
+
+func (cache *Cache) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	delete(cache.entries[entry.person.ID], entry.tenant)
+	if len(cache.entries[entry.person.ID]) == 0 {
+		delete(cache.entries, entry.person.ID)
+	}
+	cache.order.Remove(element)
+}
+
+// Invalidate will remove the people of ids from cache, for all tenants.
+func (cache *Cache) Invalidate(ids ...primitive.ObjectID) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.generation++
+	for _, id := range ids {
+		for _, element := range cache.entries[id] {
+			cache.remove(element)
+			cache.stats.Invalidations++
+		}
+	}
+}
+
+// Purge will remove all people from cache.
+func (cache *Cache) Purge() {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.generation++
+	cache.stats.Invalidations += uint64(cache.order.Len())
+	cache.entries = make(map[primitive.ObjectID]map[string]*list.Element)
+	cache.order.Init()
+}
+
+// Get will return the person of id from cache or store, ErrNotFound if there is none.
+// missing people are not cached.
+func (cache *Cache) Get(ctx context.Context, id primitive.ObjectID) (*model.Person, error) {
+	cache.lock.Lock()
+	person, ok := cache.lookup(tenantID(ctx), id)
+	generation := cache.generation
+	cache.lock.Unlock()
+	if ok {
+		return person, nil
+	}
+	person, err := cache.people().Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cache.lock.Lock()
+	cache.add(tenantID(ctx), person, generation)
+	cache.lock.Unlock()
+	return person, nil
+}
+
+// GetMany will return the people of ids, the ones that are not in cache are read with one query.
+func (cache *Cache) GetMany(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*model.Person, error) {
+	result := make(map[primitive.ObjectID]*model.Person, len(ids))
+	var missing []primitive.ObjectID
+	cache.lock.Lock()
+	for _, id := range ids {
+		if person, ok := cache.lookup(tenantID(ctx), id); ok {
+			result[id] = person
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	generation := cache.generation
+	cache.lock.Unlock()
+	if len(missing) == 0 {
+		return result, nil
+	}
+	people, err := cache.people().GetMany(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	cache.lock.Lock()
+	for id, person := range people {
+		cache.add(tenantID(ctx), person, generation)
+		result[id] = person
+	}
+	cache.lock.Unlock()
+	return result, nil
+}
+
+// Create will insert person in store.
+func (cache *Cache) Create(ctx context.Context, person *model.Person) error {
+	err := cache.people().Create(ctx, person)
+	cache.Invalidate(person.ID)
+	return err
+}
+
+// InsertMany will insert people in store.
+func (cache *Cache) InsertMany(ctx context.Context, personList []*model.Person) (inserted, skipped int, err error) {
+	inserted, skipped, err = cache.people().InsertMany(ctx, personList)
+	ids := make([]primitive.ObjectID, 0, len(personList))
+	for _, person := range personList {
+		ids = append(ids, person.ID)
+	}
+	cache.Invalidate(ids...)
+	return inserted, skipped, err
+}
+
+// ImportMany will write people in store and invalidate them.
+func (cache *Cache) ImportMany(ctx context.Context, personList []*model.Person, dryRun bool) ([]ImportResult, error) {
+	results, err := cache.people().ImportMany(ctx, personList, dryRun)
+	if !dryRun {
+		ids := make([]primitive.ObjectID, 0, len(personList))
+		for _, person := range personList {
+			ids = append(ids, person.ID)
+		}
+		cache.Invalidate(ids...)
+	}
+	return results, err
+}
+
+// DeleteAll will remove all people from store and cache.
+func (cache *Cache) DeleteAll(ctx context.Context) (int64, error) {
+	count, err := cache.people().DeleteAll(ctx)
+	cache.Purge()
+	return count, err
+}
+
+// List will return a page of people from store.
+func (cache *Cache) List(ctx context.Context, page int64) ([]model.Person, error) {
+	return cache.people().List(ctx, page)
+}
+
+// Find will return the people of filter from store.
+func (cache *Cache) Find(ctx context.Context, filter Filter, skip, limit int64) ([]model.Person, error) {
+	return cache.people().Find(ctx, filter, skip, limit)
+}
+
+// Update will set fields on the person of id in store and invalidate it, failed writes invalidate it too
+// because they may be applied.
+func (cache *Cache) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	err := cache.people().Update(ctx, id, fields)
+	cache.Invalidate(id)
+	return err
+}
+
+// Delete will remove the person of id from store and cache.
+func (cache *Cache) Delete(ctx context.Context, id primitive.ObjectID) error {
+	err := cache.people().Delete(ctx, id)
+	cache.Invalidate(id)
+	return err
+}
+
+// Watch will send the changes of store to events.
+func (cache *Cache) Watch(ctx context.Context, events chan<- Event) error {
+	return cache.people().Watch(ctx, events)
+}
+
+// Listen will invalidate the people of store changes until ctx is done, so the writes of other replicas
+// are not read from cache. ctx must have all tenants when tenancy is enabled. watch is started again
+// after errors and cache is purged because changes may be missed. change streams need a replica set,
+// on standalone servers Listen returns the error of Watch and people are only expired by ttl.
+func (cache *Cache) Listen(ctx context.Context) error {
+	events := make(chan Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			cache.Invalidate(event.ID)
+		}
+	}()
+	defer func() {
+		close(events)
+		<-done
+	}()
+	for {
+		err := cache.people().Watch(ctx, events)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if ChangeStreamsNotSupported(err) {
+			return err
+		}
+		log.Printf("Error while watching people for cache invalidation, it is started again: %v\n", err)
+		cache.Purge()
+		select {
+		case <-time.After(listenRetryInterval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// clonePerson will return a copy of person that shares no maps or slices with it.
+func clonePerson(person *model.Person) *model.Person {
+	clone := *person
+	if person.Data != nil {
+		clone.Data = cloneValue(person.Data).(map[string]interface{})
+	}
+	return &clone
+}
+
+func cloneValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			clone[key] = cloneValue(item)
+		}
+		return clone
+	case primitive.M:
+		return primitive.M(cloneValue(map[string]interface{}(value)).(map[string]interface{}))
+	case []interface{}:
+		clone := make([]interface{}, len(value))
+		for i, item := range value {
+			clone[i] = cloneValue(item)
+		}
+		return clone
+	case primitive.A:
+		return primitive.A(cloneValue([]interface{}(value)).([]interface{}))
+	case primitive.D:
+		clone := make(primitive.D, len(value))
+		for i, item := range value {
+			clone[i] = primitive.E{Key: item.Key, Value: cloneValue(item.Value)}
+		}
+		return clone
+	}
+	return value
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestCache(size int, ttl time.Duration, people ...*model.Person) (*Cache, *Memory) {
+	memory, _ := NewMemory(people...)
+	return NewCache(func() PeopleStore { return memory }, size, ttl), memory
+}
+
+func TestCacheInvalidatesWrites(t *testing.T) {
+	ctx := context.Background()
+	john := model.NewPerson("john", "", "john", "john@example.com", map[string]interface{}{"age": 30})
+	cache, _ := newTestCache(10, 0, john)
+	cache.Get(ctx, john.ID)
+	person, _ := cache.Get(ctx, john.ID)
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Fatalf("%s second read must be a hit: %+v", failed, stats)
+	}
+	person.Data["age"] = 99
+	if cached, _ := cache.Get(ctx, john.ID); cached.Data["age"] != int32(30) {
+		t.Fatalf("%s cached people must not be shared with callers: %+v", failed, cached)
+	}
+
+	cache.Update(ctx, john.ID, map[string]interface{}{"first_name": "johnny"})
+	if person, _ := cache.Get(ctx, john.ID); person.FirstName != "johnny" {
+		t.Fatalf("%s update must invalidate person: %+v", failed, person)
+	}
+	cache.Delete(ctx, john.ID)
+	if _, err := cache.Get(ctx, john.ID); err != ErrNotFound {
+		t.Fatalf("%s delete must invalidate person: %v", failed, err)
+	}
+	if stats := cache.Stats(); stats.Invalidations != 2 || stats.Entries != 0 {
+		t.Fatalf("%s invalidations are wrong: %+v", failed, stats)
+	}
+	t.Logf("%s Testing cache invalidation of writes is successful", succeed)
+}
+
+func TestCacheTenants(t *testing.T) {
+	acme := tenant.NewContext(context.Background(), tenant.Tenant{ID: "acme", Mode: tenant.ModeShared})
+	globex := tenant.NewContext(context.Background(), tenant.Tenant{ID: "globex", Mode: tenant.ModeShared})
+	cache, _ := newTestCache(10, 0)
+	john := model.NewPerson("", "", "john", "john@example.com", nil)
+	cache.Create(acme, john)
+	cache.Get(acme, john.ID)
+	if _, err := cache.Get(globex, john.ID); err != ErrNotFound {
+		t.Fatalf("%s cached people must not be read by other tenants: %v", failed, err)
+	}
+	if people, _ := cache.GetMany(globex, []primitive.ObjectID{john.ID}); len(people) != 0 {
+		t.Fatalf("%s cached people must not be read by other tenants: %+v", failed, people)
+	}
+	cache.Invalidate(john.ID)
+	if stats := cache.Stats(); stats.Entries != 0 {
+		t.Fatalf("%s invalidate must remove people of all tenants: %+v", failed, stats)
+	}
+	t.Logf("%s Testing tenants of cache is successful", succeed)
+}
+
+func TestCacheEvictionAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	a, b, c := model.NewPerson("", "", "a", "a@example.com", nil), model.NewPerson("", "", "b", "b@example.com", nil), model.NewPerson("", "", "c", "c@example.com", nil)
+	cache, _ := newTestCache(2, time.Minute, a, b, c)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Get(ctx, a.ID)
+	cache.Get(ctx, b.ID)
+	cache.Get(ctx, a.ID) // b is the least recently used now
+	people, _ := cache.GetMany(ctx, []primitive.ObjectID{c.ID, a.ID})
+	if len(people) != 2 || people[c.ID].Username != "c" {
+		t.Fatalf("%s GetMany must read missing people from store: %+v", failed, people)
+	}
+	if stats := cache.Stats(); stats.Evictions != 1 || stats.Entries != 2 || stats.Hits != 2 {
+		t.Fatalf("%s b must be evicted: %+v", failed, stats)
+	}
+	if _, ok := cache.entries[b.ID]; ok {
+		t.Fatalf("%s least recently used person must be evicted", failed)
+	}
+
+	now = now.Add(time.Minute)
+	cache.Get(ctx, a.ID)
+	if stats := cache.Stats(); stats.Misses != 4 {
+		t.Fatalf("%s expired person must be a miss: %+v", failed, stats)
+	}
+	t.Logf("%s Testing cache eviction and expiry is successful", succeed)
+}
+
+func TestCacheListen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	john := model.NewPerson("john", "", "john", "john@example.com", nil)
+	cache, memory := newTestCache(10, 0, john)
+	done := make(chan error, 1)
+	go func() { done <- cache.Listen(ctx) }()
+	for watching := false; !watching; time.Sleep(time.Millisecond) {
+		memory.lock.RLock()
+		watching = len(memory.watchers) > 0
+		memory.lock.RUnlock()
+	}
+	cache.Get(ctx, john.ID)
+	// another server writes to the store without the cache.
+	memory.Update(ctx, john.ID, map[string]interface{}{"first_name": "johnny"})
+	deadline := time.Now().Add(time.Second)
+	for cache.Stats().Entries != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if person, _ := cache.Get(ctx, john.ID); person.FirstName != "johnny" {
+		t.Fatalf("%s change events must invalidate people: %+v", failed, person)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("%s Listen must return nil when ctx is done: %v", failed, err)
+	}
+	t.Logf("%s Testing cache invalidation of change events is successful", succeed)
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/encryption"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// reencryptBatch is the number of people that Reencrypt reads in each page.
+const reencryptBatch int64 = 500
+
+// NotSearchableError is a Find filter on a randomized encrypted field, its values can not be compared.
+type NotSearchableError struct {
+	Field string
+}
+
+func (err *NotSearchableError) Error() string {
+	return err.Field + " can not be searched because it is encrypted"
+}
+
+// Encrypted encrypts the fields of people before they are written to a PeopleStore and decrypts them
+// after they are read, so the store only has their ciphertext. deterministic fields are searched with
+// their blind index.
+type Encrypted struct {
+	people PeopleStore
+	cipher *encryption.Cipher
+}
+
+var _ PeopleStore = (*Encrypted)(nil)
+
+// NewEncrypted is the Encrypted struct factory function.
+func NewEncrypted(people PeopleStore, cipher *encryption.Cipher) *Encrypted {
+	return &Encrypted{people: people, cipher: cipher}
+}
+
+// Create will insert the encrypted copy of person and set the id of person.
+func (encrypted *Encrypted) Create(ctx context.Context, person *model.Person) error {
+	copied, err := encrypted.cipher.EncryptPerson(person)
+	if err != nil {
+		return err
+	}
+	err = encrypted.people.Create(ctx, copied)
+	person.ID, person.TenantID = copied.ID, copied.TenantID
+	return err
+}
+
+// InsertMany will insert the encrypted copies of people and set their ids.
+func (encrypted *Encrypted) InsertMany(ctx context.Context, personList []*model.Person) (inserted, skipped int, err error) {
+	copies := make([]*model.Person, len(personList))
+	for i, person := range personList {
+		if copies[i], err = encrypted.cipher.EncryptPerson(person); err != nil {
+			return 0, 0, err
+		}
+	}
+	inserted, skipped, err = encrypted.people.InsertMany(ctx, copies)
+	for i, person := range personList {
+		person.ID, person.TenantID = copies[i].ID, copies[i].TenantID
+	}
+	return inserted, skipped, err
+}
+
+// ImportMany will write the encrypted copies of people and set their ids.
+func (encrypted *Encrypted) ImportMany(ctx context.Context, personList []*model.Person, dryRun bool) ([]ImportResult, error) {
+	copies := make([]*model.Person, len(personList))
+	for i, person := range personList {
+		var err error
+		if copies[i], err = encrypted.cipher.EncryptPerson(person); err != nil {
+			return nil, err
+		}
+	}
+	results, err := encrypted.people.ImportMany(ctx, copies, dryRun)
+	for i, person := range personList {
+		person.ID, person.TenantID = copies[i].ID, copies[i].TenantID
+	}
+	return results, err
+}
+
+// DeleteAll will remove all people of store.
+func (encrypted *Encrypted) DeleteAll(ctx context.Context) (int64, error) {
+	return encrypted.people.DeleteAll(ctx)
+}
+
+// Get will return the decrypted person of id.
+func (encrypted *Encrypted) Get(ctx context.Context, id primitive.ObjectID) (*model.Person, error) {
+	person, err := encrypted.people.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := encrypted.cipher.DecryptPerson(person); err != nil {
+		return nil, err
+	}
+	return person, nil
+}
+
+// List will return a decrypted page of people.
+func (encrypted *Encrypted) List(ctx context.Context, page int64) ([]model.Person, error) {
+	personList, err := encrypted.people.List(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+	return personList, encrypted.decryptList(personList)
+}
+
+func (encrypted *Encrypted) decryptList(personList []model.Person) error {
+	for i := range personList {
+		if err := encrypted.cipher.DecryptPerson(&personList[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Find will return the decrypted people of filter. randomized encrypted fields can not be filtered, a
+// NotSearchableError is returned for them. deterministic fields are filtered with their blind index, it
+// does not change with keys, so people that are not encrypted again after a key rotation are found too.
+func (encrypted *Encrypted) Find(ctx context.Context, filter Filter, skip, limit int64) ([]model.Person, error) {
+	values := map[string]*string{
+		"first_name": &filter.FirstName,
+		"last_name":  &filter.LastName,
+		"username":   &filter.Username,
+		"email":      &filter.Email,
+	}
+	// the blind indexes of the caller are copied, they must not be changed.
+	indexes := make(map[string]string, len(filter.BlindIndex))
+	for field, index := range filter.BlindIndex {
+		indexes[field] = index
+	}
+	for field, value := range values {
+		mode, ok := encrypted.cipher.Mode(field)
+		if !ok || *value == "" {
+			continue
+		}
+		if mode != encryption.Deterministic {
+			return nil, &NotSearchableError{Field: field}
+		}
+		search := *value
+		if field == "username" || field == "email" {
+			search = strings.TrimSpace(search)
+		}
+		index, err := encrypted.cipher.BlindIndex(field, search)
+		if err != nil {
+			return nil, err
+		}
+		indexes[field] = index
+		*value = ""
+	}
+	if len(indexes) > 0 {
+		filter.BlindIndex = indexes
+	}
+	personList, err := encrypted.people.Find(ctx, filter, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+	return personList, encrypted.decryptList(personList)
+}
+
+// GetMany will return the decrypted people of ids.
+func (encrypted *Encrypted) GetMany(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*model.Person, error) {
+	people, err := encrypted.people.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, person := range people {
+		if err := encrypted.cipher.DecryptPerson(person); err != nil {
+			return nil, err
+		}
+	}
+	return people, nil
+}
+
+// Update will set the encrypted values of fields on the person of id, fields is not changed.
+func (encrypted *Encrypted) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	copied, err := encrypted.cipher.EncryptFields(fields)
+	if err != nil {
+		return err
+	}
+	return encrypted.people.Update(ctx, id, copied)
+}
+
+// Delete will remove the person of id.
+func (encrypted *Encrypted) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return encrypted.people.Delete(ctx, id)
+}
+
+// Watch will send the changes of store to events, their people are decrypted. changes of people that
+// can not be decrypted are logged and skipped.
+func (encrypted *Encrypted) Watch(ctx context.Context, events chan<- Event) error {
+	inner := make(chan Event)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- encrypted.people.Watch(ctx, inner)
+		close(inner)
+	}()
+	for event := range inner {
+		if event.Person != nil {
+			if err := encrypted.cipher.DecryptPerson(event.Person); err != nil {
+				log.Printf("Error while decrypting the change of person %s: %v\n", event.ID.Hex(), err)
+				continue
+			}
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+	return <-errs
+}
+
+// Reencrypt will write again the fields of people of ctx tenant that are not encrypted with the active
+// key, the fields that are not encrypted yet and the fields that are not encrypted anymore. progress is
+// called after every page with the number of scanned people. people that are created while it runs are
+// encrypted with the active key already, people that are deleted while it runs may make it miss others,
+// so it can be run again until it updates no people.
+func (encrypted *Encrypted) Reencrypt(ctx context.Context, progress func(scanned int64)) (scanned, updated int64, err error) {
+	for skip := int64(0); ; skip += reencryptBatch {
+		personList, err := encrypted.people.Find(ctx, Filter{}, skip, reencryptBatch)
+		if err != nil {
+			return scanned, updated, err
+		}
+		for i := range personList {
+			fields, err := encrypted.cipher.Rotate(&personList[i])
+			if err != nil {
+				return scanned, updated, err
+			}
+			if len(fields) > 0 {
+				if err := encrypted.Update(ctx, personList[i].ID, fields); err != nil && err != ErrNotFound {
+					return scanned, updated, err
+				}
+				updated++
+			}
+		}
+		scanned += int64(len(personList))
+		if progress != nil {
+			progress(scanned)
+		}
+		if int64(len(personList)) < reencryptBatch {
+			return scanned, updated, nil
+		}
+	}
+}
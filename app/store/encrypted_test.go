@@ -0,0 +1,95 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/encryption"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+)
+
+func newTestEncrypted(t *testing.T, memory *Memory, active string) *Encrypted {
+	keyring, _ := encryption.NewKeyring(active, map[string][]byte{
+		"old": bytes.Repeat([]byte{1}, encryption.KeySize),
+		"new": bytes.Repeat([]byte{2}, encryption.KeySize),
+	}, bytes.Repeat([]byte{3}, encryption.KeySize))
+	fields, _ := encryption.ParseFields("username:deterministic,email:deterministic,first_name,last_name")
+	cipher, err := encryption.NewCipher(keyring, fields)
+	if err != nil {
+		t.Fatalf("%s cipher must be created: %v", failed, err)
+	}
+	return NewEncrypted(memory, cipher)
+}
+
+func TestEncryptedWritesAndFind(t *testing.T) {
+	ctx := context.Background()
+	memory, _ := NewMemory()
+	people := newTestEncrypted(t, memory, "new")
+	john := model.NewPerson("John", "Doe", "John", "john@example.com", nil)
+	if err := people.Create(ctx, john); err != nil {
+		t.Fatalf("%s person must be created: %v", failed, err)
+	}
+	raw, _ := memory.Get(ctx, john.ID)
+	if !encryption.IsEncrypted(raw.Username) || !encryption.IsEncrypted(raw.FirstName) || !encryption.IsEncrypted(raw.Email) {
+		t.Fatalf("%s store must only have ciphertexts: %+v", failed, raw)
+	}
+	if err := people.Create(ctx, model.NewPerson("", "", "JOHN", "other@example.com", nil)); !errors.As(err, new(*DuplicateError)) {
+		t.Fatalf("%s deterministic username must be unique: %v", failed, err)
+	}
+
+	fields := map[string]interface{}{"first_name": "Johnny"}
+	if err := people.Update(ctx, john.ID, fields); err != nil || fields["first_name"] != "Johnny" {
+		t.Fatalf("%s update must not change fields: %+v %v", failed, fields, err)
+	}
+	if person, _ := people.Get(ctx, john.ID); person.FirstName != "Johnny" || person.Username != "John" {
+		t.Fatalf("%s person must be decrypted: %+v", failed, person)
+	}
+	if found, err := people.Find(ctx, Filter{Username: " JOHN "}, 0, 10); err != nil || len(found) != 1 || found[0].Email != "john@example.com" {
+		t.Fatalf("%s people must be found by username: %+v %v", failed, found, err)
+	}
+	if _, err := people.Find(ctx, Filter{FirstName: "Johnny"}, 0, 10); !errors.As(err, new(*NotSearchableError)) {
+		t.Fatalf("%s randomized fields must not be searched: %v", failed, err)
+	}
+	t.Logf("%s Testing encrypted people store is successful", succeed)
+}
+
+func TestEncryptedKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	memory, _ := NewMemory()
+	old := newTestEncrypted(t, memory, "old")
+	john := model.NewPerson("John", "Doe", "john", "john@example.com", nil)
+	old.Create(ctx, john)
+	memory.Create(ctx, model.NewPerson("Jane", "Doe", "jane", "jane@example.com", nil))
+
+	people := newTestEncrypted(t, memory, "new")
+	if found, _ := people.Find(ctx, Filter{Email: "John@Example.com"}, 0, 10); len(found) != 1 || found[0].ID != john.ID {
+		t.Fatalf("%s people of old key must be found: %+v", failed, found)
+	}
+	if err := people.Create(ctx, model.NewPerson("", "", "JOHN", "other@example.com", nil)); !errors.As(err, new(*DuplicateError)) {
+		t.Fatalf("%s username of old key must be unique with the new key: %v", failed, err)
+	}
+
+	var progress int64
+	scanned, updated, err := people.Reencrypt(ctx, func(scanned int64) { progress = scanned })
+	if err != nil || scanned != 2 || progress != 2 || updated != 2 {
+		t.Fatalf("%s people must be encrypted again: scanned %d, updated %d, %v", failed, scanned, updated, err)
+	}
+	raw, _ := memory.Find(ctx, Filter{}, 0, 10)
+	for _, person := range raw {
+		if encryption.KeyID(person.Username) != "new" || encryption.KeyID(person.LastName) != "new" {
+			t.Fatalf("%s people must be encrypted with active key: %+v", failed, person)
+		}
+	}
+	if _, updated, _ := people.Reencrypt(ctx, nil); updated != 0 {
+		t.Fatalf("%s second run must update no people: %d", failed, updated)
+	}
+	if found, _ := people.Find(ctx, Filter{Username: "JANE"}, 0, 10); len(found) != 1 || found[0].Email != "jane@example.com" {
+		t.Fatalf("%s people that were not encrypted must be found after rotation: %+v", failed, found)
+	}
+	if person, _ := people.Get(ctx, john.ID); person.FirstName != "John" {
+		t.Fatalf("%s person must be decrypted after rotation: %+v", failed, person)
+	}
+	t.Logf("%s Testing key rotation of people store is successful", succeed)
+}
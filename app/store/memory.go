@@ -0,0 +1,436 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Memory keeps people in memory, it is the store of tests. it behaves like People: ids are generated,
+// username and email are unique case insensitive and people are listed newest first. people are kept as
+// bson documents, so they are decoded like the documents of mongo db and callers never share them.
+// people are scoped by the tenant of context in both modes of tenancy, their tenant is in their tenant field.
+type Memory struct {
+	lock      sync.RWMutex
+	documents map[primitive.ObjectID]bson.M
+	watchers  map[*watcher]bool
+}
+
+var (
+	_ PeopleStore = (*People)(nil)
+	_ PeopleStore = (*Memory)(nil)
+)
+
+// NewMemory is the Memory struct factory function, people are added to the empty store.
+func NewMemory(people ...*model.Person) (*Memory, error) {
+	memory := &Memory{documents: make(map[primitive.ObjectID]bson.M), watchers: make(map[*watcher]bool)}
+	for _, person := range people {
+		if err := memory.Create(context.Background(), person); err != nil {
+			return nil, err
+		}
+	}
+	return memory, nil
+}
+
+// watcher is a Watch call, events are queued so writes never wait for watchers.
+type watcher struct {
+	tenant string // id of watched tenant, empty for all tenants
+	lock   sync.Mutex
+	queue  []Event
+	notify chan struct{}
+}
+
+func (w *watcher) push(event Event) {
+	w.lock.Lock()
+	w.queue = append(w.queue, event)
+	w.lock.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) pop() []Event {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	events := w.queue
+	w.queue = nil
+	return events
+}
+
+// publish will send event to watchers, lock must be held. delete events are sent to the watchers of all
+// tenants like the change streams of shared collections.
+func (memory *Memory) publish(event Event) {
+	for w := range memory.watchers {
+		if w.tenant != "" && event.Person != nil && event.Person.TenantID != w.tenant {
+			continue
+		}
+		if event.Person != nil {
+			person := *event.Person
+			event.Person = &person
+		}
+		w.push(event)
+	}
+}
+
+// tenantID will return the id of ctx tenant, it is empty if ctx has no tenant or it is all tenants.
+func tenantID(ctx context.Context) string {
+	current, _ := tenant.FromContext(ctx)
+	return current.ID
+}
+
+// visible will return true if document is a person of tenant id, empty id sees all people.
+func visible(document bson.M, id string) bool {
+	return id == "" || document[tenant.Field] == id
+}
+
+// decode will return the person of document.
+func decode(document bson.M) *model.Person {
+	data, _ := bson.Marshal(document)
+	person := new(model.Person)
+	bson.Unmarshal(data, person)
+	return person
+}
+
+// encode will return the document of person.
+func encode(person *model.Person) (bson.M, error) {
+	data, err := bson.Marshal(person)
+	if err != nil {
+		return nil, err
+	}
+	var document bson.M
+	return document, bson.Unmarshal(data, &document)
+}
+
+// conflict will return the unique field of person that another person of its tenant has, lock must be held.
+func (memory *Memory) conflict(person *model.Person) error {
+	for id, document := range memory.documents {
+		if id == person.ID || document[tenant.Field] != nilIfEmpty(person.TenantID) {
+			continue
+		}
+		other := decode(document)
+		switch {
+		case strings.EqualFold(other.Username, person.Username), sameIndex(other, person, "username"):
+			return &DuplicateError{Field: "username"}
+		case strings.EqualFold(other.Email, person.Email), sameIndex(other, person, "email"):
+			return &DuplicateError{Field: "email"}
+		}
+	}
+	return nil
+}
+
+// sameIndex will return true if people have the same blind index of field, like its unique index.
+func sameIndex(a, b *model.Person, field string) bool {
+	return a.BlindIndex[field] != "" && a.BlindIndex[field] == b.BlindIndex[field]
+}
+
+// nilIfEmpty will return the value of id in documents, empty fields are omitted.
+func nilIfEmpty(id string) interface{} {
+	if id == "" {
+		return nil
+	}
+	return id
+}
+
+// insert will save person of ctx tenant with a new id if it has none, lock must be held.
+func (memory *Memory) insert(ctx context.Context, person *model.Person) error {
+	person.Normalize()
+	person.TenantID = tenantID(ctx)
+	if person.ID.IsZero() {
+		person.ID = primitive.NewObjectID()
+	} else if _, ok := memory.documents[person.ID]; ok {
+		return &DuplicateError{Field: "_id"}
+	}
+	if err := memory.conflict(person); err != nil {
+		return err
+	}
+	document, err := encode(person)
+	if err != nil {
+		return err
+	}
+	memory.documents[person.ID] = document
+	memory.publish(Event{Type: EventCreated, ID: person.ID, Person: decode(document)})
+	return nil
+}
+
+// Create will insert person and set its id, username and email are normalized first.
+func (memory *Memory) Create(ctx context.Context, person *model.Person) error {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	return memory.insert(ctx, person)
+}
+
+// InsertMany will insert people, people that violate a unique field are skipped and counted.
+// ids of people are not set, like InsertMany of People.
+func (memory *Memory) InsertMany(ctx context.Context, personList []*model.Person) (inserted, skipped int, err error) {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	for _, person := range personList {
+		copied := *person
+		err := memory.insert(ctx, &copied)
+		person.Normalize()
+		person.TenantID = copied.TenantID
+		if _, ok := err.(*DuplicateError); ok {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return inserted, skipped, err
+		}
+		inserted++
+	}
+	return inserted, skipped, nil
+}
+
+// ImportMany will update the people of ctx tenant with the username of people with ImportFields and create
+// the others, like ImportMany of People. ids of people are set and a dry run writes nothing.
+func (memory *Memory) ImportMany(ctx context.Context, personList []*model.Person, dryRun bool) ([]ImportResult, error) {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	results := make([]ImportResult, len(personList))
+	for i, person := range personList {
+		person.Normalize()
+		person.TenantID = tenantID(ctx)
+		current := memory.withUsername(person)
+		switch {
+		case current != nil:
+			person.ID = current.ID
+			results[i].Updated = true
+			document, updated, err := memory.updated(ctx, current.ID, ImportFields(person))
+			if err == nil && !dryRun {
+				memory.documents[current.ID] = document
+				memory.publish(Event{Type: EventUpdated, ID: current.ID, Person: updated})
+			}
+			results[i].Err = err
+		case dryRun:
+			person.ID = primitive.NewObjectID()
+			results[i].Err = memory.conflict(person)
+		default:
+			results[i].Err = memory.insert(ctx, person)
+		}
+	}
+	return results, nil
+}
+
+// withUsername will return the person of the tenant of person that has its username, lock must be held.
+func (memory *Memory) withUsername(person *model.Person) *model.Person {
+	for _, document := range memory.documents {
+		if document[tenant.Field] != nilIfEmpty(person.TenantID) {
+			continue
+		}
+		if other := decode(document); strings.EqualFold(other.Username, person.Username) || sameIndex(other, person, "username") {
+			return other
+		}
+	}
+	return nil
+}
+
+// DeleteAll will remove all people of ctx tenant and return their number.
+func (memory *Memory) DeleteAll(ctx context.Context) (int64, error) {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	var count int64
+	for id, document := range memory.documents {
+		if !visible(document, tenantID(ctx)) {
+			continue
+		}
+		count++
+		delete(memory.documents, id)
+		memory.publish(Event{Type: EventDeleted, ID: id})
+	}
+	return count, nil
+}
+
+// Get will return the person of id, ErrNotFound if there is none.
+func (memory *Memory) Get(ctx context.Context, id primitive.ObjectID) (*model.Person, error) {
+	memory.lock.RLock()
+	defer memory.lock.RUnlock()
+	document, ok := memory.documents[id]
+	if !ok || !visible(document, tenantID(ctx)) {
+		return nil, ErrNotFound
+	}
+	return decode(document), nil
+}
+
+// List will return a zero based page of people, newest first.
+func (memory *Memory) List(ctx context.Context, page int64) ([]model.Person, error) {
+	if page < 0 {
+		page = 0
+	}
+	return memory.Find(ctx, Filter{}, page*PageSize, PageSize)
+}
+
+// matches will return true if person is selected by filter.
+func (filter Filter) matches(person *model.Person) bool {
+	if len(filter.IDs) > 0 {
+		found := false
+		for _, id := range filter.IDs {
+			found = found || id == person.ID
+		}
+		if !found {
+			return false
+		}
+	}
+	for field, index := range filter.BlindIndex {
+		if person.BlindIndex[field] != index {
+			return false
+		}
+	}
+	return (filter.FirstName == "" || filter.FirstName == person.FirstName) &&
+		(filter.LastName == "" || filter.LastName == person.LastName) &&
+		(strings.TrimSpace(filter.Username) == "" || strings.EqualFold(strings.TrimSpace(filter.Username), person.Username)) &&
+		(strings.TrimSpace(filter.Email) == "" || strings.EqualFold(strings.TrimSpace(filter.Email), person.Email))
+}
+
+// Find will return the people that match filter, newest first. skip people are skipped and at most
+// limit people are returned.
+func (memory *Memory) Find(ctx context.Context, filter Filter, skip, limit int64) ([]model.Person, error) {
+	memory.lock.RLock()
+	var personList []model.Person
+	for _, document := range memory.documents {
+		if !visible(document, tenantID(ctx)) {
+			continue
+		}
+		if person := decode(document); filter.matches(person) {
+			personList = append(personList, *person)
+		}
+	}
+	memory.lock.RUnlock()
+	sort.Slice(personList, func(i, j int) bool {
+		return bytes.Compare(personList[i].ID[:], personList[j].ID[:]) > 0
+	})
+	if skip >= int64(len(personList)) {
+		return nil, nil
+	}
+	personList = personList[skip:]
+	if limit > 0 && limit < int64(len(personList)) {
+		personList = personList[:limit]
+	}
+	return personList, nil
+}
+
+// GetMany will return the people of ids, ids that have no person are not in result.
+func (memory *Memory) GetMany(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*model.Person, error) {
+	memory.lock.RLock()
+	defer memory.lock.RUnlock()
+	result := make(map[primitive.ObjectID]*model.Person, len(ids))
+	for _, id := range ids {
+		if document, ok := memory.documents[id]; ok && visible(document, tenantID(ctx)) {
+			result[id] = decode(document)
+		}
+	}
+	return result, nil
+}
+
+// Update will set fields on the person of id, username and email are trimmed first. fields are the
+// bson names of person fields, dotted names set the keys of embedded documents like $set. the tenant of
+// person can not be changed and _id is not valid.
+func (memory *Memory) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	delete(fields, tenant.Field)
+	if _, ok := fields["_id"]; ok {
+		return errIDUpdate
+	}
+	for _, field := range []string{"username", "email"} {
+		if value, ok := fields[field].(string); ok {
+			fields[field] = strings.TrimSpace(value)
+		}
+	}
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	document, person, err := memory.updated(ctx, id, fields)
+	if err != nil {
+		return err
+	}
+	memory.documents[id] = document
+	memory.publish(Event{Type: EventUpdated, ID: id, Person: person})
+	return nil
+}
+
+// updated will return the document and the person of id with fields set, they are not saved. lock must
+// be held.
+func (memory *Memory) updated(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) (bson.M, *model.Person, error) {
+	current, ok := memory.documents[id]
+	if !ok || !visible(current, tenantID(ctx)) {
+		return nil, nil, ErrNotFound
+	}
+	document, err := encode(decode(current))
+	if err != nil {
+		return nil, nil, err
+	}
+	for field, value := range fields {
+		set(document, strings.Split(field, "."), value)
+	}
+	// the document is encoded again, so values have the types that mongo db would return.
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, nil, err
+	}
+	document = nil
+	if err := bson.Unmarshal(data, &document); err != nil {
+		return nil, nil, err
+	}
+	person := decode(document)
+	if err := memory.conflict(person); err != nil {
+		return nil, nil, err
+	}
+	return document, person, nil
+}
+
+// set will set the value of path in document, missing embedded documents are created.
+func set(document bson.M, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		embedded, ok := document[key].(bson.M)
+		if !ok {
+			embedded = bson.M{}
+			document[key] = embedded
+		}
+		document = embedded
+	}
+	document[path[len(path)-1]] = value
+}
+
+// Delete will remove the person of id.
+func (memory *Memory) Delete(ctx context.Context, id primitive.ObjectID) error {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	if document, ok := memory.documents[id]; !ok || !visible(document, tenantID(ctx)) {
+		return ErrNotFound
+	}
+	delete(memory.documents, id)
+	memory.publish(Event{Type: EventDeleted, ID: id})
+	return nil
+}
+
+// Watch will send the changes of people of ctx tenant to events until ctx is done. events is not closed.
+func (memory *Memory) Watch(ctx context.Context, events chan<- Event) error {
+	w := &watcher{tenant: tenantID(ctx), notify: make(chan struct{}, 1)}
+	memory.lock.Lock()
+	memory.watchers[w] = true
+	memory.lock.Unlock()
+	defer func() {
+		memory.lock.Lock()
+		delete(memory.watchers, w)
+		memory.lock.Unlock()
+	}()
+	for {
+		select {
+		case <-w.notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		for _, event := range w.pop() {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+func TestMemoryUniqueFields(t *testing.T) {
+	ctx := context.Background()
+	john, jane := model.NewPerson("", "", "john", "john@example.com", nil), model.NewPerson("", "", "jane", "jane@example.com", nil)
+	memory, err := NewMemory(john, jane)
+	if err != nil {
+		t.Fatalf("%s NewMemory returned error: %v", failed, err)
+	}
+	var duplicate *DuplicateError
+	if err := memory.Create(ctx, model.NewPerson("", "", "other", " JOHN@example.com ", nil)); !errors.As(err, &duplicate) || duplicate.Field != "email" {
+		t.Fatalf("%s email must be unique case insensitive: %v", failed, err)
+	}
+	if err := memory.Update(ctx, jane.ID, map[string]interface{}{"username": "John"}); !errors.As(err, &duplicate) || duplicate.Field != "username" {
+		t.Fatalf("%s update must keep username unique: %v", failed, err)
+	}
+	inserted, skipped, err := memory.InsertMany(ctx, []*model.Person{
+		model.NewPerson("", "", "a", "a@example.com", nil),
+		model.NewPerson("", "", "A", "b@example.com", nil),
+	})
+	if inserted != 1 || skipped != 1 || err != nil {
+		t.Fatalf("%s InsertMany must skip duplicates: %d %d %v", failed, inserted, skipped, err)
+	}
+	t.Logf("%s Testing unique fields of memory store is successful", succeed)
+}
+
+func TestMemoryImportMany(t *testing.T) {
+	ctx := context.Background()
+	john, jane := model.NewPerson("John", "", "john", "john@example.com", nil), model.NewPerson("", "", "jane", "jane@example.com", nil)
+	memory, _ := NewMemory(john, jane)
+	batch := func() []*model.Person {
+		return []*model.Person{
+			model.NewPerson("", "Doe", "JOHN", "johnny@example.com", map[string]interface{}{"age": 30}),
+			model.NewPerson("", "", "bob", "bob@example.com", nil),
+			model.NewPerson("", "", "alice", "Jane@example.com", nil),
+		}
+	}
+	var duplicate *DuplicateError
+	results, err := memory.ImportMany(ctx, batch(), true)
+	if err != nil || !results[0].Updated || results[0].Err != nil || results[1].Updated || results[1].Err != nil ||
+		!errors.As(results[2].Err, &duplicate) || duplicate.Field != "email" {
+		t.Fatalf("%s dry run must report the updates and the emails of other people: %+v %v", failed, results, err)
+	}
+	if personList, _ := memory.Find(ctx, Filter{}, 0, 0); len(personList) != 2 {
+		t.Fatalf("%s dry run must write nothing, store has %d people", failed, len(personList))
+	}
+	personList := batch()
+	if results, err = memory.ImportMany(ctx, personList, false); err != nil || results[2].Err == nil || personList[0].ID != john.ID {
+		t.Fatalf("%s import must update john and reject the email of jane: %+v %v", failed, results, err)
+	}
+	updated, _ := memory.Get(ctx, john.ID)
+	if updated.Username != "john" || updated.FirstName != "John" || updated.LastName != "Doe" || updated.Email != "johnny@example.com" || updated.Data["age"] != int32(30) {
+		t.Fatalf("%s import must set the fields of file and keep the others: %+v", failed, updated)
+	}
+	if _, err := memory.Get(ctx, personList[1].ID); err != nil {
+		t.Fatalf("%s import must create bob: %v", failed, err)
+	}
+	t.Logf("%s Testing import of memory store is successful", succeed)
+}
+
+func TestMemoryUpdateAndFind(t *testing.T) {
+	ctx := context.Background()
+	john := model.NewPerson("john", "doe", "john", "john@example.com", map[string]interface{}{"age": 30, "city": "Tehran"})
+	memory, _ := NewMemory(john, model.NewPerson("jane", "doe", "jane", "jane@example.com", nil))
+	if err := memory.Update(ctx, john.ID, map[string]interface{}{"data.age": 31, "first_name": "johnny"}); err != nil {
+		t.Fatalf("%s Update returned error: %v", failed, err)
+	}
+	person, _ := memory.Get(ctx, john.ID)
+	if person.FirstName != "johnny" || person.Data["age"] != int32(31) || person.Data["city"] != "Tehran" {
+		t.Fatalf("%s dotted fields must set the keys of data: %+v", failed, person)
+	}
+	person.Data["city"] = "changed"
+	if again, _ := memory.Get(ctx, john.ID); again.Data["city"] != "Tehran" {
+		t.Fatalf("%s people of memory must not be shared with callers", failed)
+	}
+
+	people, _ := memory.Find(ctx, Filter{LastName: "doe"}, 0, 10)
+	if len(people) != 2 || people[0].Username != "jane" {
+		t.Fatalf("%s people must be newest first: %+v", failed, people)
+	}
+	if people, _ := memory.Find(ctx, Filter{Username: " JOHN "}, 0, 10); len(people) != 1 {
+		t.Fatalf("%s username filter must be case insensitive: %+v", failed, people)
+	}
+	if people, _ := memory.Find(ctx, Filter{}, 1, 10); len(people) != 1 || people[0].Username != "john" {
+		t.Fatalf("%s skip is wrong: %+v", failed, people)
+	}
+	if _, err := memory.Get(ctx, model.Person{}.ID); err != ErrNotFound {
+		t.Fatalf("%s missing person must be ErrNotFound: %v", failed, err)
+	}
+	t.Logf("%s Testing update and find of memory store is successful", succeed)
+}
+
+func TestMemoryWatch(t *testing.T) {
+	memory, _ := NewMemory()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan Event)
+	done := make(chan error, 1)
+	go func() { done <- memory.Watch(ctx, events) }()
+	// Watch registers itself asynchronously, writes before it are not sent.
+	for watching := false; !watching; time.Sleep(time.Millisecond) {
+		memory.lock.RLock()
+		watching = len(memory.watchers) > 0
+		memory.lock.RUnlock()
+	}
+	person := model.NewPerson("", "", "john", "john@example.com", nil)
+	memory.Create(ctx, person)
+	memory.Update(ctx, person.ID, map[string]interface{}{"first_name": "john"})
+	memory.Delete(ctx, person.ID)
+	for _, want := range []string{EventCreated, EventUpdated, EventDeleted} {
+		event := <-events
+		if event.Type != want || event.ID != person.ID || (want != EventDeleted) != (event.Person != nil) {
+			t.Fatalf("%s event must be %s of person: %+v", failed, want, event)
+		}
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("%s Watch must return the error of ctx: %v", failed, err)
+	}
+	t.Logf("%s Testing watch of memory store is successful", succeed)
+}
+
+func TestMemoryTenants(t *testing.T) {
+	acme := tenant.NewContext(context.Background(), tenant.Tenant{ID: "acme", Mode: tenant.ModeShared})
+	globex := tenant.NewContext(context.Background(), tenant.Tenant{ID: "globex", Mode: tenant.ModeShared})
+	memory, _ := NewMemory()
+	john := model.NewPerson("", "", "john", "john@example.com", nil)
+	if err := memory.Create(acme, john); err != nil || john.TenantID != "acme" {
+		t.Fatalf("%s create must set the tenant of person: %v %q", failed, err, john.TenantID)
+	}
+	if err := memory.Create(globex, model.NewPerson("", "", "John", "john@example.com", nil)); err != nil {
+		t.Fatalf("%s unique fields must be unique per tenant: %v", failed, err)
+	}
+	if _, err := memory.Get(globex, john.ID); err != ErrNotFound {
+		t.Fatalf("%s people of other tenants must not be found: %v", failed, err)
+	}
+	if err := memory.Update(acme, john.ID, map[string]interface{}{tenant.Field: "globex"}); err != nil {
+		t.Fatalf("%s Update returned error: %v", failed, err)
+	}
+	if people, _ := memory.Find(globex, Filter{}, 0, 10); len(people) != 1 {
+		t.Fatalf("%s update must not change the tenant of person: %+v", failed, people)
+	}
+	if count, _ := memory.DeleteAll(acme); count != 1 {
+		t.Fatalf("%s DeleteAll must only remove the people of tenant: %d", failed, count)
+	}
+	t.Logf("%s Testing tenants of memory store is successful", succeed)
+}
@@ -0,0 +1,524 @@
+// Package store is the data access of collections, it is shared by http handlers, grpc and graphql.
+package store
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"github.com/katoozi/golang-mongodb-rest-api/app/encryption"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PageSize is the number of people in a page of List.
+const PageSize int64 = 10
+
+// errors of people
+var (
+	ErrNotFound = errors.New("person not found")
+)
+
+// DuplicateError is a write that violates a unique field.
+type DuplicateError struct {
+	Field string
+	Err   error
+}
+
+func (err *DuplicateError) Error() string {
+	return err.Field + " already exists"
+}
+
+// Unwrap will return the mongo db error.
+func (err *DuplicateError) Unwrap() error {
+	return err.Err
+}
+
+// ImportResult is the write of a person of ImportMany.
+type ImportResult struct {
+	Updated bool  // a person has the username of imported person and it is updated, otherwise it is created
+	Err     error // the reason that person is not written, like a DuplicateError or a ValidationError
+}
+
+// ImportFields will return the fields that an import sets on the person with the username of person: email,
+// the names that are not empty, the keys of data and the blind indexes of fields other than username.
+func ImportFields(person *model.Person) map[string]interface{} {
+	fields := map[string]interface{}{"email": person.Email}
+	if person.FirstName != "" {
+		fields["first_name"] = person.FirstName
+	}
+	if person.LastName != "" {
+		fields["last_name"] = person.LastName
+	}
+	for key, value := range person.Data {
+		fields["data."+key] = value
+	}
+	for field, index := range person.BlindIndex {
+		if field != "username" {
+			fields[encryption.BlindIndexField+"."+field] = index
+		}
+	}
+	return fields
+}
+
+// PeopleStore is the data access of people that handlers, grpc and graphql use. People keeps them in
+// mongo db and Memory keeps them in memory for tests.
+type PeopleStore interface {
+	Create(ctx context.Context, person *model.Person) error
+	InsertMany(ctx context.Context, personList []*model.Person) (inserted, skipped int, err error)
+	ImportMany(ctx context.Context, personList []*model.Person, dryRun bool) ([]ImportResult, error)
+	DeleteAll(ctx context.Context) (int64, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*model.Person, error)
+	List(ctx context.Context, page int64) ([]model.Person, error)
+	Find(ctx context.Context, filter Filter, skip, limit int64) ([]model.Person, error)
+	GetMany(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*model.Person, error)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	Watch(ctx context.Context, events chan<- Event) error
+}
+
+// People is the data access of people collection. queries are scoped by the tenant of their context, see
+// the tenant package.
+type People struct {
+	database *mongo.Database
+}
+
+// NewPeople is the People struct factory function.
+func NewPeople(database *mongo.Database) *People {
+	return &People{database: database}
+}
+
+// collection will return the people collection of ctx tenant.
+func (people *People) collection(ctx context.Context) *mongo.Collection {
+	return tenant.Database(ctx, people.database).Collection("people")
+}
+
+// writeError will convert the unique index violations of err to DuplicateError.
+func writeError(err error) error {
+	if field := db.DuplicateKeyField(err, db.Indexes); field != "" {
+		return &DuplicateError{Field: field, Err: err}
+	}
+	return err
+}
+
+// Create will insert person and set its id, username and email are normalized first.
+func (people *People) Create(ctx context.Context, person *model.Person) error {
+	person.Normalize()
+	person.TenantID = tenant.SharedID(ctx)
+	result, err := people.collection(ctx).InsertOne(ctx, person)
+	if err != nil {
+		return writeError(err)
+	}
+	person.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// InsertMany will insert people in one unordered bulk write, username and email are normalized first.
+// people that violate a unique field are skipped and counted, other write errors are returned.
+func (people *People) InsertMany(ctx context.Context, personList []*model.Person) (inserted, skipped int, err error) {
+	documents := make([]interface{}, len(personList))
+	for i, person := range personList {
+		person.Normalize()
+		person.TenantID = tenant.SharedID(ctx)
+		documents[i] = person
+	}
+	_, err = people.collection(ctx).InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		failed := 0
+		for _, writeErr := range bulkErr.WriteErrors {
+			if db.IsDuplicateKey(writeErr) {
+				skipped++
+			} else {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return len(documents) - skipped - failed, skipped, err
+		}
+		return len(documents) - skipped, skipped, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(documents), 0, nil
+}
+
+// ImportMany will write people in one unordered bulk write, the people of ctx tenant with their username
+// are updated with ImportFields and the others are created. username and email are normalized first and
+// ids of people are set. a dry run writes nothing, the emails that other people have fail its results
+// like the unique index of email would fail the writes.
+func (people *People) ImportMany(ctx context.Context, personList []*model.Person, dryRun bool) ([]ImportResult, error) {
+	if len(personList) == 0 {
+		return nil, nil
+	}
+	for _, person := range personList {
+		person.Normalize()
+		person.TenantID = tenant.SharedID(ctx)
+	}
+	existing, err := people.existing(ctx, personList, "username")
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ImportResult, len(personList))
+	models := make([]mongo.WriteModel, len(personList))
+	for i, person := range personList {
+		if current, ok := existing[importKey(person, "username")]; ok {
+			person.ID = current.ID
+			results[i].Updated = true
+			models[i] = mongo.NewUpdateOneModel().
+				SetFilter(tenant.Scope(ctx, bson.M{"_id": current.ID})).
+				SetUpdate(bson.M{"$set": ImportFields(person)})
+		} else {
+			person.ID = primitive.NewObjectID()
+			models[i] = mongo.NewInsertOneModel().SetDocument(person)
+		}
+	}
+	if dryRun {
+		return results, people.collisions(ctx, personList, results)
+	}
+	_, err = people.collection(ctx).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			results[writeErr.Index].Err = writeError(writeErr)
+		}
+		return results, nil
+	}
+	return results, err
+}
+
+// importKey will return the key that people are found with by the username or email of person, field. it
+// is the blind index of field if it is encrypted, otherwise the lower case value like its unique index.
+func importKey(person *model.Person, field string) string {
+	if index := person.BlindIndex[field]; index != "" {
+		return encryption.BlindIndexField + "." + field + ":" + index
+	}
+	value := person.Username
+	if field == "email" {
+		value = person.Email
+	}
+	return field + ":" + strings.ToLower(value)
+}
+
+// existing will return the people of ctx tenant that have the username or email of people, field. they
+// are keyed by importKey.
+func (people *People) existing(ctx context.Context, personList []*model.Person, field string) (map[string]*model.Person, error) {
+	values := make(map[string][]interface{})
+	for _, person := range personList {
+		name, value := field, interface{}(person.Username)
+		if field == "email" {
+			value = person.Email
+		}
+		if index := person.BlindIndex[field]; index != "" {
+			name, value = encryption.BlindIndexField+"."+field, index
+		}
+		values[name] = append(values[name], value)
+	}
+	result := make(map[string]*model.Person)
+	for name, list := range values {
+		findOptions := options.Find().SetProjection(bson.M{"username": 1, "email": 1, encryption.BlindIndexField: 1})
+		if name == field {
+			findOptions.SetCollation(db.CaseInsensitive)
+		}
+		curser, err := people.collection(ctx).Find(ctx, tenant.Scope(ctx, bson.M{name: bson.M{"$in": list}}), findOptions)
+		if err != nil {
+			return nil, err
+		}
+		var found []*model.Person
+		if err := curser.All(ctx, &found); err != nil {
+			return nil, err
+		}
+		for _, person := range found {
+			if name == field {
+				// the blind index of a person that is found by its plaintext field is not its key.
+				person.BlindIndex = nil
+			}
+			result[importKey(person, field)] = person
+		}
+	}
+	return result, nil
+}
+
+// collisions will fail the results of people whose email another person of ctx tenant already has.
+func (people *People) collisions(ctx context.Context, personList []*model.Person, results []ImportResult) error {
+	owners, err := people.existing(ctx, personList, "email")
+	if err != nil {
+		return err
+	}
+	for i, person := range personList {
+		if owner, ok := owners[importKey(person, "email")]; ok && owner.ID != person.ID {
+			results[i].Err = &DuplicateError{Field: "email"}
+		}
+	}
+	return nil
+}
+
+// DeleteAll will remove all people of ctx tenant and return their number.
+func (people *People) DeleteAll(ctx context.Context) (int64, error) {
+	result, err := people.collection(ctx).DeleteMany(ctx, tenant.Filter(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// Get will return the person of id, ErrNotFound if there is none.
+func (people *People) Get(ctx context.Context, id primitive.ObjectID) (*model.Person, error) {
+	person := new(model.Person)
+	err := people.collection(ctx).FindOne(ctx, tenant.Scope(ctx, bson.M{"_id": id})).Decode(person)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return person, nil
+}
+
+// List will return a zero based page of people, newest first.
+func (people *People) List(ctx context.Context, page int64) ([]model.Person, error) {
+	if page < 0 {
+		page = 0
+	}
+	return people.Find(ctx, Filter{}, page*PageSize, PageSize)
+}
+
+// Filter selects the people of Find, empty fields are not used. username and email are compared case
+// insensitive like their unique indexes.
+type Filter struct {
+	IDs        []primitive.ObjectID
+	FirstName  string
+	LastName   string
+	Username   string
+	Email      string
+	BlindIndex map[string]string // blind indexes of encrypted fields by field name, they are compared as they are.
+}
+
+func (filter Filter) query() bson.M {
+	query := bson.M{}
+	if len(filter.IDs) > 0 {
+		query["_id"] = bson.M{"$in": filter.IDs}
+	}
+	for field, value := range map[string]string{
+		"first_name": filter.FirstName,
+		"last_name":  filter.LastName,
+		"username":   strings.TrimSpace(filter.Username),
+		"email":      strings.TrimSpace(filter.Email),
+	} {
+		if value != "" {
+			query[field] = value
+		}
+	}
+	for field, index := range filter.BlindIndex {
+		query[encryption.BlindIndexField+"."+field] = index
+	}
+	return query
+}
+
+// Find will return the people that match filter, newest first. skip people are skipped and at most
+// limit people are returned.
+func (people *People) Find(ctx context.Context, filter Filter, skip, limit int64) ([]model.Person, error) {
+	findOptions := options.FindOptions{
+		Skip:  &skip,
+		Limit: &limit,
+		Sort: bson.M{
+			"_id": -1, // -1 for descending and 1 for ascending
+		},
+	}
+	if filter.Username != "" || filter.Email != "" {
+		// other queries keep the simple collation, so the _id index can sort them.
+		findOptions.Collation = db.CaseInsensitive
+	}
+	curser, err := people.collection(ctx).Find(ctx, tenant.Scope(ctx, filter.query()), &findOptions)
+	if err != nil {
+		return nil, err
+	}
+	var personList []model.Person
+	if err := curser.All(ctx, &personList); err != nil {
+		return nil, err
+	}
+	return personList, nil
+}
+
+// GetMany will return the people of ids with one query, ids that have no person are not in result.
+func (people *People) GetMany(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*model.Person, error) {
+	curser, err := people.collection(ctx).Find(ctx, tenant.Scope(ctx, bson.M{"_id": bson.M{"$in": ids}}))
+	if err != nil {
+		return nil, err
+	}
+	var personList []model.Person
+	if err := curser.All(ctx, &personList); err != nil {
+		return nil, err
+	}
+	result := make(map[primitive.ObjectID]*model.Person, len(personList))
+	for i := range personList {
+		result[personList[i].ID] = &personList[i]
+	}
+	return result, nil
+}
+
+// Update will set fields on the person of id, username and email are trimmed first.
+// fields are the bson names of person fields, the tenant of person can not be changed and _id is not
+// valid.
+func (people *People) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	delete(fields, tenant.Field)
+	if _, ok := fields["_id"]; ok {
+		return errIDUpdate
+	}
+	for _, field := range []string{"username", "email"} {
+		if value, ok := fields[field].(string); ok {
+			fields[field] = strings.TrimSpace(value)
+		}
+	}
+	result, err := people.collection(ctx).UpdateOne(ctx, tenant.Scope(ctx, bson.M{"_id": id}), bson.M{"$set": fields})
+	if err != nil {
+		return writeError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete will remove the person of id.
+func (people *People) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := people.collection(ctx).DeleteOne(ctx, tenant.Scope(ctx, bson.M{"_id": id}))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// event types of Watch
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// Event is a change of people collection. Person is nil for deleted people.
+type Event struct {
+	Type   string
+	ID     primitive.ObjectID
+	Person *model.Person
+}
+
+// namespace is the database and collection of a change stream document.
+type namespace struct {
+	Namespace struct {
+		Database   string `bson:"db"`
+		Collection string `bson:"coll"`
+	} `bson:"ns"`
+}
+
+// changeEvent is the part of change stream documents that Watch reads.
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument *model.Person `bson:"fullDocument"`
+}
+
+// Watch will send the changes of people of ctx tenant to events until ctx is done or the change stream
+// fails. change streams need a replica set or sharded cluster. events is not closed.
+// delete events have no document, so when tenants share the collection the delete events of all
+// tenants are sent, they only have the id of deleted person. all tenants of database mode are watched
+// with one change stream of the databases of tenants, it watches the tenant registry too, so only the
+// registered tenants are sent and new tenants are added.
+func (people *People) Watch(ctx context.Context, events chan<- Event) error {
+	match := bson.M{
+		"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}},
+	}
+	if id := tenant.SharedID(ctx); id != "" {
+		match["$or"] = bson.A{
+			bson.M{"fullDocument." + tenant.Field: id},
+			bson.M{"operationType": "delete"},
+		}
+	}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	var stream *mongo.ChangeStream
+	var err error
+	var tenants map[string]bool // databases of registered tenants, it is nil if one collection is watched.
+	if current, ok := tenant.FromContext(ctx); ok && current.Mode == tenant.ModeDatabase && current.All() {
+		match["$or"] = bson.A{
+			bson.M{"ns.coll": "people", "ns.db": bson.M{"$regex": "^" + regexp.QuoteMeta(tenant.DatabaseName(people.database.Name(), ""))}},
+			bson.M{"ns.coll": tenant.Registry, "ns.db": people.database.Name(), "operationType": "insert"},
+		}
+		// the stream is opened before the registry is read, so tenants that are registered in between are not missed.
+		stream, err = people.database.Client().Watch(ctx, mongo.Pipeline{{{Key: "$match", Value: match}}}, opts)
+		if err == nil {
+			var names []string
+			names, err = tenant.Databases(ctx, people.database)
+			tenants = make(map[string]bool, len(names))
+			for _, name := range names {
+				tenants[name] = true
+			}
+			if err != nil {
+				stream.Close(context.Background())
+			}
+		}
+	} else {
+		stream, err = people.collection(ctx).Watch(ctx, mongo.Pipeline{{{Key: "$match", Value: match}}}, opts)
+	}
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+	for stream.Next(ctx) {
+		if tenants != nil {
+			var ns namespace
+			if err := stream.Decode(&ns); err != nil {
+				return err
+			}
+			if ns.Namespace.Collection == tenant.Registry {
+				var registered struct {
+					DocumentKey struct {
+						ID string `bson:"_id"`
+					} `bson:"documentKey"`
+				}
+				if err := stream.Decode(&registered); err != nil {
+					return err
+				}
+				tenants[tenant.DatabaseName(people.database.Name(), registered.DocumentKey.ID)] = true
+				continue
+			}
+			if !tenants[ns.Namespace.Database] {
+				continue // a database that has the prefix of tenants but is not a tenant.
+			}
+		}
+		var change changeEvent
+		if err := stream.Decode(&change); err != nil {
+			return err
+		}
+		event := Event{Type: EventUpdated, ID: change.DocumentKey.ID, Person: change.FullDocument}
+		switch change.OperationType {
+		case "insert":
+			event.Type = EventCreated
+		case "delete":
+			event.Type, event.Person = EventDeleted, nil
+		}
+		if event.Type == EventUpdated && event.Person == nil {
+			continue // deleted before the update is looked up, its delete event follows.
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return stream.Err()
+}
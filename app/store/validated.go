@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FieldError is the problem of a field of a write, Field is the bson name of field and it is empty for
+// problems of the whole write.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError is a write of people that is not valid, it has the problem of every field.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (err *ValidationError) Error() string {
+	return "person is not valid: " + err.Fields[0].Message
+}
+
+// errIDUpdate is an update of _id, stores return it because the id of person never changes.
+var errIDUpdate = &ValidationError{Fields: []FieldError{{Field: "_id", Message: "_id can not be updated"}}}
+
+// ValidatePerson will check the fields of a new person.
+func ValidatePerson(person *model.Person) error {
+	var fields []FieldError
+	for _, field := range []string{"username", "email"} {
+		if err := person.ValidateFields(field); err != nil {
+			fields = append(fields, FieldError{Field: field, Message: err.Error()})
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// ValidateUpdate will check the fields of an update, they are the bson names of person fields and the
+// dotted keys of data. other fields like _id and the fields that stores keep are not updated by writes.
+func ValidateUpdate(fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return &ValidationError{Fields: []FieldError{{Message: "no field is updated"}}}
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var problems []FieldError
+	for _, name := range names {
+		if message := validateField(name, fields[name]); message != "" {
+			problems = append(problems, FieldError{Field: name, Message: message})
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Fields: problems}
+	}
+	return nil
+}
+
+// validateField will return the problem of the update of field, it is empty if it is valid.
+func validateField(field string, value interface{}) string {
+	switch field {
+	case "username", "email", "first_name", "last_name":
+		text, ok := value.(string)
+		if !ok {
+			return field + " must be a string"
+		}
+		person := model.Person{Username: text, Email: text}
+		if err := person.ValidateFields(field); err != nil {
+			return err.Error()
+		}
+	case "data":
+		switch value.(type) {
+		case map[string]interface{}, primitive.M:
+		default:
+			return "data must be an object"
+		}
+	default:
+		if key := strings.TrimPrefix(field, "data."); key == field || key == "" {
+			return fmt.Sprintf("%s can not be updated", field)
+		}
+	}
+	return ""
+}
+
+// Validated validates the writes of people before they are passed to a PeopleStore. it is the store of
+// handlers, grpc and graphql, so their writes have the same rules. internal writes like re-encryption
+// use the stores that it wraps.
+type Validated struct {
+	people PeopleStore
+}
+
+var _ PeopleStore = (*Validated)(nil)
+
+// NewValidated is the Validated struct factory function.
+func NewValidated(people PeopleStore) *Validated {
+	return &Validated{people: people}
+}
+
+// Create will insert person if it is valid.
+func (validated *Validated) Create(ctx context.Context, person *model.Person) error {
+	if err := ValidatePerson(person); err != nil {
+		return err
+	}
+	return validated.people.Create(ctx, person)
+}
+
+// InsertMany will insert people if all of them are valid.
+func (validated *Validated) InsertMany(ctx context.Context, personList []*model.Person) (inserted, skipped int, err error) {
+	for _, person := range personList {
+		if err := ValidatePerson(person); err != nil {
+			return 0, 0, err
+		}
+	}
+	return validated.people.InsertMany(ctx, personList)
+}
+
+// ImportMany will write the valid people, the results of the others have their ValidationError.
+func (validated *Validated) ImportMany(ctx context.Context, personList []*model.Person, dryRun bool) ([]ImportResult, error) {
+	results := make([]ImportResult, len(personList))
+	valid := make([]*model.Person, 0, len(personList))
+	for i, person := range personList {
+		if results[i].Err = ValidatePerson(person); results[i].Err == nil {
+			valid = append(valid, person)
+		}
+	}
+	written, err := validated.people.ImportMany(ctx, valid, dryRun)
+	for i, j := 0, 0; i < len(results) && j < len(written); i++ {
+		if results[i].Err == nil {
+			results[i] = written[j]
+			j++
+		}
+	}
+	return results, err
+}
+
+// DeleteAll will remove all people from store.
+func (validated *Validated) DeleteAll(ctx context.Context) (int64, error) {
+	return validated.people.DeleteAll(ctx)
+}
+
+// Get will return the person of id from store.
+func (validated *Validated) Get(ctx context.Context, id primitive.ObjectID) (*model.Person, error) {
+	return validated.people.Get(ctx, id)
+}
+
+// List will return a page of people from store.
+func (validated *Validated) List(ctx context.Context, page int64) ([]model.Person, error) {
+	return validated.people.List(ctx, page)
+}
+
+// Find will return the people of filter from store.
+func (validated *Validated) Find(ctx context.Context, filter Filter, skip, limit int64) ([]model.Person, error) {
+	return validated.people.Find(ctx, filter, skip, limit)
+}
+
+// GetMany will return the people of ids from store.
+func (validated *Validated) GetMany(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*model.Person, error) {
+	return validated.people.GetMany(ctx, ids)
+}
+
+// Update will set fields on the person of id if they are valid.
+func (validated *Validated) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	if err := ValidateUpdate(fields); err != nil {
+		return err
+	}
+	return validated.people.Update(ctx, id, fields)
+}
+
+// Delete will remove the person of id from store.
+func (validated *Validated) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return validated.people.Delete(ctx, id)
+}
+
+// Watch will send the changes of store to events.
+func (validated *Validated) Watch(ctx context.Context, events chan<- Event) error {
+	return validated.people.Watch(ctx, events)
+}
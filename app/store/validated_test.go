@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+)
+
+func TestValidatedWrites(t *testing.T) {
+	ctx := context.Background()
+	john := model.NewPerson("john", "doe", "john", "john@example.com", nil)
+	memory, _ := NewMemory(john)
+	people := NewValidated(memory)
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		want   []string
+	}{
+		{"empty", map[string]interface{}{}, []string{""}},
+		{"email", map[string]interface{}{"email": "garbage"}, []string{"email"}},
+		{"username", map[string]interface{}{"username": " "}, []string{"username"}},
+		{"types", map[string]interface{}{"first_name": 1, "data": "x"}, []string{"data", "first_name"}},
+		{"internal", map[string]interface{}{"_id": john.ID, "blind_index": map[string]interface{}{}, "tenant_id": "a", "data.": 1}, []string{"_id", "blind_index", "data.", "tenant_id"}},
+	}
+	for _, test := range tests {
+		err := people.Update(ctx, john.ID, test.fields)
+		var invalid *ValidationError
+		if !errors.As(err, &invalid) {
+			t.Errorf("%s %s update must be rejected: %v", failed, test.name, err)
+			continue
+		}
+		var fields []string
+		for _, field := range invalid.Fields {
+			fields = append(fields, field.Field)
+		}
+		if !reflect.DeepEqual(fields, test.want) {
+			t.Errorf("%s %s fields are %v, want %v", failed, test.name, fields, test.want)
+		}
+	}
+	if err := people.Update(ctx, john.ID, map[string]interface{}{"email": "johnny@example.com", "data.age": 30, "last_name": ""}); err != nil {
+		t.Fatalf("%s valid update returned error: %v", failed, err)
+	}
+	if err := people.Create(ctx, model.NewPerson("", "", "", "jane", nil)); !errors.As(err, new(*ValidationError)) {
+		t.Fatalf("%s person without username and a valid email must be rejected: %v", failed, err)
+	}
+	if err := memory.Update(ctx, john.ID, map[string]interface{}{"_id": john.ID}); !errors.As(err, new(*ValidationError)) {
+		t.Fatalf("%s stores must not update _id: %v", failed, err)
+	}
+	t.Logf("%s Testing validated writes is successful", succeed)
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/openapi"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+	"github.com/katoozi/golang-mongodb-rest-api/config"
+)
+
+// untenantedPaths are the routes that are served without a tenant, they read no data of tenants.
+var untenantedPaths = map[string]bool{
+	"/openapi.json":    true,
+	"/docs":            true,
+	"/problems/{code}": true,
+	"/metrics":         true,
+}
+
+// IndexSpecs will return the index specs of config, unique fields are unique per tenant when tenants
+// share the collections.
+func IndexSpecs(config *config.Config) []db.Index {
+	if config != nil && tenant.Mode(config.Tenancy) == tenant.ModeShared {
+		return db.TenantIndexes(db.Indexes)
+	}
+	return db.Indexes
+}
+
+// tenancy will return the mode of tenancy, it is empty if multi-tenancy is disabled.
+func (app *App) tenancy() tenant.Mode {
+	if app.config == nil {
+		return ""
+	}
+	return tenant.Mode(app.config.Tenancy)
+}
+
+// tenantResolver will return the resolver of tenant_source option.
+func (app *App) tenantResolver() tenant.Resolver {
+	switch app.config.TenantSource {
+	case "subdomain":
+		return tenant.Subdomain(app.config.TenantDomain)
+	case "jwt":
+		return tenant.JWTClaim(app.config.TenantJWTClaim, []byte(app.config.TenantJWTKey))
+	}
+	return tenant.Header(app.config.TenantHeader)
+}
+
+// tenantMiddleware will resolve the tenant of requests and keep it in their context, stores and handlers
+// scope their queries by it. requests without a valid tenant are rejected, routes of untenantedPaths are
+// served without a tenant. it does nothing if multi-tenancy is disabled.
+func (app *App) tenantMiddleware(next http.Handler) http.Handler {
+	mode := app.tenancy()
+	if mode == "" {
+		return next
+	}
+	resolve := app.tenantResolver()
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		route := mux.CurrentRoute(req)
+		if route == nil {
+			next.ServeHTTP(res, req)
+			return
+		}
+		template, _ := route.GetPathTemplate()
+		if path, _, _ := routeVersion(req, template); untenantedPaths[path] {
+			next.ServeHTTP(res, req)
+			return
+		}
+		id, err := resolve(req)
+		if err != nil {
+			tenantErrorResponse(res, req, err)
+			return
+		}
+		ctx := tenant.NewContext(req.Context(), tenant.Tenant{ID: id, Mode: mode})
+		next.ServeHTTP(res, req.WithContext(ctx))
+	})
+}
+
+// tenantErrorResponse will write the error of a tenant that can not be resolved.
+func tenantErrorResponse(res http.ResponseWriter, req *http.Request, err error) {
+	switch {
+	case errors.Is(err, tenant.ErrUnauthorized):
+		res.Header().Set("WWW-Authenticate", "Bearer")
+		handler.ErrorResponse(res, req, handler.CodeUnauthorized, "bearer token is not valid.", nil)
+	case errors.Is(err, tenant.ErrInvalid):
+		handler.ErrorResponse(res, req, handler.CodeInvalidTenant, "tenant must be 1 to 32 letters, digits and dashes.", nil)
+	default:
+		handler.ErrorResponse(res, req, handler.CodeInvalidTenant, "tenant of request is missing.", nil)
+	}
+}
+
+// tenantOperation will add the tenant errors and the tenant header of tenant_source to operation of path.
+func (app *App) tenantOperation(path string, operation openapi.Operation) openapi.Operation {
+	if app.tenancy() == "" || untenantedPaths[path] {
+		return operation
+	}
+	codes := []handler.ErrorCode{handler.CodeInvalidTenant}
+	switch app.config.TenantSource {
+	case "jwt":
+		codes = append(codes, handler.CodeUnauthorized)
+	case "header", "":
+		operation.Parameters = append(append([]openapi.Parameter(nil), operation.Parameters...), openapi.Parameter{
+			Name:        app.config.TenantHeader,
+			In:          "header",
+			Description: "tenant of request, requests without it are rejected with invalid_tenant",
+			Schema:      &openapi.Schema{Type: "string", Pattern: "^[a-zA-Z0-9][a-zA-Z0-9-]{0,31}$"},
+		})
+	}
+	merged := make(map[int]openapi.Response, len(operation.Responses)+len(codes))
+	for status, response := range operation.Responses {
+		merged[status] = response
+	}
+	for _, code := range codes {
+		title := code.Title + " (" + code.Code + ")"
+		switch response, ok := merged[code.Status]; {
+		case !ok:
+			merged[code.Status] = openapi.Response{Description: title, Error: true}
+		case response.Error:
+			response.Description += ", " + title
+			merged[code.Status] = response
+		}
+	}
+	operation.Responses = merged
+	return operation
+}
@@ -0,0 +1,119 @@
+package tenant
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Resolver will return the tenant id of a request, errors are ErrMissing, ErrInvalid or ErrUnauthorized.
+// ids are parsed with ParseID.
+type Resolver func(req *http.Request) (string, error)
+
+// Header will resolve the tenant from header name, like X-Tenant-ID.
+func Header(name string) Resolver {
+	return func(req *http.Request) (string, error) {
+		return ParseID(req.Header.Get(name))
+	}
+}
+
+// Subdomain will resolve the tenant from the subdomain of domain in Host header, like acme of
+// acme.api.example.com for api.example.com.
+func Subdomain(domain string) Resolver {
+	suffix := "." + strings.ToLower(strings.Trim(domain, "."))
+	return func(req *http.Request) (string, error) {
+		host := strings.ToLower(req.Host)
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		if !strings.HasSuffix(host, suffix) {
+			return "", ErrMissing
+		}
+		subdomain := strings.TrimSuffix(host, suffix)
+		if strings.Contains(subdomain, ".") {
+			return "", ErrInvalid
+		}
+		return ParseID(subdomain)
+	}
+}
+
+// jwtHeader is the part of jwt header that JWTClaim reads.
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+}
+
+// JWTClaim will resolve the tenant from claim of the HS256 jwt of Authorization bearer token. tokens
+// with a wrong signature, other algorithms or out of their exp and nbf times are ErrUnauthorized.
+func JWTClaim(claim string, secret []byte) Resolver {
+	return func(req *http.Request) (string, error) {
+		authorization := req.Header.Get("Authorization")
+		if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "bearer ") {
+			return "", ErrMissing
+		}
+		claims, err := verifyJWT(strings.TrimSpace(authorization[7:]), secret, time.Now())
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrUnauthorized, err)
+		}
+		id, _ := claims[claim].(string)
+		return ParseID(id)
+	}
+}
+
+// verifyJWT will check the signature and times of token and return its claims.
+func verifyJWT(token string, secret []byte, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token is not a jwt")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Algorithm != "HS256" {
+		return nil, fmt.Errorf("algorithm %q is not supported", header.Algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature is not base64url")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, fmt.Errorf("signature is wrong")
+	}
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if exp, ok := claims["exp"].(json.Number); ok {
+		if seconds, err := exp.Int64(); err != nil || !now.Before(time.Unix(seconds, 0)) {
+			return nil, fmt.Errorf("token is expired")
+		}
+	}
+	if nbf, ok := claims["nbf"].(json.Number); ok {
+		if seconds, err := nbf.Int64(); err != nil || now.Before(time.Unix(seconds, 0)) {
+			return nil, fmt.Errorf("token is not valid yet")
+		}
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	content, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("token is not base64url")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("token is not json")
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package tenant keeps the tenant of a request in its context and scopes the mongo db access of stores
+// and handlers by it. in shared mode tenants are in the same collections and documents have a tenant_id
+// field, in database mode every tenant has its own database.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Mode is how the data of tenants is kept apart.
+type Mode string
+
+// modes of tenancy
+const (
+	ModeShared   Mode = "shared"   // one collection per type, documents have tenant_id field
+	ModeDatabase Mode = "database" // one database per tenant, <database>_<tenant>
+)
+
+// Field is the tenant field of documents in shared mode.
+const Field = db.TenantField
+
+// Tenant is the owner of the data of a request. a tenant without id is all tenants, it is only used by
+// background work like cache invalidation.
+type Tenant struct {
+	ID   string
+	Mode Mode
+}
+
+// All will return true if tenant is all tenants of its mode.
+func (tenant Tenant) All() bool {
+	return tenant.ID == ""
+}
+
+// errors of tenant ids
+var (
+	ErrMissing      = errors.New("tenant is missing")
+	ErrInvalid      = errors.New("tenant is not valid")
+	ErrUnauthorized = errors.New("credentials are not valid")
+)
+
+// idPattern is the format of tenant ids, they are part of database names in database mode.
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// ParseID will return the lower case form of id, ErrInvalid if it is not 1 to 32 letters, digits and dashes.
+func ParseID(id string) (string, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" {
+		return "", ErrMissing
+	}
+	if !idPattern.MatchString(id) {
+		return "", ErrInvalid
+	}
+	return id, nil
+}
+
+type contextKey struct{}
+
+// NewContext will return a copy of ctx that has tenant.
+func NewContext(ctx context.Context, tenant Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenant)
+}
+
+// FromContext will return the tenant of ctx, ok is false if tenancy is disabled or ctx is not of a request.
+func FromContext(ctx context.Context) (tenant Tenant, ok bool) {
+	tenant, ok = ctx.Value(contextKey{}).(Tenant)
+	return tenant, ok
+}
+
+// Filter will return the query of the documents of ctx tenant in shared mode, it is empty otherwise.
+// queries must add it to their filters.
+func Filter(ctx context.Context) bson.M {
+	if tenant, ok := FromContext(ctx); ok && tenant.Mode == ModeShared && !tenant.All() {
+		return bson.M{Field: tenant.ID}
+	}
+	return bson.M{}
+}
+
+// Scope will add the filter of ctx tenant to query and return it.
+func Scope(ctx context.Context, query bson.M) bson.M {
+	for key, value := range Filter(ctx) {
+		query[key] = value
+	}
+	return query
+}
+
+// SharedID will return the id of ctx tenant in shared mode, inserted documents must have it in Field.
+func SharedID(ctx context.Context) string {
+	if tenant, ok := FromContext(ctx); ok && tenant.Mode == ModeShared {
+		return tenant.ID
+	}
+	return ""
+}
+
+// DatabaseName will return the database name of tenant id in database mode.
+func DatabaseName(base, id string) string {
+	return base + "_" + id
+}
+
+// Registry is the collection of base database that has the ids of the tenants of database mode, other
+// databases that have the prefix of tenant databases are not tenants.
+const Registry = "tenants"
+
+// Databases will return the names of the tenant databases of database that are in the registry.
+func Databases(ctx context.Context, database *mongo.Database) ([]string, error) {
+	ids, err := database.Collection(Registry).Distinct(ctx, "_id", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id, ok := id.(string); ok {
+			names = append(names, DatabaseName(database.Name(), id))
+		}
+	}
+	return names, nil
+}
+
+// register will add the tenant of id to the registry of database.
+func register(ctx context.Context, database *mongo.Database, id string) error {
+	_, err := database.Collection(Registry).InsertOne(ctx, bson.M{"_id": id})
+	if db.IsDuplicateKey(err) {
+		return nil
+	}
+	return err
+}
+
+// indexed are the tenant databases that are registered and have the indexes of db.Indexes.
+var indexed sync.Map
+
+// Database will return the database of ctx tenant in database mode and database otherwise. tenants are
+// registered and the indexes of their databases are created the first time they are used.
+func Database(ctx context.Context, database *mongo.Database) *mongo.Database {
+	tenant, ok := FromContext(ctx)
+	if !ok || tenant.Mode != ModeDatabase || tenant.All() {
+		return database
+	}
+	tenantDatabase := database.Client().Database(DatabaseName(database.Name(), tenant.ID))
+	if _, ok := indexed.Load(tenantDatabase.Name()); !ok {
+		err := register(ctx, database, tenant.ID)
+		if err == nil {
+			var plan db.Plan
+			if plan, err = db.PlanIndexes(ctx, tenantDatabase, db.Indexes); err == nil {
+				err = plan.Apply(ctx, tenantDatabase, false)
+			}
+		}
+		if err != nil {
+			// the next request of tenant tries to create them again.
+			log.Printf("Error while registering tenant %s and creating its indexes: %v\n", tenant.ID, err)
+		} else {
+			indexed.Store(tenantDatabase.Name(), true)
+		}
+	}
+	return tenantDatabase
+}
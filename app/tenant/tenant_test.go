@@ -0,0 +1,94 @@
+package tenant
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+// signJWT will return a HS256 jwt of claims that is signed with secret.
+func signJWT(claims string, secret []byte) string {
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestResolvers(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://acme.api.example.com:1234/person", nil)
+	req.Header.Set("X-Tenant-ID", " Acme ")
+	if id, err := Header("X-Tenant-ID")(req); id != "acme" || err != nil {
+		t.Errorf("%s header tenant is wrong: got %q %v want %q", failed, id, err, "acme")
+	}
+	if id, err := Subdomain("api.example.com")(req); id != "acme" || err != nil {
+		t.Errorf("%s subdomain tenant is wrong: got %q %v want %q", failed, id, err, "acme")
+	}
+	req.Host = "a.b.api.example.com"
+	if _, err := Subdomain("api.example.com")(req); err != ErrInvalid {
+		t.Errorf("%s nested subdomains must be invalid: got %v", failed, err)
+	}
+	req.Header.Set("X-Tenant-ID", "../admin")
+	if _, err := Header("X-Tenant-ID")(req); err != ErrInvalid {
+		t.Errorf("%s tenants must be letters, digits and dashes: got %v", failed, err)
+	}
+	if _, err := Header("X-Other")(req); err != ErrMissing {
+		t.Errorf("%s requests without header must miss their tenant: got %v", failed, err)
+	}
+	t.Logf("%s Testing header and subdomain resolvers is successful", succeed)
+}
+
+func TestJWTClaim(t *testing.T) {
+	secret := []byte("secret")
+	resolve := JWTClaim("tenant", secret)
+	expires := time.Now().Add(time.Hour).Unix()
+	for _, test := range []struct {
+		name  string
+		token string
+		id    string
+		err   error
+	}{
+		{"valid", signJWT(`{"tenant":"acme","exp":`+strconv.FormatInt(expires, 10)+`}`, secret), "acme", nil},
+		{"wrong secret", signJWT(`{"tenant":"acme"}`, []byte("other")), "", ErrUnauthorized},
+		{"expired", signJWT(`{"tenant":"acme","exp":1}`, secret), "", ErrUnauthorized},
+		{"without claim", signJWT(`{"sub":"john"}`, secret), "", ErrMissing},
+		{"not a jwt", "token", "", ErrUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/person", nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		id, err := resolve(req)
+		if id != test.id || !errors.Is(err, test.err) {
+			t.Errorf("%s %s token is resolved to %q %v, want %q %v", failed, test.name, id, err, test.id, test.err)
+		}
+	}
+	if _, err := resolve(httptest.NewRequest("GET", "/person", nil)); err != ErrMissing {
+		t.Errorf("%s requests without token must miss their tenant: got %v", failed, err)
+	}
+	t.Logf("%s Testing jwt claim resolver is successful", succeed)
+}
+
+func TestFilter(t *testing.T) {
+	ctx := context.Background()
+	if filter := Filter(ctx); len(filter) != 0 {
+		t.Errorf("%s contexts without tenant must have no filter: got %v", failed, filter)
+	}
+	shared := NewContext(ctx, Tenant{ID: "acme", Mode: ModeShared})
+	if filter := Filter(shared); filter[Field] != "acme" {
+		t.Errorf("%s shared tenants must be filtered by their field: got %v", failed, filter)
+	}
+	if filter := Filter(NewContext(ctx, Tenant{ID: "acme", Mode: ModeDatabase})); len(filter) != 0 {
+		t.Errorf("%s tenants of database mode must have no filter: got %v", failed, filter)
+	}
+	if filter := Filter(NewContext(ctx, Tenant{Mode: ModeShared})); len(filter) != 0 {
+		t.Errorf("%s all tenants must have no filter: got %v", failed, filter)
+	}
+	t.Logf("%s Testing tenant filter is successful", succeed)
+}
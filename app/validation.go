@@ -0,0 +1,105 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/katoozi/golang-mongodb-rest-api/app/codec"
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/openapi"
+)
+
+// validationMiddleware will reject the requests whose parameters or body do not match the operation of
+// their route in the document of request version. in development mode the responses of
+// handler.ResponseWriter are validated too and contract violations are logged.
+func (app *App) validationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		route := mux.CurrentRoute(req)
+		if route == nil || app.validators == nil {
+			next.ServeHTTP(res, req)
+			return
+		}
+		template, _ := route.GetPathTemplate()
+		path, version, ok := routeVersion(req, template)
+		if !ok {
+			next.ServeHTTP(res, req) // unknown versions are rejected by route
+			return
+		}
+		validator := app.validators[version.Name]
+		operation, ok := validator.Operation(req.Method, path)
+		if !ok {
+			next.ServeHTTP(res, req)
+			return
+		}
+		errs := validator.Parameters(operation, mux.Vars(req), req.URL.Query())
+		errs = append(errs, validateBody(validator, operation, req)...)
+		if len(errs) > 0 {
+			invalidRequestResponse(res, req, errs)
+			return
+		}
+		if app.config != nil && app.config.Development {
+			res = handler.WithResponseValidator(res, func(version *handler.Version, statusCode int, contentType string, body interface{}) {
+				validator := app.validators[version.Name]
+				operation, ok := validator.Operation(req.Method, path)
+				if !ok {
+					return
+				}
+				mediaType, _, _ := mime.ParseMediaType(contentType)
+				if vendorCodec, ok := codec.Lookup(mediaType); ok && strings.HasPrefix(mediaType, "application/vnd.") {
+					// documents describe the media types of codecs, not the media types of versions.
+					mediaType, _, _ = mime.ParseMediaType(vendorCodec.ContentType())
+				}
+				for _, err := range validator.Response(operation, statusCode, mediaType, body) {
+					log.Printf("Contract violation: %s %s %s responded %d: %v\n", version.Name, req.Method, path, statusCode, err)
+				}
+			})
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// validateBody will decode and validate the request body, then it is restored for handler. bodies that
+// can not be decoded are left for handler, it responds with its own error.
+func validateBody(validator *openapi.Validator, operation *openapi.OperationObject, req *http.Request) []openapi.ValidationError {
+	if operation.RequestBody == nil {
+		return nil
+	}
+	bodyCodec, err := codec.ForContentType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil // multipart forms and unsupported media types
+	}
+	content, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(content))
+	if err != nil {
+		return []openapi.ValidationError{{Field: "body", Message: "could not be read: " + err.Error()}}
+	}
+	mediaType, _, _ := mime.ParseMediaType(bodyCodec.ContentType())
+	if len(bytes.TrimSpace(content)) == 0 {
+		return validator.Body(operation, mediaType, nil, false)
+	}
+	var body interface{}
+	if err := bodyCodec.Decode(bytes.NewReader(content), &body); err != nil {
+		return nil
+	}
+	return validator.Body(operation, mediaType, body, !codec.Typed(bodyCodec))
+}
+
+// invalidRequestResponse will write the validation errors of a request.
+func invalidRequestResponse(res http.ResponseWriter, req *http.Request, errs []openapi.ValidationError) {
+	detail := "request is not valid: " + errs[0].Error()
+	if len(errs) > 1 {
+		detail += fmt.Sprintf(" and %d more problems", len(errs)-1)
+	}
+	fields := make([]handler.FieldError, len(errs))
+	for i, err := range errs {
+		fields[i] = handler.FieldError{Field: err.Field, Message: err.Message}
+	}
+	handler.ErrorResponse(res, req, handler.CodeInvalidRequest, detail, fields, fields...)
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/openapi"
+)
+
+// envelopes are the response schemas of api versions, content is the schema of response content.
+var envelopes = map[string]func(content *openapi.Schema) *openapi.Schema{
+	handler.V1.Name: func(content *openapi.Schema) *openapi.Schema {
+		return &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"status":  {Type: "integer"},
+				"message": {Type: "string"},
+				"content": content,
+			},
+			Required: []string{"status", "message", "content"},
+		}
+	},
+	handler.V2.Name: func(content *openapi.Schema) *openapi.Schema {
+		return &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"message": {Type: "string"},
+				"content": content,
+			},
+			Required: []string{"content"},
+		}
+	},
+}
+
+// setVersionDates will set the deprecation and sunset dates of api v1 from config, versions are
+// shared by all apps of process. config is validated before, so its dates are valid.
+func (app *App) setVersionDates() {
+	handler.V1.Deprecation, handler.V1.Sunset = time.Time{}, time.Time{}
+	if app.config != nil {
+		handler.V1.Deprecation, handler.V1.Sunset, _ = app.config.V1Dates()
+	}
+}
+
+// setVersionRouters will create the /<version> subrouter of every api version. routes of App.Router
+// pick their version from Accept header, routes of subrouters have the version of their prefix.
+func (app *App) setVersionRouters() {
+	app.versionRouters = make([]*mux.Router, len(handler.Versions))
+	for i, version := range handler.Versions {
+		router := app.Router.PathPrefix("/" + version.Name).Subrouter()
+		router.Use(version.Middleware)
+		app.versionRouters[i] = router
+	}
+}
+
+// handle will register endpoint on App.Router and the subrouters of versions and add its operation to openapi spec.
+func (app *App) handle(method, path string, endpoint http.HandlerFunc, operation openapi.Operation, queries []string) {
+	app.Router.Handle(path, handler.Versions.Middleware(endpoint)).Methods(method).Queries(queries...)
+	for _, router := range app.versionRouters {
+		router.HandleFunc(path, endpoint).Methods(method).Queries(queries...)
+	}
+	app.Spec.Add(method, path, app.tenantOperation(path, operation), queries...)
+	for status, response := range operation.Responses {
+		if status >= 200 && status < 300 && response.Raw {
+			app.ownFormats[method+" "+path] = true
+		}
+	}
+}
+
+// setSpecs will create the openapi document and validator of every api version from App.Spec,
+// the default version is served on / too.
+func (app *App) setSpecs() {
+	app.specs = make(map[string]*openapi.Spec)
+	app.validators = make(map[string]*openapi.Validator)
+	for i, version := range handler.Versions {
+		spec := app.Spec.Copy()
+		spec.Envelope = envelopes[version.Name]
+		spec.Servers = []string{"/" + version.Name}
+		if i == 0 {
+			spec.Servers = append(spec.Servers, "/")
+		}
+		spec.Deprecated = version.Deprecated()
+		spec.JSON()
+		app.specs[version.Name] = spec
+		app.validators[version.Name] = spec.Validator()
+	}
+}
+
+// openAPIHandler will serve the openapi document of request version.
+func (app *App) openAPIHandler(res http.ResponseWriter, req *http.Request) {
+	version, _ := handler.ResponseVersion(res)
+	app.specs[version.Name].Handler()(res, req)
+}
+
+// routeVersion will return the version of a request and its route path template without version prefix.
+// ok is false if request asks for an unknown version.
+func routeVersion(req *http.Request, template string) (path string, version *handler.Version, ok bool) {
+	for _, version := range handler.Versions {
+		if prefix := "/" + version.Name; strings.HasPrefix(template, prefix+"/") {
+			return strings.TrimPrefix(template, prefix), version, true
+		}
+	}
+	version, _, _, ok = handler.Versions.Negotiate(req.Header.Get("Accept"))
+	return template, version, ok
+}
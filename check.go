@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app"
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"github.com/katoozi/golang-mongodb-rest-api/app/gdpr"
+	"github.com/katoozi/golang-mongodb-rest-api/app/migrations"
+)
+
+const checkUsage = `usage: check [-strict] [flags]
+
+verify that the config is valid and mongo db is reachable, it exits with 1 if they are not.
+pending migrations and index changes are reported, -strict fails on them too.
+the hash chain of erasure log is verified with erasure_log_key, changed or removed entries fail the check.
+`
+
+// check will run the checks one by one and print their results, later checks need the earlier ones.
+func check(args []string) {
+	flags := newFlagSet("check", checkUsage)
+	strict := flags.Bool("strict", false, "fail if migrations are pending or indexes are not up to date")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		os.Exit(2)
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	failed := false
+	result := func(name string, err error, warning string) bool {
+		switch {
+		case err != nil:
+			fmt.Fprintf(writer, "%s\tfailed\t%v\n", name, err)
+			failed = true
+		case warning != "":
+			fmt.Fprintf(writer, "%s\twarning\t%s\n", name, warning)
+			failed = failed || *strict
+		default:
+			fmt.Fprintf(writer, "%s\tok\t\n", name)
+		}
+		return err == nil
+	}
+	defer func() {
+		writer.Flush()
+		if failed {
+			os.Exit(1)
+		}
+	}()
+
+	configuration, err := flags.Load()
+	if !result("config", err, "") {
+		return
+	}
+	if configuration.EncryptionKeyringFile != "" {
+		_, err := app.Cipher(configuration)
+		result("encryption", err, "")
+	}
+	database, err := db.Connect(configuration.MongoDatabase, configuration.MongoURI())
+	if err == nil {
+		defer database.Client().Disconnect(context.Background())
+		err = db.Ping(database)
+	}
+	if !result("mongo", err, "") {
+		return
+	}
+
+	ctx := context.Background()
+	pending, err := migrations.New(database).Pending(ctx)
+	warning := ""
+	if len(pending) > 0 {
+		warning = fmt.Sprintf("%d pending, run migrate up", len(pending))
+	}
+	result("migrations", err, warning)
+
+	plan, err := db.PlanIndexes(ctx, database, app.IndexSpecs(configuration))
+	warning = ""
+	if changes := plan.Changes(); len(changes) > 0 {
+		warning = fmt.Sprintf("%d changes, run indexes plan to see them", len(changes))
+	}
+	result("indexes", err, warning)
+
+	if configuration.ErasureLogKey == "" {
+		result("erasure log", nil, "erasure_log_key is not set, the erasure log is not verified")
+		return
+	}
+	_, unfinished, err := gdpr.Verify(gdpr.NewContext(ctx, []byte(configuration.ErasureLogKey)), database)
+	warning = ""
+	if unfinished > 0 {
+		warning = fmt.Sprintf("%d erasures are not finished, erase their people again", unfinished)
+	}
+	result("erasure log", err, warning)
+}
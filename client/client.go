@@ -0,0 +1,257 @@
+// Package client is the go client of person api. it calls the v2 routes, errors of api are returned as *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version is the api version that client calls.
+const version = "v2"
+
+// Authenticator adds the credentials of client to requests, it is called before every attempt.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc is a function that implements Authenticator.
+type AuthenticatorFunc func(req *http.Request) error
+
+// Authenticate will call f.
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// BearerToken will return an Authenticator that sends token in Authorization header.
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// Backoff will return the wait before retry attempt, attempt starts from 1.
+type Backoff func(attempt int) time.Duration
+
+// ExponentialBackoff will return a Backoff that doubles base on every attempt up to max, waits are jittered
+// between half and all of them.
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		wait := max
+		if attempt < 32 && base<<uint(attempt-1) < max {
+			wait = base << uint(attempt-1)
+		}
+		return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	}
+}
+
+// Client calls person api. it is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	auth       Authenticator
+	retries    int
+	backoff    Backoff
+}
+
+// Option configures a Client.
+type Option func(client *Client)
+
+// WithHTTPClient will send the requests of client with httpClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
+
+// WithTransport will send the requests of client with transport, it is used with the default http client.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(client *Client) {
+		client.httpClient = &http.Client{Transport: transport, Timeout: client.httpClient.Timeout}
+	}
+}
+
+// WithAuth will authenticate the requests of client with auth.
+func WithAuth(auth Authenticator) Option {
+	return func(client *Client) {
+		client.auth = auth
+	}
+}
+
+// WithRetry will retry idempotent calls retries times, backoff is the wait between attempts.
+// retries of zero disables them, nil backoff keeps the default.
+func WithRetry(retries int, backoff Backoff) Option {
+	return func(client *Client) {
+		client.retries = retries
+		if backoff != nil {
+			client.backoff = backoff
+		}
+	}
+}
+
+// New is the Client struct factory function. baseURL is the address of api, like http://localhost:1234.
+// idempotent calls are retried 3 times by default.
+func New(baseURL string, options ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("client: base url is not valid: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: scheme of base url must be http or https, it is %q", u.Scheme)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	client := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retries:    3,
+		backoff:    ExponentialBackoff(100*time.Millisecond, 2*time.Second),
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client, nil
+}
+
+// call is a request of a client method.
+type call struct {
+	method      string
+	path        string // path of route without version
+	query       url.Values
+	body        interface{} // encoded as json
+	raw         []byte      // body that is sent as it is, with contentType
+	contentType string
+	idempotent  bool
+}
+
+// do will send c and decode the content of response into result. failures of idempotent calls that
+// may pass later are retried.
+func (client *Client) do(ctx context.Context, c call, result interface{}) error {
+	body, contentType := c.raw, c.contentType
+	if c.body != nil {
+		contentType = "application/json"
+		var err error
+		if body, err = json.Marshal(c.body); err != nil {
+			return fmt.Errorf("client: could not encode body: %w", err)
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		res, err := client.send(ctx, c, body, contentType)
+		if err == nil && res.StatusCode < 300 {
+			return decodeContent(res, result)
+		}
+		if err == nil {
+			err = decodeError(res)
+		}
+		if !c.idempotent || attempt >= client.retries || !retryable(ctx, err) {
+			return err
+		}
+		wait := client.backoff(attempt + 1)
+		var apiError *Error
+		if errors.As(err, &apiError) && apiError.RetryAfter > wait {
+			wait = apiError.RetryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// send will send an attempt of c.
+func (client *Client) send(ctx context.Context, c call, body []byte, contentType string) (*http.Response, error) {
+	u := *client.baseURL
+	u.Path += "/" + version + c.path
+	u.RawQuery = c.query.Encode()
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, c.method, u.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("client: could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if client.auth != nil {
+		if err := client.auth.Authenticate(req); err != nil {
+			return nil, fmt.Errorf("client: could not authenticate request: %w", err)
+		}
+	}
+	return client.httpClient.Do(req)
+}
+
+// envelope is the v2 response of api.
+type envelope struct {
+	Message string          `json:"message"`
+	Content json.RawMessage `json:"content"`
+}
+
+// decodeContent will decode the content of res envelope into result and close res body.
+func decodeContent(res *http.Response, result interface{}) error {
+	defer res.Body.Close()
+	var response envelope
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return fmt.Errorf("client: could not decode response: %w", err)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(response.Content, result); err != nil {
+		return fmt.Errorf("client: could not decode content of response: %w", err)
+	}
+	return nil
+}
+
+// retryable will return true if err may not happen again. canceled contexts are never retried.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var urlError *url.Error
+	if errors.As(err, &urlError) {
+		return true // transport errors, like refused connections and timeouts
+	}
+	var apiError *Error
+	if !errors.As(err, &apiError) {
+		return false
+	}
+	switch apiError.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter will parse the Retry-After header of res, it is seconds or an http date.
+func retryAfter(res *http.Response) time.Duration {
+	value := res.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// drain will read the rest of body so the connection is reused.
+func drain(body io.ReadCloser) {
+	io.Copy(ioutil.Discard, io.LimitReader(body, 1<<16))
+	body.Close()
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+// noWait is the backoff of tests.
+func noWait(int) time.Duration { return 0 }
+
+// fakeAPI serves the person routes of v2 from memory with the response writers of api.
+func fakeAPI(t *testing.T, people []model.Person) (*Client, *int32) {
+	var requests int32
+	people = append([]model.Person(nil), people...)
+	router := mux.NewRouter().PathPrefix("/v2").Subrouter()
+	router.Use(handler.V2.Middleware)
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			if req.Header.Get("Authorization") != "Bearer secret" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	})
+	find := func(req *http.Request) int {
+		for i, person := range people {
+			if person.ID.Hex() == mux.Vars(req)["id"] {
+				return i
+			}
+		}
+		return -1
+	}
+	router.HandleFunc("/person", func(res http.ResponseWriter, req *http.Request) {
+		page, _ := strconv.Atoi(req.FormValue("page"))
+		start, end := page*PageSize, (page+1)*PageSize
+		if start > len(people) {
+			start = len(people)
+		}
+		if end > len(people) {
+			end = len(people)
+		}
+		handler.ResponseWriter(res, http.StatusOK, "", people[start:end])
+	}).Methods(http.MethodGet)
+	router.HandleFunc("/person", func(res http.ResponseWriter, req *http.Request) {
+		person := new(model.Person)
+		json.NewDecoder(req.Body).Decode(person)
+		if err := person.Validate(); err != nil {
+			handler.ErrorResponse(res, req, handler.CodeInvalidBody, err.Error(), nil, handler.FieldError{Field: "email", Message: err.Error()})
+			return
+		}
+		person.ID = primitive.NewObjectID()
+		handler.ResponseWriter(res, http.StatusCreated, "", person)
+	}).Methods(http.MethodPost)
+	router.HandleFunc("/person/{id}", func(res http.ResponseWriter, req *http.Request) {
+		i := find(req)
+		if i < 0 {
+			handler.ErrorResponse(res, req, handler.CodePersonNotFound, "person not found", nil)
+			return
+		}
+		if req.Method == http.MethodDelete {
+			people = append(people[:i], people[i+1:]...)
+			handler.ResponseWriter(res, http.StatusOK, "person is deleted.", map[string]string{"id": mux.Vars(req)["id"]})
+			return
+		}
+		handler.ResponseWriter(res, http.StatusOK, "", people[i])
+	}).Methods(http.MethodGet, http.MethodDelete)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	client, err := New(server.URL+"/", WithAuth(BearerToken("secret")), WithRetry(2, noWait))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, &requests
+}
+
+func TestPeople(t *testing.T) {
+	people := make([]model.Person, 25)
+	for i := range people {
+		people[i] = model.Person{ID: primitive.NewObjectID(), Username: "user" + strconv.Itoa(i), Email: "user@example.com"}
+	}
+	client, requests := fakeAPI(t, people)
+	ctx := context.Background()
+
+	it := client.People(ctx)
+	count := 0
+	for it.Next() {
+		if it.Person().ID != people[count].ID {
+			t.Errorf("%s person %d of iterator is wrong: %v", failed, count, it.Person())
+		}
+		count++
+	}
+	if it.Err() != nil || count != len(people) || *requests != 3 {
+		t.Errorf("%s iterator read %d people with %d requests: %v", failed, count, *requests, it.Err())
+	}
+
+	person, err := client.GetPerson(ctx, people[3].ID)
+	if err != nil || person.Username != "user3" {
+		t.Errorf("%s person is wrong: %v %v", failed, person, err)
+	}
+	created, err := client.CreatePerson(ctx, &model.Person{Username: "john", Email: "john@example.com"})
+	if err != nil || created.ID.IsZero() || created.Username != "john" {
+		t.Errorf("%s created person is wrong: %v %v", failed, created, err)
+	}
+	if err := client.DeletePerson(ctx, people[3].ID); err != nil {
+		t.Errorf("%s person is not deleted: %v", failed, err)
+	}
+
+	_, err = client.GetPerson(ctx, people[3].ID)
+	var apiError *Error
+	if !errors.Is(err, ErrPersonNotFound) || !errors.As(err, &apiError) || apiError.StatusCode != http.StatusNotFound {
+		t.Errorf("%s error of missing person is wrong: %v", failed, err)
+	}
+	_, err = client.CreatePerson(ctx, &model.Person{Username: "john", Email: "john"})
+	if !errors.Is(err, ErrInvalidBody) || !errors.As(err, &apiError) || len(apiError.Fields) != 1 || apiError.Fields[0].Field != "email" {
+		t.Errorf("%s error of invalid person is wrong: %v", failed, err)
+	}
+	t.Logf("%s Testing client people calls is successful", succeed)
+}
+
+func TestRetry(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&attempts, 1)%3 != 0 {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler.ErrorResponse(res, req, handler.CodeInternal, "there is an error on server", nil)
+	}))
+	defer server.Close()
+	var roundTrips int32
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&roundTrips, 1)
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	client, _ := New(server.URL, WithTransport(transport), WithRetry(3, noWait))
+	ctx := context.Background()
+
+	_, err := client.GetPerson(ctx, primitive.NewObjectID())
+	if !errors.Is(err, ErrInternal) || attempts != 3 || roundTrips != 3 {
+		t.Errorf("%s get is sent %d times: %v", failed, attempts, err)
+	}
+	atomic.StoreInt32(&attempts, 0)
+	_, err = client.CreatePerson(ctx, &model.Person{})
+	var apiError *Error
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusServiceUnavailable || apiError.Code != "" || attempts != 1 {
+		t.Errorf("%s create is sent %d times: %v", failed, attempts, err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	atomic.StoreInt32(&attempts, 0)
+	if err := client.DeletePerson(canceled, primitive.NewObjectID()); err == nil || attempts != 0 {
+		t.Errorf("%s canceled delete is sent %d times: %v", failed, attempts, err)
+	}
+	t.Logf("%s Testing client retries is successful", succeed)
+}
+
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"localhost:1234", "ftp://localhost", "://"} {
+		if _, err := New(baseURL); err == nil {
+			t.Errorf("%s base url %q is accepted", failed, baseURL)
+		}
+	}
+	backoff := ExponentialBackoff(100*time.Millisecond, time.Second)
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		if wait := backoff(attempt); wait < max/2 || wait > max {
+			t.Errorf("%s wait of attempt %d is %v", failed, attempt, wait)
+		}
+	}
+	t.Logf("%s Testing client options is successful", succeed)
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+)
+
+// Error is an error response of api. Code is the code of api error catalog, it is empty for responses
+// that are not problem details, like the errors of proxies.
+type Error struct {
+	StatusCode int
+	Code       string
+	Title      string
+	Detail     string
+	Fields     []FieldError
+	RetryAfter time.Duration // Retry-After of 429 and 503 responses
+}
+
+// FieldError is the problem of a request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// errors of api catalog, they are compared with errors.Is.
+var (
+	ErrInvalidBody          = &Error{Code: "invalid_body"}
+	ErrInvalidID            = &Error{Code: "invalid_id"}
+	ErrInvalidRequest       = &Error{Code: "invalid_request"}
+	ErrPersonNotFound       = &Error{Code: "person_not_found"}
+	ErrDuplicateField       = &Error{Code: "duplicate_field"}
+	ErrUnsupportedMediaType = &Error{Code: "unsupported_media_type"}
+	ErrInternal             = &Error{Code: "internal_error"}
+)
+
+func (err *Error) Error() string {
+	message := err.Detail
+	if message == "" {
+		message = err.Title
+	}
+	if message == "" {
+		message = http.StatusText(err.StatusCode)
+	}
+	if err.Code == "" {
+		return fmt.Sprintf("client: api error %d: %s", err.StatusCode, message)
+	}
+	return fmt.Sprintf("client: api error %d %s: %s", err.StatusCode, err.Code, message)
+}
+
+// JobError is a background job that is failed or cancelled.
+type JobError struct {
+	Job *model.Job
+}
+
+func (err *JobError) Error() string {
+	if err.Job.Error == "" {
+		return fmt.Sprintf("client: job %s is %s", err.Job.ID.Hex(), err.Job.State)
+	}
+	return fmt.Sprintf("client: job %s is %s: %s", err.Job.ID.Hex(), err.Job.State, err.Job.Error)
+}
+
+// Is will return true if target is an *Error with the same code, so errors.Is(err, ErrPersonNotFound) works.
+func (err *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != "" && t.Code == err.Code
+}
+
+// problem is the RFC 7807 problem details of api.
+type problem struct {
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail"`
+	Code   string       `json:"code"`
+	Errors []FieldError `json:"errors"`
+}
+
+// decodeError will read the error of res and close its body. problem details are decoded into the
+// fields of Error, the message of envelopes is its Detail.
+func decodeError(res *http.Response) error {
+	defer drain(res.Body)
+	err := &Error{StatusCode: res.StatusCode, RetryAfter: retryAfter(res)}
+	content, readErr := ioutil.ReadAll(res.Body)
+	if readErr != nil {
+		return err
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/problem+json":
+		var p problem
+		if json.Unmarshal(content, &p) == nil {
+			err.Code, err.Title, err.Detail, err.Fields = p.Code, p.Title, p.Detail, p.Errors
+		}
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var response envelope
+		if json.Unmarshal(content, &response) == nil {
+			err.Detail = response.Message
+		}
+	}
+	return err
+}
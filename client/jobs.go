@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetJob will return the background job of id with its state, progress and result.
+func (client *Client) GetJob(ctx context.Context, id primitive.ObjectID) (*model.Job, error) {
+	job := new(model.Job)
+	if err := client.do(ctx, call{method: http.MethodGet, path: "/jobs/" + id.Hex(), idempotent: true}, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// WaitJob will get the job of id every interval until it is finished. a job that is failed or cancelled is
+// returned with a *JobError.
+func (client *Client) WaitJob(ctx context.Context, id primitive.ObjectID, interval time.Duration) (*model.Job, error) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		job, err := client.GetJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.Finished() {
+			if job.State != model.JobSucceeded {
+				return job, &JobError{Job: job}
+			}
+			return job, nil
+		}
+		timer.Reset(interval)
+	}
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PageSize is the number of people in a page of ListPeople.
+const PageSize = 10
+
+// CreatePerson will create person and return it with its id. it is not retried, a retry could create
+// the person twice.
+func (client *Client) CreatePerson(ctx context.Context, person *model.Person) (*model.Person, error) {
+	created := new(model.Person)
+	if err := client.do(ctx, call{method: http.MethodPost, path: "/person", body: person}, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetPerson will return the person of id, the error is ErrPersonNotFound if there is none.
+func (client *Client) GetPerson(ctx context.Context, id primitive.ObjectID) (*model.Person, error) {
+	person := new(model.Person)
+	if err := client.do(ctx, call{method: http.MethodGet, path: "/person/" + id.Hex(), idempotent: true}, person); err != nil {
+		return nil, err
+	}
+	return person, nil
+}
+
+// ListPeople will return a zero based page of people, newest first.
+func (client *Client) ListPeople(ctx context.Context, page int) ([]model.Person, error) {
+	var people []model.Person
+	query := url.Values{"page": {strconv.Itoa(page)}}
+	if err := client.do(ctx, call{method: http.MethodGet, path: "/person", query: query, idempotent: true}, &people); err != nil {
+		return nil, err
+	}
+	return people, nil
+}
+
+// UpdatePerson will set fields on the person of id, fields are the json names of person fields. it is
+// retried because fields are set, sending them again has the same result.
+func (client *Client) UpdatePerson(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	return client.do(ctx, call{method: http.MethodPatch, path: "/person/" + id.Hex(), body: fields, idempotent: true}, nil)
+}
+
+// DeletePerson will delete the person of id, the error is ErrPersonNotFound if there is none.
+func (client *Client) DeletePerson(ctx context.Context, id primitive.ObjectID) error {
+	return client.do(ctx, call{method: http.MethodDelete, path: "/person/" + id.Hex(), idempotent: true}, nil)
+}
+
+// PersonIterator reads all people page by page, newest first.
+//
+//	people := client.People(ctx)
+//	for people.Next() {
+//		person := people.Person()
+//	}
+//	if err := people.Err(); err != nil {
+//		log.Fatal(err)
+//	}
+type PersonIterator struct {
+	ctx    context.Context
+	client *Client
+	page   int
+	people []model.Person
+	index  int
+	done   bool
+	err    error
+}
+
+// People will return an iterator of all people, pages are read when they are needed.
+func (client *Client) People(ctx context.Context) *PersonIterator {
+	return &PersonIterator{ctx: ctx, client: client, index: -1}
+}
+
+// Next will move to the next person, it returns false at the end or after an error.
+func (it *PersonIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.index++
+	if it.index < len(it.people) {
+		return true
+	}
+	if it.done {
+		return false
+	}
+	it.people, it.err = it.client.ListPeople(it.ctx, it.page)
+	it.page++
+	it.index = 0
+	// a short page is the last one.
+	it.done = len(it.people) < PageSize
+	return it.err == nil && len(it.people) > 0
+}
+
+// Person will return the current person, it is valid after Next returns true.
+func (it *PersonIterator) Person() model.Person {
+	return it.people[it.index]
+}
+
+// Err will return the error that stopped the iterator.
+func (it *PersonIterator) Err() error {
+	return it.err
+}
+
+// ImportOptions are the options of ImportPeople.
+type ImportOptions struct {
+	Format   string            // csv or ndjson, it is detected from filename if it is empty.
+	Mapping  map[string]string // column -> person field, data.<key> or "-".
+	DryRun   bool
+	Interval time.Duration // wait between the checks of import job, it is a second if it is zero.
+}
+
+// ImportPeople will upload file to the bulk import of people and wait for its job, filename is sent as the
+// name of file. the report of import is the result of job, a job that does not succeed is a *JobError.
+func (client *Client) ImportPeople(ctx context.Context, filename string, file io.Reader, options ImportOptions) (*model.ImportReport, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, fmt.Errorf("client: could not read import file: %w", err)
+	}
+	if options.Format != "" {
+		form.WriteField("format", options.Format)
+	}
+	if len(options.Mapping) > 0 {
+		mapping, _ := json.Marshal(options.Mapping)
+		form.WriteField("mapping", string(mapping))
+	}
+	form.WriteField("dry_run", strconv.FormatBool(options.DryRun))
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+	job := new(model.Job)
+	c := call{method: http.MethodPost, path: "/person/import", raw: body.Bytes(), contentType: form.FormDataContentType()}
+	if err := client.do(ctx, c, job); err != nil {
+		return nil, err
+	}
+	if options.Interval <= 0 {
+		options.Interval = time.Second
+	}
+	job, err = client.WaitJob(ctx, job.ID, options.Interval)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := json.Marshal(job.Result)
+	report := new(model.ImportReport)
+	if err := json.Unmarshal(result, report); err != nil {
+		return nil, fmt.Errorf("client: could not decode import report: %w", err)
+	}
+	return report, nil
+}
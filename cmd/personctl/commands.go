@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/client"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v2"
+)
+
+func listFlags(e *env) {
+	e.flags.IntVar(&e.page, "page", 0, "zero based page number")
+	e.flags.BoolVar(&e.all, "all", false, "list all pages")
+}
+
+func payloadFlags(e *env) {
+	e.flags.StringVar(&e.file, "f", "-", "json or yaml payload file, - is stdin")
+}
+
+func exportFlags(e *env) {
+	e.flags.StringVar(&e.format, "format", "ndjson", "output format, ndjson or csv")
+	e.flags.StringVar(&e.file, "f", "-", "output file, - is stdout")
+}
+
+func importFlags(e *env) {
+	e.flags.StringVar(&e.format, "format", "", "csv or ndjson, it is detected from file name if it is empty")
+	e.flags.StringVar(&e.mapping, "mapping", "", `json object of column -> field, data.<key> or "-", _id is "-" by default`)
+	e.flags.BoolVar(&e.dryRun, "dry-run", false, "only report what would be imported")
+}
+
+// idArg will parse the only argument of command as an object id.
+func (e *env) idArg() (primitive.ObjectID, error) {
+	if len(e.args) != 1 {
+		return primitive.ObjectID{}, errUsage
+	}
+	id, err := primitive.ObjectIDFromHex(e.args[0])
+	if err != nil {
+		return id, fmt.Errorf("id %q is not a hex object id", e.args[0])
+	}
+	return id, nil
+}
+
+func getCommand(e *env) error {
+	id, err := e.idArg()
+	if err != nil {
+		return err
+	}
+	p, err := e.printer()
+	if err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	person, err := c.GetPerson(e.ctx, id)
+	if err != nil {
+		return err
+	}
+	return p.person(person)
+}
+
+func listCommand(e *env) error {
+	if len(e.args) != 0 {
+		return errUsage
+	}
+	p, err := e.printer()
+	if err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	if !e.all {
+		people, err := c.ListPeople(e.ctx, e.page)
+		if err != nil {
+			return err
+		}
+		return p.people(people)
+	}
+	people := []model.Person{}
+	it := c.People(e.ctx)
+	for it.Next() {
+		people = append(people, it.Person())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return p.people(people)
+}
+
+func createCommand(e *env) error {
+	if len(e.args) != 0 {
+		return errUsage
+	}
+	p, err := e.printer()
+	if err != nil {
+		return err
+	}
+	person := new(model.Person)
+	if err := e.readPayload(person); err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	created, err := c.CreatePerson(e.ctx, person)
+	if err != nil {
+		return err
+	}
+	return p.person(created)
+}
+
+func updateCommand(e *env) error {
+	id, err := e.idArg()
+	if err != nil {
+		return err
+	}
+	p, err := e.printer()
+	if err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	if err := e.readPayload(&fields); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return errors.New("payload has no field")
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := c.UpdatePerson(e.ctx, id, fields); err != nil {
+		return err
+	}
+	person, err := c.GetPerson(e.ctx, id)
+	if err != nil {
+		return err
+	}
+	return p.person(person)
+}
+
+func deleteCommand(e *env) error {
+	id, err := e.idArg()
+	if err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := c.DeletePerson(e.ctx, id); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "person %s is deleted\n", id.Hex())
+	return nil
+}
+
+// readPayload will decode the json or yaml object of -f file into v.
+func (e *env) readPayload(v interface{}) error {
+	file, err := e.open(e.file)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(content, &object); err != nil {
+		// yaml is a superset of json, so json errors are only returned for json files.
+		var tree interface{}
+		if strings.HasSuffix(e.file, ".json") || yaml.Unmarshal(content, &tree) != nil {
+			return fmt.Errorf("payload is not a json or yaml object: %v", err)
+		}
+		var ok bool
+		if object, ok = jsonValue(tree).(map[string]interface{}); !ok {
+			return errors.New("payload is not an object")
+		}
+	}
+	content, _ = json.Marshal(object)
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("payload is not a person: %v", err)
+	}
+	return nil
+}
+
+// jsonValue will convert the maps of yaml values to json objects.
+func jsonValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			object[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return object
+	case []interface{}:
+		for i, item := range value {
+			value[i] = jsonValue(item)
+		}
+	}
+	return value
+}
+
+func exportCommand(e *env) error {
+	if len(e.args) != 0 {
+		return errUsage
+	}
+	if e.format != "ndjson" && e.format != "csv" {
+		return fmt.Errorf("format %q is not supported, use ndjson or csv", e.format)
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	w := e.stdout
+	if e.file != "-" {
+		file, err := os.Create(e.file)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	buffered := bufio.NewWriter(w)
+	var people []model.Person
+	it := c.People(e.ctx)
+	for it.Next() {
+		if e.format == "csv" {
+			// columns are the data keys of all people, so csv is written at the end.
+			people = append(people, it.Person())
+			continue
+		}
+		content, err := json.Marshal(it.Person())
+		if err != nil {
+			return err
+		}
+		buffered.Write(append(content, '\n'))
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if e.format == "csv" {
+		if err := writeCSV(buffered, people); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// writeCSV will write people with the person fields and a data.<key> column for every data key.
+// values of data that are not strings are written in json.
+func writeCSV(w io.Writer, people []model.Person) error {
+	keys := map[string]bool{}
+	for _, person := range people {
+		for key := range person.Data {
+			keys[key] = true
+		}
+	}
+	dataKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		dataKeys = append(dataKeys, key)
+	}
+	sort.Strings(dataKeys)
+	writer := csv.NewWriter(w)
+	header := []string{"_id", "first_name", "last_name", "username", "email"}
+	for _, key := range dataKeys {
+		header = append(header, "data."+key)
+	}
+	writer.Write(header)
+	for _, person := range people {
+		record := []string{person.ID.Hex(), person.FirstName, person.LastName, person.Username, person.Email}
+		for _, key := range dataKeys {
+			value, ok := person.Data[key]
+			text, isText := value.(string)
+			if ok && !isText {
+				content, _ := json.Marshal(value)
+				text = string(content)
+			}
+			record = append(record, text)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func importCommand(e *env) error {
+	if len(e.args) != 1 {
+		return errUsage
+	}
+	name := e.args[0]
+	if name == "-" && e.format == "" {
+		return errors.New("format is required when file is stdin")
+	}
+	mapping := map[string]string{}
+	if e.mapping != "" {
+		if err := json.Unmarshal([]byte(e.mapping), &mapping); err != nil {
+			return fmt.Errorf("mapping is not a json object of strings: %v", err)
+		}
+	}
+	if _, ok := mapping["_id"]; !ok {
+		// ids of export are not imported, people get new ids.
+		mapping["_id"] = "-"
+	}
+	p, err := e.printer()
+	if err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	file, err := e.open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	filename := filepath.Base(name)
+	if name == "-" {
+		filename = "stdin." + e.format
+	}
+	report, err := c.ImportPeople(e.ctx, filename, file, client.ImportOptions{Format: e.format, Mapping: mapping, DryRun: e.dryRun})
+	if err != nil {
+		return err
+	}
+	return p.value(report)
+}
+
+func profileCommand(e *env) error {
+	args := e.args
+	if len(args) == 0 {
+		return errUsage
+	}
+	config, err := loadConfig(e.configPath)
+	if err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		for _, name := range config.names() {
+			current := " "
+			if name == config.Current {
+				current = "*"
+			}
+			fmt.Fprintf(e.stdout, "%s %s\t%s\n", current, name, config.Profiles[name].URL)
+		}
+		return nil
+	case args[0] == "use" && len(args) == 2:
+		if _, ok := config.Profiles[args[1]]; !ok {
+			return fmt.Errorf("profile %q is not in config, profiles are %v", args[1], config.names())
+		}
+		config.Current = args[1]
+	case args[0] == "set" && len(args) == 2:
+		selected := config.Profiles[args[1]]
+		if e.url != "" {
+			selected.URL = e.url
+		}
+		if e.token != "" {
+			selected.Token = e.token
+		}
+		if selected.URL == "" {
+			return errors.New("url of profile is required")
+		}
+		config.Profiles[args[1]] = selected
+		if config.Current == "" {
+			config.Current = args[1]
+		}
+	default:
+		return errUsage
+	}
+	return config.save(e.configPath)
+}
@@ -0,0 +1,180 @@
+// personctl operates the person api from command line, it is a client of the v2 api.
+//
+//	personctl <command> [flags] [args]
+//
+// run personctl help to see the commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/katoozi/golang-mongodb-rest-api/client"
+)
+
+// errUsage is returned when the arguments of a command are wrong, its usage is printed.
+var errUsage = errors.New("usage")
+
+// env is the environment of a command, flags are the common flags and the flags of command.
+type env struct {
+	ctx    context.Context
+	stdin  io.Reader
+	stdout io.Writer
+	flags  *flag.FlagSet
+	args   []string // arguments of command, flags can be before and after them
+
+	configPath string
+	profile    string
+	url        string
+	token      string
+	output     string
+
+	// flags of commands
+	file    string
+	page    int
+	all     bool
+	format  string
+	mapping string
+	dryRun  bool
+}
+
+// command is a subcommand of personctl, flags registers the flags of command in env.
+type command struct {
+	args    string
+	summary string
+	flags   func(e *env)
+	run     func(e *env) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"get":     {"<id>", "Get a person", nil, getCommand},
+		"list":    {"", "List people, newest first", listFlags, listCommand},
+		"create":  {"", "Create a person from a json or yaml payload", payloadFlags, createCommand},
+		"update":  {"<id>", "Set the fields of payload on a person", payloadFlags, updateCommand},
+		"delete":  {"<id>", "Delete a person", nil, deleteCommand},
+		"export":  {"", "Write all people as ndjson or csv", exportFlags, exportCommand},
+		"import":  {"<file|->", "Import people from a csv or ndjson file", importFlags, importCommand},
+		"profile": {"list | use <name> | set <name>", "Manage the api profiles of config file", nil, profileCommand},
+	}
+}
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	cancel()
+	os.Exit(code)
+}
+
+// run will run the command of args and return the exit code, 2 is a usage error.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		usage(stderr)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "personctl: unknown command %q\n\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	e := &env{ctx: ctx, stdin: stdin, stdout: stdout, flags: flag.NewFlagSet("personctl "+args[0], flag.ContinueOnError)}
+	e.flags.SetOutput(stderr)
+	e.flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: personctl %s [flags] %s\n\n%s.\n\nflags:\n", args[0], cmd.args, cmd.summary)
+		e.flags.PrintDefaults()
+	}
+	e.flags.StringVar(&e.configPath, "config", defaultConfigPath(), "config file of profiles, $"+configEnv+" sets the default")
+	e.flags.StringVar(&e.profile, "profile", "", "profile of config file, default is the current profile")
+	e.flags.StringVar(&e.url, "url", "", "address of api, it overrides the url of profile")
+	e.flags.StringVar(&e.token, "token", "", "bearer token, it overrides the token of profile")
+	e.flags.StringVar(&e.output, "o", outputTable, "output format, table, json or yaml")
+	if cmd.flags != nil {
+		cmd.flags(e)
+	}
+	for rest := args[1:]; ; {
+		if err := e.flags.Parse(rest); err != nil {
+			if err == flag.ErrHelp {
+				return 0
+			}
+			return 2
+		}
+		if rest = e.flags.Args(); len(rest) == 0 {
+			break
+		}
+		e.args, rest = append(e.args, rest[0]), rest[1:]
+	}
+	err := cmd.run(e)
+	if err == errUsage {
+		e.flags.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "personctl %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "personctl operates the person api.\n\nusage: personctl <command> [flags] [args]\n\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w, "\nrun personctl <command> -h to see its flags.")
+}
+
+// client will create the api client of the selected profile.
+func (e *env) client() (*client.Client, error) {
+	config, err := loadConfig(e.configPath)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := config.resolve(e.profile, e.url, e.token)
+	if err != nil {
+		return nil, err
+	}
+	var options []client.Option
+	if selected.Token != "" {
+		options = append(options, client.WithAuth(client.BearerToken(selected.Token)))
+	}
+	return client.New(selected.URL, options...)
+}
+
+// printer will return the printer of -o flag.
+func (e *env) printer() (*printer, error) {
+	return newPrinter(e.stdout, strings.ToLower(e.output))
+}
+
+// open will open the file of name, - is stdin.
+func (e *env) open(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return ioutil.NopCloser(e.stdin), nil
+	}
+	return os.Open(name)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"gopkg.in/yaml.v2"
+)
+
+// output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// printer writes the results of commands in an output format.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return &printer{w: w, format: format}, nil
+	}
+	return nil, fmt.Errorf("output %q is not supported, use table, json or yaml", format)
+}
+
+// people will print people, a table has a row for every person.
+func (p *printer) people(people []model.Person) error {
+	if p.format != outputTable {
+		return p.value(people)
+	}
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tFIRST NAME\tLAST NAME\tDATA")
+	for _, person := range people {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", person.ID.Hex(), person.Username, person.Email, person.FirstName, person.LastName, dataKeys(person.Data))
+	}
+	return w.Flush()
+}
+
+// person will print person, a table has its row.
+func (p *printer) person(person *model.Person) error {
+	if p.format == outputTable {
+		return p.people([]model.Person{*person})
+	}
+	return p.value(person)
+}
+
+// value will print v, a table is the key and value of its json fields.
+func (p *printer) value(v interface{}) error {
+	tree, err := jsonTree(v)
+	if err != nil {
+		return err
+	}
+	switch p.format {
+	case outputJSON:
+		encoder := json.NewEncoder(p.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(tree)
+	case outputYAML:
+		content, err := yaml.Marshal(tree)
+		if err != nil {
+			return err
+		}
+		_, err = p.w.Write(content)
+		return err
+	}
+	object, ok := tree.(map[string]interface{})
+	if !ok {
+		_, err := fmt.Fprintln(p.w, tree)
+		return err
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	for _, key := range keys {
+		value := object[key]
+		if _, ok := value.(string); !ok {
+			content, _ := json.Marshal(value)
+			value = string(content)
+		}
+		fmt.Fprintf(w, "%s\t%v\n", strings.ToUpper(strings.TrimLeft(key, "_")), value)
+	}
+	return w.Flush()
+}
+
+// jsonTree will convert v to its json form, so object ids and field names are the same in all formats.
+func jsonTree(v interface{}) (interface{}, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	return tree, json.Unmarshal(content, &tree)
+}
+
+// dataKeys will return the sorted keys of data, values are too long for a table.
+func dataKeys(data map[string]interface{}) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
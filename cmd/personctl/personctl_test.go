@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/katoozi/golang-mongodb-rest-api/app/handler"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const succeed = "\u2713"
+const failed = "\u2717"
+
+// fakeAPI serves get, list and create of v2 from memory, requests must have the bearer token.
+func fakeAPI(t *testing.T, people []model.Person) string {
+	router := mux.NewRouter().PathPrefix("/v2").Subrouter()
+	router.Use(handler.V2.Middleware)
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "Bearer secret" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	})
+	router.HandleFunc("/person", func(res http.ResponseWriter, req *http.Request) {
+		if req.FormValue("page") != "0" {
+			handler.ResponseWriter(res, http.StatusOK, "", []model.Person{})
+			return
+		}
+		handler.ResponseWriter(res, http.StatusOK, "", people)
+	}).Methods(http.MethodGet)
+	router.HandleFunc("/person", func(res http.ResponseWriter, req *http.Request) {
+		person := new(model.Person)
+		json.NewDecoder(req.Body).Decode(person)
+		person.ID = primitive.NewObjectID()
+		handler.ResponseWriter(res, http.StatusCreated, "", person)
+	}).Methods(http.MethodPost)
+	router.HandleFunc("/person/{id}", func(res http.ResponseWriter, req *http.Request) {
+		for _, person := range people {
+			if person.ID.Hex() == mux.Vars(req)["id"] {
+				handler.ResponseWriter(res, http.StatusOK, "", person)
+				return
+			}
+		}
+		handler.ErrorResponse(res, req, handler.CodePersonNotFound, "person not found", nil)
+	}).Methods(http.MethodGet)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestCommands(t *testing.T) {
+	john := model.Person{ID: primitive.NewObjectID(), Username: "john", Email: "john@example.com", Data: map[string]interface{}{"age": 30.0, "city": "Tehran"}}
+	jane := model.Person{ID: primitive.NewObjectID(), Username: "jane", Email: "jane@example.com"}
+	url := fakeAPI(t, []model.Person{john, jane})
+	config := "-config=" + filepath.Join(t.TempDir(), "config.yaml")
+
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		code  int
+		out   []string // substrings of stdout
+	}{
+		{"set profile", []string{"profile", "set", "local", "-url", url, "-token", "secret", config}, "", 0, nil},
+		{"set other profile", []string{"profile", config, "set", "prod", "-url", "https://person.example.com"}, "", 0, nil},
+		{"list profiles", []string{"profile", "list", config}, "", 0, []string{"* local\t" + url, "  prod\thttps://person.example.com"}},
+		{"get json", []string{"get", john.ID.Hex(), "-o", "json", config}, "", 0, []string{`"username": "john"`, `"_id": "` + john.ID.Hex()}},
+		{"get yaml", []string{"get", "-o=yaml", config, jane.ID.Hex()}, "", 0, []string{"username: jane"}},
+		{"list table", []string{"list", config}, "", 0, []string{"ID", john.ID.Hex() + "  john", "age,city"}},
+		{"create yaml", []string{"create", config, "-o", "json"}, "username: ali\nemail: ali@example.com\ndata:\n  age: 20\n", 0, []string{`"username": "ali"`, `"age": 20`}},
+		{"create invalid", []string{"create", config, "-f", "person.json"}, "", 1, nil},
+		{"export csv", []string{"export", "-format", "csv", config}, "", 0, []string{
+			"_id,first_name,last_name,username,email,data.age,data.city",
+			john.ID.Hex() + ",,,john,john@example.com,30,Tehran",
+			jane.ID.Hex() + ",,,jane,jane@example.com,,",
+		}},
+		{"export ndjson", []string{"export", config}, "", 0, []string{`{"_id":"` + jane.ID.Hex() + `","username":"jane","email":"jane@example.com"}` + "\n"}},
+		{"not found", []string{"get", primitive.NewObjectID().Hex(), config}, "", 1, nil},
+		{"unauthorized", []string{"get", john.ID.Hex(), "-token", "wrong", config}, "", 1, nil},
+		{"unknown profile", []string{"list", "-profile", "test", config}, "", 1, nil},
+		{"invalid id", []string{"delete", "123", config}, "", 1, nil},
+		{"usage", []string{"get", config}, "", 2, nil},
+		{"unknown command", []string{"remove"}, "", 2, nil},
+		{"output", []string{"list", "-o", "xml", config}, "", 1, nil},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(context.Background(), test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+		if code != test.code {
+			t.Errorf("%s %s exit code is %d, want %d: %s", failed, test.name, code, test.code, stderr.String())
+			continue
+		}
+		for _, out := range test.out {
+			if !strings.Contains(stdout.String(), out) {
+				t.Errorf("%s %s output does not have %q:\n%s", failed, test.name, out, stdout.String())
+			}
+		}
+	}
+	t.Logf("%s Testing personctl commands is successful", succeed)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// configEnv is the environment variable of config file path.
+const configEnv = "PERSONCTL_CONFIG"
+
+type (
+	// ctlConfig is the config file of personctl, it holds the api address of every environment.
+	//
+	//	current: staging
+	//	profiles:
+	//	  local:
+	//	    url: http://localhost:1234
+	//	  staging:
+	//	    url: https://person.staging.example.com
+	//	    token: secret
+	ctlConfig struct {
+		Current  string             `yaml:"current,omitempty"`
+		Profiles map[string]profile `yaml:"profiles,omitempty"`
+	}
+
+	// profile is an environment of api.
+	profile struct {
+		URL   string `yaml:"url"`
+		Token string `yaml:"token,omitempty"`
+	}
+)
+
+// defaultConfigPath will return $PERSONCTL_CONFIG or personctl/config.yaml in user config directory.
+func defaultConfigPath() string {
+	if path := os.Getenv(configEnv); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "personctl.yaml"
+	}
+	return filepath.Join(dir, "personctl", "config.yaml")
+}
+
+// loadConfig will read the config file of path, a missing file is an empty config.
+func loadConfig(path string) (*ctlConfig, error) {
+	config := &ctlConfig{Profiles: map[string]profile{}}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, fmt.Errorf("config file %s is not valid: %v", path, err)
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]profile{}
+	}
+	return config, nil
+}
+
+// save will write config to path, the file is only readable by user because it has tokens.
+func (config *ctlConfig) save(path string) error {
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// names will return the sorted names of profiles.
+func (config *ctlConfig) names() []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve will return the profile of name, the current profile if name is empty. url and token
+// override the fields of profile. without any profile the api is on localhost.
+func (config *ctlConfig) resolve(name, url, token string) (profile, error) {
+	if name == "" {
+		name = config.Current
+	}
+	selected := profile{URL: "http://localhost:1234"}
+	if name != "" {
+		var ok bool
+		if selected, ok = config.Profiles[name]; !ok {
+			return selected, fmt.Errorf("profile %q is not in config, profiles are %v", name, config.names())
+		}
+	}
+	if url != "" {
+		selected.URL = url
+	}
+	if token != "" {
+		selected.Token = token
+	}
+	if selected.URL == "" {
+		return selected, errors.New("url of api is empty")
+	}
+	return selected, nil
+}
@@ -1,43 +1,481 @@
+// Package config loads the server configuration.
+//
+// every option can be set in a config file, an environment variable and a flag.
+// options are read in this order and the later one wins:
+//
+//  1. default values
+//  2. config file (yaml or json), path is taken from -config flag or config_file environment variable
+//  3. environment variables, e.g. mongo_host
+//  4. command-line flags, e.g. -mongo-host
+//
+// secrets like mongo_password can be read from a file with <name>_file option, e.g. mongo_password_file.
+// the content of file overrides the option and the files can be watched with WatchSecretFiles.
+//
+// the loaded config is validated and all problems are returned in one ValidationError.
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/encryption"
+	"gopkg.in/yaml.v2"
 )
 
+// redacted is the value that secrets will be printed with.
+const redacted = "******"
+
 // Config is the server configuration structure.
-// all fields will be filled with environment variables.
 type Config struct {
-	ServerHost    string // address that server will listening on
-	MongoUser     string // mongo db username
-	MongoPassword string // mongo db password
-	MongoHost     string // host that mongo db listening on
-	MongoPort     string // port that mongo db listening on
+	ServerHost     string `json:"server_host" yaml:"server_host"`           // address that server will listening on
+	GRPCHost       string `json:"grpc_host" yaml:"grpc_host"`               // address that grpc server will listening on, empty disables it
+	GRPCReflection bool   `json:"grpc_reflection" yaml:"grpc_reflection"`   // serve grpc reflection, it lists the services to every client
+	MongoUser      string `json:"mongo_user" yaml:"mongo_user"`             // mongo db username
+	MongoPassword  string `json:"mongo_password" yaml:"mongo_password"`     // mongo db password
+	MongoHost      string `json:"mongo_host" yaml:"mongo_host"`             // host that mongo db listening on, comma separated for replica sets
+	MongoPort      string `json:"mongo_port" yaml:"mongo_port"`             // port that mongo db listening on
+	JobWorkers     int    `json:"job_workers" yaml:"job_workers"`           // number of background job workers
+	MigrateOnStart bool   `json:"migrate_on_start" yaml:"migrate_on_start"` // run pending migrations in app initialize
+	Development    bool   `json:"development" yaml:"development"`           // validate responses against openapi document
+	V1Deprecation  string `json:"v1_deprecation" yaml:"v1_deprecation"`     // date that api v1 is deprecated since, empty if it is not deprecated
+	V1Sunset       string `json:"v1_sunset" yaml:"v1_sunset"`               // date that api v1 is removed after, empty if it is not known
+
+	PersonCacheSize       int `json:"person_cache_size" yaml:"person_cache_size"`               // max people of read cache, 0 disables it
+	PersonCacheTTLSeconds int `json:"person_cache_ttl_seconds" yaml:"person_cache_ttl_seconds"` // seconds that cached people are used, 0 keeps them until eviction
+
+	Tenancy        string `json:"tenancy" yaml:"tenancy"`                   // shared or database, empty disables multi-tenancy
+	TenantSource   string `json:"tenant_source" yaml:"tenant_source"`       // header, subdomain or jwt
+	TenantHeader   string `json:"tenant_header" yaml:"tenant_header"`       // header of tenant id when tenant_source is header
+	TenantDomain   string `json:"tenant_domain" yaml:"tenant_domain"`       // domain that tenants are subdomains of when tenant_source is subdomain
+	TenantJWTClaim string `json:"tenant_jwt_claim" yaml:"tenant_jwt_claim"` // claim of tenant id when tenant_source is jwt
+	TenantJWTKey   string `json:"tenant_jwt_key" yaml:"tenant_jwt_key"`     // HS256 key of bearer tokens when tenant_source is jwt
+
+	EncryptionKeyringFile string `json:"encryption_keyring_file" yaml:"encryption_keyring_file"` // keyring file of field encryption, empty disables it
+	EncryptedFields       string `json:"encrypted_fields" yaml:"encrypted_fields"`               // comma separated fields of people that are encrypted
+
+	ErasureLogKey string `json:"erasure_log_key" yaml:"erasure_log_key"` // HMAC key of the hash chain of erasure log, erasures are refused without it
+
+	MongoConnectionString         string `json:"mongo_uri" yaml:"mongo_uri"`                                                 // full connection string, other connection options are ignored if it is set
+	MongoDatabase                 string `json:"mongo_database" yaml:"mongo_database"`                                       // name of database
+	MongoSRV                      bool   `json:"mongo_srv" yaml:"mongo_srv"`                                                 // use mongodb+srv scheme, mongo_port is ignored
+	MongoAuthSource               string `json:"mongo_auth_source" yaml:"mongo_auth_source"`                                 // database that user is defined in
+	MongoReplicaSet               string `json:"mongo_replica_set" yaml:"mongo_replica_set"`                                 // name of replica set
+	MongoTLS                      bool   `json:"mongo_tls" yaml:"mongo_tls"`                                                 // use tls connections
+	MongoTLSCAFile                string `json:"mongo_tls_ca_file" yaml:"mongo_tls_ca_file"`                                 // pem file of certificate authorities
+	MongoTLSCertificateKeyFile    string `json:"mongo_tls_certificate_key_file" yaml:"mongo_tls_certificate_key_file"`       // pem file of client certificate and private key
+	MongoTLSInsecure              bool   `json:"mongo_tls_insecure" yaml:"mongo_tls_insecure"`                               // skip server certificate and hostname checks
+	MongoMaxPoolSize              int    `json:"mongo_max_pool_size" yaml:"mongo_max_pool_size"`                             // max connections of pool per server, 0 is driver default
+	MongoMinPoolSize              int    `json:"mongo_min_pool_size" yaml:"mongo_min_pool_size"`                             // min connections of pool per server
+	MongoConnectTimeoutMS         int    `json:"mongo_connect_timeout_ms" yaml:"mongo_connect_timeout_ms"`                   // 0 is driver default
+	MongoServerSelectionTimeoutMS int    `json:"mongo_server_selection_timeout_ms" yaml:"mongo_server_selection_timeout_ms"` // 0 is driver default
+	MongoSocketTimeoutMS          int    `json:"mongo_socket_timeout_ms" yaml:"mongo_socket_timeout_ms"`                     // 0 is driver default
+
+	MongoPasswordFile         string `json:"mongo_password_file" yaml:"mongo_password_file"` // file that mongo_password is read from, e.g. a docker secret
+	MongoConnectionStringFile string `json:"mongo_uri_file" yaml:"mongo_uri_file"`           // file that mongo_uri is read from
+}
+
+// field describes a config option. it is used to read the option from environment variables and flags.
+type field struct {
+	name   string      // name of option in config file and environment variables
+	usage  string      // help text of option flag
+	secret bool        // secrets will be redacted in print
+	value  interface{} // pointer to config field
+}
+
+// fields will return the options of config.
+func (config *Config) fields() []field {
+	return []field{
+		{"server_host", "address that server will listening on", false, &config.ServerHost},
+		{"grpc_host", "address that grpc server will listening on, empty disables grpc", false, &config.GRPCHost},
+		{"grpc_reflection", "serve grpc reflection, it lists the services and messages to every client", false, &config.GRPCReflection},
+		{"mongo_user", "mongo db username", false, &config.MongoUser},
+		{"mongo_password", "mongo db password", true, &config.MongoPassword},
+		{"mongo_host", "host that mongo db listening on", false, &config.MongoHost},
+		{"mongo_port", "port that mongo db listening on", false, &config.MongoPort},
+		{"job_workers", "number of background job workers", false, &config.JobWorkers},
+		{"migrate_on_start", "run pending migrations when server starts", false, &config.MigrateOnStart},
+		{"development", "validate responses against openapi document and log contract violations", false, &config.Development},
+		{"v1_deprecation", "date that api v1 is deprecated since in YYYY-MM-DD format, empty if it is not deprecated", false, &config.V1Deprecation},
+		{"v1_sunset", "date that api v1 is removed after in YYYY-MM-DD format, empty if it is not known", false, &config.V1Sunset},
+		{"person_cache_size", "max people of the read cache of person lookups, 0 disables it", false, &config.PersonCacheSize},
+		{"person_cache_ttl_seconds", "seconds that cached people are used, 0 keeps them until they are evicted", false, &config.PersonCacheTTLSeconds},
+		{"tenancy", "isolation of tenants, shared collections or database per tenant, empty disables multi-tenancy", false, &config.Tenancy},
+		{"tenant_source", "where tenant of requests is taken from, header, subdomain or jwt", false, &config.TenantSource},
+		{"tenant_header", "header of tenant id when tenant_source is header", false, &config.TenantHeader},
+		{"tenant_domain", "domain that tenants are subdomains of when tenant_source is subdomain", false, &config.TenantDomain},
+		{"tenant_jwt_claim", "claim of tenant id when tenant_source is jwt", false, &config.TenantJWTClaim},
+		{"tenant_jwt_key", "HS256 key of bearer tokens when tenant_source is jwt", true, &config.TenantJWTKey},
+		{"encryption_keyring_file", "keyring file of field encryption keys, empty disables field encryption", false, &config.EncryptionKeyringFile},
+		{"encrypted_fields", "comma separated fields of people that are encrypted, field:deterministic fields can be searched", false, &config.EncryptedFields},
+		{"erasure_log_key", "HMAC key of the hash chain of erasure log, people can not be erased if it is empty", true, &config.ErasureLogKey},
+		{"mongo_uri", "full mongo db connection string, other connection options are ignored if it is set", true, &config.MongoConnectionString},
+		{"mongo_database", "name of mongo db database", false, &config.MongoDatabase},
+		{"mongo_srv", "use mongodb+srv scheme, mongo_port is ignored", false, &config.MongoSRV},
+		{"mongo_auth_source", "database that mongo_user is defined in", false, &config.MongoAuthSource},
+		{"mongo_replica_set", "name of mongo db replica set", false, &config.MongoReplicaSet},
+		{"mongo_tls", "use tls for mongo db connections", false, &config.MongoTLS},
+		{"mongo_tls_ca_file", "pem file of certificate authorities", false, &config.MongoTLSCAFile},
+		{"mongo_tls_certificate_key_file", "pem file of client certificate and private key", false, &config.MongoTLSCertificateKeyFile},
+		{"mongo_tls_insecure", "skip mongo db server certificate and hostname checks", false, &config.MongoTLSInsecure},
+		{"mongo_max_pool_size", "max connections of pool per server, 0 is driver default", false, &config.MongoMaxPoolSize},
+		{"mongo_min_pool_size", "min connections of pool per server", false, &config.MongoMinPoolSize},
+		{"mongo_connect_timeout_ms", "connect timeout in milliseconds, 0 is driver default", false, &config.MongoConnectTimeoutMS},
+		{"mongo_server_selection_timeout_ms", "server selection timeout in milliseconds, 0 is driver default", false, &config.MongoServerSelectionTimeoutMS},
+		{"mongo_socket_timeout_ms", "socket read and write timeout in milliseconds, 0 is driver default", false, &config.MongoSocketTimeoutMS},
+		{"mongo_password_file", "file that mongo_password is read from, it overrides mongo_password", false, &config.MongoPasswordFile},
+		{"mongo_uri_file", "file that mongo_uri is read from, it overrides mongo_uri", false, &config.MongoConnectionStringFile},
+	}
+}
+
+// flagName will return the flag name of option, e.g. mongo_host is -mongo-host
+func (f field) flagName() string {
+	return strings.Replace(f.name, "_", "-", -1)
+}
+
+// set will parse value and save it in config field.
+func (f field) set(value string) error {
+	switch ptr := f.value.(type) {
+	case *string:
+		*ptr = value
+	case *int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a number, got %q", f.name, value)
+		}
+		*ptr = number
+	case *bool:
+		boolean, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", f.name, value)
+		}
+		*ptr = boolean
+	}
+	return nil
+}
+
+// newDefaultConfig will create config with default values.
+func newDefaultConfig() *Config {
+	return &Config{
+		ServerHost:            ":1234",
+		MongoHost:             "localhost",
+		MongoPort:             "27017",
+		JobWorkers:            4,
+		MongoDatabase:         "golang",
+		PersonCacheTTLSeconds: 60,
+		V1Deprecation:         "2026-11-01",
+		V1Sunset:              "2027-05-01",
+		TenantSource:          "header",
+		TenantHeader:          "X-Tenant-ID",
+		TenantJWTClaim:        "tenant",
+		EncryptedFields:       "username:deterministic,email:deterministic,first_name,last_name",
+	}
+}
+
+// readFile will read yaml or json config file. format is detected from file extension.
+// unknown options in file are errors.
+func (config *Config) readFile(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %v", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(content, config)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(config)
+	default:
+		return fmt.Errorf("config file %q must be a .yaml, .yml or .json file", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %q is incorrect: %v", path, err)
+	}
+	return nil
+}
+
+// readEnv will read environment variables that are set and not empty.
+// upper case names are accepted too, e.g. MONGO_PASSWORD_FILE
+func (config *Config) readEnv() ValidationError {
+	var problems ValidationError
+	for _, f := range config.fields() {
+		value := os.Getenv(f.name)
+		if value == "" {
+			value = os.Getenv(strings.ToUpper(f.name))
+		}
+		if value == "" {
+			continue
+		}
+		if err := f.set(value); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+// Validate will check all options and return a ValidationError with all problems.
+func (config *Config) Validate() error {
+	var problems ValidationError
+	if config.ServerHost == "" {
+		problems = append(problems, "server_host is required")
+	} else if _, _, err := net.SplitHostPort(config.ServerHost); err != nil {
+		problems = append(problems, fmt.Sprintf("server_host must be in host:port format, got %q", config.ServerHost))
+	}
+	if config.GRPCHost != "" {
+		if _, _, err := net.SplitHostPort(config.GRPCHost); err != nil {
+			problems = append(problems, fmt.Sprintf("grpc_host must be in host:port format, got %q", config.GRPCHost))
+		} else if config.GRPCHost == config.ServerHost {
+			problems = append(problems, "grpc_host must be different from server_host")
+		}
+	}
+	if config.MongoConnectionString != "" {
+		if !strings.HasPrefix(config.MongoConnectionString, "mongodb://") && !strings.HasPrefix(config.MongoConnectionString, "mongodb+srv://") {
+			problems = append(problems, "mongo_uri must start with mongodb:// or mongodb+srv://")
+		}
+	} else {
+		problems = append(problems, config.validateMongoConnection()...)
+	}
+	if config.MongoDatabase == "" {
+		problems = append(problems, "mongo_database is required")
+	} else if strings.ContainsAny(config.MongoDatabase, "/\\. \"$") {
+		problems = append(problems, fmt.Sprintf("mongo_database %q has invalid characters", config.MongoDatabase))
+	}
+	if config.JobWorkers < 0 {
+		problems = append(problems, "job_workers can not be negative")
+	}
+	if config.PersonCacheSize < 0 {
+		problems = append(problems, "person_cache_size can not be negative")
+	}
+	if config.PersonCacheTTLSeconds < 0 {
+		problems = append(problems, "person_cache_ttl_seconds can not be negative")
+	}
+	if _, _, err := config.V1Dates(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	problems = append(problems, config.validateTenancy()...)
+	if config.EncryptionKeyringFile != "" {
+		if _, err := encryption.ParseFields(config.EncryptedFields); err != nil {
+			problems = append(problems, fmt.Sprintf("encrypted_fields is not valid: %v", err))
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// dateLayout is the format of date options.
+const dateLayout = "2006-01-02"
+
+// V1Dates will return the deprecation and sunset dates of api v1, they are zero if their options are empty.
+func (config *Config) V1Dates() (deprecation, sunset time.Time, err error) {
+	if config.V1Deprecation != "" {
+		if deprecation, err = time.Parse(dateLayout, config.V1Deprecation); err != nil {
+			return deprecation, sunset, fmt.Errorf("v1_deprecation must be in YYYY-MM-DD format, got %q", config.V1Deprecation)
+		}
+	}
+	if config.V1Sunset != "" {
+		if sunset, err = time.Parse(dateLayout, config.V1Sunset); err != nil {
+			return deprecation, sunset, fmt.Errorf("v1_sunset must be in YYYY-MM-DD format, got %q", config.V1Sunset)
+		}
+		if deprecation.IsZero() || sunset.Before(deprecation) {
+			return deprecation, sunset, fmt.Errorf("v1_sunset needs a v1_deprecation that is before it")
+		}
+	}
+	return deprecation, sunset, nil
+}
+
+// validateTenancy will check the tenancy options, sources are only checked if tenancy is enabled.
+func (config *Config) validateTenancy() []string {
+	var problems []string
+	switch config.Tenancy {
+	case "":
+		return nil
+	case "shared", "database":
+	default:
+		problems = append(problems, fmt.Sprintf("tenancy must be shared or database, got %q", config.Tenancy))
+	}
+	switch config.TenantSource {
+	case "header":
+		if config.TenantHeader == "" {
+			problems = append(problems, "tenant_header is required when tenant_source is header")
+		}
+	case "subdomain":
+		if strings.Trim(config.TenantDomain, ".") == "" {
+			problems = append(problems, "tenant_domain is required when tenant_source is subdomain")
+		}
+	case "jwt":
+		if config.TenantJWTClaim == "" {
+			problems = append(problems, "tenant_jwt_claim is required when tenant_source is jwt")
+		}
+		if config.TenantJWTKey == "" {
+			problems = append(problems, "tenant_jwt_key is required when tenant_source is jwt")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("tenant_source must be header, subdomain or jwt, got %q", config.TenantSource))
+	}
+	return problems
 }
 
-// initialize will read environment variables and save them in config structure fields
-func (config *Config) initialize() {
-	// read environment variables
-	config.ServerHost = os.Getenv("server_host")
-	config.MongoUser = os.Getenv("mongo_user")
-	config.MongoPassword = os.Getenv("mongo_password")
-	config.MongoHost = os.Getenv("mongo_host")
-	config.MongoPort = os.Getenv("mongo_port")
+// validateMongoConnection will check the options that mongo uri is built from.
+func (config *Config) validateMongoConnection() []string {
+	var problems []string
+	switch {
+	case config.MongoHost == "":
+		problems = append(problems, "mongo_host is required")
+	case config.MongoSRV:
+		if strings.ContainsAny(config.MongoHost, ",:") {
+			problems = append(problems, "mongo_host must be a single host name without port when mongo_srv is true")
+		}
+	default:
+		if port, err := strconv.Atoi(config.MongoPort); err != nil || port < 1 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("mongo_port must be a port number, got %q", config.MongoPort))
+		}
+	}
+	if (config.MongoUser == "") != (config.MongoPassword == "") {
+		problems = append(problems, "mongo_user and mongo_password must be set together")
+	}
+	for _, f := range config.fields() {
+		path, ok := f.value.(*string)
+		if !ok || !strings.HasPrefix(f.name, "mongo_tls_") || !strings.HasSuffix(f.name, "_file") || *path == "" {
+			continue
+		}
+		if _, err := os.Stat(*path); err != nil {
+			problems = append(problems, fmt.Sprintf("%s is not readable: %v", f.name, err))
+		}
+	}
+	if config.MongoMinPoolSize < 0 || config.MongoMaxPoolSize < 0 {
+		problems = append(problems, "mongo_min_pool_size and mongo_max_pool_size can not be negative")
+	} else if config.MongoMaxPoolSize > 0 && config.MongoMinPoolSize > config.MongoMaxPoolSize {
+		problems = append(problems, "mongo_min_pool_size can not be bigger than mongo_max_pool_size")
+	}
+	if config.MongoConnectTimeoutMS < 0 || config.MongoServerSelectionTimeoutMS < 0 || config.MongoSocketTimeoutMS < 0 {
+		problems = append(problems, "mongo timeouts can not be negative")
+	}
+	return problems
 }
 
-// MongoURI will generate mongo db connect uri
+// mongoHosts will add mongo_port to the hosts of mongo_host that have no port.
+func (config *Config) mongoHosts() string {
+	if config.MongoSRV {
+		return config.MongoHost
+	}
+	hosts := strings.Split(config.MongoHost, ",")
+	for i, host := range hosts {
+		host = strings.TrimSpace(host)
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, config.MongoPort)
+		}
+		hosts[i] = host
+	}
+	return strings.Join(hosts, ",")
+}
+
+// MongoURI will generate mongo db connect uri. username and password are escaped.
+// mongo_uri will be returned as it is if it is set.
 func (config *Config) MongoURI() string {
-	return fmt.Sprintf("mongodb://%s:%s@%s:%s",
-		config.MongoUser,
-		config.MongoPassword,
-		config.MongoHost,
-		config.MongoPort,
+	if config.MongoConnectionString != "" {
+		return config.MongoConnectionString
+	}
+	uri := url.URL{Scheme: "mongodb", Host: config.mongoHosts()}
+	if config.MongoSRV {
+		uri.Scheme = "mongodb+srv"
+	}
+	if config.MongoUser != "" {
+		uri.User = url.UserPassword(config.MongoUser, config.MongoPassword)
+	}
+
+	query := url.Values{}
+	setString := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	setInt := func(key string, value int) {
+		if value > 0 {
+			query.Set(key, strconv.Itoa(value))
+		}
+	}
+	setString("authSource", config.MongoAuthSource)
+	setString("replicaSet", config.MongoReplicaSet)
+	if config.MongoTLS || config.MongoTLSCAFile != "" || config.MongoTLSCertificateKeyFile != "" {
+		query.Set("tls", "true")
+	}
+	setString("tlsCAFile", config.MongoTLSCAFile)
+	setString("tlsCertificateKeyFile", config.MongoTLSCertificateKeyFile)
+	if config.MongoTLSInsecure {
+		query.Set("tlsInsecure", "true")
+	}
+	setInt("maxPoolSize", config.MongoMaxPoolSize)
+	setInt("minPoolSize", config.MongoMinPoolSize)
+	setInt("connectTimeoutMS", config.MongoConnectTimeoutMS)
+	setInt("serverSelectionTimeoutMS", config.MongoServerSelectionTimeoutMS)
+	setInt("socketTimeoutMS", config.MongoSocketTimeoutMS)
+	if len(query) > 0 {
+		uri.Path = "/"
+		uri.RawQuery = query.Encode()
+	}
+	return uri.String()
+}
+
+// Redacted will return a copy of config that its secrets are replaced.
+func (config *Config) Redacted() *Config {
+	copied := *config
+	for _, f := range copied.fields() {
+		if ptr, ok := f.value.(*string); ok && f.secret && *ptr != "" {
+			*ptr = redacted
+		}
+	}
+	return &copied
+}
+
+// Print will write the config with redacted secrets in yaml or json format.
+func (config *Config) Print(w io.Writer, format string) error {
+	var (
+		content []byte
+		err     error
 	)
+	switch format {
+	case "yaml", "yml":
+		content, err = yaml.Marshal(config.Redacted())
+	case "json":
+		content, err = json.MarshalIndent(config.Redacted(), "", "  ")
+		content = append(content, '\n')
+	default:
+		return fmt.Errorf("format %q is not supported, use yaml or json", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
 }
 
-// NewConfig will create and initialize config struct
+// NewConfig will create and initialize config struct from default values and environment variables.
+// use Load or FlagSet to read config file and flags too.
 func NewConfig() *Config {
-	config := new(Config)
-	config.initialize()
+	config := newDefaultConfig()
+	config.readEnv()
+	config.readSecretFiles()
 	return config
 }
+
+// Load will create config from default values, config file, environment variables
+// and flags of args, then validates it.
+func Load(args []string) (*Config, error) {
+	flags := NewFlagSet(os.Args[0])
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	return flags.Load()
+}
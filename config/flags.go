@@ -0,0 +1,75 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+// FlagSet is a flag.FlagSet that has a flag for every config option and the -config flag.
+// commands can add their own flags to it before Parse.
+type FlagSet struct {
+	*flag.FlagSet
+	file *string
+}
+
+// NewFlagSet will create a FlagSet with config flags.
+func NewFlagSet(name string) *FlagSet {
+	flags := &FlagSet{FlagSet: flag.NewFlagSet(name, flag.ContinueOnError)}
+	flags.file = flags.String("config", "", "path of yaml or json config file (env: config_file)")
+	for _, f := range new(Config).fields() {
+		usage := f.usage + " (env: " + f.name + ")"
+		if _, ok := f.value.(*bool); ok {
+			flags.Bool(f.flagName(), false, usage)
+		} else {
+			flags.String(f.flagName(), "", usage)
+		}
+	}
+	return flags
+}
+
+// Load will create config from default values, config file, environment variables and
+// the flags that are set, then validates it. it must be called after Parse.
+func (flags *FlagSet) Load() (*Config, error) {
+	config := newDefaultConfig()
+	path := *flags.file
+	if path == "" {
+		path = os.Getenv("config_file")
+	}
+	if path != "" {
+		if err := config.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	problems := config.readEnv()
+
+	options := make(map[string]field)
+	for _, f := range config.fields() {
+		options[f.flagName()] = f
+	}
+	flags.Visit(func(flag *flag.Flag) {
+		f, ok := options[flag.Name]
+		if !ok {
+			return
+		}
+		if err := f.set(flag.Value.String()); err != nil {
+			problems = append(problems, err.Error())
+		}
+	})
+
+	problems = append(problems, config.readSecretFiles()...)
+	if err := config.Validate(); err != nil {
+		problems = append(problems, err.(ValidationError)...)
+	}
+	if len(problems) > 0 {
+		return config, problems
+	}
+	return config, nil
+}
+
+// ValidationError holds all the problems of a config.
+type ValidationError []string
+
+func (problems ValidationError) Error() string {
+	return "config is not valid:\n  - " + strings.Join(problems, "\n  - ")
+}
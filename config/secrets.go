@@ -0,0 +1,119 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+)
+
+// secretFile is a secret option and the option that holds the path of its file.
+type secretFile struct {
+	name  string
+	value *string
+	path  *string
+}
+
+// secretFiles will return the secret options that have a <name>_file option.
+func (config *Config) secretFiles() []secretFile {
+	options := make(map[string]field)
+	for _, f := range config.fields() {
+		options[f.name] = f
+	}
+	var files []secretFile
+	for _, f := range config.fields() {
+		file, ok := options[f.name+"_file"]
+		if !f.secret || !ok {
+			continue
+		}
+		files = append(files, secretFile{name: f.name, value: f.value.(*string), path: file.value.(*string)})
+	}
+	return files
+}
+
+// readSecretFiles will read the secrets that their file is set. trailing new lines are removed.
+func (config *Config) readSecretFiles() ValidationError {
+	var problems ValidationError
+	for _, file := range config.secretFiles() {
+		if *file.path == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(*file.path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s_file is not readable: %v", file.name, err))
+			continue
+		}
+		*file.value = strings.TrimRight(string(content), "\r\n")
+	}
+	return problems
+}
+
+// fileHashes will return the hash of secret files content, unreadable files have an empty hash.
+func (config *Config) fileHashes() map[string][]byte {
+	hashes := make(map[string][]byte)
+	for _, file := range config.secretFiles() {
+		if *file.path == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(*file.path)
+		if err != nil {
+			hashes[*file.path] = nil
+			continue
+		}
+		hash := sha256.Sum256(content)
+		hashes[*file.path] = hash[:]
+	}
+	return hashes
+}
+
+// WatchSecretFiles will check the secret files every interval until ctx is done. when content of a
+// file changes, a copy of config with new secrets is validated and passed to onChange.
+// it returns right away if no secret file is set.
+func (config *Config) WatchSecretFiles(ctx context.Context, interval time.Duration, onChange func(*Config)) {
+	current := config
+	last := current.fileHashes()
+	if len(last) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		hashes := current.fileHashes()
+		if sameHashes(last, hashes) {
+			continue
+		}
+		last = hashes
+		updated := *current
+		if problems := updated.readSecretFiles(); len(problems) > 0 {
+			log.Printf("Error while reloading secret files: %v\n", problems)
+			continue
+		}
+		if err := updated.Validate(); err != nil {
+			log.Printf("Error while reloading secret files: %v\n", err)
+			continue
+		}
+		current = &updated
+		onChange(current)
+	}
+}
+
+func sameHashes(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, hash := range a {
+		if other, ok := b[path]; !ok || !bytes.Equal(hash, other) {
+			return false
+		}
+	}
+	return true
+}
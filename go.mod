@@ -3,15 +3,18 @@ module github.com/katoozi/golang-mongodb-rest-api
 go 1.14
 
 require (
+	github.com/andybalholm/brotli v1.0.0
 	github.com/go-stack/stack v1.8.0 // indirect
+	github.com/golang/protobuf v1.4.1
 	github.com/golang/snappy v0.0.1 // indirect
-	github.com/google/go-cmp v0.4.1 // indirect
 	github.com/gorilla/mux v1.7.4
-	github.com/klauspost/compress v1.10.8 // indirect
+	github.com/graphql-go/graphql v0.8.0
+	github.com/klauspost/compress v1.10.8
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/stretchr/objx v0.2.0 // indirect
 	github.com/stretchr/testify v1.6.1 // indirect
 	github.com/tidwall/pretty v1.0.1 // indirect
+	github.com/ugorji/go/codec v1.1.7
 	github.com/xdg/scram v0.0.0-20180814205039-7eeb5667e42c // indirect
 	github.com/xdg/stringprep v1.0.0 // indirect
 	go.mongodb.org/mongo-driver v1.3.4
@@ -19,6 +22,9 @@ require (
 	golang.org/x/net v0.0.0-20200602114024-627f9648deb9
 	golang.org/x/sync v0.0.0-20200317015054-43a5402ce75a // indirect
 	golang.org/x/text v0.3.3 // indirect
-	gopkg.in/yaml.v2 v2.3.0 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013
+	google.golang.org/grpc v1.33.2
+	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v2 v2.3.0
 	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
 )
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app"
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+)
+
+const indexesUsage = `usage: indexes plan|apply [flags]
+
+  plan   show the indexes that will be created, recreated or dropped (dry-run)
+  apply  create the missing indexes, -drop recreates drifted and drops unknown indexes
+`
+
+// indexes will run the indexes subcommands.
+func indexes(args []string) {
+	if len(args) == 0 || (args[0] != "plan" && args[0] != "apply") {
+		fmt.Fprint(os.Stderr, indexesUsage)
+		os.Exit(2)
+	}
+	command := args[0]
+	flags := newFlagSet("indexes "+command, indexesUsage)
+	drop := flags.Bool("drop", false, "recreate drifted indexes and drop indexes that are not in specs, only for apply")
+	configuration := loadConfig(flags, args[1:])
+	database := db.InitialConnection(configuration.MongoDatabase, configuration.MongoURI())
+	defer database.Client().Disconnect(context.Background())
+
+	ctx := context.Background()
+	plan, err := db.PlanIndexes(ctx, database, app.IndexSpecs(configuration))
+	if err != nil {
+		log.Fatal(err)
+	}
+	changes := plan.Changes()
+	if len(changes) == 0 {
+		fmt.Println("indexes are up to date.")
+		return
+	}
+	changes.Print(os.Stdout)
+	if command == "plan" {
+		return
+	}
+	if err := changes.Apply(ctx, database, *drop); err != nil {
+		log.Fatal(err)
+	}
+	if !*drop {
+		fmt.Println("drifted and unknown indexes are not changed, use -drop to change them.")
+	}
+}
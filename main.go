@@ -1,15 +1,132 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/katoozi/golang-mongodb-rest-api/app"
 	"github.com/katoozi/golang-mongodb-rest-api/config"
 )
 
+// command is a subcommand of server binary. all commands load the config from the same file,
+// environment variables and flags.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "run the http and grpc servers, it is the default command", serve},
+		{"migrate", "apply, revert or show the schema migrations", migrate},
+		{"indexes", "plan or apply the indexes of collections", indexes},
+		{"seed", "insert realistic fake people for development", seedCommand},
+		{"reencrypt", "encrypt people again with the active encryption key", reencrypt},
+		{"check", "verify the config and mongo db connection", check},
+		{"config", "print the effective config", configCommand},
+	}
+}
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-	config := config.NewConfig()
-	app.ConfigAndRunApp(config)
+	args := os.Args[1:]
+	// flags without a command run the server, like before the commands.
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		serve(args)
+		return
+	}
+	if args[0] == "help" {
+		usage()
+		return
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			cmd.run(args[1:])
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun %s <command> -h to see its flags, they include the config flags.\n", os.Args[0])
+}
+
+// newFlagSet will create the config flags of command, help prints text and the flags.
+func newFlagSet(name, text string) *config.FlagSet {
+	flags := config.NewFlagSet(name)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), text)
+		fmt.Fprintln(flags.Output(), "\nflags:")
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// loadConfig will parse args and load the config, it exits on errors and help.
+func loadConfig(flags *config.FlagSet, args []string) *config.Config {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	configuration, err := flags.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return configuration
+}
+
+const serveUsage = `usage: serve [flags]
+
+run the http server, the grpc server and the job workers until SIGINT or SIGTERM.
+`
+
+// serve will run the servers.
+func serve(args []string) {
+	app.ConfigAndRunApp(loadConfig(newFlagSet("serve", serveUsage), args))
+}
+
+const configUsage = `usage: config print [-format yaml|json] [flags]
+
+print the effective config, secrets are redacted.
+`
+
+// configCommand will print the effective config with redacted secrets.
+func configCommand(args []string) {
+	flags := newFlagSet("config print", configUsage)
+	if len(args) == 0 || args[0] != "print" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	format := flags.String("format", "yaml", "output format, yaml or json")
+	if err := flags.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		os.Exit(2)
+	}
+	configuration, err := flags.Load()
+	if configuration != nil {
+		if err := configuration.Print(os.Stdout, *format); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"github.com/katoozi/golang-mongodb-rest-api/app/migrations"
+)
+
+const migrateUsage = `usage: migrate up|down|status [flags]
+
+  up      apply all pending migrations
+  down    revert the last applied migrations, -steps sets how many (default 1)
+  status  show registered and applied migrations
+`
+
+// migrate will run the migrate subcommands.
+func migrate(args []string) {
+	if len(args) == 0 || (args[0] != "up" && args[0] != "down" && args[0] != "status") {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	command := args[0]
+	flags := newFlagSet("migrate "+command, migrateUsage)
+	steps := flags.Int("steps", 1, "number of migrations to revert, only for down")
+	configuration := loadConfig(flags, args[1:])
+	database := db.InitialConnection(configuration.MongoDatabase, configuration.MongoURI())
+	defer database.Client().Disconnect(context.Background())
+
+	migrator := migrations.New(database)
+	ctx := context.Background()
+	switch command {
+	case "up":
+		done, err := migrator.Up(ctx)
+		printMigrations("applied", done)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "down":
+		done, err := migrator.Down(ctx, *steps)
+		printMigrations("reverted", done)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tAPPLIED AT\tDESCRIPTION")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			description := status.Description
+			if status.Unknown {
+				description += " (unknown, not registered)"
+			}
+			fmt.Fprintf(writer, "%d\t%s\t%s\n", status.Version, appliedAt, description)
+		}
+		writer.Flush()
+	}
+}
+
+func printMigrations(action string, done []migrations.Migration) {
+	if len(done) == 0 {
+		fmt.Printf("no migration is %s.\n", action)
+	}
+	for _, migration := range done {
+		fmt.Printf("%s %d %s\n", action, migration.Version, migration.Description)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app"
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"github.com/katoozi/golang-mongodb-rest-api/app/jobs"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+)
+
+const reencryptUsage = `usage: reencrypt [-tenant id] [flags]
+
+queue a job that encrypts people again with the active key of keyring, the job workers of servers run it.
+run it after a new active key is added to the keyring, index_key is set or encrypted_fields is changed,
+old keys can be removed from the keyring when its result has 0 reencrypted people.
+-tenant encrypts only the people of a tenant, it is required when tenants have their own databases.
+`
+
+// reencrypt will queue the job that encrypts people again.
+func reencrypt(args []string) {
+	flags := newFlagSet("reencrypt", reencryptUsage)
+	tenantID := flags.String("tenant", "", "tenant of people, required when tenancy is database")
+	configuration := loadConfig(flags, args)
+	if configuration.EncryptionKeyringFile == "" {
+		log.Fatal("field encryption is disabled, encryption_keyring_file is not set")
+	}
+	if _, err := app.Cipher(configuration); err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+	params := map[string]interface{}{}
+	switch {
+	case *tenantID != "" && configuration.Tenancy == "":
+		log.Fatal("-tenant needs the tenancy option")
+	case *tenantID != "":
+		id, err := tenant.ParseID(*tenantID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx = tenant.NewContext(ctx, tenant.Tenant{ID: id, Mode: tenant.Mode(configuration.Tenancy)})
+		params["tenant"] = id
+	case tenant.Mode(configuration.Tenancy) == tenant.ModeDatabase:
+		log.Fatal("-tenant is required when tenants have their own databases")
+	}
+
+	database := db.InitialConnection(configuration.MongoDatabase, configuration.MongoURI())
+	defer database.Client().Disconnect(context.Background())
+	job, err := jobs.Enqueue(ctx, database, app.ReencryptJob, params)
+	if err != nil {
+		log.Fatalf("Error while queueing the job: %v", err)
+	}
+	fmt.Printf("job %s is queued, its progress is the number of scanned people.\n", job.ID.Hex())
+}
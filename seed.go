@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/katoozi/golang-mongodb-rest-api/app"
+	"github.com/katoozi/golang-mongodb-rest-api/app/db"
+	"github.com/katoozi/golang-mongodb-rest-api/app/model"
+	"github.com/katoozi/golang-mongodb-rest-api/app/seed"
+	"github.com/katoozi/golang-mongodb-rest-api/app/store"
+	"github.com/katoozi/golang-mongodb-rest-api/app/tenant"
+)
+
+const seedUsage = `usage: seed [-count n] [-seed n] [-locale list] [-batch n] [-tenant id] [-wipe] [flags]
+
+insert realistic fake people for development, usernames and emails are unique.
+the same seed and locales insert the same people, people that exist already are skipped.
+-wipe deletes all people first, it needs the development option so it never runs in production.
+-tenant is required when tenancy is enabled, people are seeded for it and -wipe only deletes its people.
+`
+
+// seedCommand will insert the fake people in batches, it is not named seed to not shadow the seed package.
+func seedCommand(args []string) {
+	flags := newFlagSet("seed", seedUsage)
+	count := flags.Int("count", 100, "number of people")
+	seedValue := flags.Int64("seed", 0, "seed of fake data, 0 uses the current time")
+	localeList := flags.String("locale", "", "comma separated locales of people, empty uses all of "+strings.Join(seed.Locales(), ", "))
+	batch := flags.Int("batch", 500, "number of people in each insert")
+	wipe := flags.Bool("wipe", false, "delete all people before seeding, only with the development option")
+	tenantID := flags.String("tenant", "", "tenant of people, required when tenancy is enabled")
+	configuration := loadConfig(flags, args)
+	if *count < 1 {
+		log.Fatal("count must be at least 1")
+	}
+	if *batch < 1 {
+		log.Fatal("batch must be at least 1")
+	}
+	if *wipe && !configuration.Development {
+		log.Fatal("-wipe needs the development option, it is not allowed in production")
+	}
+	if *seedValue == 0 {
+		*seedValue = time.Now().UnixNano()
+	}
+	var locales []string
+	if *localeList != "" {
+		locales = strings.Split(*localeList, ",")
+	}
+	generator, err := seed.NewGenerator(*seedValue, locales...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+	if configuration.Tenancy != "" {
+		id, err := tenant.ParseID(*tenantID)
+		if err != nil {
+			log.Fatalf("-tenant is required when tenancy is enabled: %v", err)
+		}
+		ctx = tenant.NewContext(ctx, tenant.Tenant{ID: id, Mode: tenant.Mode(configuration.Tenancy)})
+	} else if *tenantID != "" {
+		log.Fatal("-tenant needs the tenancy option")
+	}
+
+	database := db.InitialConnection(configuration.MongoDatabase, configuration.MongoURI())
+	defer database.Client().Disconnect(context.Background())
+	var people store.PeopleStore = store.NewPeople(database)
+	cipher, err := app.Cipher(configuration)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cipher != nil {
+		people = store.NewEncrypted(people, cipher)
+	}
+	if *wipe {
+		deleted, err := people.DeleteAll(ctx)
+		if err != nil {
+			log.Fatalf("Error while deleting people: %v", err)
+		}
+		fmt.Printf("%d people are deleted.\n", deleted)
+	}
+
+	inserted, skipped := 0, 0
+	for done := 0; done < *count; {
+		size := *batch
+		if remaining := *count - done; remaining < size {
+			size = remaining
+		}
+		personList := make([]*model.Person, size)
+		for i := range personList {
+			personList[i] = generator.Person()
+		}
+		batchInserted, batchSkipped, err := people.InsertMany(ctx, personList)
+		inserted, skipped = inserted+batchInserted, skipped+batchSkipped
+		if err != nil {
+			log.Fatalf("Error while inserting people %d to %d: %v", done+1, done+size, err)
+		}
+		done += size
+	}
+	fmt.Printf("%d people are inserted, %d are skipped because their username or email exists, seed is %d.\n", inserted, skipped, *seedValue)
+}